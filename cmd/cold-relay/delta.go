@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/output"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/platform"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/util"
+)
+
+// incrementalRun carries the stored snapshot of a --delta run from
+// collection to export.
+type incrementalRun struct {
+	store  *platform.FileRunStore
+	domain string
+	prev   *platform.Snapshot
+	point  krb.SyncPoint
+	since  krb.SyncPoint
+	stats  ingest.DeltaStats
+	resume bool
+}
+
+// startIncremental loads the previous snapshot and records the DC's current
+// USN before anything is collected. It returns nil when incremental state
+// cannot be kept, in which case the run is a plain full collection.
+func startIncremental(client *krb.LDAPClient, storeDir, domain string) *incrementalRun {
+	if domain == "" {
+		log.Printf("[!] --delta needs a domain name (-d or RootDSE); running a full collection")
+		return nil
+	}
+	point, err := client.SyncPoint()
+	if err != nil {
+		log.Printf("[!] Cannot read highestCommittedUSN (%v); running a full collection", err)
+		return nil
+	}
+	run := &incrementalRun{store: platform.NewFileRunStore(storeDir), domain: domain, point: point}
+	if run.prev, err = run.store.LoadSnapshot(domain); err != nil {
+		log.Printf("[!] Ignoring unreadable snapshot: %v", err)
+	}
+	switch since, ok := run.prev.SyncPointFor(point); {
+	case ok:
+		run.since, run.resume = since, true
+		log.Printf("[*] Incremental run: %s at USN %d, last synced at USN %d (run %s)", point.DSA, point.USN, since.USN, run.prev.RunID)
+	case run.prev != nil:
+		log.Printf("[*] No usable sync point for %s in the snapshot; running a full collection", point.DSA)
+	default:
+		log.Printf("[*] No snapshot for %s yet; this full collection becomes the baseline", domain)
+	}
+	return run
+}
+
+// collect applies the changes since the stored USN to the snapshot
+// directory. It returns nil when the caller should collect everything.
+func (r *incrementalRun) collect(client *krb.LDAPClient) *ingest.Directory {
+	if r == nil || !r.resume {
+		return nil
+	}
+	dir := r.prev.Directory
+	stats, err := client.CollectDelta(dir, r.since.USN)
+	if err != nil {
+		log.Printf("[!] Delta collection failed (%v); running a full collection", err)
+		r.resume = false
+		return nil
+	}
+	r.stats = stats
+	return dir
+}
+
+// finish diffs findings against the previous run, attaches the delta report
+// and stores the new snapshot.
+func (r *incrementalRun) finish(results *output.Results, dir *ingest.Directory) {
+	if r == nil || dir == nil {
+		return
+	}
+	if r.prev != nil {
+		newCount, changedCount, gone := platform.DiffFindings(r.prev.Candidates, results.Candidates)
+		results.Delta = &output.DeltaReport{
+			PreviousRun:     r.prev.RunID,
+			DC:              r.point.DSA,
+			HighestUSN:      r.point.USN,
+			NewFindings:     newCount,
+			ChangedFindings: changedCount,
+			GoneFindings:    gone,
+		}
+		if r.resume {
+			results.Delta.SinceUSN = r.since.USN
+			results.Delta.Objects = r.stats
+		}
+		log.Printf("%s[+] Findings since run %s: %d new, %d changed, %d gone%s",
+			util.Cyan, r.prev.RunID, newCount, changedCount, len(gone), util.Reset)
+	}
+
+	points := make(map[string]krb.SyncPoint)
+	if r.prev != nil {
+		for dsa, p := range r.prev.SyncPoints {
+			points[dsa] = p
+		}
+	}
+	points[r.point.DSA] = r.point
+	err := r.store.SaveSnapshot(platform.Snapshot{
+		Domain:     r.domain,
+		SyncPoints: points,
+		Directory:  dir,
+		Candidates: results.Candidates,
+	})
+	if err != nil {
+		log.Printf("[x] Failed to store snapshot: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/fleet"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/util"
+)
+
+// assessTargets runs the per-host checks against every -t/--targets host.
+// The credentials are not used until a bind has validated them; see
+// fleet.Report.ProbeChannelBinding.
+func assessTargets(targets []string, opts krb.ConnectOptions) *fleet.Report {
+	log.Printf("[*] Assessing %d targets...", len(targets))
+	hosts := make([]fleet.Host, 0, len(targets))
+	for _, t := range targets {
+		hosts = append(hosts, fleet.Host{Address: t, Source: fleet.SourceTarget})
+	}
+	report := fleet.Assess(hosts, fleet.Options{LDAP: opts})
+	log.Printf("%s[+] %d of %d targets answered%s", util.Green, len(report.Hosts), len(targets), util.Reset)
+	return report
+}
+
+// addDomainControllers assesses the DCs registered in DNS that are not in
+// the report yet. The bound DC doubles as nameserver when the local resolver
+// does not know the domain.
+func addDomainControllers(report *fleet.Report, domain, nameserver string, opts krb.ConnectOptions) *fleet.Report {
+	if report == nil {
+		report = &fleet.Report{}
+	}
+	dcs, err := krb.LocateDomainControllers(domain, nameserver)
+	if err != nil {
+		log.Printf("[!] DC discovery failed: %v", err)
+		return report
+	}
+	var hosts []fleet.Host
+	for _, dc := range dcs {
+		if report.Has(dc.Host()) || report.Has(dc.Name) {
+			continue
+		}
+		hosts = append(hosts, fleet.Host{Address: dc.Host(), Name: dc.Name, Source: fleet.SourceDNS})
+	}
+	log.Printf("[*] DNS lists %d DCs for %s; assessing %d more", len(dcs), domain, len(hosts))
+	if len(hosts) > 0 {
+		report.Add(fleet.Assess(hosts, fleet.Options{LDAP: opts}))
+	}
+	return report
+}
+
+// logFleet prints the checks on which the DCs disagree.
+func logFleet(report *fleet.Report) {
+	if report == nil {
+		return
+	}
+	for _, h := range report.Hosts {
+		if !h.DC {
+			continue
+		}
+		log.Printf("[*] DC %s: SMB signing %s, SMBv1 %s, LDAP signing %s, channel binding %s, RDP NLA %s",
+			h.Host, h.SMBSigning, h.SMB1, h.LDAPSigning, h.ChannelBinding, h.RDPNLA)
+	}
+	for _, d := range report.Differences {
+		values := make([]string, 0, len(d.Values))
+		for v, hosts := range d.Values {
+			values = append(values, v+" on "+strings.Join(hosts, ", "))
+		}
+		sort.Strings(values)
+		log.Printf("%s[!] Inconsistent %s across DCs: %s%s", util.Yellow, d.Check, strings.Join(values, "; "), util.Reset)
+	}
+}
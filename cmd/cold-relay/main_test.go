@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/cracker"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+func TestCrackTimeroastHashes(t *testing.T) {
+	responder, err := krb.NewSNTPResponder("127.0.0.1:0", map[uint32]string{
+		1103: "ws01",       // pre-created
+		1104: "Autumn2026", // set by hand
+		1105: "q7#Lr9!vX2mZ0$kP4@tW8&nB",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	computers := []ingest.Computer{
+		{SamAccountName: "WS01$", ObjectSID: "S-1-5-21-1-2-3-1103"},
+		{SamAccountName: "SRV01$", ObjectSID: "S-1-5-21-1-2-3-1104"},
+		{SamAccountName: "SRV02$", ObjectSID: "S-1-5-21-1-2-3-1105"},
+	}
+	sntp, err := krb.TimeroastComputers(responder.Addr(), computers, krb.TimeroastOptions{Interval: time.Millisecond, Timeout: 300 * time.Millisecond})
+	if err != nil || len(sntp) != 3 {
+		t.Fatalf("%d hashes, %v", len(sntp), err)
+	}
+
+	wordlist := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(wordlist, []byte(strings.Join([]string{"Summer2026", "Autumn2026", "ws01"}, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	crackStoredHashesNative(nil, sntp, wordlist, cracker.NativeOptions{Workers: 1})
+	if sntp[1].Password != "Autumn2026" || sntp[1].PreCreated || sntp[2].Password != "" {
+		t.Fatalf("timeroast hashes after cracking: %+v", sntp)
+	}
+
+	candidates := timeroastCandidates(sntp, "CORP.LOCAL")
+	if len(candidates) != 2 || candidates[0].SamAccountName != "WS01$" || candidates[1].SamAccountName != "SRV01$" {
+		t.Fatalf("candidates %+v", candidates)
+	}
+	if c := candidates[1]; c.Validation != krb.StatusValidated || !strings.Contains(c.Reasons[0], "cracked") {
+		t.Errorf("cracked computer candidate %+v", c)
+	}
+}
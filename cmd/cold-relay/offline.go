@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/advanced"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/controlplane"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/output"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/reasoning"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/triage"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/util"
+)
+
+// offlineMode is recorded in the graph context and run store for file-based analysis.
+const offlineMode = "offline"
+
+// offlineOptions carries the subset of CLI flags that apply when analyzing an export.
+type offlineOptions struct {
+	Path           string
+	Domain         string
+	OutFile        string
+	CSVOut         string
+	ReportOut      string
+	BloodHoundJSON string
+	BloodHoundCSV  string
+	RunStoreDir    string
+	SIEM           bool
+	JSONOnly       bool
+	Tickets        ticketOptions
+}
+
+// runOffline re-runs candidate selection, scoring, reasoning and output on a
+// previously collected directory export. No network connections are made.
+func runOffline(opts offlineOptions) error {
+	log.Printf("[*] Offline analysis of %s (no network access)", opts.Path)
+
+	dir, err := ingest.Load(opts.Path)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", opts.Path, err)
+	}
+	dir.ResolveMemberships()
+	users := dir.Users
+	log.Printf("%s[+] Loaded %d users, %d groups, %d computers, %d ACEs from export%s",
+		util.Green, len(users), len(dir.Groups), len(dir.Computers), len(dir.ACEs), util.Reset)
+
+	domain := strings.ToUpper(strings.TrimSpace(opts.Domain))
+	if domain == "" {
+		domain = dir.Domain
+	}
+	if domain == "" {
+		for _, u := range users {
+			if u.DistinguishedName != "" {
+				domain = ingest.DomainFromDN(u.DistinguishedName)
+				break
+			}
+		}
+	}
+
+	cfg := triage.DefaultConfig()
+	asrep := krb.FindASREPCandidates(users)
+	kerb := krb.FindKerberoastCandidates(users)
+	etypes := analyzeEtypes(dir, kerb)
+	all := triage.ScoreCandidates(asrep, kerb, cfg)
+
+	log.Printf("%s[+] %d AS-REP roastable  |  %d Kerberoastable%s", util.Green, len(asrep), len(kerb), util.Reset)
+
+	results := output.Results{
+		SchemaVersion: "2.0",
+		Domain: output.DomainInfo{
+			Name: domain,
+			DN:   domainDN(domain),
+		},
+		Summary:    reconSummary(users, asrep, kerb),
+		Candidates: all,
+		Users:      users,
+		Computers:  dir.Computers,
+	}
+
+	advResults := map[string]interface{}{"directory": dir}
+	if edges := advanced.ControlEdgesFromDirectory(dir); len(edges) > 0 {
+		log.Printf("[+] Extracted %d ACL control edges from export", len(edges))
+		advResults["acl_control_edges"] = edges
+	}
+	if etypes != nil {
+		advResults["etype_posture"] = etypes
+		results.Advanced.EtypePosture = etypes
+	}
+	if dir.Policy != nil {
+		analyzer := advanced.NewPasswordPolicyAnalyzer(nil, false)
+		policy := analyzer.AnalyzeDomainPolicy(dir.Policy)
+		advResults["password_policies"] = analyzer.GeneratePasswordPolicyReport([]*advanced.PasswordPolicyResult{policy})
+		results.Advanced.PasswordPolicies = advResults["password_policies"]
+	}
+	results.Advanced.Tickets = analyzeTickets(opts.Tickets, dir)
+	riskInsights, newCandidates := generateRiskInsights(users, advResults)
+	results.RiskInsights = riskInsights
+	results.Candidates = append(results.Candidates, newCandidates...)
+	results.Candidates = reasoning.AnnotateCandidates(results.Candidates)
+	graph := reasoning.BuildGraph(reasoning.BuildContext{
+		Domain: domain,
+		Mode:   offlineMode,
+	}, users, results.Candidates, advResults)
+	results.AttackGraph = &graph
+	cp := controlplane.BuildFromReasoning(results.AttackGraph, advResults)
+	results.ControlPlane = &cp
+
+	tallyCandidates(&results)
+	logRiskInsights(results.RiskInsights)
+
+	writeResults(results, all, cfg, opts.OutFile, opts.CSVOut, opts.SIEM, opts.JSONOnly, opts.ReportOut)
+	writeExports(results, opts.BloodHoundJSON, opts.BloodHoundCSV, opts.RunStoreDir, opts.Path, offlineMode)
+	logCompletion(results, opts.OutFile)
+	return nil
+}
+
+// domainDN converts CORP.LOCAL into DC=corp,DC=local.
+func domainDN(domain string) string {
+	if domain == "" {
+		return ""
+	}
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, label := range labels {
+		labels[i] = "DC=" + label
+	}
+	return strings.Join(labels, ",")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/output"
+)
+
+const offlineLDIF = `version: 1
+
+dn: CN=alice,CN=Users,DC=corp,DC=local
+objectClass: top
+objectClass: person
+objectClass: user
+sAMAccountName: alice
+userAccountControl: 4194816
+
+dn: CN=svc_sql,OU=Service Accounts,DC=corp,DC=local
+objectClass: top
+objectClass: person
+objectClass: user
+sAMAccountName: svc_sql
+servicePrincipalName: MSSQLSvc/sql01.corp.local:1433
+userAccountControl: 66048
+
+dn: CN=bob,CN=Users,DC=corp,DC=local
+objectClass: top
+objectClass: person
+objectClass: user
+sAMAccountName: bob
+userAccountControl: 512
+`
+
+func TestRunOffline(t *testing.T) {
+	dir := t.TempDir()
+	export := filepath.Join(dir, "corp.ldif")
+	if err := os.WriteFile(export, []byte(offlineLDIF), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		domain, wantName, wantDN string
+	}{
+		{"", "CORP.LOCAL", "DC=corp,DC=local"},              // derived from the export
+		{"lab.example", "LAB.EXAMPLE", "DC=lab,DC=example"}, // -d wins
+	} {
+		outFile := filepath.Join(dir, tc.wantName, "results.json")
+		if err := runOffline(offlineOptions{Path: export, Domain: tc.domain, OutFile: outFile}); err != nil {
+			t.Fatalf("-d %q: %v", tc.domain, err)
+		}
+		data, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results output.Results
+		if err := json.Unmarshal(data, &results); err != nil {
+			t.Fatal(err)
+		}
+		if results.Domain.Name != tc.wantName || results.Domain.DN != tc.wantDN {
+			t.Errorf("-d %q: domain %+v, want %s / %s", tc.domain, results.Domain, tc.wantName, tc.wantDN)
+		}
+		if len(results.Users) != 3 {
+			t.Errorf("-d %q: %d users, want 3", tc.domain, len(results.Users))
+		}
+
+		types := make(map[string]string)
+		for _, c := range results.Candidates {
+			types[c.SamAccountName] = c.Type
+		}
+		if types["alice"] != "ASREP" || types["svc_sql"] != "KERBEROAST" {
+			t.Errorf("-d %q: candidates %v, want alice ASREP and svc_sql KERBEROAST", tc.domain, types)
+		}
+		if _, ok := types["bob"]; ok {
+			t.Errorf("-d %q: bob is not roastable", tc.domain)
+		}
+	}
+}
+
+func TestDomainDN(t *testing.T) {
+	for domain, want := range map[string]string{
+		"":              "",
+		"CORP.LOCAL":    "DC=corp,DC=local",
+		"eu.corp.local": "DC=eu,DC=corp,DC=local",
+	} {
+		if got := domainDN(domain); got != want {
+			t.Errorf("domainDN(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
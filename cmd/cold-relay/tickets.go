@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/advanced"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/output"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/util"
+)
+
+// ticketOptions carries the forged-ticket triage flags.
+type ticketOptions struct {
+	Files []string        // .kirbi or ccache files
+	Keys  []krb.TicketKey // from --ticket-key and --ticket-keytab
+}
+
+// parseTicketOptions reads --ticket, --ticket-key and --ticket-keytab. The
+// first two take comma-separated lists.
+func parseTicketOptions(files, keys, keytab string) (ticketOptions, error) {
+	var opts ticketOptions
+	opts.Files = splitList(files)
+	for _, spec := range splitList(keys) {
+		k, err := krb.ParseTicketKey(spec)
+		if err != nil {
+			return opts, fmt.Errorf("--ticket-key: %w", err)
+		}
+		opts.Keys = append(opts.Keys, k)
+	}
+	if keytab != "" {
+		ks, err := krb.LoadKeytabKeys(keytab)
+		if err != nil {
+			return opts, fmt.Errorf("--ticket-keytab: %w", err)
+		}
+		opts.Keys = append(opts.Keys, ks...)
+	}
+	if len(opts.Keys) > 0 && len(opts.Files) == 0 {
+		return opts, fmt.Errorf("--ticket-key and --ticket-keytab need --ticket")
+	}
+	return opts, nil
+}
+
+// analyzeTickets triages every ticket file with the supplied keys. With dir
+// set, the decrypted PACs are also checked against the directory. A file
+// that cannot be read or parsed is logged and skipped.
+func analyzeTickets(opts ticketOptions, dir *ingest.Directory) []*advanced.TicketResult {
+	if len(opts.Files) == 0 {
+		return nil
+	}
+	log.Printf("[*] Triaging %d ticket file(s) with %d key(s)", len(opts.Files), len(opts.Keys))
+	analyzer := advanced.NewAdvancedAnalyzer(nil, true, false, "", "", "", "")
+	analyzer.TicketKeys = opts.Keys
+	analyzer.Directory = dir
+
+	var results []*advanced.TicketResult
+	for _, path := range opts.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[x] Ticket %s: %v", path, err)
+			continue
+		}
+		if err := analyzer.RunTicketAnalysis(data, "Unknown"); err != nil {
+			log.Printf("[x] Ticket %s: %v", path, err)
+			continue
+		}
+		results, _ = analyzer.Results["tickets"].([]*advanced.TicketResult)
+		results[len(results)-1].Metadata["file"] = path
+	}
+	return results
+}
+
+// runTicketsOnly triages tickets without a directory, for --ticket given
+// without a target or export.
+func runTicketsOnly(opts ticketOptions, outFile string) error {
+	results := output.Results{
+		SchemaVersion: "2.0",
+		Advanced:      output.AdvancedResults{Tickets: analyzeTickets(opts, nil)},
+	}
+	if err := output.WriteJSON(outFile, results); err != nil {
+		return err
+	}
+	log.Printf("%s[+] Results → %s%s", util.Green, outFile, util.Reset)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
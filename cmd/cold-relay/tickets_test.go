@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// testdata/golden.kirbi is a ten-year TGT for krbtgt/TEST.GOKRB5 whose
+// ticket and PAC signatures use an AES256 key of 32 0x07 bytes.
+var goldenKey = strings.Repeat("07", 32)
+
+func TestTicketFlagsDecryptGoldenTicket(t *testing.T) {
+	kt := keytab.New()
+	if err := kt.AddEntry("krbtgt/TEST.GOKRB5", "TEST.GOKRB5", "unused", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), 2, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+		t.Fatal(err)
+	}
+	kt.Entries[0].Key.KeyValue = bytes.Repeat([]byte{7}, 32)
+	b, err := kt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ktPath := filepath.Join(t.TempDir(), "krbtgt.keytab")
+	if err := os.WriteFile(ktPath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, flags := range map[string][3]string{
+		"ticket-key":    {"testdata/golden.kirbi", "krbtgt:aes256:" + goldenKey, ""},
+		"ticket-keytab": {"testdata/golden.kirbi", "", ktPath},
+	} {
+		opts, err := parseTicketOptions(flags[0], flags[1], flags[2])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(opts.Keys) != 1 || !opts.Keys[0].IsKrbtgt() {
+			t.Fatalf("%s: keys %+v, want one krbtgt key", name, opts.Keys)
+		}
+		results := analyzeTickets(opts, nil)
+		if len(results) != 1 {
+			t.Fatalf("%s: %d results, want 1", name, len(results))
+		}
+		r := results[0]
+		if r.PAC == nil || r.Metadata["decrypted"] != true || r.Metadata["file"] != "testdata/golden.kirbi" {
+			t.Errorf("%s: ticket not decrypted: %v", name, r.Metadata)
+		}
+		if !r.IsForged || r.TicketType != "Golden" {
+			t.Errorf("%s: forged %v, type %s, indicators %v; want a golden ticket", name, r.IsForged, r.TicketType, r.ForgeryIndicators)
+		}
+	}
+}
+
+func TestParseTicketOptionsErrors(t *testing.T) {
+	for _, flags := range [][3]string{
+		{"a.kirbi", "aes256:00", ""},
+		{"a.kirbi", "", filepath.Join(t.TempDir(), "missing.keytab")},
+		{"", "rc4:" + strings.Repeat("00", 16), ""},
+	} {
+		if _, err := parseTicketOptions(flags[0], flags[1], flags[2]); err == nil {
+			t.Errorf("parseTicketOptions%q: want an error", flags)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
@@ -18,6 +19,9 @@ type AdvancedAnalyzer struct {
 	Password      string
 	Domain        string
 	Results       map[string]interface{}
+	// TicketKeys and Directory feed PAC decoding in RunTicketAnalysis.
+	TicketKeys []krb.TicketKey
+	Directory  *ingest.Directory
 }
 
 // NewAdvancedAnalyzer creates a new advanced analyzer
@@ -34,11 +38,13 @@ func NewAdvancedAnalyzer(client *krb.LDAPClient, auditMode, dangerousMode bool,
 	}
 }
 
-// RunTimeroastingAnalysis runs timeroasting analysis
-func (aa *AdvancedAnalyzer) RunTimeroastingAnalysis(kirbiPath string, spns []string) error {
+// RunTimeroastingAnalysis parses the ticket files under kirbiPath and, with
+// sweep, asks the DC (aa.Target) for an MS-SNTP hash of every computer.
+func (aa *AdvancedAnalyzer) RunTimeroastingAnalysis(kirbiPath string, sweep bool) error {
 	log.Printf("[*] Starting Timeroasting analysis...")
 
 	analyzer := NewTimeroastAnalyzer(true, true, "")
+	analyzer.Client = aa.Client
 
 	var results []*TimeroastResult
 
@@ -52,13 +58,15 @@ func (aa *AdvancedAnalyzer) RunTimeroastingAnalysis(kirbiPath string, spns []str
 		}
 	}
 
-	// Request tickets for SPNs if provided
-	if len(spns) > 0 {
-		spnResults, err := analyzer.RequestTicketsUnderSPN(aa.Client, spns)
+	// Sweep computer RIDs over MS-SNTP
+	if sweep && aa.Client != nil {
+		computers, err := aa.Client.EnumerateComputers()
 		if err != nil {
-			log.Printf("[x] SPN ticket requests failed: %v", err)
+			log.Printf("[x] Computer enumeration failed: %v", err)
+		} else if sntpResults, err := analyzer.TimeroastComputers(aa.Target, computers); err != nil {
+			log.Printf("[x] MS-SNTP requests failed: %v", err)
 		} else {
-			results = append(results, spnResults...)
+			results = append(results, sntpResults...)
 		}
 	}
 
@@ -173,11 +181,14 @@ func (aa *AdvancedAnalyzer) RunOverpassAnalysis(hashes map[string]string) error
 	return nil
 }
 
-// RunTicketAnalysis runs Silver/Golden ticket analysis
+// RunTicketAnalysis runs Silver/Golden ticket analysis and appends the
+// result to aa.Results["tickets"].
 func (aa *AdvancedAnalyzer) RunTicketAnalysis(ticketData []byte, ticketType string) error {
 	log.Printf("[*] Starting Silver/Golden ticket analysis...")
 
 	analyzer := NewTicketAnalyzer(aa.Client, aa.AuditMode, aa.DangerousMode)
+	analyzer.Keys = aa.TicketKeys
+	analyzer.Directory = aa.Directory
 
 	// Analyze ticket
 	result, err := analyzer.AnalyzeTicket(ticketData, ticketType)
@@ -186,6 +197,8 @@ func (aa *AdvancedAnalyzer) RunTicketAnalysis(ticketData []byte, ticketType stri
 	}
 
 	log.Printf("[+] Ticket analysis completed: %s ticket, Risk: %s", result.TicketType, result.RiskLevel)
+	tickets, _ := aa.Results["tickets"].([]*TicketResult)
+	aa.Results["tickets"] = append(tickets, result)
 
 	if result.IsForged {
 		log.Printf("[+] FORGED TICKET DETECTED!")
@@ -541,6 +554,7 @@ func (aa *AdvancedAnalyzer) RunFullAnalysis() error {
 		{"gpo", aa.RunGPOAnalysis},
 		{"sessions", aa.RunSessionAnalysis},
 		{"acl", aa.RunACLAnalysis},
+		{"deleted_objects", aa.RunTombstoneAnalysis},
 		{"user_attributes", aa.RunUserAttributeAnalysis},
 		{"shadow_credentials", aa.RunShadowCredentialsAnalysis},
 		{"password_policy", aa.RunPasswordPolicyAnalysis},
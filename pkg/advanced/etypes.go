@@ -0,0 +1,239 @@
+package advanced
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+// Account kinds in the etype report.
+const (
+	etypeKindUser     = "user"
+	etypeKindComputer = "computer"
+	etypeKindGMSA     = "gmsa"
+	etypeKindKrbtgt   = "krbtgt"
+	etypeKindDC       = "dc"
+)
+
+// uacServerTrustAccount marks a domain controller's computer account.
+const uacServerTrustAccount = 0x2000
+
+// AccountEtypes is the Kerberos encryption-type posture of one account.
+type AccountEtypes struct {
+	SamAccountName string `json:"sam_account_name"`
+	DN             string `json:"dn"`
+	Kind           string `json:"kind"` // user | computer | gmsa | krbtgt | dc
+	// SupportedEncryptionTypes is msDS-SupportedEncryptionTypes as stored;
+	// EffectiveEtypes is what the KDC would actually use for tickets.
+	SupportedEncryptionTypes int       `json:"supported_encryption_types"`
+	EffectiveEtypes          []string  `json:"effective_etypes"`
+	PwdLastSet               time.Time `json:"pwd_last_set,omitempty"`
+	AESKeys                  bool      `json:"aes_keys"`
+	Posture                  string    `json:"posture"` // rc4_only | aes_capable | aes_enforced
+	Service                  bool      `json:"service"` // enabled user account with an SPN, i.e. Kerberoastable
+	Disabled                 bool      `json:"disabled,omitempty"`
+	Reasons                  []string  `json:"reasons,omitempty"`
+}
+
+// EtypeReport is the encryption-type posture of every account.
+type EtypeReport struct {
+	// AESIntroduced is when the first Windows Server 2008 DC joined, taken
+	// from the creation of Read-only Domain Controllers (RID 521). Passwords
+	// set before then have no AES keys.
+	AESIntroduced time.Time `json:"aes_introduced,omitempty"`
+	// DCEtypes is what every DC allows, from the DCs' own
+	// msDS-SupportedEncryptionTypes; empty when any DC leaves it unset.
+	DCEtypes               []string        `json:"dc_etypes,omitempty"`
+	DCRC4Disabled          bool            `json:"dc_rc4_disabled"`
+	Accounts               []AccountEtypes `json:"accounts"`
+	RC4Only                int             `json:"rc4_only"`
+	AESCapable             int             `json:"aes_capable"`
+	AESEnforced            int             `json:"aes_enforced"`
+	RC4OnlyServiceAccounts []string        `json:"rc4_only_service_accounts,omitempty"`
+	KrbtgtPosture          string          `json:"krbtgt_posture,omitempty"`
+}
+
+// AnalyzeEncryptionTypes classifies every user, computer, gMSA and krbtgt
+// in dir as RC4-only, AES-capable or AES-enforced. It needs only collected
+// attributes, so it runs the same on live and offline data.
+func AnalyzeEncryptionTypes(dir *ingest.Directory) *EtypeReport {
+	if dir == nil {
+		return nil
+	}
+	report := &EtypeReport{AESIntroduced: aesIntroduced(dir.Groups)}
+
+	dcMask, dcMaskKnown := dcAllowedEtypes(dir.Computers)
+	if dcMaskKnown {
+		report.DCEtypes = krb.EtypeNames(dcMask)
+		report.DCRC4Disabled = dcMask&krb.EtypeRC4HMAC == 0
+	}
+
+	for _, u := range dir.Users {
+		kind := etypeKindUser
+		if strings.EqualFold(u.SamAccountName, "krbtgt") {
+			kind = etypeKindKrbtgt
+		}
+		disabled := u.UserAccountControl&0x2 != 0
+		account := AccountEtypes{
+			SamAccountName:           u.SamAccountName,
+			DN:                       u.DistinguishedName,
+			Kind:                     kind,
+			SupportedEncryptionTypes: u.SupportedEncryptionTypes,
+			PwdLastSet:               u.PwdLastSet,
+			Service: kind == etypeKindUser && !disabled && len(u.ServicePrincipalNames) > 0 &&
+				!strings.HasSuffix(u.SamAccountName, "$"),
+			Disabled: disabled,
+		}
+		report.add(account, dcMask, dcMaskKnown)
+	}
+	for _, c := range dir.Computers {
+		kind := etypeKindComputer
+		switch {
+		case c.UserAccountControl&uacServerTrustAccount != 0:
+			kind = etypeKindDC
+		case isGMSA(c):
+			kind = etypeKindGMSA
+		}
+		report.add(AccountEtypes{
+			SamAccountName:           c.SamAccountName,
+			DN:                       c.DistinguishedName,
+			Kind:                     kind,
+			SupportedEncryptionTypes: c.SupportedEncryptionTypes,
+			PwdLastSet:               c.PwdLastSet,
+			Disabled:                 c.UserAccountControl&0x2 != 0,
+		}, dcMask, dcMaskKnown)
+	}
+	sort.Strings(report.RC4OnlyServiceAccounts)
+	return report
+}
+
+// add classifies account and records it.
+func (r *EtypeReport) add(account AccountEtypes, dcMask int, dcMaskKnown bool) {
+	effective := account.SupportedEncryptionTypes
+	if effective == 0 {
+		if account.Kind == etypeKindKrbtgt {
+			// The KDC encrypts TGTs with the strongest krbtgt key regardless
+			// of the attribute.
+			effective = krb.EtypeRC4HMAC | krb.EtypeAES128 | krb.EtypeAES256
+		} else {
+			effective = krb.DefaultSupportedEtypes
+			account.Reasons = append(account.Reasons,
+				fmt.Sprintf("msDS-SupportedEncryptionTypes unset; the KDC default 0x%X issues RC4 tickets", krb.DefaultSupportedEtypes))
+		}
+	}
+	if dcMaskKnown {
+		if effective&krb.EtypeRC4HMAC != 0 && dcMask&krb.EtypeRC4HMAC == 0 {
+			account.Reasons = append(account.Reasons, "Domain controllers do not allow RC4")
+		}
+		effective &= dcMask | krb.EtypeAESSessionKeys
+	}
+
+	account.AESKeys = r.AESIntroduced.IsZero() || account.PwdLastSet.IsZero() ||
+		account.PwdLastSet.After(r.AESIntroduced)
+	if !account.AESKeys {
+		account.Reasons = append(account.Reasons, fmt.Sprintf("Password last set %s, before AES was introduced (%s); no AES keys exist",
+			account.PwdLastSet.Format("2006-01-02"), r.AESIntroduced.Format("2006-01-02")))
+		effective &^= krb.EtypeAES128 | krb.EtypeAES256
+	}
+	account.EffectiveEtypes = krb.EtypeNames(effective)
+	account.Posture = krb.ClassifyEtypes(effective, account.AESKeys)
+
+	switch account.Posture {
+	case krb.EtypeRC4Only:
+		r.RC4Only++
+		if account.Service {
+			r.RC4OnlyServiceAccounts = append(r.RC4OnlyServiceAccounts, account.SamAccountName)
+		}
+	case krb.EtypeAESCapable:
+		r.AESCapable++
+	case krb.EtypeAESEnforced:
+		r.AESEnforced++
+	}
+	if account.Kind == etypeKindKrbtgt {
+		r.KrbtgtPosture = account.Posture
+	}
+	r.Accounts = append(r.Accounts, account)
+}
+
+// Lookup returns the posture of an account by sAMAccountName.
+func (r *EtypeReport) Lookup(sam string) (AccountEtypes, bool) {
+	if r == nil {
+		return AccountEtypes{}, false
+	}
+	for i := range r.Accounts {
+		if strings.EqualFold(r.Accounts[i].SamAccountName, sam) {
+			return r.Accounts[i], true
+		}
+	}
+	return AccountEtypes{}, false
+}
+
+// MarkCandidates records the etype posture on Kerberoast candidates so
+// triage can weight RC4-only accounts.
+func (r *EtypeReport) MarkCandidates(candidates []krb.Candidate) {
+	if r == nil {
+		return
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Type != "KERBEROAST" {
+			continue
+		}
+		account, ok := r.Lookup(c.SamAccountName)
+		if !ok {
+			continue
+		}
+		c.EtypePosture = account.Posture
+		switch account.Posture {
+		case krb.EtypeRC4Only:
+			c.Reasons = append(c.Reasons, "RC4-only: service tickets are RC4-HMAC, keyed by the NT hash")
+		case krb.EtypeAESEnforced:
+			c.Reasons = append(c.Reasons, "AES enforced: service tickets are AES and far slower to crack")
+		}
+	}
+}
+
+// aesIntroduced is the creation time of the Read-only Domain Controllers
+// group, which adprep creates when the first 2008 DC is promoted.
+func aesIntroduced(groups []ingest.Group) time.Time {
+	for _, g := range groups {
+		if strings.HasSuffix(g.ObjectSID, "-521") {
+			return g.WhenCreated
+		}
+	}
+	return time.Time{}
+}
+
+// dcAllowedEtypes intersects the DCs' msDS-SupportedEncryptionTypes, which
+// the "Configure encryption types allowed for Kerberos" policy writes. It
+// is unknown when any DC leaves the attribute unset.
+func dcAllowedEtypes(computers []ingest.Computer) (int, bool) {
+	mask, found := -1, false
+	for _, c := range computers {
+		if c.UserAccountControl&uacServerTrustAccount == 0 {
+			continue
+		}
+		if c.SupportedEncryptionTypes == 0 {
+			return 0, false
+		}
+		mask &= c.SupportedEncryptionTypes
+		found = true
+	}
+	if !found {
+		return 0, false
+	}
+	return mask, true
+}
+
+func isGMSA(c ingest.Computer) bool {
+	for name, value := range c.RawFields {
+		if strings.EqualFold(name, "objectClass") {
+			return strings.Contains(strings.ToLower(value), "msds-groupmanagedserviceaccount")
+		}
+	}
+	return false
+}
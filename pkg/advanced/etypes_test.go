@@ -0,0 +1,89 @@
+package advanced
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+func TestAnalyzeEncryptionTypes(t *testing.T) {
+	aesDate := time.Date(2010, 3, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	dir := &ingest.Directory{
+		Groups: []ingest.Group{
+			{SamAccountName: "Read-only Domain Controllers", ObjectSID: "S-1-5-21-1-2-3-521", WhenCreated: aesDate},
+		},
+		Users: []ingest.User{
+			{SamAccountName: "krbtgt", UserAccountControl: 0x202, PwdLastSet: time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{SamAccountName: "sqlsvc", UserAccountControl: 0x200, ServicePrincipalNames: []string{"MSSQLSvc/sql.corp.local"}, PwdLastSet: recent},
+			{SamAccountName: "websvc", UserAccountControl: 0x200, ServicePrincipalNames: []string{"HTTP/web.corp.local"},
+				PwdLastSet: recent, SupportedEncryptionTypes: krb.EtypeAES128 | krb.EtypeAES256},
+			{SamAccountName: "legacysvc", UserAccountControl: 0x200, ServicePrincipalNames: []string{"HTTP/old.corp.local"},
+				PwdLastSet: time.Date(2009, 6, 1, 0, 0, 0, 0, time.UTC), SupportedEncryptionTypes: 0x1C},
+			{SamAccountName: "alice", UserAccountControl: 0x200, PwdLastSet: recent, SupportedEncryptionTypes: 0x1C},
+		},
+		Computers: []ingest.Computer{
+			{SamAccountName: "DC01$", UserAccountControl: 0x82000, SupportedEncryptionTypes: 0x1C, PwdLastSet: recent},
+			{SamAccountName: "gmsa-web$", UserAccountControl: 0x1000, SupportedEncryptionTypes: 0x1C, PwdLastSet: recent,
+				RawFields: map[string]string{"objectClass": "top;person;organizationalPerson;user;computer;msDS-GroupManagedServiceAccount"}},
+		},
+	}
+
+	report := AnalyzeEncryptionTypes(dir)
+	if !report.AESIntroduced.Equal(aesDate) {
+		t.Fatalf("AES introduction %v, want %v", report.AESIntroduced, aesDate)
+	}
+	want := map[string]struct{ kind, posture string }{
+		"krbtgt":    {etypeKindKrbtgt, krb.EtypeRC4Only},
+		"sqlsvc":    {etypeKindUser, krb.EtypeRC4Only},
+		"websvc":    {etypeKindUser, krb.EtypeAESEnforced},
+		"legacysvc": {etypeKindUser, krb.EtypeRC4Only},
+		"alice":     {etypeKindUser, krb.EtypeAESCapable},
+		"DC01$":     {etypeKindDC, krb.EtypeAESCapable},
+		"gmsa-web$": {etypeKindGMSA, krb.EtypeAESCapable},
+	}
+	for name, w := range want {
+		account, ok := report.Lookup(name)
+		if !ok {
+			t.Fatalf("%s missing from report", name)
+		}
+		if account.Kind != w.kind || account.Posture != w.posture {
+			t.Errorf("%s: kind %s posture %s, want %s %s (%v)", name, account.Kind, account.Posture, w.kind, w.posture, account.Reasons)
+		}
+	}
+	if len(report.RC4OnlyServiceAccounts) != 2 || report.RC4OnlyServiceAccounts[0] != "legacysvc" || report.RC4OnlyServiceAccounts[1] != "sqlsvc" {
+		t.Errorf("RC4-only service accounts %v", report.RC4OnlyServiceAccounts)
+	}
+	if report.KrbtgtPosture != krb.EtypeRC4Only || report.DCRC4Disabled {
+		t.Errorf("krbtgt %s, DC RC4 disabled %v", report.KrbtgtPosture, report.DCRC4Disabled)
+	}
+
+	candidates := krb.FindKerberoastCandidates(dir.Users)
+	report.MarkCandidates(candidates)
+	for _, c := range candidates {
+		if c.EtypePosture != want[c.SamAccountName].posture {
+			t.Errorf("candidate %s marked %q", c.SamAccountName, c.EtypePosture)
+		}
+	}
+}
+
+func TestEtypeDCPolicyDisablesRC4(t *testing.T) {
+	dir := &ingest.Directory{
+		Users: []ingest.User{
+			{SamAccountName: "svc", UserAccountControl: 0x200, ServicePrincipalNames: []string{"HTTP/a"}, SupportedEncryptionTypes: 0x1C},
+		},
+		Computers: []ingest.Computer{
+			{SamAccountName: "DC01$", UserAccountControl: 0x82000, SupportedEncryptionTypes: 0x18},
+			{SamAccountName: "DC02$", UserAccountControl: 0x82000, SupportedEncryptionTypes: 0x1C},
+		},
+	}
+	report := AnalyzeEncryptionTypes(dir)
+	if !report.DCRC4Disabled {
+		t.Fatalf("expected RC4 disabled by DC policy, got %v", report.DCEtypes)
+	}
+	if svc, _ := report.Lookup("svc"); svc.Posture != krb.EtypeAESEnforced {
+		t.Fatalf("svc posture %s, want aes_enforced", svc.Posture)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/miekg/dns"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
 type TrustResult struct {
@@ -268,13 +269,11 @@ func (aa *AdvancedAnalyzer) RunGPOAnalysis() error {
 func (aa *AdvancedAnalyzer) RunSessionAnalysis() error {
 	log.Printf("[*] Starting session enumeration analysis...")
 
-	entries, err := aa.Client.SearchSubtreePaged("(&(objectCategory=person)(objectClass=user))", []string{"sAMAccountName", "lastLogonTimestamp", "lastLogon", "logonCount"}, 500)
-	if err != nil {
-		return fmt.Errorf("session enumeration failed: %v", err)
-	}
-
 	var results []SessionResult
-	for _, entry := range entries {
+	err := aa.Client.Stream(krb.StreamRequest{
+		Filter:     "(&(objectCategory=person)(objectClass=user))",
+		Attributes: []string{"sAMAccountName", "lastLogonTimestamp", "lastLogon", "logonCount"},
+	}, func(entry *ldap.Entry) error {
 		lastLogon := entry.GetAttributeValue("lastLogon")
 		lastLogonTimestamp := entry.GetAttributeValue("lastLogonTimestamp")
 		logonCount := parseLDAPInt(entry.GetAttributeValue("logonCount"))
@@ -286,6 +285,10 @@ func (aa *AdvancedAnalyzer) RunSessionAnalysis() error {
 			LogonCount:         logonCount,
 			LikelyActive:       active,
 		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("session enumeration failed: %v", err)
 	}
 
 	log.Printf("[+] Session enumeration completed, %d user entries analyzed", len(results))
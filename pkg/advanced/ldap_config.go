@@ -1,6 +1,7 @@
 package advanced
 
 import (
+	"fmt"
 	"log"
 	"strings"
 
@@ -15,6 +16,9 @@ type LDAPConfigResult struct {
 	RiskLevel       string   `json:"risk_level"`
 	Findings        []string `json:"findings"`
 	Recommendations []string `json:"recommendations"`
+	// Validation and Evidence are set by the behavioral bind probes.
+	Validation string   `json:"validation,omitempty"`
+	Evidence   []string `json:"evidence,omitempty"`
 }
 
 // LDAPConfigAnalyzer handles LDAP configuration analysis
@@ -59,6 +63,14 @@ func (lca *LDAPConfigAnalyzer) EnumerateLDAPMisconfigurations() ([]*LDAPConfigRe
 		results = append(results, signing)
 	}
 
+	// Check for LDAPS channel binding enforcement
+	binding, err := lca.checkChannelBinding()
+	if err != nil {
+		log.Printf("[!] Failed to check LDAP channel binding: %v", err)
+	} else {
+		results = append(results, binding)
+	}
+
 	// Check for StartTLS support
 	startTLS, err := lca.checkStartTLS()
 	if err != nil {
@@ -100,7 +112,8 @@ func (lca *LDAPConfigAnalyzer) checkAnonymousBinds() (*LDAPConfigResult, error)
 	return result, nil
 }
 
-// checkLDAPSigning checks if LDAP signing is required
+// checkLDAPSigning binds over plain 389 without signing and reports whether
+// the DC answered strongerAuthRequired.
 func (lca *LDAPConfigAnalyzer) checkLDAPSigning() (*LDAPConfigResult, error) {
 	result := &LDAPConfigResult{
 		CheckType:       "ldap_signing",
@@ -108,47 +121,72 @@ func (lca *LDAPConfigAnalyzer) checkLDAPSigning() (*LDAPConfigResult, error) {
 		Recommendations: []string{},
 	}
 
-	// Check domain controller LDAP signing policy
-	searchRequest := ldap.NewSearchRequest(
-		lca.Client.GetBaseDN(),
-		ldap.ScopeBaseObject,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		"(objectClass=domain)",
-		[]string{"ldapSigningIntegrity"},
-		nil,
-	)
-
-	sr, err := lca.Client.GetConnection().Search(searchRequest)
-	if err != nil {
+	host := lca.Client.LDAPHost()
+	probe, err := krb.ProbeLDAPSigning(host, lca.Client.ConnectOptions())
+	result.Evidence = probe.Evidence
+	if err != nil || probe.Verdict == krb.LDAPSigningUnknown {
 		result.Status = "unknown"
 		result.RiskLevel = "Medium"
-		result.Findings = append(result.Findings, "Unable to determine LDAP signing policy")
+		result.Validation = krb.StatusInsufficientVisibility
+		result.Findings = append(result.Findings, fmt.Sprintf("Unable to determine LDAP signing policy on %s: %v", host, err))
 		result.Recommendations = append(result.Recommendations, "Manually verify LDAP signing requirements")
 		return result, nil
 	}
 
-	if len(sr.Entries) == 0 {
+	result.Validation = krb.StatusValidated
+	if probe.Verdict == krb.LDAPSigningRequired {
+		result.Status = "secure"
+		result.RiskLevel = "Low"
+		result.Findings = append(result.Findings, fmt.Sprintf("LDAP signing is required on %s (unsigned bind rejected with strongerAuthRequired)", host))
+		result.Recommendations = append(result.Recommendations, "Continue enforcing LDAP signing")
+	} else {
+		result.Status = "vulnerable"
+		result.RiskLevel = "High"
+		result.Findings = append(result.Findings, fmt.Sprintf("LDAP signing not required on %s - unsigned bind accepted, susceptible to NTLM relay", host))
+		result.Recommendations = append(result.Recommendations, "Set 'Domain controller: LDAP server signing requirements' to Require signing on all domain controllers")
+	}
+	return result, nil
+}
+
+// checkChannelBinding classifies the LDAPS channel binding policy by binding
+// with, without and with a wrong channel binding token.
+func (lca *LDAPConfigAnalyzer) checkChannelBinding() (*LDAPConfigResult, error) {
+	result := &LDAPConfigResult{
+		CheckType:       "ldap_channel_binding",
+		Findings:        []string{},
+		Recommendations: []string{},
+	}
+
+	host := lca.Client.LDAPHost()
+	probe, err := krb.ProbeChannelBinding(host, lca.Client.ConnectOptions())
+	result.Evidence = probe.Evidence
+	if err != nil || probe.Verdict == krb.ChannelBindingUnknown {
 		result.Status = "unknown"
 		result.RiskLevel = "Medium"
-		result.Findings = append(result.Findings, "Domain object not found")
+		result.Validation = krb.StatusInsufficientVisibility
+		result.Findings = append(result.Findings, fmt.Sprintf("Unable to determine LDAPS channel binding policy on %s: %v", host, err))
+		result.Recommendations = append(result.Recommendations, "Rerun with a username and password or NT hash, and LDAPS reachable on 636")
 		return result, nil
 	}
 
-	signingIntegrity := sr.Entries[0].GetAttributeValue("ldapSigningIntegrity")
-	if signingIntegrity == "1" {
+	result.Validation = krb.StatusValidated
+	switch probe.Verdict {
+	case krb.ChannelBindingAlways:
 		result.Status = "secure"
 		result.RiskLevel = "Low"
-		result.Findings = append(result.Findings, "LDAP signing is required")
-		result.Recommendations = append(result.Recommendations, "Continue enforcing LDAP signing")
-	} else {
+		result.Findings = append(result.Findings, fmt.Sprintf("LDAPS channel binding is Always on %s (bind without a token rejected)", host))
+		result.Recommendations = append(result.Recommendations, "Continue enforcing LDAPS channel binding")
+	case krb.ChannelBindingWhenSupported:
+		result.Status = "vulnerable"
+		result.RiskLevel = "Medium"
+		result.Findings = append(result.Findings, fmt.Sprintf("LDAPS channel binding is When Supported on %s - binds without a token are still accepted", host))
+		result.Recommendations = append(result.Recommendations, "Set LdapEnforceChannelBinding to 2 (Always) once clients support it")
+	default:
 		result.Status = "vulnerable"
 		result.RiskLevel = "High"
-		result.Findings = append(result.Findings, "LDAP signing not required - susceptible to relay attacks")
-		result.Recommendations = append(result.Recommendations, "Enable LDAP signing requirement on all domain controllers")
-		result.Recommendations = append(result.Recommendations, "Implement LDAP channel binding")
+		result.Findings = append(result.Findings, fmt.Sprintf("LDAPS channel binding is Never on %s - NTLM can be relayed to LDAPS", host))
+		result.Recommendations = append(result.Recommendations, "Set 'Domain controller: LDAP server channel binding token requirements' to Always")
 	}
-
 	return result, nil
 }
 
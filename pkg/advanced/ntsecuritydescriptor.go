@@ -4,9 +4,13 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
 
 	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
 const (
@@ -55,7 +59,19 @@ func (aa *AdvancedAnalyzer) EnumerateNTSecurityDescriptorEdges() ([]ACLControlEd
 		return nil, fmt.Errorf("nTSecurityDescriptor search failed: %w", err)
 	}
 
-	sidToDN := aa.buildSIDToDNIndex()
+	sidToDN, err := aa.buildSIDToDNIndex()
+	if err != nil {
+		log.Printf("[!] objectSid index failed, ACE trustees stay unresolved: %v", err)
+	} else if aa.Results != nil {
+		// The domain object's SID marks which trustees should resolve here;
+		// only then can an unresolved one be called stale.
+		for sid, dn := range sidToDN {
+			if strings.EqualFold(dn, aa.Client.GetBaseDN()) {
+				aa.Results["acl_domain_sid"] = sid
+				break
+			}
+		}
+	}
 	var out []ACLControlEdge
 	for _, entry := range resp.Entries {
 		targetDN := entry.GetAttributeValue("distinguishedName")
@@ -84,21 +100,144 @@ func (aa *AdvancedAnalyzer) EnumerateNTSecurityDescriptorEdges() ([]ACLControlEd
 	return out, nil
 }
 
-func (aa *AdvancedAnalyzer) buildSIDToDNIndex() map[string]string {
-	index := make(map[string]string)
-	entries, err := aa.Client.SearchSubtreePaged("(objectSid=*)", []string{"distinguishedName", "objectSid"}, 500)
-	if err != nil {
-		return index
+// ControlEdgesFromACEs converts ACEs collected by an external tool (e.g. a
+// SharpHound archive) into the same control edges produced from a live DACL.
+// Replication rights collapse to DCSync; rights the control plane does not
+// model are dropped.
+func ControlEdgesFromACEs(aces []ingest.ACE) []ACLControlEdge {
+	var out []ACLControlEdge
+	seen := make(map[string]bool)
+	for _, ace := range aces {
+		if ace.PrincipalSID == "" || ace.TargetDN == "" {
+			continue
+		}
+		right := controlRightFromName(ace.Right)
+		if right == "" {
+			continue
+		}
+		key := ace.PrincipalSID + "|" + strings.ToLower(ace.TargetDN) + "|" + right
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		evidence := fmt.Sprintf("collected ACE %s on %s", ace.Right, ace.TargetType)
+		if ace.Inherited {
+			evidence += " (inherited)"
+		}
+		out = append(out, ACLControlEdge{
+			TrusteeSID: ace.PrincipalSID,
+			TrusteeDN:  ace.PrincipalDN,
+			TargetDN:   ace.TargetDN,
+			Right:      right,
+			Evidence:   []string{evidence},
+		})
+	}
+	return out
+}
+
+// ControlEdgesFromDirectory builds control edges for an offline collection:
+// ACEs already resolved by the collector plus DACLs parsed from raw
+// nTSecurityDescriptor values. As with the live query, descriptors are only
+// parsed for the domain head and adminCount-protected objects.
+func ControlEdgesFromDirectory(dir *ingest.Directory) []ACLControlEdge {
+	if dir == nil {
+		return nil
+	}
+	out := ControlEdgesFromACEs(dir.ACEs)
+
+	descriptors := make(map[string][]byte)
+	for dn, sd := range dir.SecurityDescriptors {
+		if isDomainHeadDN(dn) {
+			descriptors[dn] = sd
+		}
+	}
+	for _, u := range dir.Users {
+		if u.RawFields["adminCount"] == "1" && len(u.NTSecurityDescriptor) > 0 {
+			descriptors[u.DistinguishedName] = u.NTSecurityDescriptor
+		}
+	}
+	for _, g := range dir.Groups {
+		if g.AdminCount && len(g.NTSecurityDescriptor) > 0 {
+			descriptors[g.DistinguishedName] = g.NTSecurityDescriptor
+		}
+	}
+	for _, c := range dir.Computers {
+		if c.RawFields["adminCount"] == "1" && len(c.NTSecurityDescriptor) > 0 {
+			descriptors[c.DistinguishedName] = c.NTSecurityDescriptor
+		}
 	}
-	for _, e := range entries {
-		rawSID := e.GetRawAttributeValue("objectSid")
-		sid, err := parseSID(rawSID)
+
+	targets := make([]string, 0, len(descriptors))
+	for dn := range descriptors {
+		targets = append(targets, dn)
+	}
+	sort.Strings(targets)
+	for _, targetDN := range targets {
+		aces, err := parseDACL(descriptors[targetDN])
 		if err != nil {
 			continue
 		}
-		index[sid] = e.DN
+		for _, ace := range aces {
+			for _, right := range rightsFromACE(ace, isDomainHeadDN(targetDN)) {
+				trustee, _ := dir.LookupSID(ace.SID)
+				out = append(out, ACLControlEdge{
+					TrusteeSID: ace.SID,
+					TrusteeDN:  trustee.DN,
+					TargetDN:   targetDN,
+					Right:      right,
+					Evidence:   []string{fmt.Sprintf("ACE type=0x%02x mask=0x%08x (offline nTSecurityDescriptor)", ace.AceType, ace.Mask)},
+				})
+			}
+		}
 	}
-	return index
+	return out
+}
+
+// isDomainHeadDN reports whether dn consists only of DC= components.
+func isDomainHeadDN(dn string) bool {
+	if dn == "" {
+		return false
+	}
+	for _, part := range strings.Split(dn, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) < 3 || !strings.EqualFold(part[:3], "DC=") {
+			return false
+		}
+	}
+	return true
+}
+
+func controlRightFromName(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "genericall", "owns":
+		return "GenericAll"
+	case "genericwrite", "writeproperty", "addmember", "addself", "writespn", "addkeycredentiallink", "writeaccountrestrictions":
+		return "GenericWrite"
+	case "writedacl":
+		return "WriteDacl"
+	case "writeowner":
+		return "WriteOwner"
+	case "getchanges", "getchangesall", "getchangesinfilteredset", "dcsync":
+		return "DCSync"
+	case "allextendedrights", "forcechangepassword":
+		return "AllExtendedRights"
+	default:
+		return ""
+	}
+}
+
+// buildSIDToDNIndex maps the objectSid of every object under the base DN to
+// its DN. An error means the index is incomplete and an unresolved SID says
+// nothing about whether its object still exists.
+func (aa *AdvancedAnalyzer) buildSIDToDNIndex() (map[string]string, error) {
+	index := make(map[string]string)
+	err := aa.Client.Stream(krb.StreamRequest{Filter: "(objectSid=*)", Attributes: []string{"objectSid"}}, func(e *ldap.Entry) error {
+		if sid, err := parseSID(e.GetRawAttributeValue("objectSid")); err == nil {
+			index[sid] = e.DN
+		}
+		return nil
+	})
+	return index, err
 }
 
 type parsedACE struct {
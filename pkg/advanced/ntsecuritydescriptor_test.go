@@ -0,0 +1,71 @@
+package advanced
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// sid S-1-5-21-1-2-3-1104
+var testTrusteeSID = []byte{
+	0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+	0x15, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00,
+	0x50, 0x04, 0x00, 0x00,
+}
+
+// DS-Replication-Get-Changes-All in little-endian GUID layout
+var testGetChangesAllGUID = []byte{
+	0xad, 0xf6, 0x31, 0x11, 0x07, 0x9c, 0xd1, 0x11,
+	0xf7, 0x9f, 0x00, 0xc0, 0x4f, 0xc2, 0xdc, 0xd2,
+}
+
+// descriptorWithObjectACE builds a self-relative descriptor whose DACL holds
+// a single ACCESS_ALLOWED_OBJECT ACE granting CONTROL_ACCESS for guid.
+func descriptorWithObjectACE(guid, sid []byte) []byte {
+	ace := []byte{aceTypeAccessAllowedObject, 0x00, 0x00, 0x00}
+	ace = binary.LittleEndian.AppendUint32(ace, accessMaskControlAccess)
+	ace = binary.LittleEndian.AppendUint32(ace, 0x1) // ACE_OBJECT_TYPE_PRESENT
+	ace = append(ace, guid...)
+	ace = append(ace, sid...)
+	binary.LittleEndian.PutUint16(ace[2:], uint16(len(ace)))
+
+	dacl := []byte{0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}
+	dacl = append(dacl, ace...)
+	binary.LittleEndian.PutUint16(dacl[2:], uint16(len(dacl)))
+
+	sd := make([]byte, 20)
+	sd[0] = 1
+	binary.LittleEndian.PutUint16(sd[2:], 0x8004) // SE_SELF_RELATIVE | SE_DACL_PRESENT
+	binary.LittleEndian.PutUint32(sd[16:], 20)
+	return append(sd, dacl...)
+}
+
+func TestControlEdgesFromDirectory(t *testing.T) {
+	dir := &ingest.Directory{
+		Users: []ingest.User{
+			{SamAccountName: "svc_sync", DistinguishedName: "CN=svc_sync,DC=corp,DC=local", ObjectSID: "S-1-5-21-1-2-3-1104"},
+		},
+		ACEs: []ingest.ACE{
+			{TargetDN: "CN=Domain Admins,CN=Users,DC=corp,DC=local", PrincipalSID: "S-1-5-21-1-2-3-1105", Right: "AddMember"},
+			{TargetDN: "CN=Domain Admins,CN=Users,DC=corp,DC=local", PrincipalSID: "S-1-5-21-1-2-3-1105", Right: "ReadProperty"},
+		},
+		SecurityDescriptors: map[string][]byte{
+			"DC=corp,DC=local":                 descriptorWithObjectACE(testGetChangesAllGUID, testTrusteeSID),
+			"OU=Workstations,DC=corp,DC=local": descriptorWithObjectACE(testGetChangesAllGUID, testTrusteeSID),
+		},
+	}
+
+	edges := ControlEdgesFromDirectory(dir)
+	if len(edges) != 2 {
+		t.Fatalf("expected 2 edges, got %+v", edges)
+	}
+	if edges[0].Right != "GenericWrite" {
+		t.Fatalf("AddMember should map to GenericWrite, got %q", edges[0].Right)
+	}
+	sync := edges[1]
+	if sync.Right != "DCSync" || sync.TargetDN != "DC=corp,DC=local" || sync.TrusteeDN != "CN=svc_sync,DC=corp,DC=local" {
+		t.Fatalf("unexpected DCSync edge %+v", sync)
+	}
+}
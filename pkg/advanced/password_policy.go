@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
@@ -130,6 +131,27 @@ func (ppa *PasswordPolicyAnalyzer) getDefaultDomainPolicy() (*PasswordPolicyResu
 	return result, nil
 }
 
+// AnalyzeDomainPolicy scores a default domain policy loaded from an offline
+// export (LDIF, ldapdomaindump, ADExplorer snapshot).
+func (ppa *PasswordPolicyAnalyzer) AnalyzeDomainPolicy(policy *ingest.DomainPolicy) *PasswordPolicyResult {
+	result := &PasswordPolicyResult{
+		PolicyType:       "default_domain_policy",
+		Name:             "Default Domain Policy",
+		MinLength:        policy.MinPwdLength,
+		Complexity:       policy.PwdProperties&0x1 != 0,
+		History:          policy.PwdHistoryLength,
+		LockoutDuration:  int(policy.LockoutDuration.Seconds()),
+		LockoutThreshold: policy.LockoutThreshold,
+		MaxAge:           int(policy.MaxPwdAge.Hours() / 24),
+		MinAge:           int(policy.MinPwdAge.Hours() / 24),
+		Recommendations:  []string{},
+	}
+	result.RiskScore = ppa.calculatePolicyRisk(result)
+	result.RiskLevel = ppa.determineRiskLevel(result.RiskScore)
+	result.Recommendations = ppa.generateRecommendations(result)
+	return result
+}
+
 // getFineGrainedPolicies retrieves fine-grained password policies (PSOs)
 func (ppa *PasswordPolicyAnalyzer) getFineGrainedPolicies() ([]*PasswordPolicyResult, error) {
 	searchFilter := "(objectClass=msDS-PasswordSettings)"
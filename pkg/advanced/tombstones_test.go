@@ -0,0 +1,74 @@
+package advanced
+
+import "testing"
+
+func TestClassifyDeletedObjectsAndStaleACEs(t *testing.T) {
+	report := &TombstoneReport{
+		RecycleBinEnabled: true,
+		DeletedObjects: []DeletedObject{
+			{
+				Name:            "old.admin",
+				SamAccountName:  "old.admin",
+				ObjectClass:     "user",
+				ObjectSID:       "S-1-5-21-1-2-3-1107",
+				LastKnownParent: "OU=Admins,DC=corp,DC=local",
+				DeletedAt:       "2026-03-01T10:00:00Z",
+				FormerGroups:    []string{"CN=Domain Admins,CN=Users,DC=corp,DC=local"},
+			},
+			{Name: "WS99", SamAccountName: "WS99$", ObjectClass: "computer", DeletedAt: "2026-01-01T10:00:00Z", Recycled: true},
+			{Name: "Helpdesk", ObjectClass: "group", DeletedAt: "2026-02-01T10:00:00Z"},
+		},
+	}
+	classifyDeletedObjects(report)
+
+	if report.DeletedUsers != 1 || report.DeletedComputers != 1 || report.DeletedGroups != 1 {
+		t.Fatalf("unexpected counts %+v", report)
+	}
+	admin := report.DeletedObjects[0]
+	if admin.SamAccountName != "old.admin" || !admin.Restorable || !admin.Privileged {
+		t.Fatalf("expected newest object to be the restorable admin, got %+v", admin)
+	}
+	if len(report.RestorablePrivileged) != 1 || report.RestorablePrivileged[0] != "old.admin" {
+		t.Fatalf("unexpected restorable privileged list %v", report.RestorablePrivileged)
+	}
+	if ws := report.DeletedObjects[2]; ws.Restorable {
+		t.Fatalf("recycled object must not be restorable: %+v", ws)
+	}
+
+	edges := []ACLControlEdge{
+		{TrusteeSID: "S-1-5-21-1-2-3-1107", TargetDN: "DC=corp,DC=local", Right: "DCSync"},
+		{TrusteeSID: "S-1-5-21-1-2-3-1107", TargetDN: "DC=corp,DC=local", Right: "DCSync"},
+		{TrusteeSID: "S-1-5-21-1-2-3-1200", TargetDN: "CN=AdminSDHolder,CN=System,DC=corp,DC=local", Right: "GenericAll"},
+		{TrusteeSID: "S-1-5-21-1-2-3-512", TrusteeDN: "CN=Domain Admins,CN=Users,DC=corp,DC=local", TargetDN: "DC=corp,DC=local", Right: "GenericAll"},
+		{TrusteeSID: "S-1-5-32-544", TargetDN: "DC=corp,DC=local", Right: "GenericAll"},
+	}
+	stale := FindStaleACEReferences(edges, report.DeletedObjects, "S-1-5-21-1-2-3")
+	if len(stale) != 2 {
+		t.Fatalf("expected two stale references, got %+v", stale)
+	}
+	if stale[0].DeletedObject != "old.admin" || stale[1].DeletedObject != "" {
+		t.Fatalf("unexpected attribution %+v", stale)
+	}
+}
+
+func TestFindStaleACEReferencesScope(t *testing.T) {
+	edges := []ACLControlEdge{
+		{TrusteeSID: "S-1-5-21-1-2-3-1107", TargetDN: "DC=child,DC=corp,DC=local", Right: "DCSync"},
+		// Enterprise Admins of the forest root, seen from a child domain.
+		{TrusteeSID: "S-1-5-21-9-8-7-519", TargetDN: "DC=child,DC=corp,DC=local", Right: "GenericAll"},
+		// A principal of a trusted domain.
+		{TrusteeSID: "S-1-5-21-4-5-6-1105", TargetDN: "CN=AdminSDHolder,CN=System,DC=child,DC=corp,DC=local", Right: "WriteDacl"},
+		// Same prefix, different domain: S-1-5-21-1-2-33.
+		{TrusteeSID: "S-1-5-21-1-2-33-1105", TargetDN: "DC=child,DC=corp,DC=local", Right: "GenericAll"},
+	}
+	stale := FindStaleACEReferences(edges, nil, "S-1-5-21-1-2-3")
+	if len(stale) != 1 || stale[0].SID != "S-1-5-21-1-2-3-1107" {
+		t.Fatalf("expected only the local SID, got %+v", stale)
+	}
+
+	// When the SID index could not be built no domain SID is known, and an
+	// unresolved trustee proves nothing.
+	if stale := FindStaleACEReferences(edges, nil, ""); len(stale) != 0 {
+		t.Fatalf("expected no findings without a SID index, got %+v", stale)
+	}
+}
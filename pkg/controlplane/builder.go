@@ -88,6 +88,10 @@ func mapEdgeToRight(edgeType string) (string, bool) {
 		return "SessionLead", true
 	case "has_trust":
 		return "TrustRelationship", true
+	case "gp_link":
+		return "GPLink", true
+	case "contains":
+		return "Contains", true
 	default:
 		return "", false
 	}
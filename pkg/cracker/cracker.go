@@ -1,10 +1,12 @@
 package cracker
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -22,52 +24,50 @@ func CrackHashes(hashfile, wordlist, attackType string) (map[string]string, erro
 
 // CrackASREP cracks AS-REP hashes using hashcat mode 18200
 func CrackASREP(hashfile, wordlist string) (map[string]string, error) {
-	return crackWithMode(hashfile, wordlist, "18200", "AS-REP")
+	return crackWithMode(context.Background(), hashfile, wordlist, "18200", "AS-REP", NativeOptions{})
 }
 
 // CrackKerberoast cracks Kerberoast hashes using hashcat mode 13100
 func CrackKerberoast(hashfile, wordlist string) (map[string]string, error) {
-	return crackWithMode(hashfile, wordlist, "13100", "Kerberoast")
+	return crackWithMode(context.Background(), hashfile, wordlist, "13100", "Kerberoast", NativeOptions{})
 }
 
-// crackWithMode performs cracking with specific hashcat mode
-func crackWithMode(hashfile, wordlist, mode, attackType string) (map[string]string, error) {
-	// Check for cracking tools
-	crackerPath, crackerType := findCracker()
-	if crackerPath == "" {
-		return nil, fmt.Errorf("no cracking tool found. Please install hashcat or john")
+// CrackMode cracks a file of hashes that all share one hashcat mode, as
+// written from stored krb.HashRecords (13100/19600/19700 for Kerberoast,
+// 18200/32100/32200 for AS-REP). Without hashcat or john the file is
+// cracked natively with opts until ctx is cancelled.
+func CrackMode(ctx context.Context, hashfile, wordlist string, mode int, opts NativeOptions) (map[string]string, error) {
+	attackType := "AS-REP"
+	switch mode {
+	case 13100, 19600, 19700:
+		attackType = "Kerberoast"
+	case 18200, 32100, 32200:
+	default:
+		return nil, fmt.Errorf("unsupported hashcat mode: %d", mode)
 	}
+	return crackWithMode(ctx, hashfile, wordlist, fmt.Sprint(mode), fmt.Sprintf("%s-%d", attackType, mode), opts)
+}
 
+// crackWithMode performs cracking with specific hashcat mode
+func crackWithMode(ctx context.Context, hashfile, wordlist, mode, attackType string, opts NativeOptions) (map[string]string, error) {
 	// Verify hash file exists
 	if _, err := os.Stat(hashfile); err != nil {
 		return nil, fmt.Errorf("hash file not found: %s", hashfile)
 	}
 
-	// Verify wordlist exists - use default if specified doesn't exist
-	if _, err := os.Stat(wordlist); err != nil {
-		fmt.Printf("[!] Wordlist %s not found, trying common locations...\n", wordlist)
-
-		// Try common wordlist locations
-		commonWordlists := []string{
-			"/usr/share/wordlists/rockyou.txt",
-			"/usr/share/dict/words",
-			"/opt/wordlists/rockyou.txt",
-			"C:\\wordlists\\rockyou.txt",
-		}
-
-		found := false
-		for _, w := range commonWordlists {
-			if _, err := os.Stat(w); err == nil {
-				fmt.Printf("[+] Using wordlist: %s\n", w)
-				wordlist = w
-				found = true
-				break
-			}
-		}
+	wordlist, err := resolveWordlist(wordlist)
+	if err != nil {
+		return nil, err
+	}
 
-		if !found {
-			return nil, fmt.Errorf("no wordlist found. Please install rockyou.txt or specify valid wordlist path")
+	// Check for cracking tools
+	crackerPath, crackerType := findCracker()
+	if crackerPath == "" {
+		if m, _ := strconv.Atoi(mode); SupportsNative(m) {
+			fmt.Printf("[*] hashcat and john not found, using the native %s engine\n", attackType)
+			return crackFileNative(ctx, hashfile, wordlist, opts)
 		}
+		return nil, fmt.Errorf("no cracking tool found for mode %s. Please install hashcat or john", mode)
 	}
 
 	// Create results directory
@@ -103,14 +103,13 @@ func crackWithMode(hashfile, wordlist, mode, attackType string) (map[string]stri
 			hashfile,
 			wordlist,
 			"--potfile-path", potFile,
-			"--force",  // Force run
 			"--quiet",  // Quiet mode
 			"--status", // Show status
 		)
 	case "john":
 		// John the Ripper command
 		johnFormat := "krb5asrep"
-		if attackType == "Kerberoast" {
+		if strings.HasPrefix(attackType, "Kerberoast") {
 			johnFormat = "krb5tgs"
 		}
 		cmd = exec.Command(crackerPath,
@@ -167,6 +166,45 @@ func crackWithMode(hashfile, wordlist, mode, attackType string) (map[string]stri
 	return results, nil
 }
 
+// resolveWordlist returns wordlist, or the first common wordlist found
+// when it does not exist.
+func resolveWordlist(wordlist string) (string, error) {
+	if _, err := os.Stat(wordlist); err == nil {
+		return wordlist, nil
+	}
+	fmt.Printf("[!] Wordlist %s not found, trying common locations...\n", wordlist)
+
+	// Try common wordlist locations
+	commonWordlists := []string{
+		"/usr/share/wordlists/rockyou.txt",
+		"/usr/share/dict/words",
+		"/opt/wordlists/rockyou.txt",
+		"C:\\wordlists\\rockyou.txt",
+	}
+	for _, w := range commonWordlists {
+		if _, err := os.Stat(w); err == nil {
+			fmt.Printf("[+] Using wordlist: %s\n", w)
+			return w, nil
+		}
+	}
+	return "", fmt.Errorf("no wordlist found. Please install rockyou.txt or specify valid wordlist path")
+}
+
+// crackFileNative cracks a hash file with CrackNative and returns hash to
+// password, like a hashcat pot.
+func crackFileNative(ctx context.Context, hashfile, wordlist string, opts NativeOptions) (map[string]string, error) {
+	data, err := os.ReadFile(hashfile)
+	if err != nil {
+		return nil, err
+	}
+	cracked, err := CrackNative(ctx, strings.Split(string(data), "\n"), wordlist, opts)
+	results := make(map[string]string, len(cracked))
+	for _, c := range cracked {
+		results[c.Hash] = c.Password
+	}
+	return results, err
+}
+
 // InvokeCracker is the legacy function for backward compatibility
 func InvokeCracker(hashfile, wordlist string) error {
 	// Determine attack type based on file name
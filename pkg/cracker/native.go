@@ -0,0 +1,503 @@
+package cracker
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+// NativeModes are the hashcat modes the built-in engine cracks: RC4 and
+// AES Kerberoast and AS-REP hashes, and MS-SNTP (timeroast) MACs.
+var NativeModes = []int{13100, 18200, 19600, 19700, 31300, 32100, 32200}
+
+// SupportsNative reports whether the built-in engine cracks mode.
+func SupportsNative(mode int) bool {
+	for _, m := range NativeModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Cracked is a hash the engine recovered the password for. User and Realm
+// are empty for a $sntp-ms$ hash, which only the RID it was requested for
+// ties to an account.
+type Cracked struct {
+	Hash     string `json:"hash"`
+	User     string `json:"user"`
+	Realm    string `json:"realm,omitempty"`
+	Password string `json:"password"`
+}
+
+// Progress is a snapshot of a running crack.
+type Progress struct {
+	Words   int64         // wordlist entries tried, including those a resume skipped
+	Offset  int64         // wordlist bytes done
+	Size    int64         // wordlist size
+	Cracked int           // hashes cracked so far
+	Hashes  int           // hashes being cracked
+	Rate    float64       // entries per second in this session
+	Elapsed time.Duration // time spent in this session
+}
+
+// Percent is how much of the wordlist is done.
+func (p Progress) Percent() float64 {
+	if p.Size <= 0 {
+		return 0
+	}
+	return 100 * float64(p.Offset) / float64(p.Size)
+}
+
+// NativeOptions controls CrackNative.
+type NativeOptions struct {
+	// Workers is the number of cracking goroutines, default one per CPU.
+	Workers int
+	// StateFile, when set, is where progress is saved every
+	// ProgressInterval and on exit. A later run with the same hashes and
+	// wordlist continues from it instead of starting over.
+	StateFile string
+	// Progress is called every ProgressInterval (default 10s) and once at
+	// the end.
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+}
+
+// nativeBatch is the number of wordlist entries a worker takes at a time.
+const nativeBatch = 512
+
+// nativeTarget is one hash being cracked.
+type nativeTarget struct {
+	hash string
+	h    krb.RoastHash
+	sntp *krb.SNTPHash // set for a $sntp-ms$ hash, which leaves h empty
+	done atomic.Bool
+}
+
+// opens reports whether k is the target's key.
+func (t *nativeTarget) opens(k types.EncryptionKey) bool {
+	if t.sntp != nil {
+		return t.sntp.Verify(k.KeyValue)
+	}
+	return t.h.Verify(k)
+}
+
+// keyGroup holds the targets one derived key is tried against: all RC4 and
+// MS-SNTP hashes share the unsalted NT hash, AES hashes share a key per
+// etype and salt, so PBKDF2 runs once per word for each account.
+type keyGroup struct {
+	etype   int32
+	salt    string
+	targets []*nativeTarget
+}
+
+// nativeState is the resume file.
+type nativeState struct {
+	Wordlist string    `json:"wordlist"`
+	Size     int64     `json:"size"`
+	Hashes   string    `json:"hashes"` // digest of the sorted hash set
+	Offset   int64     `json:"offset"`
+	Words    int64     `json:"words"`
+	Cracked  []Cracked `json:"cracked,omitempty"`
+	Updated  time.Time `json:"updated"`
+}
+
+type batch struct {
+	seq   int64
+	end   int64 // wordlist offset after the batch
+	words []string
+}
+
+// CrackNative runs a dictionary attack on Kerberoast, AS-REP and timeroast
+// hashes on all CPU cores, without hashcat or john. The wordlist is
+// streamed, one entry per line. Every candidate password is checked by
+// deriving the key and decrypting the captured ciphertext (or recomputing
+// the MS-SNTP MAC), so a result is never a false positive. When ctx is cancelled the state is saved and what was cracked
+// so far is returned with ctx's error.
+func CrackNative(ctx context.Context, hashes []string, wordlist string, opts NativeOptions) ([]Cracked, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = 10 * time.Second
+	}
+
+	groups, targets := nativeTargets(hashes)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no hash the native engine can crack")
+	}
+
+	f, err := os.Open(wordlist)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	state := nativeState{Wordlist: wordlist, Size: info.Size(), Hashes: hashSetDigest(targets)}
+	if opts.StateFile != "" {
+		if prev, err := loadNativeState(opts.StateFile); err == nil {
+			if prev.Size == state.Size && prev.Hashes == state.Hashes {
+				state = prev
+				fmt.Printf("[*] Resuming at entry %d (%.1f%%) with %d already cracked\n",
+					state.Words, 100*float64(state.Offset)/float64(max(state.Size, 1)), len(state.Cracked))
+			} else {
+				fmt.Printf("[!] %s is for a different wordlist or hash set, starting over\n", opts.StateFile)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	cracked := append([]Cracked(nil), state.Cracked...)
+	remaining := int64(len(targets))
+	for _, c := range cracked {
+		for _, t := range targets {
+			if t.hash == c.Hash && !t.done.Swap(true) {
+				remaining--
+			}
+		}
+	}
+	if _, err := f.Seek(state.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	left := atomic.Int64{}
+	left.Store(remaining)
+	if remaining == 0 {
+		cancel()
+	}
+
+	// The committed offset only moves past a batch once it and every batch
+	// before it are done, so a resume never skips an untried entry.
+	var (
+		committed    = state.Offset
+		words        = state.Words
+		nextSeq      int64
+		finished     = make(map[int64]batch)
+		sessionWords atomic.Int64
+		start        = time.Now()
+	)
+	commit := func(b batch) {
+		mu.Lock()
+		defer mu.Unlock()
+		finished[b.seq] = b
+		for {
+			d, ok := finished[nextSeq]
+			if !ok {
+				return
+			}
+			delete(finished, nextSeq)
+			committed, words = d.end, words+int64(len(d.words))
+			nextSeq++
+		}
+	}
+	snapshot := func() (nativeState, Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		s := state
+		s.Offset, s.Words, s.Updated = committed, words, time.Now()
+		s.Cracked = append([]Cracked(nil), cracked...)
+		elapsed := time.Since(start)
+		return s, Progress{
+			Words:   words,
+			Offset:  committed,
+			Size:    state.Size,
+			Cracked: len(cracked),
+			Hashes:  len(targets),
+			Rate:    float64(sessionWords.Load()) / max(elapsed.Seconds(), 1e-9),
+			Elapsed: elapsed,
+		}
+	}
+
+	batches := make(chan batch, opts.Workers*2)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(batches)
+		readErr <- readWordlist(ctx, f, state.Offset, batches)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				for _, word := range b.words {
+					if ctx.Err() != nil {
+						return
+					}
+					for _, c := range tryWord(groups, word) {
+						mu.Lock()
+						cracked = append(cracked, c)
+						mu.Unlock()
+						if c.User != "" {
+							fmt.Printf("[+] CRACKED %s@%s => %s\n", c.User, c.Realm, c.Password)
+						} else {
+							fmt.Printf("[+] CRACKED %.40s... => %s\n", c.Hash, c.Password)
+						}
+						if left.Add(-1) == 0 {
+							cancel()
+						}
+					}
+				}
+				sessionWords.Add(int64(len(b.words)))
+				commit(b)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(opts.ProgressInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-done:
+			break loop
+		case <-ticker.C:
+			s, p := snapshot()
+			if opts.StateFile != "" {
+				if err := saveNativeState(opts.StateFile, s); err != nil {
+					fmt.Printf("[!] Saving crack state: %v\n", err)
+				}
+			}
+			if opts.Progress != nil {
+				opts.Progress(p)
+			}
+		}
+	}
+
+	err = <-readErr
+	s, p := snapshot()
+	if err == nil && ctx.Err() == nil {
+		// The whole wordlist was tried.
+		s.Offset, p.Offset = s.Size, s.Size
+	}
+	if opts.StateFile != "" {
+		if serr := saveNativeState(opts.StateFile, s); serr != nil {
+			fmt.Printf("[!] Saving crack state: %v\n", serr)
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress(p)
+	}
+	if err != nil {
+		return s.Cracked, err
+	}
+	if left.Load() > 0 {
+		// Cancelled from outside, not because everything was cracked.
+		return s.Cracked, ctx.Err()
+	}
+	return s.Cracked, nil
+}
+
+// UserPasswords maps each cracked account to its password.
+func UserPasswords(cracked []Cracked) map[string]string {
+	passwords := make(map[string]string, len(cracked))
+	for _, c := range cracked {
+		if c.User != "" {
+			passwords[c.User] = c.Password
+		}
+	}
+	return passwords
+}
+
+// ApplyPasswords writes cracked passwords back into the candidates they
+// belong to, matching accounts case-insensitively. Each password is
+// verified against the candidate's own hashes before it is stored, and the
+// candidate is then marked validated. It returns how many were applied.
+func ApplyPasswords(candidates []krb.Candidate, passwords map[string]string) int {
+	byUser := make(map[string]string, len(passwords))
+	for user, password := range passwords {
+		byUser[strings.ToLower(user)] = password
+	}
+	applied := 0
+	for i := range candidates {
+		c := &candidates[i]
+		password, ok := byUser[strings.ToLower(c.SamAccountName)]
+		if !ok || c.Password != "" {
+			continue
+		}
+		if ok, err := krb.VerifyCandidatePassword(c, password); ok {
+			applied++
+		} else if err != nil {
+			fmt.Printf("[!] %s: %v\n", c.SamAccountName, err)
+		}
+	}
+	return applied
+}
+
+// nativeTargets parses hashes, drops those it cannot crack and groups the
+// rest by the key they need.
+func nativeTargets(hashes []string) ([]*keyGroup, []*nativeTarget) {
+	var groups []*keyGroup
+	var targets []*nativeTarget
+	index := make(map[string]*keyGroup)
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		hash = strings.TrimSpace(hash)
+		if hash == "" || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		t, err := parseNativeTarget(hash)
+		if err != nil {
+			fmt.Printf("[!] Skipping hash %.40s...: %v\n", hash, err)
+			continue
+		}
+		etype, salt := int32(etypeID.RC4_HMAC), ""
+		if t.sntp == nil && t.h.Etype != etypeID.RC4_HMAC {
+			etype, salt = t.h.Etype, t.h.Salt()
+		}
+		id := fmt.Sprintf("%d/%s", etype, salt)
+		g := index[id]
+		if g == nil {
+			g = &keyGroup{etype: etype, salt: salt}
+			index[id] = g
+			groups = append(groups, g)
+		}
+		g.targets = append(g.targets, t)
+		targets = append(targets, t)
+	}
+	return groups, targets
+}
+
+// parseNativeTarget reads a hash the engine can crack.
+func parseNativeTarget(hash string) (*nativeTarget, error) {
+	t := &nativeTarget{hash: hash}
+	if strings.HasPrefix(hash, "$sntp-ms$") {
+		h, err := krb.ParseSNTPHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		t.sntp = &h
+		return t, nil
+	}
+	h, err := krb.ParseRoastHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.Key(""); err != nil {
+		return nil, fmt.Errorf("%s hash for %s: %v", h.Type, h.User, err)
+	}
+	t.h = h
+	return t, nil
+}
+
+// tryWord derives word's key for every group with hashes left and returns
+// the hashes it opens.
+func tryWord(groups []*keyGroup, word string) []Cracked {
+	var found []Cracked
+	for _, g := range groups {
+		var pending []*nativeTarget
+		for _, t := range g.targets {
+			if !t.done.Load() {
+				pending = append(pending, t)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		k, err := krb.StringToKey(g.etype, word, g.salt)
+		if err != nil {
+			continue
+		}
+		for _, t := range pending {
+			if t.opens(k) && !t.done.Swap(true) {
+				found = append(found, Cracked{Hash: t.hash, User: t.h.User, Realm: t.h.Realm, Password: word})
+			}
+		}
+	}
+	return found
+}
+
+// readWordlist sends the wordlist from offset on in batches. Line endings
+// (\n or \r\n) are stripped; empty lines are kept, an empty password is a
+// candidate too.
+func readWordlist(ctx context.Context, r io.Reader, offset int64, out chan<- batch) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var seq int64
+	b := batch{words: make([]string, 0, nativeBatch)}
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			offset += int64(len(line))
+			b.words = append(b.words, strings.TrimRight(line, "\r\n"))
+		}
+		if len(b.words) == nativeBatch || (err != nil && len(b.words) > 0) {
+			b.seq, b.end = seq, offset
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return nil
+			}
+			seq++
+			b = batch{words: make([]string, 0, nativeBatch)}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func hashSetDigest(targets []*nativeTarget) string {
+	hashes := make([]string, len(targets))
+	for i, t := range targets {
+		hashes[i] = t.hash
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func loadNativeState(path string) (nativeState, error) {
+	var s nativeState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// saveNativeState writes the state through a temporary file so an
+// interrupted save never leaves a truncated one behind.
+func saveNativeState(path string, s nativeState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,165 @@
+package cracker
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+// roast encrypts a dummy enc-part under the account's key and formats it
+// with krb's Kerberoast (13100/19600/19700) and AS-REP (18200) formatters.
+func roast(t *testing.T, asrep bool, etype int32, user, password string) string {
+	t.Helper()
+	keys, err := krb.DeriveKeys("corp.local", user, password, etype)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := uint32(keyusage.KDC_REP_TICKET)
+	if asrep {
+		usage = keyusage.AS_REP_ENCPART
+	}
+	ed, err := crypto.GetEncryptedData([]byte(strings.Repeat("enc-part ", 8)), keys[0], usage, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asrep {
+		return krb.ASREPHash(user, "corp.local", ed)
+	}
+	return krb.KerberoastHash(user, "CORP.LOCAL", "MSSQLSvc/sql01", ed)
+}
+
+// sntpHash signs an NTP header with the computer password's NT hash, as
+// a DC answering an MS-SNTP request does (31300).
+func sntpHash(t *testing.T, password string) string {
+	t.Helper()
+	key, err := krb.StringToKey(etypeID.RC4_HMAC, password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []byte(strings.Repeat("ntp header ", 5)[:48])
+	mac := md5.Sum(append(key.KeyValue, header...))
+	return fmt.Sprintf("$sntp-ms$%x$%x", mac, header)
+}
+
+func writeWordlist(t *testing.T, words ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(words, "\r\n")+"\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCrackNative(t *testing.T) {
+	hashes := []string{
+		roast(t, false, etypeID.RC4_HMAC, "svc_sql", "Summer2026!"),
+		roast(t, false, etypeID.AES256_CTS_HMAC_SHA1_96, "SQL01$", "Summer2026!"),
+		roast(t, true, etypeID.RC4_HMAC, "alice", "Winter1"),
+		roast(t, true, etypeID.RC4_HMAC, "svc_web", "not in the list"),
+		sntpHash(t, "Winter1"),
+		"$sntp-ms$00$00", // malformed, skipped
+	}
+	var words []string
+	for i := 0; i < 600; i++ {
+		words = append(words, fmt.Sprintf("guess%d", i))
+	}
+	words = append(words, "Winter1", "Summer2026!", "")
+	wordlist := writeWordlist(t, words...)
+
+	var last Progress
+	cracked, err := CrackNative(context.Background(), hashes, wordlist, NativeOptions{
+		Workers:  4,
+		Progress: func(p Progress) { last = p },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := UserPasswords(cracked)
+	want := map[string]string{"svc_sql": "Summer2026!", "SQL01$": "Summer2026!", "alice": "Winter1"}
+	if len(got) != len(want) {
+		t.Fatalf("cracked %v, want %v", got, want)
+	}
+	for user, password := range want {
+		if got[user] != password {
+			t.Errorf("%s: got %q, want %q", user, got[user], password)
+		}
+	}
+	if sntp := hashes[4]; !slices.ContainsFunc(cracked, func(c Cracked) bool { return c.Hash == sntp && c.Password == "Winter1" }) {
+		t.Errorf("MS-SNTP hash not cracked: %+v", cracked)
+	}
+	if last.Words != int64(len(words)) || last.Percent() != 100 || last.Cracked != 4 || last.Hashes != 5 {
+		t.Errorf("final progress %+v", last)
+	}
+}
+
+func TestCrackNativeResume(t *testing.T) {
+	hashes := []string{
+		roast(t, false, etypeID.RC4_HMAC, "svc_sql", "Summer2026!"),
+		roast(t, true, etypeID.RC4_HMAC, "alice", "not in the list"),
+	}
+	wordlist := writeWordlist(t, "a", "Summer2026!", "b")
+	state := filepath.Join(t.TempDir(), "crack.state")
+
+	// A cancelled run saves nothing past what it tried.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CrackNative(ctx, hashes, wordlist, NativeOptions{StateFile: state}); err == nil {
+		t.Error("cancelled run returned no error")
+	}
+	s, err := loadNativeState(state)
+	if err != nil || s.Offset != 0 {
+		t.Fatalf("state after cancel: %+v %v", s, err)
+	}
+
+	if _, err := CrackNative(context.Background(), hashes, wordlist, NativeOptions{StateFile: state}); err != nil {
+		t.Fatal(err)
+	}
+	s, err = loadNativeState(state)
+	if err != nil || s.Offset != s.Size || s.Words != 3 || len(s.Cracked) != 1 {
+		data, _ := json.Marshal(s)
+		t.Fatalf("state after the full list: %s %v", data, err)
+	}
+
+	// The rerun has nothing left to try but still reports what was found.
+	var last Progress
+	cracked, err := CrackNative(context.Background(), hashes, wordlist, NativeOptions{StateFile: state, Progress: func(p Progress) { last = p }})
+	if err != nil || len(cracked) != 1 || cracked[0].User != "svc_sql" || cracked[0].Password != "Summer2026!" {
+		t.Errorf("resumed: %+v %v", cracked, err)
+	}
+	if last.Words != 3 || last.Cracked != 1 {
+		t.Errorf("resumed progress %+v", last)
+	}
+
+	// A different hash set starts over.
+	other := append(hashes, roast(t, true, etypeID.RC4_HMAC, "bob", "b"))
+	cracked, err = CrackNative(context.Background(), other, wordlist, NativeOptions{StateFile: state})
+	if err != nil || len(UserPasswords(cracked)) != 2 {
+		t.Errorf("new hash set: %+v %v", cracked, err)
+	}
+}
+
+func TestApplyPasswords(t *testing.T) {
+	hash := roast(t, false, etypeID.AES256_CTS_HMAC_SHA1_96, "svc_sql", "Summer2026!")
+	candidates := []krb.Candidate{
+		{SamAccountName: "SVC_SQL", Type: "KERBEROAST", Hash: hash, Hashes: []krb.HashRecord{{SPN: "MSSQLSvc/sql01", Hash: hash}}},
+		{SamAccountName: "alice", Type: "ASREP"},
+	}
+	n := ApplyPasswords(candidates, map[string]string{"svc_sql": "Summer2026!", "alice": "wrong"})
+	if n != 1 || candidates[0].Password != "Summer2026!" || candidates[0].Validation != krb.StatusValidated {
+		t.Errorf("applied %d: %+v", n, candidates[0])
+	}
+	if candidates[1].Password != "" || candidates[1].Validation != "" {
+		t.Errorf("unverifiable password applied: %+v", candidates[1])
+	}
+}
@@ -0,0 +1,195 @@
+// Package discovery finds the AD services a target exposes and fingerprints
+// each one that answers: TLS certificates, SMB dialects and signing, WinRM
+// authentication schemes and the RDP NLA requirement.
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ports are the services probed by Discover.
+var Ports = map[int]string{
+	88:   "Kerberos",
+	135:  "RPC",
+	389:  "LDAP",
+	445:  "SMB",
+	464:  "kpasswd",
+	636:  "LDAPS",
+	3268: "GC",
+	3269: "GC_SSL",
+	3389: "RDP",
+	5985: "WinRM",
+	5986: "WinRM_SSL",
+	9389: "ADWS",
+}
+
+// Options tunes Discover. Zero values use the defaults.
+type Options struct {
+	ConnectTimeout time.Duration // TCP connect, default 1s
+	ProbeTimeout   time.Duration // whole fingerprint exchange, default 3s
+	Ports          map[int]string
+}
+
+// Service is one open port and whatever its fingerprint revealed.
+type Service struct {
+	Name  string       `json:"name"`
+	Port  int          `json:"port"`
+	TLS   *Certificate `json:"tls,omitempty"`
+	SMB   *SMBInfo     `json:"smb,omitempty"`
+	HTTP  *HTTPInfo    `json:"http,omitempty"`
+	RDP   *RDPInfo     `json:"rdp,omitempty"`
+	Error string       `json:"error,omitempty"` // fingerprint failure; the port is still open
+}
+
+// String renders the service the way earlier runs recorded it ("SMB:445").
+func (s Service) String() string {
+	return fmt.Sprintf("%s:%d", s.Name, s.Port)
+}
+
+// Certificate is the leaf certificate a TLS service presented.
+type Certificate struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	SANs       []string  `json:"sans,omitempty"`
+	NotAfter   time.Time `json:"not_after"`
+	Expired    bool      `json:"expired"`
+	SelfSigned bool      `json:"self_signed"`
+}
+
+// SMBInfo is what SMB negotiation revealed.
+type SMBInfo struct {
+	Dialects        []string `json:"dialects"`
+	SMB1            bool     `json:"smb1"`
+	SigningRequired bool     `json:"signing_required"`
+}
+
+// HTTPInfo is the unauthenticated response of an HTTP service (WinRM).
+type HTTPInfo struct {
+	Status      int      `json:"status"`
+	AuthSchemes []string `json:"auth_schemes,omitempty"`
+	Server      string   `json:"server,omitempty"`
+}
+
+// RDPInfo is the outcome of RDP security negotiation.
+type RDPInfo struct {
+	NLARequired bool   `json:"nla_required"`
+	Protocol    string `json:"protocol,omitempty"` // selected when SSL/Hybrid is offered
+}
+
+// Discover connects to every port concurrently and fingerprints the ones
+// that accept. Services are returned in port order.
+func Discover(target string, opts Options) []Service {
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = time.Second
+	}
+	if opts.ProbeTimeout == 0 {
+		opts.ProbeTimeout = 3 * time.Second
+	}
+	ports := opts.Ports
+	if ports == nil {
+		ports = Ports
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		services []Service
+	)
+	for port, name := range ports {
+		wg.Add(1)
+		go func(port int, name string) {
+			defer wg.Done()
+			address := net.JoinHostPort(target, strconv.Itoa(port))
+			conn, err := net.DialTimeout("tcp", address, opts.ConnectTimeout)
+			if err != nil {
+				return
+			}
+			conn.Close()
+
+			svc := Service{Name: name, Port: port}
+			fingerprint(&svc, address, opts.ProbeTimeout)
+			mu.Lock()
+			services = append(services, svc)
+			mu.Unlock()
+		}(port, name)
+	}
+	wg.Wait()
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Port < services[j].Port })
+	return services
+}
+
+// fingerprint runs the probe matching the service name.
+func fingerprint(svc *Service, address string, timeout time.Duration) {
+	var err error
+	switch svc.Name {
+	case "LDAPS", "GC_SSL":
+		svc.TLS, err = ProbeTLS(address, timeout)
+	case "SMB":
+		svc.SMB, err = ProbeSMB(address, timeout)
+	case "WinRM":
+		svc.HTTP, err = ProbeWinRM("http://"+address, timeout)
+	case "WinRM_SSL":
+		svc.HTTP, err = ProbeWinRM("https://"+address, timeout)
+		if err == nil {
+			svc.TLS, err = ProbeTLS(address, timeout)
+		}
+	case "RDP":
+		svc.RDP, svc.TLS, err = ProbeRDP(address, timeout)
+	}
+	if err != nil {
+		svc.Error = err.Error()
+	}
+}
+
+// Names returns the services as "Name:port" strings.
+func Names(services []Service) []string {
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, s.String())
+	}
+	return names
+}
+
+// Properties flattens a fingerprint into graph node properties.
+func (s Service) Properties() map[string]interface{} {
+	props := map[string]interface{}{
+		"raw":  s.String(),
+		"port": s.Port,
+	}
+	if s.TLS != nil {
+		props["tls_subject"] = s.TLS.Subject
+		props["tls_issuer"] = s.TLS.Issuer
+		props["tls_sans"] = s.TLS.SANs
+		props["tls_not_after"] = s.TLS.NotAfter.Format(time.RFC3339)
+		props["tls_expired"] = s.TLS.Expired
+		props["tls_self_signed"] = s.TLS.SelfSigned
+	}
+	if s.SMB != nil {
+		props["smb_dialects"] = s.SMB.Dialects
+		props["smb1"] = s.SMB.SMB1
+		props["smb_signing_required"] = s.SMB.SigningRequired
+	}
+	if s.HTTP != nil {
+		props["http_status"] = s.HTTP.Status
+		props["http_auth"] = s.HTTP.AuthSchemes
+		if s.HTTP.Server != "" {
+			props["http_server"] = s.HTTP.Server
+		}
+	}
+	if s.RDP != nil {
+		props["rdp_nla_required"] = s.RDP.NLARequired
+		if s.RDP.Protocol != "" {
+			props["rdp_protocol"] = s.RDP.Protocol
+		}
+	}
+	if s.Error != "" {
+		props["fingerprint_error"] = s.Error
+	}
+	return props
+}
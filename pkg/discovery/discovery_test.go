@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serve accepts connections on a local port and hands each to handle.
+func serve(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// fakeSMB accepts SMB 2.1 and 3.1.1 with signing required and drops SMB1
+// and every other dialect, like a hardened DC.
+func fakeSMB(conn net.Conn) {
+	req, err := readNetBIOS(conn)
+	if err != nil || len(req) < 64+38 || req[0] != 0xfe {
+		return
+	}
+	dialect := binary.LittleEndian.Uint16(req[64+36:])
+	if dialect != 0x0210 && dialect != 0x0311 {
+		return
+	}
+	resp := make([]byte, 64+65)
+	copy(resp, "\xfeSMB")
+	binary.LittleEndian.PutUint16(resp[64:], 65)
+	binary.LittleEndian.PutUint16(resp[66:], 0x3)
+	binary.LittleEndian.PutUint16(resp[68:], dialect)
+	conn.Write(netbios(resp))
+}
+
+func TestProbeSMBListsDialectsAndSigning(t *testing.T) {
+	info, err := ProbeSMB(serve(t, fakeSMB), time.Second)
+	if err != nil {
+		t.Fatalf("ProbeSMB: %v", err)
+	}
+	if len(info.Dialects) != 2 || info.Dialects[0] != "2.1" || info.Dialects[1] != "3.1.1" {
+		t.Fatalf("unexpected dialects %v", info.Dialects)
+	}
+	if !info.SigningRequired || info.SMB1 {
+		t.Fatalf("unexpected SMB info %+v", info)
+	}
+}
+
+func TestProbeRDPDetectsNLARequirement(t *testing.T) {
+	addr := serve(t, func(conn net.Conn) {
+		buf := make([]byte, 19)
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+		// Connection Confirm with RDP_NEG_FAILURE HYBRID_REQUIRED_BY_SERVER.
+		conn.Write([]byte{3, 0, 0, 19, 14, 0xd0, 0, 0, 0x12, 0x34, 0, 0x03, 0, 8, 0, 5, 0, 0, 0})
+	})
+	info, _, err := ProbeRDP(addr, time.Second)
+	if err != nil {
+		t.Fatalf("ProbeRDP: %v", err)
+	}
+	if !info.NLARequired {
+		t.Fatal("HYBRID_REQUIRED_BY_SERVER should mark NLA as required")
+	}
+}
+
+func TestProbeWinRMCollectsAuthSchemes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wsman" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Add("WWW-Authenticate", "Negotiate")
+		w.Header().Add("WWW-Authenticate", `Basic realm="WSMAN"`)
+		w.Header().Set("Server", "Microsoft-HTTPAPI/2.0")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	info, err := ProbeWinRM(srv.URL, time.Second)
+	if err != nil {
+		t.Fatalf("ProbeWinRM: %v", err)
+	}
+	if info.Status != http.StatusUnauthorized || len(info.AuthSchemes) != 2 || info.AuthSchemes[0] != "Basic" || info.AuthSchemes[1] != "Negotiate" {
+		t.Fatalf("unexpected WinRM info %+v", info)
+	}
+}
+
+func TestDiscoverFingerprintsTLSServices(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	services := Discover("127.0.0.1", Options{Ports: map[int]string{port: "LDAPS"}})
+	if len(services) != 1 || services[0].TLS == nil {
+		t.Fatalf("expected a fingerprinted LDAPS service, got %+v", services)
+	}
+	cert := services[0].TLS
+	if cert.Expired || cert.NotAfter.IsZero() || len(cert.SANs) == 0 {
+		t.Fatalf("unexpected certificate %+v", cert)
+	}
+	if props := services[0].Properties(); props["tls_subject"] != cert.Subject || props["port"] != port {
+		t.Fatalf("certificate not flattened into properties: %+v", props)
+	}
+}
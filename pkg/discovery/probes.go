@@ -0,0 +1,366 @@
+package discovery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeTLS completes a TLS handshake without verification and describes the
+// leaf certificate.
+func ProbeTLS(address string, timeout time.Duration) (*Certificate, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return certificateFromState(conn.ConnectionState())
+}
+
+func certificateFromState(state tls.ConnectionState) (*Certificate, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+	return describeCertificate(state.PeerCertificates[0]), nil
+}
+
+func describeCertificate(cert *x509.Certificate) *Certificate {
+	info := &Certificate{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		NotAfter:   cert.NotAfter.UTC(),
+		Expired:    time.Now().After(cert.NotAfter),
+		SelfSigned: bytes.Equal(cert.RawSubject, cert.RawIssuer),
+	}
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	return info
+}
+
+// smbDialects are the SMB2/3 dialects offered one at a time, so the result
+// lists everything the server accepts rather than only the highest.
+var smbDialects = []struct {
+	code uint16
+	name string
+}{
+	{0x0202, "2.0.2"},
+	{0x0210, "2.1"},
+	{0x0300, "3.0"},
+	{0x0302, "3.0.2"},
+	{0x0311, "3.1.1"},
+}
+
+// ProbeSMB negotiates each dialect (and SMB1) separately. The signing
+// requirement comes from the SecurityMode of the negotiate response.
+func ProbeSMB(address string, timeout time.Duration) (*SMBInfo, error) {
+	type result struct {
+		ok      bool
+		signing bool
+		err     error
+	}
+	results := make([]result, len(smbDialects))
+	var wg sync.WaitGroup
+	for i, d := range smbDialects {
+		wg.Add(1)
+		go func(i int, dialect uint16) {
+			defer wg.Done()
+			var r result
+			r.ok, r.signing, r.err = negotiateSMB2(address, dialect, timeout)
+			results[i] = r
+		}(i, d.code)
+	}
+	smb1 := negotiateSMB1(address, timeout)
+	wg.Wait()
+
+	info := &SMBInfo{SMB1: smb1}
+	var lastErr error
+	for i, r := range results {
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if r.ok {
+			info.Dialects = append(info.Dialects, smbDialects[i].name)
+			info.SigningRequired = info.SigningRequired || r.signing
+		}
+	}
+	if len(info.Dialects) == 0 && !smb1 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no SMB dialect accepted")
+		}
+		return nil, lastErr
+	}
+	return info, nil
+}
+
+// negotiateSMB2 sends an SMB2 NEGOTIATE offering a single dialect
+// (MS-SMB2 2.2.3). 3.1.1 must carry a preauth integrity context.
+func negotiateSMB2(address string, dialect uint16, timeout time.Duration) (accepted, signingRequired bool, err error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return false, false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(netbios(smb2NegotiateRequest(dialect))); err != nil {
+		return false, false, err
+	}
+	resp, err := readNetBIOS(conn)
+	if err != nil {
+		// Servers drop the connection when no offered dialect is acceptable.
+		return false, false, nil
+	}
+	return parseSMB2NegotiateResponse(resp, dialect)
+}
+
+func smb2NegotiateRequest(dialect uint16) []byte {
+	header := make([]byte, 64)
+	copy(header, "\xfeSMB")
+	binary.LittleEndian.PutUint16(header[4:], 64) // StructureSize
+	binary.LittleEndian.PutUint16(header[14:], 1) // CreditRequest
+
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)  // StructureSize
+	binary.LittleEndian.PutUint16(body[2:], 1)   // DialectCount
+	binary.LittleEndian.PutUint16(body[4:], 0x1) // SecurityMode: signing enabled
+	rand.Read(body[12:28])                       // ClientGuid
+	body = binary.LittleEndian.AppendUint16(body, dialect)
+
+	if dialect == 0x0311 {
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+		binary.LittleEndian.PutUint32(body[28:], uint32(64+len(body))) // NegotiateContextOffset
+		binary.LittleEndian.PutUint16(body[32:], 1)                    // NegotiateContextCount
+
+		// SMB2_PREAUTH_INTEGRITY_CAPABILITIES with SHA-512 and a 32-byte salt.
+		ctx := make([]byte, 8+6+32)
+		binary.LittleEndian.PutUint16(ctx[0:], 0x0001)
+		binary.LittleEndian.PutUint16(ctx[2:], 6+32)
+		binary.LittleEndian.PutUint16(ctx[8:], 1)
+		binary.LittleEndian.PutUint16(ctx[10:], 32)
+		binary.LittleEndian.PutUint16(ctx[12:], 0x0001)
+		rand.Read(ctx[14:])
+		body = append(body, ctx...)
+	}
+	return append(header, body...)
+}
+
+// parseSMB2NegotiateResponse reads SecurityMode and DialectRevision from an
+// SMB2 NEGOTIATE response (MS-SMB2 2.2.4).
+func parseSMB2NegotiateResponse(resp []byte, dialect uint16) (accepted, signingRequired bool, err error) {
+	if len(resp) < 64+6 || !bytes.HasPrefix(resp, []byte("\xfeSMB")) {
+		return false, false, fmt.Errorf("not an SMB2 response")
+	}
+	if status := binary.LittleEndian.Uint32(resp[8:12]); status != 0 {
+		return false, false, nil
+	}
+	body := resp[64:]
+	securityMode := binary.LittleEndian.Uint16(body[2:4])
+	revision := binary.LittleEndian.Uint16(body[4:6])
+	return revision == dialect, securityMode&0x2 != 0, nil
+}
+
+// negotiateSMB1 offers only "NT LM 0.12" and reports whether the server
+// answered with an SMB1 header, i.e. SMBv1 is still enabled.
+func negotiateSMB1(address string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	header := make([]byte, 32)
+	copy(header, "\xffSMB")
+	header[4] = 0x72 // SMB_COM_NEGOTIATE
+	header[9] = 0x18 // Flags: case-insensitive, canonicalized paths
+	binary.LittleEndian.PutUint16(header[10:], 0xc801)
+	dialects := []byte("\x02NT LM 0.12\x00")
+	body := []byte{0} // WordCount
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(dialects)))
+	body = append(body, dialects...)
+
+	if _, err := conn.Write(netbios(append(header, body...))); err != nil {
+		return false
+	}
+	resp, err := readNetBIOS(conn)
+	return err == nil && len(resp) >= 9 && bytes.HasPrefix(resp, []byte("\xffSMB")) && binary.LittleEndian.Uint32(resp[5:9]) == 0
+}
+
+func netbios(msg []byte) []byte {
+	frame := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg))&0xffffff)
+	return append(frame, msg...)
+}
+
+func readNetBIOS(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:]) & 0xffffff
+	if n > 1<<16 {
+		return nil, fmt.Errorf("oversized SMB response (%d bytes)", n)
+	}
+	msg := make([]byte, n)
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+// ProbeWinRM posts an empty request to /wsman and records the schemes offered
+// in WWW-Authenticate.
+func ProbeWinRM(baseURL string, timeout time.Duration) (*HTTPInfo, error) {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/wsman", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	info := &HTTPInfo{Status: resp.StatusCode, Server: resp.Header.Get("Server")}
+	seen := make(map[string]bool)
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		scheme := strings.Fields(value)
+		if len(scheme) == 0 || seen[strings.ToLower(scheme[0])] {
+			continue
+		}
+		seen[strings.ToLower(scheme[0])] = true
+		info.AuthSchemes = append(info.AuthSchemes, scheme[0])
+	}
+	sort.Strings(info.AuthSchemes)
+	return info, nil
+}
+
+// RDP security protocols (MS-RDPBCGR 2.2.1.1.1).
+const (
+	rdpProtocolRDP      = 0x0
+	rdpProtocolSSL      = 0x1
+	rdpProtocolHybrid   = 0x2
+	rdpProtocolHybridEx = 0x8
+
+	// RDP_NEG_FAILURE codes
+	rdpSSLNotAllowed  = 0x2 // SSL_NOT_ALLOWED_BY_SERVER
+	rdpHybridRequired = 0x5 // HYBRID_REQUIRED_BY_SERVER
+)
+
+var rdpProtocolNames = map[uint32]string{
+	rdpProtocolRDP:      "RDP",
+	rdpProtocolSSL:      "SSL",
+	rdpProtocolHybrid:   "HYBRID",
+	rdpProtocolHybridEx: "HYBRID_EX",
+}
+
+// ProbeRDP offers TLS without CredSSP: a server that insists on NLA refuses
+// with HYBRID_REQUIRED_BY_SERVER. Otherwise the TLS session is started to
+// capture the certificate.
+func ProbeRDP(address string, timeout time.Duration) (*RDPInfo, *Certificate, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(rdpConnectionRequest(rdpProtocolSSL)); err != nil {
+		return nil, nil, err
+	}
+	selected, failure, err := readRDPConnectionConfirm(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch failure {
+	case 0:
+	case rdpHybridRequired:
+		return &RDPInfo{NLARequired: true}, nil, nil
+	case rdpSSLNotAllowed:
+		return &RDPInfo{Protocol: rdpProtocolNames[rdpProtocolRDP]}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("RDP negotiation failure code %d", failure)
+	}
+
+	info := &RDPInfo{Protocol: rdpProtocolNames[selected]}
+	if selected != rdpProtocolSSL {
+		return info, nil, nil
+	}
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		return info, nil, err
+	}
+	cert, err := certificateFromState(tlsConn.ConnectionState())
+	return info, cert, err
+}
+
+// rdpConnectionRequest is a TPKT-framed X.224 Connection Request carrying
+// an RDP_NEG_REQ.
+func rdpConnectionRequest(protocols uint32) []byte {
+	x224 := []byte{14, 0xe0, 0, 0, 0, 0, 0} // LI, CR, dst-ref, src-ref, class
+	neg := []byte{0x01, 0, 8, 0}            // TYPE_RDP_NEG_REQ, flags, length
+	neg = binary.LittleEndian.AppendUint32(neg, protocols)
+
+	pdu := []byte{3, 0, 0, 0}
+	pdu = append(append(pdu, x224...), neg...)
+	binary.BigEndian.PutUint16(pdu[2:], uint16(len(pdu)))
+	return pdu
+}
+
+// readRDPConnectionConfirm returns the selected protocol of an RDP_NEG_RSP
+// or the failure code of an RDP_NEG_FAILURE. A confirm without negotiation
+// data means standard RDP security.
+func readRDPConnectionConfirm(r io.Reader) (selected, failure uint32, err error) {
+	var tpkt [4]byte
+	if _, err := io.ReadFull(r, tpkt[:]); err != nil {
+		return 0, 0, err
+	}
+	if tpkt[0] != 3 {
+		return 0, 0, fmt.Errorf("not a TPKT response")
+	}
+	n := int(binary.BigEndian.Uint16(tpkt[2:]))
+	if n < 11 || n > 512 {
+		return 0, 0, fmt.Errorf("unexpected TPKT length %d", n)
+	}
+	pdu := make([]byte, n-4)
+	if _, err := io.ReadFull(r, pdu); err != nil {
+		return 0, 0, err
+	}
+	if pdu[1]&0xf0 != 0xd0 {
+		return 0, 0, fmt.Errorf("not an X.224 Connection Confirm")
+	}
+	if len(pdu) < 7+8 {
+		return rdpProtocolRDP, 0, nil
+	}
+	neg := pdu[7:]
+	value := binary.LittleEndian.Uint32(neg[4:8])
+	switch neg[0] {
+	case 0x02:
+		return value, 0, nil
+	case 0x03:
+		return 0, value, nil
+	}
+	return 0, 0, fmt.Errorf("unexpected RDP negotiation type %d", neg[0])
+}
@@ -0,0 +1,335 @@
+// Package fleet runs the per-host hardening checks against several targets,
+// typically every DC of a domain, and reports where they disagree.
+package fleet
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/discovery"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+// Where a host came from.
+const (
+	SourceTarget = "target"  // -t or --targets
+	SourceDNS    = "dns_srv" // _ldap._tcp.dc._msdcs
+)
+
+// Check values shared by the comparison columns.
+const (
+	Required    = "required"
+	NotRequired = "not_required"
+	Enabled     = "enabled"
+	Disabled    = "disabled"
+	Unknown     = "unknown"
+	NotTested   = "not_tested"
+)
+
+// Host is a host to assess.
+type Host struct {
+	Address string
+	Name    string // DNS name when known (SRV results)
+	Source  string
+}
+
+// Options controls Assess.
+type Options struct {
+	Discovery discovery.Options
+	// LDAP carries the credentials used for the LDAP signing and channel
+	// binding probes.
+	LDAP krb.ConnectOptions
+	// Validated means a bind has already accepted the LDAP credentials.
+	// Until then the signing probe binds as a nonexistent account and the
+	// channel binding probe, which needs a working account, is left for
+	// ProbeChannelBinding: a mistyped password must not cost a failed logon
+	// on every host.
+	Validated bool
+	Workers   int // hosts assessed at once, default 16
+}
+
+// HostReport is the hardening picture of one host.
+type HostReport struct {
+	Host           string                 `json:"host"`
+	Name           string                 `json:"name,omitempty"`
+	Source         string                 `json:"source"`
+	DC             bool                   `json:"dc"`
+	Services       []discovery.Service    `json:"services,omitempty"`
+	SMBSigning     string                 `json:"smb_signing"`
+	SMBDialects    []string               `json:"smb_dialects,omitempty"`
+	SMB1           string                 `json:"smb1"`
+	LDAPSigning    string                 `json:"ldap_signing"`
+	ChannelBinding string                 `json:"ldap_channel_binding"`
+	RDPNLA         string                 `json:"rdp_nla"`
+	Certificate    *discovery.Certificate `json:"ldaps_certificate,omitempty"`
+	Evidence       []string               `json:"evidence,omitempty"` // bind outcomes behind the LDAP verdicts
+	Errors         []string               `json:"errors,omitempty"`
+}
+
+// ServiceNames returns the open services as "Name:port".
+func (h HostReport) ServiceNames() []string {
+	return discovery.Names(h.Services)
+}
+
+// Difference is a check on which the compared hosts disagree.
+type Difference struct {
+	Check  string              `json:"check"`
+	Values map[string][]string `json:"values"` // value -> hosts
+}
+
+// Report is the per-host table and the checks that differ between DCs.
+type Report struct {
+	Hosts       []HostReport `json:"hosts"`
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// Assess checks every host concurrently. Hosts are reported in input order.
+func Assess(hosts []Host, opts Options) *Report {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 16
+	}
+	reports := make([]HostReport, len(hosts))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, h Host) {
+			defer func() { <-sem; wg.Done() }()
+			reports[i] = assessHost(h, opts)
+		}(i, h)
+	}
+	wg.Wait()
+
+	report := &Report{}
+	for _, r := range reports {
+		if len(r.Services) > 0 {
+			report.Hosts = append(report.Hosts, r)
+		}
+	}
+	report.Differences = Compare(report.Hosts)
+	return report
+}
+
+// Add appends hosts assessed later (e.g. DCs found via DNS) and recomputes
+// the differences.
+func (r *Report) Add(more *Report) {
+	if r == nil || more == nil {
+		return
+	}
+	r.Hosts = append(r.Hosts, more.Hosts...)
+	r.Differences = Compare(r.Hosts)
+}
+
+// Has reports whether address is already in the report.
+func (r *Report) Has(address string) bool {
+	if r == nil {
+		return false
+	}
+	for _, h := range r.Hosts {
+		if strings.EqualFold(h.Host, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Primary returns the first DC in the report, falling back to def.
+func (r *Report) Primary(def string) string {
+	if r != nil {
+		for _, h := range r.Hosts {
+			if h.DC {
+				return h.Host
+			}
+		}
+	}
+	return def
+}
+
+// Lookup returns the report for address.
+func (r *Report) Lookup(address string) (HostReport, bool) {
+	if r != nil {
+		for _, h := range r.Hosts {
+			if strings.EqualFold(h.Host, address) {
+				return h, true
+			}
+		}
+	}
+	return HostReport{}, false
+}
+
+func assessHost(h Host, opts Options) HostReport {
+	report := HostReport{
+		Host:           h.Address,
+		Name:           h.Name,
+		Source:         h.Source,
+		SMBSigning:     Unknown,
+		SMB1:           Unknown,
+		LDAPSigning:    NotTested,
+		ChannelBinding: NotTested,
+		RDPNLA:         Unknown,
+	}
+	report.Services = discovery.Discover(h.Address, opts.Discovery)
+
+	open := make(map[string]bool)
+	for _, svc := range report.Services {
+		open[svc.Name] = true
+		if svc.Error != "" {
+			report.Errors = append(report.Errors, svc.String()+": "+svc.Error)
+		}
+		if svc.SMB != nil {
+			report.SMBSigning = verdict(svc.SMB.SigningRequired, Required, NotRequired)
+			report.SMB1 = verdict(svc.SMB.SMB1, Enabled, Disabled)
+			report.SMBDialects = svc.SMB.Dialects
+		}
+		if svc.RDP != nil {
+			report.RDPNLA = verdict(svc.RDP.NLARequired, Required, NotRequired)
+		}
+		if svc.TLS != nil && (svc.Name == "LDAPS" || report.Certificate == nil && svc.Name == "GC_SSL") {
+			report.Certificate = svc.TLS
+		}
+	}
+	report.DC = open["Kerberos"] && (open["LDAP"] || open["LDAPS"])
+
+	if open["LDAP"] {
+		signing, err := krb.ProbeLDAPSigning(h.Address, signingProbeOptions(opts))
+		report.LDAPSigning = signing.Verdict
+		report.Evidence = append(report.Evidence, signing.Evidence...)
+		if err != nil {
+			report.Errors = append(report.Errors, "LDAP signing: "+err.Error())
+		}
+	}
+	if open["LDAPS"] && opts.Validated && hasNTLMCredentials(opts.LDAP) {
+		probeChannelBinding(&report, opts.LDAP)
+	}
+	return report
+}
+
+// signingProbeOptions keeps the credentials for the signing probe only once
+// they are known to work; otherwise the probe binds as the nonexistent
+// probe account, which reads the same strongerAuthRequired answer.
+func signingProbeOptions(opts Options) krb.ConnectOptions {
+	if opts.Validated {
+		return opts.LDAP
+	}
+	return krb.ConnectOptions{Timeout: opts.LDAP.Timeout}
+}
+
+func probeChannelBinding(report *HostReport, opts krb.ConnectOptions) {
+	cbt, err := krb.ProbeChannelBinding(report.Host, opts)
+	report.ChannelBinding = cbt.Verdict
+	report.Evidence = append(report.Evidence, cbt.Evidence...)
+	if err != nil {
+		report.Errors = append(report.Errors, "LDAP channel binding: "+err.Error())
+	}
+}
+
+// ProbeChannelBinding runs the channel binding probe on every host with
+// LDAPS open that has not been probed yet. Call it once a bind has
+// validated opts; the probe logs on with them three times per host.
+func (r *Report) ProbeChannelBinding(opts krb.ConnectOptions) {
+	if r == nil || !hasNTLMCredentials(opts) {
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 16)
+	for i := range r.Hosts {
+		h := &r.Hosts[i]
+		if h.ChannelBinding != NotTested || !hasService(h.Services, "LDAPS") {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			probeChannelBinding(h, opts)
+		}()
+	}
+	wg.Wait()
+	r.Differences = Compare(r.Hosts)
+}
+
+func hasService(services []discovery.Service, name string) bool {
+	for _, svc := range services {
+		if svc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNTLMCredentials reports whether the channel binding probe can run; it
+// needs a real account, unlike the signing probe.
+func hasNTLMCredentials(opts krb.ConnectOptions) bool {
+	return opts.BindUser != "" && (opts.BindPass != "" || opts.NTHash != "")
+}
+
+func verdict(ok bool, yes, no string) string {
+	if ok {
+		return yes
+	}
+	return no
+}
+
+// Compare lists the checks on which DCs (or all hosts when none is a DC)
+// disagree. Unknown and untested values are not counted as disagreement.
+func Compare(hosts []HostReport) []Difference {
+	compared := make([]HostReport, 0, len(hosts))
+	for _, h := range hosts {
+		if h.DC {
+			compared = append(compared, h)
+		}
+	}
+	if len(compared) == 0 {
+		compared = hosts
+	}
+	if len(compared) < 2 {
+		return nil
+	}
+
+	checks := []struct {
+		name  string
+		value func(HostReport) string
+	}{
+		{"smb_signing", func(h HostReport) string { return h.SMBSigning }},
+		{"smb1", func(h HostReport) string { return h.SMB1 }},
+		{"smb_dialects", func(h HostReport) string { return strings.Join(h.SMBDialects, ",") }},
+		{"ldap_signing", func(h HostReport) string { return h.LDAPSigning }},
+		{"ldap_channel_binding", func(h HostReport) string { return h.ChannelBinding }},
+		{"rdp_nla", func(h HostReport) string { return h.RDPNLA }},
+		{"services", func(h HostReport) string { return strings.Join(h.ServiceNames(), ",") }},
+		{"ldaps_certificate_issuer", func(h HostReport) string {
+			if h.Certificate == nil {
+				return ""
+			}
+			return h.Certificate.Issuer
+		}},
+		{"ldaps_certificate_expired", func(h HostReport) string {
+			if h.Certificate == nil {
+				return ""
+			}
+			return verdict(h.Certificate.Expired, "expired", "valid")
+		}},
+	}
+
+	var diffs []Difference
+	for _, check := range checks {
+		values := make(map[string][]string)
+		for _, h := range compared {
+			v := check.value(h)
+			if v == "" || v == Unknown || v == NotTested {
+				continue
+			}
+			values[v] = append(values[v], h.Host)
+		}
+		if len(values) > 1 {
+			for _, hosts := range values {
+				sort.Strings(hosts)
+			}
+			diffs = append(diffs, Difference{Check: check.name, Values: values})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,87 @@
+package fleet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/discovery"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+func TestExpandTargets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(file, []byte("# lab DCs\n10.0.0.10\n\ndc2.corp.local\n10.0.1.0/30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ExpandTargets("10.0.0.10,10.0.0.8/31", file)
+	if err != nil {
+		t.Fatalf("ExpandTargets: %v", err)
+	}
+	want := "10.0.0.10,10.0.0.8,10.0.0.9,dc2.corp.local,10.0.1.1,10.0.1.2"
+	if got := strings.Join(hosts, ","); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if _, err := ExpandTargets("10.0.0.0/8", ""); err == nil {
+		t.Fatal("a /8 should exceed MaxTargets")
+	}
+	if _, err := ExpandTargets("10.0.0.0/33", ""); err == nil {
+		t.Fatal("invalid prefix should fail")
+	}
+}
+
+func TestCompareFlagsInconsistentDCs(t *testing.T) {
+	cert := &discovery.Certificate{Issuer: "CN=corp-CA"}
+	hosts := []HostReport{
+		{Host: "10.0.0.10", DC: true, SMBSigning: Required, SMB1: Disabled, LDAPSigning: Required, ChannelBinding: NotTested, RDPNLA: Required, Certificate: cert},
+		{Host: "10.0.0.11", DC: true, SMBSigning: Required, SMB1: Enabled, LDAPSigning: NotRequired, ChannelBinding: NotTested, RDPNLA: Unknown, Certificate: cert},
+		{Host: "10.0.0.50", DC: false, SMBSigning: NotRequired},
+	}
+	diffs := Compare(hosts)
+
+	got := make(map[string]Difference)
+	for _, d := range diffs {
+		got[d.Check] = d
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected smb1 and ldap_signing to differ, got %+v", diffs)
+	}
+	ldap := got["ldap_signing"]
+	if len(ldap.Values[NotRequired]) != 1 || ldap.Values[NotRequired][0] != "10.0.0.11" {
+		t.Fatalf("unexpected ldap_signing difference %+v", ldap)
+	}
+	if _, ok := got["smb_signing"]; ok {
+		t.Fatal("non-DC hosts should not be compared with DCs")
+	}
+	if _, ok := got["rdp_nla"]; ok {
+		t.Fatal("unknown values are not a disagreement")
+	}
+}
+
+func TestProbesWaitForValidatedCredentials(t *testing.T) {
+	creds := krb.ConnectOptions{BindUser: `CORP\alice`, BindPass: "Typo2026!", Timeout: time.Second}
+
+	// Before a bind has accepted the password the signing probe must not
+	// log on with it.
+	if got := signingProbeOptions(Options{LDAP: creds}); got.BindUser != "" || got.BindPass != "" || got.Timeout != time.Second {
+		t.Fatalf("unvalidated probe options carry credentials: %+v", got)
+	}
+	if got := signingProbeOptions(Options{LDAP: creds, Validated: true}); got.BindUser != creds.BindUser {
+		t.Fatalf("validated probe options lost the credentials: %+v", got)
+	}
+
+	// ProbeChannelBinding only touches hosts with LDAPS that were not probed.
+	report := &Report{Hosts: []HostReport{
+		{Host: "10.0.0.10", DC: true, ChannelBinding: NotTested, Services: []discovery.Service{{Name: "LDAP", Port: 389}}},
+		{Host: "10.0.0.11", DC: true, ChannelBinding: Required, Services: []discovery.Service{{Name: "LDAPS", Port: 636}}},
+	}}
+	report.ProbeChannelBinding(creds)
+	if report.Hosts[0].ChannelBinding != NotTested || report.Hosts[1].ChannelBinding != Required || len(report.Hosts[0].Errors)+len(report.Hosts[1].Errors) != 0 {
+		t.Fatalf("hosts probed unexpectedly: %+v", report.Hosts)
+	}
+	var none *Report
+	none.ProbeChannelBinding(creds)
+}
@@ -0,0 +1,110 @@
+package fleet
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// MaxTargets bounds CIDR expansion so a mistyped prefix cannot start a scan
+// of a whole network.
+const MaxTargets = 4096
+
+// ExpandTargets turns comma-separated hosts and CIDR ranges, plus the lines
+// of an optional targets file, into a de-duplicated host list in the order
+// given. Network and broadcast addresses of IPv4 ranges are skipped.
+func ExpandTargets(spec, file string) ([]string, error) {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		items = append(items, item)
+	}
+	if file != "" {
+		lines, err := readTargetFile(file)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, lines...)
+	}
+
+	seen := make(map[string]bool)
+	var hosts []string
+	add := func(h string) error {
+		if seen[strings.ToLower(h)] {
+			return nil
+		}
+		if len(hosts) >= MaxTargets {
+			return fmt.Errorf("more than %d targets; narrow the ranges", MaxTargets)
+		}
+		seen[strings.ToLower(h)] = true
+		hosts = append(hosts, h)
+		return nil
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if err := add(item); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %v", item, err)
+		}
+		prefix = prefix.Masked()
+		skipEdges := prefix.Addr().Is4() && prefix.Bits() < 31
+		last := lastAddr(prefix)
+		for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+			if !(skipEdges && (addr == prefix.Addr() || addr == last)) {
+				if err := add(addr.String()); err != nil {
+					return nil, err
+				}
+			}
+			if addr == last {
+				break
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// readTargetFile reads one host or CIDR per line; blank lines and lines
+// starting with # are ignored.
+func readTargetFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := range b {
+		hostBits := len(b)*8 - prefix.Bits() - (len(b)-1-i)*8
+		switch {
+		case hostBits >= 8:
+			b[i] = 0xff
+		case hostBits > 0:
+			b[i] |= byte(1<<hostBits - 1)
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
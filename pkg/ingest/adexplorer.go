@@ -0,0 +1,310 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// ADExplorer snapshot layout (Sysinternals AD Explorer "win-ad-ob" format):
+// a fixed header, numObjects object records, then the property (attribute
+// schema) table at the offset given in the header. Each object is a table of
+// (property index, offset) pairs; values live inside the object record.
+const (
+	adExplorerSignature  = "win-ad-ob\x00"
+	adExplorerHeaderSize = 0x43e
+	adExplorerNameChars  = 260
+)
+
+// ADSTYPE values used by the snapshot to tag attribute encodings.
+const (
+	adsTypeDNString           = 1
+	adsTypeCaseExactString    = 2
+	adsTypeCaseIgnoreString   = 3
+	adsTypePrintableString    = 4
+	adsTypeNumericString      = 5
+	adsTypeBoolean            = 6
+	adsTypeInteger            = 7
+	adsTypeOctetString        = 8
+	adsTypeUTCTime            = 9
+	adsTypeLargeInteger       = 10
+	adsTypeObjectClass        = 12
+	adsTypeNTSecurityDescript = 25
+)
+
+type adExplorerHeader struct {
+	NumObjects uint32
+	NumProps   uint32
+	PropOffset int64
+}
+
+type adExplorerProperty struct {
+	Name    string
+	ADSType uint32
+}
+
+// ParseADExplorerSnapshot reads a Sysinternals ADExplorer .dat snapshot into
+// a Directory. Raw nTSecurityDescriptor values are preserved so ACL analysis
+// can run offline.
+func ParseADExplorerSnapshot(path string) (*Directory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hdr, err := readADExplorerHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	props, err := readADExplorerProperties(f, hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LDIFEntry, 0, hdr.NumObjects)
+	pos := int64(adExplorerHeaderSize)
+	for i := uint32(0); i < hdr.NumObjects; i++ {
+		var sizeBuf [4]byte
+		if _, err := f.ReadAt(sizeBuf[:], pos); err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		size := int64(binary.LittleEndian.Uint32(sizeBuf[:]))
+		if size < 8 || pos+size > hdr.PropOffset {
+			return nil, fmt.Errorf("object %d: invalid record size %d", i, size)
+		}
+		record := make([]byte, size)
+		if _, err := f.ReadAt(record, pos); err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		entry, err := parseADExplorerObject(record, props)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		entries = append(entries, entry)
+		pos += size
+	}
+
+	return DirectoryFromEntries(entries), nil
+}
+
+func readADExplorerHeader(r io.ReaderAt) (adExplorerHeader, error) {
+	buf := make([]byte, adExplorerHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return adExplorerHeader{}, fmt.Errorf("read snapshot header: %w", err)
+	}
+	if string(buf[:10]) != adExplorerSignature {
+		return adExplorerHeader{}, fmt.Errorf("not an ADExplorer snapshot")
+	}
+	// 10 sig + 4 marker + 8 filetime + 520 description + 520 server
+	const countsOffset = 10 + 4 + 8 + 2*adExplorerNameChars*2
+	hdr := adExplorerHeader{
+		NumObjects: binary.LittleEndian.Uint32(buf[countsOffset:]),
+		NumProps:   binary.LittleEndian.Uint32(buf[countsOffset+4:]),
+	}
+	low := binary.LittleEndian.Uint32(buf[countsOffset+8:])
+	high := binary.LittleEndian.Uint32(buf[countsOffset+12:])
+	hdr.PropOffset = int64(high)<<32 | int64(low)
+	if hdr.PropOffset < adExplorerHeaderSize {
+		return adExplorerHeader{}, fmt.Errorf("invalid property table offset %d", hdr.PropOffset)
+	}
+	return hdr, nil
+}
+
+func readADExplorerProperties(f *os.File, hdr adExplorerHeader) ([]adExplorerProperty, error) {
+	r := bufio.NewReader(io.NewSectionReader(f, hdr.PropOffset, 1<<62))
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("read property table: %w", err)
+	}
+
+	props := make([]adExplorerProperty, 0, count)
+	for i := uint32(0); i < count; i++ {
+		name, err := readADExplorerString(r)
+		if err != nil {
+			return nil, fmt.Errorf("property %d: %w", i, err)
+		}
+		var fixed struct {
+			Unknown uint32
+			ADSType uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &fixed); err != nil {
+			return nil, fmt.Errorf("property %d: %w", i, err)
+		}
+		if _, err := readADExplorerString(r); err != nil { // attribute schema DN
+			return nil, fmt.Errorf("property %d: %w", i, err)
+		}
+		// schemaIDGUID, attributeSecurityGUID and a 4-byte trailer
+		if _, err := io.CopyN(io.Discard, r, 16+16+4); err != nil {
+			return nil, fmt.Errorf("property %d: %w", i, err)
+		}
+		props = append(props, adExplorerProperty{Name: name, ADSType: fixed.ADSType})
+	}
+	return props, nil
+}
+
+// readADExplorerString reads a byte-length-prefixed UTF-16LE string.
+func readADExplorerString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n > 1<<16 {
+		return "", fmt.Errorf("string length %d out of range", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return decodeUTF16(buf), nil
+}
+
+func parseADExplorerObject(record []byte, props []adExplorerProperty) (LDIFEntry, error) {
+	var entry LDIFEntry
+	tableSize := int(binary.LittleEndian.Uint32(record[4:8]))
+	if 8+tableSize*8 > len(record) {
+		return entry, fmt.Errorf("mapping table overruns record")
+	}
+	for i := 0; i < tableSize; i++ {
+		idx := int(binary.LittleEndian.Uint32(record[8+i*8:]))
+		off := int(int32(binary.LittleEndian.Uint32(record[12+i*8:])))
+		if idx >= len(props) || off < 0 || off+4 > len(record) {
+			continue
+		}
+		prop := props[idx]
+		values, err := decodeADExplorerValues(record[off:], prop.ADSType)
+		if err != nil {
+			return entry, fmt.Errorf("attribute %s: %w", prop.Name, err)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		entry.Attributes = append(entry.Attributes, LDIFAttribute{Name: prop.Name, Values: values})
+	}
+	entry.DN = entry.Get("distinguishedName")
+	return entry, nil
+}
+
+// decodeADExplorerValues decodes one attribute block: a value count followed
+// by type-specific data. String offsets are relative to the block start.
+func decodeADExplorerValues(block []byte, adsType uint32) ([][]byte, error) {
+	count := int(binary.LittleEndian.Uint32(block))
+	data := block[4:]
+	if count > len(data) {
+		return nil, fmt.Errorf("value count %d exceeds record", count)
+	}
+	need := func(n int) error {
+		if n > len(data) {
+			return fmt.Errorf("value data truncated")
+		}
+		return nil
+	}
+
+	values := make([][]byte, 0, count)
+	switch adsType {
+	case adsTypeDNString, adsTypeCaseExactString, adsTypeCaseIgnoreString,
+		adsTypePrintableString, adsTypeNumericString, adsTypeObjectClass:
+		if err := need(count * 4); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			off := int(binary.LittleEndian.Uint32(data[i*4:]))
+			if off >= len(block) {
+				return nil, fmt.Errorf("string offset out of range")
+			}
+			values = append(values, []byte(decodeUTF16(block[off:])))
+		}
+	case adsTypeOctetString:
+		if err := need(count * 4); err != nil {
+			return nil, err
+		}
+		pos := count * 4
+		for i := 0; i < count; i++ {
+			n := int(binary.LittleEndian.Uint32(data[i*4:]))
+			if err := need(pos + n); err != nil {
+				return nil, err
+			}
+			values = append(values, append([]byte(nil), data[pos:pos+n]...))
+			pos += n
+		}
+	case adsTypeNTSecurityDescript:
+		pos := 0
+		for i := 0; i < count; i++ {
+			if err := need(pos + 4); err != nil {
+				return nil, err
+			}
+			n := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if err := need(pos + n); err != nil {
+				return nil, err
+			}
+			values = append(values, append([]byte(nil), data[pos:pos+n]...))
+			pos += n
+		}
+	case adsTypeBoolean:
+		if err := need(count * 4); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			if binary.LittleEndian.Uint32(data[i*4:]) != 0 {
+				values = append(values, []byte("TRUE"))
+			} else {
+				values = append(values, []byte("FALSE"))
+			}
+		}
+	case adsTypeInteger:
+		if err := need(count * 4); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			v := int32(binary.LittleEndian.Uint32(data[i*4:]))
+			values = append(values, []byte(strconv.FormatInt(int64(v), 10)))
+		}
+	case adsTypeLargeInteger:
+		if err := need(count * 8); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			v := int64(binary.LittleEndian.Uint64(data[i*8:]))
+			values = append(values, []byte(strconv.FormatInt(v, 10)))
+		}
+	case adsTypeUTCTime:
+		if err := need(count * 16); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			st := data[i*16:]
+			t := time.Date(
+				int(binary.LittleEndian.Uint16(st[0:])),
+				time.Month(binary.LittleEndian.Uint16(st[2:])),
+				int(binary.LittleEndian.Uint16(st[6:])),
+				int(binary.LittleEndian.Uint16(st[8:])),
+				int(binary.LittleEndian.Uint16(st[10:])),
+				int(binary.LittleEndian.Uint16(st[12:])),
+				0, time.UTC)
+			values = append(values, []byte(t.Format("20060102150405.0Z")))
+		}
+	default:
+		// Unknown encodings are skipped rather than guessed at.
+		return nil, nil
+	}
+	return values, nil
+}
+
+// decodeUTF16 decodes a NUL-terminated (or buffer-bounded) UTF-16LE string.
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u := binary.LittleEndian.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
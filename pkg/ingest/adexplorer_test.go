@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+type snapshotAttr struct {
+	name    string
+	adsType uint32
+	values  []interface{} // string, []byte, int32 or int64 depending on adsType
+}
+
+func utf16z(s string) []byte {
+	var buf bytes.Buffer
+	for _, u := range utf16.Encode([]rune(s)) {
+		binary.Write(&buf, binary.LittleEndian, u)
+	}
+	buf.Write([]byte{0, 0})
+	return buf.Bytes()
+}
+
+func snapshotValueBlock(attr snapshotAttr) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(attr.values)))
+	switch attr.adsType {
+	case adsTypeDNString, adsTypeCaseIgnoreString, adsTypeObjectClass:
+		off := 4 + 4*len(attr.values)
+		var strs bytes.Buffer
+		for _, v := range attr.values {
+			binary.Write(&buf, binary.LittleEndian, uint32(off+strs.Len()))
+			strs.Write(utf16z(v.(string)))
+		}
+		buf.Write(strs.Bytes())
+	case adsTypeOctetString:
+		for _, v := range attr.values {
+			binary.Write(&buf, binary.LittleEndian, uint32(len(v.([]byte))))
+		}
+		for _, v := range attr.values {
+			buf.Write(v.([]byte))
+		}
+	case adsTypeNTSecurityDescript:
+		for _, v := range attr.values {
+			binary.Write(&buf, binary.LittleEndian, uint32(len(v.([]byte))))
+			buf.Write(v.([]byte))
+		}
+	case adsTypeInteger:
+		for _, v := range attr.values {
+			binary.Write(&buf, binary.LittleEndian, v.(int32))
+		}
+	case adsTypeLargeInteger:
+		for _, v := range attr.values {
+			binary.Write(&buf, binary.LittleEndian, v.(int64))
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeSnapshot lays out a minimal ADExplorer snapshot for the given objects.
+func writeSnapshot(t *testing.T, objects [][]snapshotAttr) string {
+	t.Helper()
+	propIndex := make(map[string]int)
+	var props []snapshotAttr
+	var body bytes.Buffer
+	for _, obj := range objects {
+		var table, blocks bytes.Buffer
+		base := 8 + 8*len(obj)
+		for _, attr := range obj {
+			idx, ok := propIndex[attr.name]
+			if !ok {
+				idx = len(props)
+				propIndex[attr.name] = idx
+				props = append(props, attr)
+			}
+			binary.Write(&table, binary.LittleEndian, uint32(idx))
+			binary.Write(&table, binary.LittleEndian, int32(base+blocks.Len()))
+			blocks.Write(snapshotValueBlock(attr))
+		}
+		binary.Write(&body, binary.LittleEndian, uint32(base+blocks.Len()))
+		binary.Write(&body, binary.LittleEndian, uint32(len(obj)))
+		body.Write(table.Bytes())
+		body.Write(blocks.Bytes())
+	}
+
+	var propTable bytes.Buffer
+	binary.Write(&propTable, binary.LittleEndian, uint32(len(props)))
+	for _, p := range props {
+		name := utf16z(p.name)
+		binary.Write(&propTable, binary.LittleEndian, uint32(len(name)))
+		propTable.Write(name)
+		binary.Write(&propTable, binary.LittleEndian, int32(0))
+		binary.Write(&propTable, binary.LittleEndian, p.adsType)
+		dn := utf16z("CN=" + p.name + ",CN=Schema,CN=Configuration,DC=corp,DC=local")
+		binary.Write(&propTable, binary.LittleEndian, uint32(len(dn)))
+		propTable.Write(dn)
+		propTable.Write(make([]byte, 36))
+	}
+
+	header := make([]byte, adExplorerHeaderSize)
+	copy(header, adExplorerSignature)
+	const counts = 10 + 4 + 8 + 2*adExplorerNameChars*2
+	propOffset := uint32(adExplorerHeaderSize + body.Len())
+	binary.LittleEndian.PutUint32(header[counts:], uint32(len(objects)))
+	binary.LittleEndian.PutUint32(header[counts+4:], uint32(len(props)))
+	binary.LittleEndian.PutUint32(header[counts+8:], propOffset)
+	binary.LittleEndian.PutUint32(header[counts+16:], propOffset+uint32(propTable.Len()))
+
+	path := filepath.Join(t.TempDir(), "corp.dat")
+	data := append(append(header, body.Bytes()...), propTable.Bytes()...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseADExplorerSnapshot(t *testing.T) {
+	sd := []byte{0x01, 0x00, 0x04, 0x80, 0x14, 0x00, 0x00, 0x00, 0x30, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4c, 0x00, 0x00, 0x00}
+	path := writeSnapshot(t, [][]snapshotAttr{
+		{
+			{"distinguishedName", adsTypeDNString, []interface{}{"DC=corp,DC=local"}},
+			{"objectClass", adsTypeObjectClass, []interface{}{"top", "domain", "domainDNS"}},
+			{"minPwdLength", adsTypeInteger, []interface{}{int32(7)}},
+			{"lockoutThreshold", adsTypeInteger, []interface{}{int32(0)}},
+			{"maxPwdAge", adsTypeLargeInteger, []interface{}{int64(-36288000000000)}},
+			{"nTSecurityDescriptor", adsTypeNTSecurityDescript, []interface{}{sd}},
+		},
+		{
+			{"distinguishedName", adsTypeDNString, []interface{}{"CN=svc_sql,OU=Service Accounts,DC=corp,DC=local"}},
+			{"objectClass", adsTypeObjectClass, []interface{}{"top", "person", "organizationalPerson", "user"}},
+			{"sAMAccountName", adsTypeCaseIgnoreString, []interface{}{"svc_sql"}},
+			{"userAccountControl", adsTypeInteger, []interface{}{int32(4260352)}},
+			{"servicePrincipalName", adsTypeCaseIgnoreString, []interface{}{"MSSQLSvc/sql01.corp.local:1433"}},
+			{"memberOf", adsTypeDNString, []interface{}{"CN=SQL Admins,OU=Groups,DC=corp,DC=local"}},
+			{"objectSid", adsTypeOctetString, []interface{}{testSID}},
+			{"pwdLastSet", adsTypeLargeInteger, []interface{}{int64(133485408000000000)}},
+			{"nTSecurityDescriptor", adsTypeNTSecurityDescript, []interface{}{sd}},
+		},
+		{
+			{"distinguishedName", adsTypeDNString, []interface{}{"CN=SQL Admins,OU=Groups,DC=corp,DC=local"}},
+			{"objectClass", adsTypeObjectClass, []interface{}{"top", "group"}},
+			{"sAMAccountName", adsTypeCaseIgnoreString, []interface{}{"SQL Admins"}},
+		},
+		{
+			{"distinguishedName", adsTypeDNString, []interface{}{"CN=SQL01,OU=Servers,DC=corp,DC=local"}},
+			{"objectClass", adsTypeObjectClass, []interface{}{"top", "person", "organizationalPerson", "user", "computer"}},
+			{"sAMAccountName", adsTypeCaseIgnoreString, []interface{}{"SQL01$"}},
+			{"dNSHostName", adsTypeCaseIgnoreString, []interface{}{"sql01.corp.local"}},
+			{"userAccountControl", adsTypeInteger, []interface{}{int32(4096)}},
+		},
+	})
+
+	dir, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if dir.Domain != "CORP.LOCAL" {
+		t.Fatalf("unexpected domain %q", dir.Domain)
+	}
+	if len(dir.Users) != 1 || len(dir.Groups) != 1 || len(dir.Computers) != 1 {
+		t.Fatalf("unexpected object counts: %d users, %d groups, %d computers", len(dir.Users), len(dir.Groups), len(dir.Computers))
+	}
+
+	user := dir.Users[0]
+	if user.SamAccountName != "svc_sql" || !user.DoesNotRequirePreAuth || user.ObjectSID != "S-1-5-21-1-2-3-1104" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if user.PwdLastSet.IsZero() {
+		t.Fatal("pwdLastSet large integer not decoded")
+	}
+	if !bytes.Equal(user.NTSecurityDescriptor, sd) {
+		t.Fatal("user security descriptor not preserved")
+	}
+	if !bytes.Equal(dir.SecurityDescriptors["DC=corp,DC=local"], sd) {
+		t.Fatal("domain head security descriptor not preserved")
+	}
+	if len(dir.Groups[0].Members) != 1 {
+		t.Fatalf("group members not mirrored from memberOf: %v", dir.Groups[0].Members)
+	}
+	if dir.Computers[0].DNSHostName != "sql01.corp.local" {
+		t.Fatalf("unexpected computer %+v", dir.Computers[0])
+	}
+	if dir.Policy == nil || dir.Policy.MinPwdLength != 7 || dir.Policy.MaxPwdAge.Hours() != 42*24 {
+		t.Fatalf("unexpected policy %+v", dir.Policy)
+	}
+}
+
+func TestParseADExplorerSnapshotRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.dat")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{'x'}, adExplorerHeaderSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseADExplorerSnapshot(path); err == nil {
+		t.Fatal("expected signature error")
+	}
+}
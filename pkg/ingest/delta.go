@@ -0,0 +1,178 @@
+package ingest
+
+import "strings"
+
+// DeltaStats counts the objects an incremental collection touched.
+type DeltaStats struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+}
+
+// ApplyDelta merges objects changed since a previous collection into d.
+// Objects in changed replace the snapshot copy with the same DN or are
+// appended; when present is non-nil, snapshot objects whose DN is not in it
+// are dropped as deleted. memberOf is a backlink that does not bump the
+// member's uSNChanged, so it is rebuilt from the member lists of changed and
+// removed groups. EffectiveGroups are stale afterwards; call
+// ResolveMemberships again.
+func (d *Directory) ApplyDelta(changed *Directory, present []string) DeltaStats {
+	var stats DeltaStats
+	var keep map[string]bool
+	if present != nil {
+		keep = make(map[string]bool, len(present))
+		for _, dn := range present {
+			keep[strings.ToLower(dn)] = true
+		}
+	}
+	var removedDNs []string
+	exists := func(dn string) bool {
+		if keep == nil || keep[strings.ToLower(dn)] {
+			return true
+		}
+		removedDNs = append(removedDNs, dn)
+		return false
+	}
+
+	d.Users = applyByDN(d.Users, changed.Users, func(u User) string { return u.DistinguishedName }, exists, &stats)
+	d.Groups = applyByDN(d.Groups, changed.Groups, func(g Group) string { return g.DistinguishedName }, exists, &stats)
+	d.Computers = applyByDN(d.Computers, changed.Computers, func(c Computer) string { return c.DistinguishedName }, exists, &stats)
+	d.OUs = applyByDN(d.OUs, changed.OUs, func(ou OU) string { return ou.DistinguishedName }, exists, &stats)
+	d.GPOs = applyByDN(d.GPOs, changed.GPOs, func(g GPO) string { return g.DistinguishedName }, exists, &stats)
+
+	// Domain head changes carry the policy and domain-level GPO links.
+	if changed.Policy != nil {
+		d.Policy = changed.Policy
+		d.DomainGPLinks = changed.DomainGPLinks
+	}
+	if d.Domain == "" {
+		d.Domain = changed.Domain
+	}
+	if d.DomainSID == "" {
+		d.DomainSID = changed.DomainSID
+	}
+
+	// ACEs and descriptors of changed or removed objects are replaced wholesale.
+	touched := make(map[string]bool)
+	for _, dn := range append(changed.DNs(), removedDNs...) {
+		touched[strings.ToLower(dn)] = true
+	}
+	aces := d.ACEs[:0]
+	for _, ace := range d.ACEs {
+		if !touched[strings.ToLower(ace.TargetDN)] {
+			aces = append(aces, ace)
+		}
+	}
+	d.ACEs = append(aces, changed.ACEs...)
+	for dn := range d.SecurityDescriptors {
+		if touched[strings.ToLower(dn)] {
+			delete(d.SecurityDescriptors, dn)
+		}
+	}
+	for dn, sd := range changed.SecurityDescriptors {
+		if d.SecurityDescriptors == nil {
+			d.SecurityDescriptors = make(map[string][]byte)
+		}
+		d.SecurityDescriptors[dn] = sd
+	}
+
+	var groupDNs []string
+	for _, g := range changed.Groups {
+		groupDNs = append(groupDNs, g.DistinguishedName)
+	}
+	d.rebuildMemberOf(append(groupDNs, removedDNs...))
+	d.Reindex()
+	return stats
+}
+
+// DNs lists the distinguished name of every typed object in d.
+func (d *Directory) DNs() []string {
+	var dns []string
+	for _, u := range d.Users {
+		dns = append(dns, u.DistinguishedName)
+	}
+	for _, g := range d.Groups {
+		dns = append(dns, g.DistinguishedName)
+	}
+	for _, c := range d.Computers {
+		dns = append(dns, c.DistinguishedName)
+	}
+	for _, ou := range d.OUs {
+		dns = append(dns, ou.DistinguishedName)
+	}
+	for _, g := range d.GPOs {
+		dns = append(dns, g.DistinguishedName)
+	}
+	return dns
+}
+
+// rebuildMemberOf drops the listed group DNs from every memberOf and adds
+// them back from the member lists of the groups that still exist.
+func (d *Directory) rebuildMemberOf(groupDNs []string) {
+	if len(groupDNs) == 0 {
+		return
+	}
+	rebuilt := make(map[string]bool, len(groupDNs))
+	for _, dn := range groupDNs {
+		rebuilt[strings.ToLower(dn)] = true
+	}
+	members := make(map[string][]string)
+	for _, g := range d.Groups {
+		if !rebuilt[strings.ToLower(g.DistinguishedName)] {
+			continue
+		}
+		for _, m := range g.Members {
+			key := strings.ToLower(m)
+			members[key] = append(members[key], g.DistinguishedName)
+		}
+	}
+	reset := func(dn string, memberOf []string) []string {
+		out := memberOf[:0:0]
+		for _, parent := range memberOf {
+			if !rebuilt[strings.ToLower(parent)] {
+				out = append(out, parent)
+			}
+		}
+		return append(out, members[strings.ToLower(dn)]...)
+	}
+	for i := range d.Users {
+		d.Users[i].MemberOf = reset(d.Users[i].DistinguishedName, d.Users[i].MemberOf)
+	}
+	for i := range d.Groups {
+		d.Groups[i].MemberOf = reset(d.Groups[i].DistinguishedName, d.Groups[i].MemberOf)
+	}
+	for i := range d.Computers {
+		d.Computers[i].MemberOf = reset(d.Computers[i].DistinguishedName, d.Computers[i].MemberOf)
+	}
+}
+
+// applyByDN keeps the snapshot objects that still exist, replaces those with
+// a changed copy and appends the rest of changed.
+func applyByDN[T any](snapshot, changed []T, dnOf func(T) string, exists func(string) bool, stats *DeltaStats) []T {
+	updates := make(map[string]T, len(changed))
+	for _, obj := range changed {
+		updates[strings.ToLower(dnOf(obj))] = obj
+	}
+	out := snapshot[:0]
+	for _, obj := range snapshot {
+		key := strings.ToLower(dnOf(obj))
+		if upd, ok := updates[key]; ok {
+			out = append(out, upd)
+			delete(updates, key)
+			stats.Updated++
+			continue
+		}
+		if !exists(dnOf(obj)) {
+			stats.Removed++
+			continue
+		}
+		out = append(out, obj)
+	}
+	for _, obj := range changed {
+		if _, ok := updates[strings.ToLower(dnOf(obj))]; ok {
+			out = append(out, obj)
+			stats.Added++
+		}
+	}
+	return out
+}
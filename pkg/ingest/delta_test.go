@@ -0,0 +1,53 @@
+package ingest
+
+import "testing"
+
+func TestApplyDelta(t *testing.T) {
+	const (
+		helpdesk = "CN=Helpdesk,OU=Groups,DC=corp,DC=local"
+		ops      = "CN=Server Ops,OU=Groups,DC=corp,DC=local"
+	)
+	snap := &Directory{
+		Users: []User{
+			{SamAccountName: "alice", DistinguishedName: "CN=alice,CN=Users,DC=corp,DC=local", MemberOf: []string{helpdesk}},
+			{SamAccountName: "bob", DistinguishedName: "CN=bob,CN=Users,DC=corp,DC=local", MemberOf: []string{ops}},
+			{SamAccountName: "carol", DistinguishedName: "CN=carol,CN=Users,DC=corp,DC=local"},
+		},
+		Groups: []Group{
+			{SamAccountName: "Helpdesk", DistinguishedName: helpdesk, Members: []string{"CN=alice,CN=Users,DC=corp,DC=local"}},
+			{SamAccountName: "Server Ops", DistinguishedName: ops, Members: []string{"CN=bob,CN=Users,DC=corp,DC=local"}},
+		},
+	}
+	snap.Reindex()
+
+	// alice moved from Helpdesk to Server Ops; only the groups changed, carol
+	// was deleted and dave is new.
+	changed := &Directory{
+		Users: []User{{SamAccountName: "dave", DistinguishedName: "CN=dave,CN=Users,DC=corp,DC=local"}},
+		Groups: []Group{
+			{SamAccountName: "Helpdesk", DistinguishedName: helpdesk},
+			{SamAccountName: "Server Ops", DistinguishedName: ops, Members: []string{"CN=bob,CN=Users,DC=corp,DC=local", "CN=alice,CN=Users,DC=corp,DC=local"}},
+		},
+	}
+	present := []string{
+		"CN=alice,CN=Users,DC=corp,DC=local",
+		"CN=bob,CN=Users,DC=corp,DC=local",
+		"CN=dave,CN=Users,DC=corp,DC=local",
+		helpdesk, ops,
+	}
+
+	stats := snap.ApplyDelta(changed, present)
+	if stats != (DeltaStats{Added: 1, Updated: 2, Removed: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if _, ok := snap.LookupSAM("carol"); ok {
+		t.Fatal("deleted user still indexed")
+	}
+	alice := snap.Users[0]
+	if len(alice.MemberOf) != 1 || alice.MemberOf[0] != ops {
+		t.Fatalf("alice memberOf not rebuilt from group members: %v", alice.MemberOf)
+	}
+	if bob := snap.Users[1]; len(bob.MemberOf) != 1 || bob.MemberOf[0] != ops {
+		t.Fatalf("bob memberOf duplicated or lost: %v", bob.MemberOf)
+	}
+}
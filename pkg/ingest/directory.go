@@ -0,0 +1,662 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Group is an AD group object.
+type Group struct {
+	SamAccountName       string
+	DistinguishedName    string
+	ObjectSID            string
+	Description          string
+	AdminCount           bool
+	WhenCreated          time.Time
+	Members              []string // member DNs (SID strings when the member could not be resolved)
+	MemberOf             []string
+	NTSecurityDescriptor []byte
+	RawFields            map[string]string
+	DecodedFields        map[string]interface{}
+}
+
+// Computer is an AD computer object.
+type Computer struct {
+	SamAccountName           string
+	DistinguishedName        string
+	DNSHostName              string
+	ObjectSID                string
+	OperatingSystem          string
+	OperatingSystemVersion   string
+	UserAccountControl       int
+	ServicePrincipalNames    []string
+	AllowedToDelegateTo      []string
+	CreatorSID               string    // ms-DS-CreatorSID, set when a user joined the machine via MachineAccountQuota
+	LAPSExpiration           time.Time // ms-Mcs-AdmPwdExpirationTime or msLAPS-PasswordExpirationTime
+	LastLogonTimestamp       time.Time
+	PwdLastSet               time.Time
+	SupportedEncryptionTypes int // msDS-SupportedEncryptionTypes; 0 when unset
+	MemberOf                 []string
+	PrimaryGroupID           int
+	EffectiveGroups          []GroupMembership // filled by Directory.ResolveMemberships
+	NTSecurityDescriptor     []byte
+	RawFields                map[string]string
+	DecodedFields            map[string]interface{}
+}
+
+// userAccountControl delegation bits.
+const (
+	UACTrustedForDelegation       = 0x80000
+	UACNotDelegated               = 0x100000
+	UACTrustedToAuthForDelegation = 0x1000000
+)
+
+// UnconstrainedDelegation reports TRUSTED_FOR_DELEGATION.
+func (c *Computer) UnconstrainedDelegation() bool {
+	return c.UserAccountControl&UACTrustedForDelegation != 0
+}
+
+// ProtocolTransition reports TRUSTED_TO_AUTH_FOR_DELEGATION (constrained
+// delegation with S4U2Self to any user).
+func (c *Computer) ProtocolTransition() bool {
+	return c.UserAccountControl&UACTrustedToAuthForDelegation != 0
+}
+
+// HasLAPS reports whether either LAPS generation manages the local admin password.
+func (c *Computer) HasLAPS() bool {
+	return !c.LAPSExpiration.IsZero()
+}
+
+// ACE is a single access-control entry granting a principal a right over a
+// directory object, as collected by an external tool or parsed from a DACL.
+type ACE struct {
+	TargetDN      string
+	TargetSID     string
+	TargetType    string
+	PrincipalSID  string
+	PrincipalDN   string
+	PrincipalType string
+	Right         string
+	Inherited     bool
+}
+
+// OU is an organizational unit.
+type OU struct {
+	Name                 string
+	DistinguishedName    string
+	Description          string
+	GPLinks              []GPLink
+	BlocksInheritance    bool // gPOptions bit 0
+	NTSecurityDescriptor []byte
+	RawFields            map[string]string
+	DecodedFields        map[string]interface{}
+}
+
+// GPO is a group policy container.
+type GPO struct {
+	Name                 string // CN, the policy GUID in braces
+	DisplayName          string
+	DistinguishedName    string
+	FileSysPath          string // gPCFileSysPath
+	VersionNumber        int
+	Flags                int // 1 = user settings disabled, 2 = computer settings disabled
+	NTSecurityDescriptor []byte
+	RawFields            map[string]string
+	DecodedFields        map[string]interface{}
+}
+
+// GPLink is one entry of a gPLink attribute.
+type GPLink struct {
+	GPODN    string
+	Disabled bool
+	Enforced bool
+}
+
+// DomainPolicy is the default domain password and lockout policy read from
+// the domain head. Durations are stored as positive values.
+type DomainPolicy struct {
+	MinPwdLength        int
+	PwdHistoryLength    int
+	PwdProperties       int
+	LockoutThreshold    int
+	LockoutDuration     time.Duration
+	MaxPwdAge           time.Duration
+	MinPwdAge           time.Duration
+	MachineAccountQuota int
+}
+
+// Directory is the set of objects collected for a domain, or for several
+// domains of a forest after Merge.
+type Directory struct {
+	Domain    string
+	DomainSID string
+	// Domains lists every domain whose objects are present; empty for a
+	// single-domain collection.
+	Domains   []string
+	Users     []User
+	Groups    []Group
+	Computers []Computer
+	OUs       []OU
+	GPOs      []GPO
+	ACEs      []ACE
+	Policy    *DomainPolicy
+	// DomainGPLinks are the GPOs linked at the domain head.
+	DomainGPLinks []GPLink
+	// SecurityDescriptors holds raw nTSecurityDescriptor values, keyed by DN,
+	// for objects without a typed model (domain head, containers).
+	SecurityDescriptors map[string][]byte
+
+	index *directoryIndex
+}
+
+// ObjectKind identifies which Directory slice an indexed object lives in.
+type ObjectKind string
+
+const (
+	KindUser     ObjectKind = "user"
+	KindGroup    ObjectKind = "group"
+	KindComputer ObjectKind = "computer"
+	KindOU       ObjectKind = "ou"
+	KindGPO      ObjectKind = "gpo"
+)
+
+// ObjectRef points at an object inside a Directory.
+type ObjectRef struct {
+	Kind  ObjectKind
+	Index int
+	DN    string
+}
+
+type directoryIndex struct {
+	byDN  map[string]ObjectRef
+	bySID map[string]ObjectRef
+	bySAM map[string]ObjectRef
+}
+
+// Reindex rebuilds the DN, SID and sAMAccountName indexes. Lookups build the
+// index on first use; call Reindex after appending objects.
+func (d *Directory) Reindex() {
+	idx := &directoryIndex{
+		byDN:  make(map[string]ObjectRef),
+		bySID: make(map[string]ObjectRef),
+		bySAM: make(map[string]ObjectRef),
+	}
+	add := func(kind ObjectKind, i int, dn, sid, sam string) {
+		ref := ObjectRef{Kind: kind, Index: i, DN: dn}
+		if dn != "" {
+			idx.byDN[strings.ToLower(dn)] = ref
+		}
+		if sid != "" {
+			idx.bySID[strings.ToUpper(sid)] = ref
+		}
+		if sam != "" {
+			idx.bySAM[strings.ToLower(sam)] = ref
+		}
+	}
+	for i, u := range d.Users {
+		add(KindUser, i, u.DistinguishedName, u.ObjectSID, u.SamAccountName)
+	}
+	for i, g := range d.Groups {
+		add(KindGroup, i, g.DistinguishedName, g.ObjectSID, g.SamAccountName)
+	}
+	for i, c := range d.Computers {
+		add(KindComputer, i, c.DistinguishedName, c.ObjectSID, c.SamAccountName)
+	}
+	for i, ou := range d.OUs {
+		add(KindOU, i, ou.DistinguishedName, "", "")
+	}
+	for i, gpo := range d.GPOs {
+		add(KindGPO, i, gpo.DistinguishedName, "", "")
+	}
+	d.index = idx
+}
+
+func (d *Directory) lookup(table func(*directoryIndex) map[string]ObjectRef, key string) (ObjectRef, bool) {
+	if d.index == nil {
+		d.Reindex()
+	}
+	ref, ok := table(d.index)[key]
+	return ref, ok
+}
+
+// LookupDN finds an object by distinguished name (case-insensitive).
+func (d *Directory) LookupDN(dn string) (ObjectRef, bool) {
+	return d.lookup(func(i *directoryIndex) map[string]ObjectRef { return i.byDN }, strings.ToLower(dn))
+}
+
+// LookupSID finds a user, group or computer by objectSid string.
+func (d *Directory) LookupSID(sid string) (ObjectRef, bool) {
+	return d.lookup(func(i *directoryIndex) map[string]ObjectRef { return i.bySID }, strings.ToUpper(sid))
+}
+
+// LookupSAM finds a user, group or computer by sAMAccountName (case-insensitive).
+func (d *Directory) LookupSAM(name string) (ObjectRef, bool) {
+	return d.lookup(func(i *directoryIndex) map[string]ObjectRef { return i.bySAM }, strings.ToLower(name))
+}
+
+// UserByDN returns the user with the given DN, or nil.
+func (d *Directory) UserByDN(dn string) *User {
+	if ref, ok := d.LookupDN(dn); ok && ref.Kind == KindUser {
+		return &d.Users[ref.Index]
+	}
+	return nil
+}
+
+// GroupByDN returns the group with the given DN, or nil.
+func (d *Directory) GroupByDN(dn string) *Group {
+	if ref, ok := d.LookupDN(dn); ok && ref.Kind == KindGroup {
+		return &d.Groups[ref.Index]
+	}
+	return nil
+}
+
+// ComputerByDN returns the computer with the given DN, or nil.
+func (d *Directory) ComputerByDN(dn string) *Computer {
+	if ref, ok := d.LookupDN(dn); ok && ref.Kind == KindComputer {
+		return &d.Computers[ref.Index]
+	}
+	return nil
+}
+
+// OUByDN returns the OU with the given DN, or nil.
+func (d *Directory) OUByDN(dn string) *OU {
+	if ref, ok := d.LookupDN(dn); ok && ref.Kind == KindOU {
+		return &d.OUs[ref.Index]
+	}
+	return nil
+}
+
+// GPOByDN returns the GPO with the given DN, or nil.
+func (d *Directory) GPOByDN(dn string) *GPO {
+	if ref, ok := d.LookupDN(dn); ok && ref.Kind == KindGPO {
+		return &d.GPOs[ref.Index]
+	}
+	return nil
+}
+
+// Merge appends another domain's objects. d keeps its own domain, SID and
+// policy; objects keep their DNs, so their source domain stays derivable via
+// DomainFromDN. Call ResolveMemberships afterwards so nesting across domains
+// is followed.
+func (d *Directory) Merge(other *Directory) {
+	if other == nil {
+		return
+	}
+	if len(d.Domains) == 0 && d.Domain != "" {
+		d.Domains = []string{d.Domain}
+	}
+	for _, name := range append([]string{other.Domain}, other.Domains...) {
+		if name != "" && !containsFold(d.Domains, name) {
+			d.Domains = append(d.Domains, name)
+		}
+	}
+	d.Users = append(d.Users, other.Users...)
+	d.Groups = append(d.Groups, other.Groups...)
+	d.Computers = append(d.Computers, other.Computers...)
+	d.OUs = append(d.OUs, other.OUs...)
+	d.GPOs = append(d.GPOs, other.GPOs...)
+	d.ACEs = append(d.ACEs, other.ACEs...)
+	if len(other.SecurityDescriptors) > 0 && d.SecurityDescriptors == nil {
+		d.SecurityDescriptors = make(map[string][]byte)
+	}
+	for dn, sd := range other.SecurityDescriptors {
+		d.SecurityDescriptors[dn] = sd
+	}
+	d.Reindex()
+}
+
+// Load reads any supported export into a Directory. SharpHound archives (or
+// a folder of extracted SharpHound JSON) carry groups, computers, OUs, GPOs
+// and ACEs; LDIF and ADExplorer snapshots carry every object class plus the
+// domain policy; ldapdomaindump output carries everything but OUs and GPOs.
+// Flat CSV/JSON dumps carry users, with groups inferred from memberOf.
+func Load(path string) (*Directory, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if isLDAPDomainDump(path) {
+			return ParseLDAPDomainDump(path)
+		}
+		return ParseSharpHound(path)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		return ParseSharpHound(path)
+	case ".dat":
+		return ParseADExplorerSnapshot(path)
+	case ".ldif", ".ldf":
+		entries, err := ParseLDIFFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return DirectoryFromEntries(entries), nil
+	case ".json":
+		if strings.HasPrefix(strings.ToLower(filepath.Base(path)), "domain_") {
+			return ParseLDAPDomainDump(filepath.Dir(path))
+		}
+	}
+
+	users, err := ParseAD(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := &Directory{Users: users, Groups: groupsFromMemberOf(users)}
+	for _, u := range users {
+		if dir.Domain = DomainFromDN(u.DistinguishedName); dir.Domain != "" {
+			break
+		}
+	}
+	return dir, nil
+}
+
+// groupsFromMemberOf builds placeholder groups from user memberOf values for
+// exports that do not include group objects.
+func groupsFromMemberOf(users []User) []Group {
+	var groups []Group
+	index := make(map[string]int)
+	for _, u := range users {
+		for _, dn := range u.MemberOf {
+			key := strings.ToLower(dn)
+			i, ok := index[key]
+			if !ok {
+				i = len(groups)
+				index[key] = i
+				groups = append(groups, Group{SamAccountName: cnFromDN(dn), DistinguishedName: dn})
+			}
+			if u.DistinguishedName != "" {
+				groups[i].Members = append(groups[i].Members, u.DistinguishedName)
+			}
+		}
+	}
+	return groups
+}
+
+// cnFromDN returns the value of the first RDN (CN=Domain Admins,... → Domain Admins).
+func cnFromDN(dn string) string {
+	first := dn
+	if parent := ParentDN(dn); parent != "" {
+		first = dn[:len(dn)-len(parent)]
+		first = strings.TrimSuffix(strings.TrimSpace(first), ",")
+	}
+	if i := strings.Index(first, "="); i >= 0 {
+		return strings.ReplaceAll(first[i+1:], "\\,", ",")
+	}
+	return first
+}
+
+// DirectoryFromEntries maps attribute-bag records (LDIF, ldapdomaindump,
+// ADExplorer, live LDAP) onto the typed directory models.
+func DirectoryFromEntries(entries []LDIFEntry) *Directory {
+	b := NewDirectoryBuilder()
+	for i := range entries {
+		b.Add(&entries[i])
+	}
+	return b.Directory()
+}
+
+// DirectoryBuilder maps records onto a Directory one at a time, so streamed
+// LDAP results can be converted as they arrive instead of being buffered.
+type DirectoryBuilder struct {
+	dir        *Directory
+	groupIndex map[string]int
+}
+
+// NewDirectoryBuilder returns an empty builder.
+func NewDirectoryBuilder() *DirectoryBuilder {
+	return &DirectoryBuilder{
+		dir:        &Directory{SecurityDescriptors: make(map[string][]byte)},
+		groupIndex: make(map[string]int),
+	}
+}
+
+// Add maps one record. The record is not retained.
+func (b *DirectoryBuilder) Add(entry *LDIFEntry) {
+	dir := b.dir
+	if entry.ChangeType != "" && entry.ChangeType != "add" {
+		return
+	}
+	switch {
+	case entry.HasObjectClass("computer"):
+		dir.Computers = append(dir.Computers, ComputerFromEntry(entry))
+	case isUserEntry(entry):
+		dir.Users = append(dir.Users, userFromLDIF(entry))
+	case entry.HasObjectClass("group"):
+		b.groupIndex[strings.ToLower(entry.DN)] = len(dir.Groups)
+		dir.Groups = append(dir.Groups, GroupFromEntry(entry))
+	case entry.HasObjectClass("organizationalUnit"):
+		dir.OUs = append(dir.OUs, OUFromEntry(entry))
+	case entry.HasObjectClass("groupPolicyContainer"):
+		dir.GPOs = append(dir.GPOs, GPOFromEntry(entry))
+	case entry.HasObjectClass("domainDNS") || entry.HasObjectClass("domain"):
+		if dir.Domain == "" {
+			dir.Domain = DomainFromDN(entry.DN)
+			dir.DomainSID, _ = FormatSID(entry.GetRaw("objectSid"))
+			dir.Policy = policyFromEntry(entry)
+			dir.DomainGPLinks = ParseGPLink(entry.Get("gPLink"))
+		}
+		if sd := entry.GetRaw("nTSecurityDescriptor"); len(sd) > 0 {
+			dir.SecurityDescriptors[entry.DN] = sd
+		}
+	default:
+		if sd := entry.GetRaw("nTSecurityDescriptor"); len(sd) > 0 && entry.DN != "" {
+			dir.SecurityDescriptors[entry.DN] = sd
+		}
+	}
+}
+
+// Directory finishes the mapping and returns the result.
+func (b *DirectoryBuilder) Directory() *Directory {
+	dir := b.dir
+
+	// Some exports only carry memberOf on the member side; mirror it onto
+	// the group so both directions are populated.
+	addMember := func(memberDN string, groups []string) {
+		for _, groupDN := range groups {
+			idx, ok := b.groupIndex[strings.ToLower(groupDN)]
+			if !ok || containsFold(dir.Groups[idx].Members, memberDN) {
+				continue
+			}
+			dir.Groups[idx].Members = append(dir.Groups[idx].Members, memberDN)
+		}
+	}
+	for _, u := range dir.Users {
+		addMember(u.DistinguishedName, u.MemberOf)
+	}
+	for _, c := range dir.Computers {
+		addMember(c.DistinguishedName, c.MemberOf)
+	}
+	for _, g := range dir.Groups {
+		addMember(g.DistinguishedName, g.MemberOf)
+	}
+
+	if dir.Domain == "" {
+		for _, u := range dir.Users {
+			if dir.Domain = DomainFromDN(u.DistinguishedName); dir.Domain != "" {
+				break
+			}
+		}
+	}
+	return dir
+}
+
+// isUserEntry matches person accounts. Entries without any objectClass are
+// accepted when they carry a sAMAccountName, as minimal exports often omit it.
+func isUserEntry(entry *LDIFEntry) bool {
+	if entry.HasObjectClass("computer") {
+		return false
+	}
+	if entry.HasObjectClass("user") {
+		return true
+	}
+	return len(entry.ObjectClasses()) == 0 && entry.Get("sAMAccountName") != ""
+}
+
+// GroupFromEntry maps a group record onto Group.
+func GroupFromEntry(entry *LDIFEntry) Group {
+	group := Group{
+		SamAccountName:       entry.Get("sAMAccountName"),
+		DistinguishedName:    entry.DN,
+		Description:          entry.Get("description"),
+		AdminCount:           entry.Get("adminCount") == "1",
+		WhenCreated:          parseTime(entry.Get("whenCreated")),
+		Members:              entry.GetAll("member"),
+		MemberOf:             entry.GetAll("memberOf"),
+		NTSecurityDescriptor: entry.GetRaw("nTSecurityDescriptor"),
+	}
+	group.ObjectSID, _ = FormatSID(entry.GetRaw("objectSid"))
+	group.RawFields, group.DecodedFields = entry.Schema.DecodeFields(entry.Attributes)
+	return group
+}
+
+// ComputerFromEntry maps a computer record onto Computer.
+func ComputerFromEntry(entry *LDIFEntry) Computer {
+	computer := Computer{
+		SamAccountName:         entry.Get("sAMAccountName"),
+		DistinguishedName:      entry.DN,
+		DNSHostName:            entry.Get("dNSHostName"),
+		OperatingSystem:        entry.Get("operatingSystem"),
+		OperatingSystemVersion: entry.Get("operatingSystemVersion"),
+		ServicePrincipalNames:  entry.GetAll("servicePrincipalName"),
+		AllowedToDelegateTo:    entry.GetAll("msDS-AllowedToDelegateTo"),
+		LAPSExpiration:         parseTime(firstNonEmptyString(entry.Get("msLAPS-PasswordExpirationTime"), entry.Get("ms-Mcs-AdmPwdExpirationTime"))),
+		LastLogonTimestamp:     parseTime(entry.Get("lastLogonTimestamp")),
+		PwdLastSet:             parseTime(entry.Get("pwdLastSet")),
+		MemberOf:               entry.GetAll("memberOf"),
+		NTSecurityDescriptor:   entry.GetRaw("nTSecurityDescriptor"),
+	}
+	computer.ObjectSID, _ = FormatSID(entry.GetRaw("objectSid"))
+	computer.CreatorSID, _ = FormatSID(entry.GetRaw("ms-DS-CreatorSID"))
+	computer.UserAccountControl, _ = strconv.Atoi(entry.Get("userAccountControl"))
+	computer.PrimaryGroupID, _ = strconv.Atoi(entry.Get("primaryGroupID"))
+	computer.SupportedEncryptionTypes, _ = strconv.Atoi(entry.Get("msDS-SupportedEncryptionTypes"))
+	computer.RawFields, computer.DecodedFields = entry.Schema.DecodeFields(entry.Attributes)
+	return computer
+}
+
+// OUFromEntry maps an organizationalUnit record onto OU.
+func OUFromEntry(entry *LDIFEntry) OU {
+	ou := OU{
+		Name:                 entry.Get("ou"),
+		DistinguishedName:    entry.DN,
+		Description:          entry.Get("description"),
+		GPLinks:              ParseGPLink(entry.Get("gPLink")),
+		NTSecurityDescriptor: entry.GetRaw("nTSecurityDescriptor"),
+	}
+	if opts, err := strconv.Atoi(entry.Get("gPOptions")); err == nil {
+		ou.BlocksInheritance = opts&0x1 != 0
+	}
+	ou.RawFields, ou.DecodedFields = entry.Schema.DecodeFields(entry.Attributes)
+	return ou
+}
+
+// GPOFromEntry maps a groupPolicyContainer record onto GPO.
+func GPOFromEntry(entry *LDIFEntry) GPO {
+	gpo := GPO{
+		Name:                 entry.Get("cn"),
+		DisplayName:          entry.Get("displayName"),
+		DistinguishedName:    entry.DN,
+		FileSysPath:          entry.Get("gPCFileSysPath"),
+		NTSecurityDescriptor: entry.GetRaw("nTSecurityDescriptor"),
+	}
+	gpo.VersionNumber, _ = strconv.Atoi(entry.Get("versionNumber"))
+	gpo.Flags, _ = strconv.Atoi(entry.Get("flags"))
+	gpo.RawFields, gpo.DecodedFields = entry.Schema.DecodeFields(entry.Attributes)
+	return gpo
+}
+
+// ParseGPLink splits a gPLink value
+// ("[LDAP://cn={GUID},cn=policies,cn=system,DC=corp,DC=local;0][...]").
+// Option bit 0 disables the link, bit 1 enforces it.
+func ParseGPLink(value string) []GPLink {
+	var links []GPLink
+	for _, part := range strings.Split(value, "[") {
+		part = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), "]"))
+		if part == "" {
+			continue
+		}
+		dn, opts := part, 0
+		if i := strings.LastIndex(part, ";"); i >= 0 {
+			dn = part[:i]
+			opts, _ = strconv.Atoi(part[i+1:])
+		}
+		if len(dn) >= 7 && strings.EqualFold(dn[:7], "LDAP://") {
+			dn = dn[7:]
+		}
+		if dn == "" {
+			continue
+		}
+		links = append(links, GPLink{GPODN: dn, Disabled: opts&0x1 != 0, Enforced: opts&0x2 != 0})
+	}
+	return links
+}
+
+func policyFromEntry(entry *LDIFEntry) *DomainPolicy {
+	if entry.Get("minPwdLength") == "" && entry.Get("lockoutThreshold") == "" && entry.Get("maxPwdAge") == "" {
+		return nil
+	}
+	atoi := func(name string) int {
+		v, _ := strconv.Atoi(entry.Get(name))
+		return v
+	}
+	return &DomainPolicy{
+		MinPwdLength:        atoi("minPwdLength"),
+		PwdHistoryLength:    atoi("pwdHistoryLength"),
+		PwdProperties:       atoi("pwdProperties"),
+		LockoutThreshold:    atoi("lockoutThreshold"),
+		LockoutDuration:     parseInterval(entry.Get("lockoutDuration")),
+		MaxPwdAge:           parseInterval(entry.Get("maxPwdAge")),
+		MinPwdAge:           parseInterval(entry.Get("minPwdAge")),
+		MachineAccountQuota: atoi("ms-DS-MachineAccountQuota"),
+	}
+}
+
+// parseInterval converts an AD interval attribute to a positive duration.
+// AD stores these as negative counts of 100ns; the "never" value is the
+// minimum int64 and maps to zero.
+func parseInterval(s string) time.Duration {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || v == -1<<63 {
+		return 0
+	}
+	if v < 0 {
+		v = -v
+	}
+	return time.Duration(v) * 100
+}
+
+// DomainFromDN derives the DNS domain from the DC= components of a DN
+// (CN=x,DC=corp,DC=local → CORP.LOCAL).
+func DomainFromDN(dn string) string {
+	var labels []string
+	for _, part := range strings.Split(dn, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) > 3 && strings.EqualFold(part[:3], "DC=") {
+			labels = append(labels, part[3:])
+		}
+	}
+	return strings.ToUpper(strings.Join(labels, "."))
+}
+
+// ParentDN returns the DN of the container holding dn (escaped commas aware).
+func ParentDN(dn string) string {
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',':
+			return strings.TrimSpace(dn[i+1:])
+		}
+	}
+	return ""
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
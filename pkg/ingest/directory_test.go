@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLDIFBuildsIndexedDirectory(t *testing.T) {
+	ldif := "dn: DC=corp,DC=local\n" +
+		"objectClass: domainDNS\n" +
+		"gPLink: [LDAP://cn={31B2F340-016D-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=corp,DC=local;0]\n" +
+		"\n" +
+		"dn: OU=Workstations,DC=corp,DC=local\n" +
+		"objectClass: organizationalUnit\n" +
+		"ou: Workstations\n" +
+		"gPOptions: 1\n" +
+		"gPLink: [LDAP://cn={6AC1786C-016F-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=corp,DC=local;2][LDAP://cn={00000000-0000-0000-0000-000000000000},cn=policies,cn=system,DC=corp,DC=local;1]\n" +
+		"\n" +
+		"dn: cn={6AC1786C-016F-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=corp,DC=local\n" +
+		"objectClass: groupPolicyContainer\n" +
+		"cn: {6AC1786C-016F-11D2-945F-00C04FB984F9}\n" +
+		"displayName: Workstation Baseline\n" +
+		"gPCFileSysPath: \\\\corp.local\\SysVol\\corp.local\\Policies\\{6AC1786C-016F-11D2-945F-00C04FB984F9}\n" +
+		"\n" +
+		"dn: CN=WS01,OU=Workstations,DC=corp,DC=local\n" +
+		"objectClass: computer\n" +
+		"sAMAccountName: WS01$\n" +
+		"userAccountControl: 16781312\n" +
+		"ms-DS-CreatorSID:: " + base64.StdEncoding.EncodeToString(testSID) + "\n" +
+		"ms-Mcs-AdmPwdExpirationTime: 133500000000000000\n" +
+		"\n" +
+		"dn: CN=alice,OU=Staff,DC=corp,DC=local\n" +
+		"objectClass: user\n" +
+		"sAMAccountName: alice\n" +
+		"objectSid:: " + base64.StdEncoding.EncodeToString(testSID) + "\n"
+
+	path := filepath.Join(t.TempDir(), "corp.ldif")
+	if err := os.WriteFile(path, []byte(ldif), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(dir.OUs) != 1 || len(dir.GPOs) != 1 || len(dir.Computers) != 1 || len(dir.Users) != 1 {
+		t.Fatalf("unexpected object counts: %d OUs, %d GPOs, %d computers, %d users", len(dir.OUs), len(dir.GPOs), len(dir.Computers), len(dir.Users))
+	}
+	if len(dir.DomainGPLinks) != 1 {
+		t.Fatalf("expected domain gPLink, got %+v", dir.DomainGPLinks)
+	}
+
+	ou := dir.OUByDN("ou=workstations,dc=corp,dc=local")
+	if ou == nil || !ou.BlocksInheritance || len(ou.GPLinks) != 2 {
+		t.Fatalf("unexpected OU %+v", ou)
+	}
+	if !ou.GPLinks[0].Enforced || !ou.GPLinks[1].Disabled {
+		t.Fatalf("gPLink options not decoded: %+v", ou.GPLinks)
+	}
+	if gpo := dir.GPOByDN(ou.GPLinks[0].GPODN); gpo == nil || gpo.DisplayName != "Workstation Baseline" {
+		t.Fatalf("linked GPO not indexed: %+v", gpo)
+	}
+
+	if ref, ok := dir.LookupSAM("ws01$"); !ok || ref.Kind != KindComputer {
+		t.Fatalf("computer not indexed by sAMAccountName: %+v", ref)
+	}
+	if ref, ok := dir.LookupSID("S-1-5-21-1-2-3-1104"); !ok || ref.DN != "CN=alice,OU=Staff,DC=corp,DC=local" {
+		t.Fatalf("user not indexed by SID: %+v", ref)
+	}
+	ws := dir.Computers[0]
+	if ws.CreatorSID != "S-1-5-21-1-2-3-1104" || !ws.HasLAPS() || !ws.ProtocolTransition() || ws.UnconstrainedDelegation() {
+		t.Fatalf("computer attributes not decoded: %+v", ws)
+	}
+	if ParentDN(dir.Computers[0].DistinguishedName) != ou.DistinguishedName {
+		t.Fatalf("unexpected parent DN %q", ParentDN(dir.Computers[0].DistinguishedName))
+	}
+}
+
+func TestLoadCSVInfersGroups(t *testing.T) {
+	csv := "samaccountname,distinguishedname,memberof\n" +
+		"alice,\"CN=alice,OU=Staff,DC=corp,DC=local\",\"CN=Helpdesk,OU=Groups,DC=corp,DC=local;CN=Domain Admins,CN=Users,DC=corp,DC=local\"\n" +
+		"bob,\"CN=bob,OU=Staff,DC=corp,DC=local\",\"CN=Helpdesk,OU=Groups,DC=corp,DC=local\"\n"
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if dir.Domain != "CORP.LOCAL" {
+		t.Fatalf("unexpected domain %q", dir.Domain)
+	}
+	if len(dir.Groups) != 2 {
+		t.Fatalf("expected 2 groups inferred from memberOf, got %+v", dir.Groups)
+	}
+	helpdesk := dir.GroupByDN("CN=Helpdesk,OU=Groups,DC=corp,DC=local")
+	if helpdesk == nil || helpdesk.SamAccountName != "Helpdesk" || len(helpdesk.Members) != 2 {
+		t.Fatalf("unexpected inferred group %+v", helpdesk)
+	}
+	if got := cnFromDN(`CN=Admins\, Tier 0,CN=Users,DC=corp,DC=local`); got != "Admins, Tier 0" {
+		t.Fatalf("cnFromDN = %q", got)
+	}
+}
@@ -0,0 +1,234 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ldapDomainDumpFiles are the JSON outputs of ldapdomaindump that carry
+// directory objects. Missing files are skipped.
+var ldapDomainDumpFiles = []string{
+	"domain_policy.json",
+	"domain_users.json",
+	"domain_groups.json",
+	"domain_computers.json",
+}
+
+// ldapDomainDumpEntry is one object as serialised by ldapdomaindump (ldap3
+// entry_to_json): multi-valued attributes are lists, binary values base64.
+type ldapDomainDumpEntry struct {
+	DN         string                 `json:"dn"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// filetimeAttributes are rendered by ldap3 as datetimes; they are converted
+// back to FILETIME so every reader hands parseTime the same input. The schema
+// decoder uses the same list to tell FILETIMEs from plain large integers.
+var filetimeAttributes = map[string]bool{
+	"pwdlastset":                    true,
+	"lastlogon":                     true,
+	"lastlogontimestamp":            true,
+	"lastlogoff":                    true,
+	"accountexpires":                true,
+	"badpasswordtime":               true,
+	"lockouttime":                   true,
+	"creationtime":                  true,
+	"ms-mcs-admpwdexpirationtime":   true,
+	"mslaps-passwordexpirationtime": true,
+}
+
+// intervalAttributes are rendered by ldap3 as Python timedeltas.
+var intervalAttributes = map[string]bool{
+	"maxpwdage":                     true,
+	"minpwdage":                     true,
+	"lockoutduration":               true,
+	"lockoutobservationwindow":      true,
+	"forcelogoff":                   true,
+	"msds-maximumpasswordage":       true,
+	"msds-minimumpasswordage":       true,
+	"msds-lockoutduration":          true,
+	"msds-lockoutobservationwindow": true,
+}
+
+func isLDAPDomainDump(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "domain_users.json"))
+	return err == nil
+}
+
+// ParseLDAPDomainDump reads the JSON files written by ldapdomaindump from a
+// directory into a Directory.
+func ParseLDAPDomainDump(dir string) (*Directory, error) {
+	var entries []LDIFEntry
+	found := false
+	for _, name := range ldapDomainDumpFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		var raw []ldapDomainDumpEntry
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		for _, r := range raw {
+			entries = append(entries, entryFromLDAPDomainDump(r))
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no ldapdomaindump JSON files in %s", dir)
+	}
+	return DirectoryFromEntries(entries), nil
+}
+
+func entryFromLDAPDomainDump(r ldapDomainDumpEntry) LDIFEntry {
+	entry := LDIFEntry{DN: r.DN}
+	for name, value := range r.Attributes {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		attr := LDIFAttribute{Name: name}
+		for _, v := range values {
+			if b, ok := ldapDomainDumpValue(name, v); ok {
+				attr.Values = append(attr.Values, b)
+			}
+		}
+		if len(attr.Values) > 0 {
+			entry.Attributes = append(entry.Attributes, attr)
+		}
+	}
+	if entry.DN == "" {
+		entry.DN = entry.Get("distinguishedName")
+	}
+	return entry
+}
+
+func ldapDomainDumpValue(name string, v interface{}) ([]byte, bool) {
+	lower := strings.ToLower(name)
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case bool:
+		if val {
+			return []byte("TRUE"), true
+		}
+		return []byte("FALSE"), true
+	case float64:
+		return []byte(strconv.FormatInt(int64(val), 10)), true
+	case string:
+		switch {
+		case lower == "objectsid" || lower == "sidhistory" || lower == "securityidentifier":
+			if sid, err := encodeSID(val); err == nil {
+				return sid, true
+			}
+		case IsBinaryAttribute(lower):
+			if b, err := base64.StdEncoding.DecodeString(val); err == nil {
+				return b, true
+			}
+		case filetimeAttributes[lower]:
+			return []byte(strconv.FormatInt(filetimeFromString(val), 10)), true
+		case intervalAttributes[lower]:
+			if d, ok := parseTimedelta(val); ok {
+				return []byte(strconv.FormatInt(-int64(d/100), 10)), true
+			}
+		}
+		return []byte(val), true
+	default:
+		return []byte(fmt.Sprintf("%v", val)), true
+	}
+}
+
+// filetimeFromString converts an ldap3 datetime ("2024-01-05 10:11:12.123456+00:00")
+// back to a FILETIME. The 1601 epoch ("never") becomes 0.
+func filetimeFromString(s string) int64 {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07:00", "2006-01-02 15:04:05-07:00", time.RFC3339Nano} {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if t.Year() <= 1601 {
+			return 0
+		}
+		return t.Unix()*10000000 + int64(t.Nanosecond()/100) + 116444736000000000
+	}
+	return 0
+}
+
+// parseTimedelta parses Python's str(timedelta): "42 days, 0:00:00",
+// "-1 day, 23:30:00" or "0:30:00". The magnitude is returned.
+func parseTimedelta(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	var days int64
+	if i := strings.Index(s, ","); i >= 0 {
+		fields := strings.Fields(s[:i])
+		if len(fields) != 2 {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		days = n
+		s = strings.TrimSpace(s[i+1:])
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	h, err1 := strconv.ParseInt(parts[0], 10, 64)
+	m, err2 := strconv.ParseInt(parts[1], 10, 64)
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	d := time.Duration(days)*24*time.Hour + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second))
+	if d < 0 {
+		d = -d
+	}
+	return d, true
+}
+
+// encodeSID converts S-1-5-21-... into its binary form (MS-DTYP 2.4.2.2).
+func encodeSID(s string) ([]byte, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return nil, fmt.Errorf("invalid SID %q", s)
+	}
+	rev, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SID revision in %q", s)
+	}
+	authority, err := strconv.ParseUint(parts[2], 10, 48)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SID authority in %q", s)
+	}
+	subs := parts[3:]
+	out := make([]byte, 8, 8+4*len(subs))
+	out[0] = byte(rev)
+	out[1] = byte(len(subs))
+	for i := 0; i < 6; i++ {
+		out[7-i] = byte(authority >> (8 * i))
+	}
+	for _, sub := range subs {
+		v, err := strconv.ParseUint(sub, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SID sub-authority in %q", s)
+		}
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+	}
+	return out, nil
+}
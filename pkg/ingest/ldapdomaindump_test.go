@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLDAPDomainDump(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"domain_policy.json": `[{"attributes": {
+			"distinguishedName": ["DC=corp,DC=local"],
+			"objectClass": ["top", "domain", "domainDNS"],
+			"objectSid": ["S-1-5-21-1-2-3"],
+			"minPwdLength": [7],
+			"pwdProperties": [1],
+			"lockoutThreshold": [5],
+			"lockoutDuration": ["-1 day, 23:30:00"],
+			"maxPwdAge": ["-42 days, 0:00:00"],
+			"ms-DS-MachineAccountQuota": [10]
+		}, "dn": "DC=corp,DC=local"}]`,
+		"domain_users.json": `[{"attributes": {
+			"distinguishedName": ["CN=svc_sql,OU=Service Accounts,DC=corp,DC=local"],
+			"objectClass": ["top", "person", "organizationalPerson", "user"],
+			"sAMAccountName": ["svc_sql"],
+			"objectSid": ["S-1-5-21-1-2-3-1104"],
+			"userAccountControl": [4260352],
+			"servicePrincipalName": ["MSSQLSvc/sql01.corp.local:1433"],
+			"memberOf": ["CN=SQL Admins,OU=Groups,DC=corp,DC=local"],
+			"pwdLastSet": ["2024-01-05 10:11:12.123456+00:00"],
+			"lastLogon": ["1601-01-01 00:00:00+00:00"]
+		}, "dn": "CN=svc_sql,OU=Service Accounts,DC=corp,DC=local"}]`,
+		"domain_groups.json": `[{"attributes": {
+			"distinguishedName": ["CN=SQL Admins,OU=Groups,DC=corp,DC=local"],
+			"objectClass": ["top", "group"],
+			"sAMAccountName": ["SQL Admins"],
+			"objectSid": ["S-1-5-21-1-2-3-1110"],
+			"adminCount": [1]
+		}, "dn": "CN=SQL Admins,OU=Groups,DC=corp,DC=local"}]`,
+		"domain_computers.json": `[{"attributes": {
+			"distinguishedName": ["CN=SQL01,OU=Servers,DC=corp,DC=local"],
+			"objectClass": ["top", "person", "organizationalPerson", "user", "computer"],
+			"sAMAccountName": ["SQL01$"],
+			"dNSHostName": ["sql01.corp.local"],
+			"operatingSystem": ["Windows Server 2019 Standard"],
+			"userAccountControl": [4096]
+		}, "dn": "CN=SQL01,OU=Servers,DC=corp,DC=local"}]`,
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if d.Domain != "CORP.LOCAL" || d.DomainSID != "S-1-5-21-1-2-3" {
+		t.Fatalf("unexpected domain %q / %q", d.Domain, d.DomainSID)
+	}
+	if len(d.Users) != 1 || len(d.Groups) != 1 || len(d.Computers) != 1 {
+		t.Fatalf("unexpected object counts: %d users, %d groups, %d computers", len(d.Users), len(d.Groups), len(d.Computers))
+	}
+
+	user := d.Users[0]
+	if user.ObjectSID != "S-1-5-21-1-2-3-1104" || !user.DoesNotRequirePreAuth {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if user.PwdLastSet.Year() != 2024 || !user.LastLogon.IsZero() {
+		t.Fatalf("unexpected timestamps: pwdLastSet=%v lastLogon=%v", user.PwdLastSet, user.LastLogon)
+	}
+	if !d.Groups[0].AdminCount || len(d.Groups[0].Members) != 1 {
+		t.Fatalf("unexpected group %+v", d.Groups[0])
+	}
+
+	policy := d.Policy
+	if policy == nil {
+		t.Fatal("expected domain policy")
+	}
+	if policy.LockoutThreshold != 5 || policy.LockoutDuration.Minutes() != 30 || policy.MaxPwdAge.Hours() != 42*24 || policy.MachineAccountQuota != 10 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+}
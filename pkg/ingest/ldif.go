@@ -0,0 +1,463 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LDIFAttribute is one attribute of an LDIF record. Values are kept as raw
+// bytes so binary attributes (objectSid, nTSecurityDescriptor, ...) survive
+// base64 decoding untouched.
+type LDIFAttribute struct {
+	Name    string   // attribute type without options
+	Options []string // attribute options such as "binary" or "range=0-1499"
+	Values  [][]byte
+}
+
+// LDIFModification is one mod-spec of a "changetype: modify" record.
+type LDIFModification struct {
+	Operation string // add | delete | replace | increment
+	Attribute LDIFAttribute
+}
+
+// LDIFEntry is a single LDIF record. Content records and "changetype: add"
+// records carry Attributes; other change records carry ChangeType and, for
+// modify, the list of Modifications.
+type LDIFEntry struct {
+	DN            string
+	ChangeType    string
+	Attributes    []LDIFAttribute
+	Modifications []LDIFModification
+	NewRDN        string
+	DeleteOldRDN  bool
+	NewSuperior   string
+	// RefusedURLs are the "attr:< URL" values left out of Attributes: an
+	// export must not be able to pull files off the analyst's machine.
+	RefusedURLs []string
+	// Schema, when set, decides how attribute values are decoded into
+	// RawFields and DecodedFields; nil uses the built-in syntax table.
+	Schema *Schema
+}
+
+// binaryAttributes are AD attributes whose values are not printable text.
+var binaryAttributes = map[string]bool{
+	"objectsid":            true,
+	"objectguid":           true,
+	"sidhistory":           true,
+	"ntsecuritydescriptor": true,
+	"tokengroups":          true,
+	"msds-allowedtoactonbehalfofotheridentity": true,
+	"msds-generationid":                        true,
+	"msds-managedpasswordid":                   true,
+	"msds-managedpassword":                     true,
+	"msds-groupmsamembership":                  true,
+	"usercertificate":                          true,
+	"cacertificate":                            true,
+	"thumbnailphoto":                           true,
+	"jpegphoto":                                true,
+	"logonhours":                               true,
+	"securityidentifier":                       true,
+	"pkiexpirationperiod":                      true,
+	"pkioverlapperiod":                         true,
+	"pkikeyusage":                              true,
+}
+
+// IsBinaryAttribute reports whether an attribute is stored as raw bytes in AD.
+func IsBinaryAttribute(name string) bool {
+	return binaryAttributes[strings.ToLower(name)]
+}
+
+// Get returns the first value of an attribute as a string (case-insensitive name).
+func (e *LDIFEntry) Get(name string) string {
+	values := e.GetRawValues(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return string(values[0])
+}
+
+// GetAll returns every value of an attribute as strings.
+func (e *LDIFEntry) GetAll(name string) []string {
+	values := e.GetRawValues(name)
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, string(v))
+	}
+	return out
+}
+
+// GetRaw returns the first value of an attribute as bytes.
+func (e *LDIFEntry) GetRaw(name string) []byte {
+	values := e.GetRawValues(name)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// GetRawValues returns every value of an attribute as bytes. Ranged attributes
+// (member;range=0-1499) are merged into their base attribute.
+func (e *LDIFEntry) GetRawValues(name string) [][]byte {
+	var out [][]byte
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			out = append(out, attr.Values...)
+		}
+	}
+	return out
+}
+
+// ObjectClasses returns the objectClass values of the entry.
+func (e *LDIFEntry) ObjectClasses() []string {
+	return e.GetAll("objectClass")
+}
+
+// HasObjectClass reports whether the entry carries the given objectClass.
+func (e *LDIFEntry) HasObjectClass(class string) bool {
+	for _, oc := range e.ObjectClasses() {
+		if strings.EqualFold(oc, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLDIFFile reads every record from an LDIF file.
+func ParseLDIFFile(path string) ([]LDIFEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadLDIF(file)
+}
+
+// ReadLDIF parses an RFC 2849 LDIF stream. It handles folded continuation
+// lines, comments, base64 ("attr::") values, attribute options and change
+// records. URL ("attr:<") values are never read; they are listed in the
+// entry's RefusedURLs.
+func ReadLDIF(r io.Reader) ([]LDIFEntry, error) {
+	records, err := splitLDIFRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LDIFEntry
+	for i, lines := range records {
+		if i == 0 && len(lines) > 0 && strings.HasPrefix(strings.ToLower(lines[0].text), "version:") {
+			lines = lines[1:]
+			if len(lines) == 0 {
+				continue
+			}
+		}
+		entry, err := parseLDIFRecord(lines)
+		if err != nil {
+			return entries, fmt.Errorf("ldif record at line %d: %w", lines[0].number, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+type ldifLine struct {
+	number int
+	text   string
+}
+
+// splitLDIFRecords unfolds continuation lines, drops comments and groups the
+// remaining logical lines into blank-line separated records.
+func splitLDIFRecords(r io.Reader) ([][]ldifLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	var records [][]ldifLine
+	var current []ldifLine
+	inComment := false
+	number := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			records = append(records, current)
+			current = nil
+		}
+	}
+
+	for scanner.Scan() {
+		number++
+		raw := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+
+		if strings.HasPrefix(raw, " ") {
+			if inComment {
+				continue
+			}
+			if len(current) == 0 {
+				return nil, fmt.Errorf("ldif line %d: continuation line without a preceding attribute", number)
+			}
+			current[len(current)-1].text += raw[1:]
+			continue
+		}
+		inComment = false
+
+		if strings.TrimSpace(raw) == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(raw, "#") {
+			inComment = true
+			continue
+		}
+		current = append(current, ldifLine{number: number, text: raw})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return records, nil
+}
+
+func parseLDIFRecord(lines []ldifLine) (LDIFEntry, error) {
+	var entry LDIFEntry
+
+	name, _, value, err := parseLDIFLine(lines[0].text)
+	if err != nil {
+		return entry, err
+	}
+	if !strings.EqualFold(name, "dn") {
+		return entry, fmt.Errorf("record does not start with dn: (got %q)", name)
+	}
+	entry.DN = string(value)
+	lines = lines[1:]
+
+	// Skip control: lines that may precede changetype.
+	for len(lines) > 0 && strings.HasPrefix(strings.ToLower(lines[0].text), "control:") {
+		lines = lines[1:]
+	}
+
+	if len(lines) > 0 {
+		name, _, value, err := parseLDIFLine(lines[0].text)
+		if err != nil {
+			return entry, err
+		}
+		if strings.EqualFold(name, "changetype") {
+			entry.ChangeType = strings.ToLower(strings.TrimSpace(string(value)))
+			lines = lines[1:]
+		}
+	}
+
+	switch entry.ChangeType {
+	case "", "add":
+		for _, line := range lines {
+			name, options, value, err := parseLDIFLine(line.text)
+			if errors.Is(err, errLDIFURL) {
+				entry.RefusedURLs = append(entry.RefusedURLs, name+": "+string(value))
+				continue
+			}
+			if err != nil {
+				return entry, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			entry.Attributes = appendLDIFValue(entry.Attributes, name, options, value)
+		}
+	case "delete":
+	case "modrdn", "moddn":
+		for _, line := range lines {
+			name, _, value, err := parseLDIFLine(line.text)
+			if err != nil {
+				return entry, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			switch strings.ToLower(name) {
+			case "newrdn":
+				entry.NewRDN = string(value)
+			case "deleteoldrdn":
+				entry.DeleteOldRDN = strings.TrimSpace(string(value)) == "1"
+			case "newsuperior":
+				entry.NewSuperior = string(value)
+			}
+		}
+	case "modify":
+		var mod *LDIFModification
+		for _, line := range lines {
+			if line.text == "-" {
+				if mod != nil {
+					entry.Modifications = append(entry.Modifications, *mod)
+				}
+				mod = nil
+				continue
+			}
+			name, options, value, err := parseLDIFLine(line.text)
+			if errors.Is(err, errLDIFURL) && mod != nil {
+				entry.RefusedURLs = append(entry.RefusedURLs, name+": "+string(value))
+				continue
+			}
+			if err != nil {
+				return entry, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			if mod == nil {
+				op := strings.ToLower(name)
+				switch op {
+				case "add", "delete", "replace", "increment":
+				default:
+					return entry, fmt.Errorf("line %d: unknown modify operation %q", line.number, name)
+				}
+				attrName, attrOptions := splitAttributeOptions(strings.TrimSpace(string(value)))
+				mod = &LDIFModification{Operation: op, Attribute: LDIFAttribute{Name: attrName, Options: attrOptions}}
+				continue
+			}
+			mod.Attribute.Values = append(mod.Attribute.Values, value)
+			if len(options) > 0 && len(mod.Attribute.Options) == 0 {
+				mod.Attribute.Options = options
+			}
+		}
+		if mod != nil {
+			entry.Modifications = append(entry.Modifications, *mod)
+		}
+	default:
+		return entry, fmt.Errorf("unsupported changetype %q", entry.ChangeType)
+	}
+
+	return entry, nil
+}
+
+// parseLDIFLine splits "attr;opt: value", "attr:: base64" and "attr:< url".
+func parseLDIFLine(line string) (string, []string, []byte, error) {
+	idx := strings.Index(line, ":")
+	if idx <= 0 {
+		return "", nil, nil, fmt.Errorf("missing attribute separator in %q", line)
+	}
+	name, options := splitAttributeOptions(line[:idx])
+	rest := line[idx+1:]
+
+	switch {
+	case strings.HasPrefix(rest, ":"):
+		encoded := strings.TrimSpace(rest[1:])
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("invalid base64 value for %s: %w", name, err)
+		}
+		return name, options, value, nil
+	case strings.HasPrefix(rest, "<"):
+		return name, options, []byte(strings.TrimSpace(rest[1:])), errLDIFURL
+	default:
+		return name, options, []byte(strings.TrimLeft(rest, " ")), nil
+	}
+}
+
+func splitAttributeOptions(attr string) (string, []string) {
+	parts := strings.Split(strings.TrimSpace(attr), ";")
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return parts[0], parts[1:]
+}
+
+// errLDIFURL marks an "attr:< URL" value. The LDIF comes from the client
+// being assessed, so a file:// reference is not followed: it could name
+// /etc/shadow or an SSH key and land the contents in the results.
+var errLDIFURL = errors.New("URL values are not read")
+
+func appendLDIFValue(attrs []LDIFAttribute, name string, options []string, value []byte) []LDIFAttribute {
+	for i := range attrs {
+		if strings.EqualFold(attrs[i].Name, name) && equalOptions(attrs[i].Options, options) {
+			attrs[i].Values = append(attrs[i].Values, value)
+			return attrs
+		}
+	}
+	return append(attrs, LDIFAttribute{Name: name, Options: options, Values: [][]byte{value}})
+}
+
+func equalOptions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// usersFromLDIF maps user-class LDIF records into User values.
+func usersFromLDIF(entries []LDIFEntry) []User {
+	var users []User
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ChangeType != "" && entry.ChangeType != "add" {
+			continue
+		}
+		if !isUserEntry(entry) {
+			continue
+		}
+		users = append(users, userFromLDIF(entry))
+	}
+	return users
+}
+
+func userFromLDIF(entry *LDIFEntry) User {
+	user := User{
+		SamAccountName:             entry.Get("sAMAccountName"),
+		DistinguishedName:          entry.DN,
+		Description:                entry.Get("description"),
+		Info:                       entry.Get("info"),
+		Comment:                    entry.Get("comment"),
+		PhysicalDeliveryOfficeName: entry.Get("physicalDeliveryOfficeName"),
+		PostOfficeBox:              entry.Get("postOfficeBox"),
+		Email:                      entry.Get("mail"),
+		ServicePrincipalNames:      entry.GetAll("servicePrincipalName"),
+		MemberOf:                   entry.GetAll("memberOf"),
+		PwdLastSet:                 parseTime(entry.Get("pwdLastSet")),
+		LastLogon:                  parseTime(entry.Get("lastLogon")),
+		LastLogonTimestamp:         parseTime(entry.Get("lastLogonTimestamp")),
+		NTSecurityDescriptor:       entry.GetRaw("nTSecurityDescriptor"),
+	}
+	user.RawFields, user.DecodedFields = entry.Schema.DecodeFields(entry.Attributes)
+	if sid, err := FormatSID(entry.GetRaw("objectSid")); err == nil {
+		user.ObjectSID = sid
+	}
+	if uac := entry.Get("userAccountControl"); uac != "" {
+		user.UserAccountControl, _ = strconv.Atoi(uac)
+		user.DoesNotRequirePreAuth = user.UserAccountControl&0x400000 != 0
+	}
+	user.PrimaryGroupID, _ = strconv.Atoi(entry.Get("primaryGroupID"))
+	user.SupportedEncryptionTypes, _ = strconv.Atoi(entry.Get("msDS-SupportedEncryptionTypes"))
+	return user
+}
+
+func isPrintable(b []byte) bool {
+	return utf8.Valid(b) && !bytes.ContainsRune(b, 0)
+}
+
+// FormatSID renders a binary SID (MS-DTYP 2.4.2.2) as S-1-5-21-...
+func FormatSID(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", fmt.Errorf("sid too short")
+	}
+	subCount := int(b[1])
+	if len(b) < 8+subCount*4 {
+		return "", fmt.Errorf("sid truncated")
+	}
+	var authority uint64
+	for i := 2; i < 8; i++ {
+		authority = (authority << 8) | uint64(b[i])
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "S-%d-%d", b[0], authority)
+	for i := 0; i < subCount; i++ {
+		fmt.Fprintf(&sb, "-%d", binary.LittleEndian.Uint32(b[8+i*4:12+i*4]))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,176 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sid S-1-5-21-1-2-3-1104
+var testSID = []byte{
+	0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+	0x15, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00,
+	0x50, 0x04, 0x00, 0x00,
+}
+
+func TestReadLDIFDecodesRFC2849Values(t *testing.T) {
+	ldif := "version: 1\n" +
+		"# exported by ldapsearch\n" +
+		"#  folded comment continuation\n" +
+		"dn: CN=svc_sql,OU=Service\n" +
+		"  Accounts,DC=corp,DC=local\n" +
+		"objectClass: top\n" +
+		"objectClass: person\n" +
+		"objectClass: user\n" +
+		"sAMAccountName: svc_sql\n" +
+		"description:: " + base64.StdEncoding.EncodeToString([]byte("Pässword: Winter2024!")) + "\n" +
+		"objectSid:: " + base64.StdEncoding.EncodeToString(testSID) + "\n" +
+		"servicePrincipalName: MSSQLSvc/sql01.corp.local:1433\n" +
+		"servicePrincipalName: MSSQLSvc/sql01.corp.local\n" +
+		"userAccountControl: 4260352\n" +
+		"\n" +
+		"dn: CN=Helpdesk,OU=Groups,DC=corp,DC=local\n" +
+		"objectClass: group\n" +
+		"member: CN=svc_sql,OU=Service Accounts,DC=corp,DC=local\n"
+
+	entries, err := ReadLDIF(strings.NewReader(ldif))
+	if err != nil {
+		t.Fatalf("ReadLDIF failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	user := entries[0]
+	if user.DN != "CN=svc_sql,OU=Service Accounts,DC=corp,DC=local" {
+		t.Fatalf("folded DN not joined: %q", user.DN)
+	}
+	if got := user.Get("description"); got != "Pässword: Winter2024!" {
+		t.Fatalf("base64 value not decoded: %q", got)
+	}
+	if !bytes.Equal(user.GetRaw("objectsid"), testSID) {
+		t.Fatal("objectSid bytes not preserved")
+	}
+	if len(user.GetAll("servicePrincipalName")) != 2 {
+		t.Fatalf("expected 2 SPNs, got %v", user.GetAll("servicePrincipalName"))
+	}
+	if !entries[1].HasObjectClass("group") {
+		t.Fatal("non-user entries must be returned")
+	}
+}
+
+func TestReadLDIFChangeRecords(t *testing.T) {
+	ldif := "dn: CN=alice,DC=corp,DC=local\n" +
+		"changetype: add\n" +
+		"objectClass: user\n" +
+		"sAMAccountName: alice\n" +
+		"\n" +
+		"dn: CN=Helpdesk,DC=corp,DC=local\n" +
+		"changetype: modify\n" +
+		"add: member\n" +
+		"member: CN=alice,DC=corp,DC=local\n" +
+		"-\n" +
+		"replace: description\n" +
+		"description: tier 1\n" +
+		"-\n" +
+		"\n" +
+		"dn: CN=bob,DC=corp,DC=local\n" +
+		"changetype: delete\n"
+
+	entries, err := ReadLDIF(strings.NewReader(ldif))
+	if err != nil {
+		t.Fatalf("ReadLDIF failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(entries))
+	}
+	if entries[0].ChangeType != "add" || entries[0].Get("sAMAccountName") != "alice" {
+		t.Fatalf("add record not parsed: %+v", entries[0])
+	}
+	if len(entries[1].Modifications) != 2 || entries[1].Modifications[0].Attribute.Name != "member" {
+		t.Fatalf("modify record not parsed: %+v", entries[1].Modifications)
+	}
+	if entries[2].ChangeType != "delete" {
+		t.Fatalf("expected delete record, got %q", entries[2].ChangeType)
+	}
+
+	users := usersFromLDIF(entries)
+	if len(users) != 1 || users[0].SamAccountName != "alice" {
+		t.Fatalf("expected only the added user, got %+v", users)
+	}
+}
+
+func TestParseADLDIFKeepsBinaryAttributes(t *testing.T) {
+	ldif := "dn: CN=svc_sql,DC=corp,DC=local\n" +
+		"objectClass: user\n" +
+		"sAMAccountName: svc_sql\n" +
+		"objectSid:: " + base64.StdEncoding.EncodeToString(testSID) + "\n" +
+		"nTSecurityDescriptor:: AQAEgBQAAAAwAAAAAAAAAEwAAAA=\n" +
+		"userAccountControl: 4260352\n"
+
+	path := filepath.Join(t.TempDir(), "dump.ldf")
+	if err := os.WriteFile(path, []byte(ldif), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := ParseAD(path)
+	if err != nil {
+		t.Fatalf("ParseAD failed: %v", err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	if users[0].ObjectSID != "S-1-5-21-1-2-3-1104" {
+		t.Fatalf("unexpected SID %q", users[0].ObjectSID)
+	}
+	if len(users[0].NTSecurityDescriptor) != 20 {
+		t.Fatalf("expected raw security descriptor bytes, got %d", len(users[0].NTSecurityDescriptor))
+	}
+	if users[0].RawFields["objectSid"] != "S-1-5-21-1-2-3-1104" {
+		t.Fatalf("objectSid RawFields should be decoded by syntax, got %q", users[0].RawFields["objectSid"])
+	}
+	if !users[0].DoesNotRequirePreAuth {
+		t.Fatal("DONT_REQ_PREAUTH should be derived from userAccountControl")
+	}
+}
+
+func TestReadLDIFRefusesFileURLs(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(secret, []byte("PRIVATE KEY"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ldif := "dn: CN=alice,DC=corp,DC=local\n" +
+		"objectClass: user\n" +
+		"sAMAccountName: alice\n" +
+		"description:< file:///etc/passwd\n" +
+		"info:< file://" + secret + "\n" +
+		"\n" +
+		"dn: CN=Helpdesk,DC=corp,DC=local\n" +
+		"changetype: modify\n" +
+		"replace: description\n" +
+		"description:< file:///etc/passwd\n" +
+		"-\n"
+
+	entries, err := ReadLDIF(strings.NewReader(ldif))
+	if err != nil {
+		t.Fatalf("ReadLDIF failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(entries))
+	}
+	alice := entries[0]
+	if alice.Get("sAMAccountName") != "alice" || alice.Get("description") != "" || alice.Get("info") != "" {
+		t.Fatalf("URL values were read: %+v", alice.Attributes)
+	}
+	if len(alice.RefusedURLs) != 2 || alice.RefusedURLs[0] != "description: file:///etc/passwd" {
+		t.Fatalf("refused URLs not recorded: %q", alice.RefusedURLs)
+	}
+	mod := entries[1].Modifications
+	if len(mod) != 1 || len(mod[0].Attribute.Values) != 0 || len(entries[1].RefusedURLs) != 1 {
+		t.Fatalf("URL value read into a modification: %+v", entries[1])
+	}
+}
@@ -0,0 +1,232 @@
+package ingest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// GroupMembership is one group a principal belongs to once group nesting and
+// the primary group are taken into account.
+type GroupMembership struct {
+	DN   string
+	Name string
+	SID  string
+	// Primary is set when the membership comes from primaryGroupID (directly
+	// or through a group the primary group is nested in).
+	Primary bool
+	// Chain lists the group DNs from the directly held group down to DN. It
+	// is empty when a server-side transitive query reported the membership
+	// but the intermediate groups were not collected.
+	Chain []string
+}
+
+// Nested reports whether the membership is only held through another group.
+func (m GroupMembership) Nested() bool {
+	return len(m.Chain) > 1
+}
+
+// Path renders the nesting chain as group names (Helpdesk → Account Operators).
+func (m GroupMembership) Path() string {
+	if len(m.Chain) == 0 {
+		return firstNonEmptyString(m.Name, cnFromDN(m.DN))
+	}
+	names := make([]string, len(m.Chain))
+	for i, dn := range m.Chain {
+		names[i] = cnFromDN(dn)
+	}
+	return strings.Join(names, " → ")
+}
+
+// EffectiveGroupDNs returns the DN of every effective group, falling back to
+// the direct memberOf values when memberships have not been resolved.
+func (u *User) EffectiveGroupDNs() []string {
+	if len(u.EffectiveGroups) == 0 {
+		return u.MemberOf
+	}
+	dns := make([]string, len(u.EffectiveGroups))
+	for i, m := range u.EffectiveGroups {
+		dns[i] = m.DN
+	}
+	return dns
+}
+
+// wellKnownPrimaryGroups names the domain-relative RIDs that appear as
+// primaryGroupID, used when the export does not carry the group object.
+var wellKnownPrimaryGroups = map[int]string{
+	512: "Domain Admins",
+	513: "Domain Users",
+	514: "Domain Guests",
+	515: "Domain Computers",
+	516: "Domain Controllers",
+	521: "Read-only Domain Controllers",
+}
+
+// ResolveMemberships fills EffectiveGroups on every user and computer with
+// the transitive closure of memberOf/member plus the primary group.
+func (d *Directory) ResolveMemberships() {
+	// Either side of the link may be missing depending on the collector, so
+	// index both memberOf and member.
+	parents := make(map[string][]string)
+	addParent := func(child, parent string) {
+		key := strings.ToLower(child)
+		if child == "" || parent == "" || containsFold(parents[key], parent) {
+			return
+		}
+		parents[key] = append(parents[key], parent)
+	}
+	for _, g := range d.Groups {
+		for _, parent := range g.MemberOf {
+			addParent(g.DistinguishedName, parent)
+		}
+		for _, member := range g.Members {
+			addParent(d.resolveForeignPrincipal(member), g.DistinguishedName)
+		}
+	}
+	direct := func(dn string, memberOf []string) []string {
+		out := append([]string(nil), memberOf...)
+		for _, parent := range parents[strings.ToLower(dn)] {
+			if !containsFold(out, parent) {
+				out = append(out, parent)
+			}
+		}
+		return out
+	}
+
+	for i := range d.Users {
+		u := &d.Users[i]
+		u.EffectiveGroups = d.closure(direct(u.DistinguishedName, u.MemberOf), d.primaryGroupDN(u.DistinguishedName, u.ObjectSID, u.PrimaryGroupID), parents)
+	}
+	for i := range d.Computers {
+		c := &d.Computers[i]
+		c.EffectiveGroups = d.closure(direct(c.DistinguishedName, c.MemberOf), d.primaryGroupDN(c.DistinguishedName, c.ObjectSID, c.PrimaryGroupID), parents)
+	}
+}
+
+// closure walks group nesting breadth-first from the direct groups so each
+// membership records its shortest chain. Cycles are cut by the visited set.
+func (d *Directory) closure(direct []string, primary string, parents map[string][]string) []GroupMembership {
+	type step struct {
+		chain   []string
+		primary bool
+	}
+	var queue []step
+	for _, dn := range direct {
+		queue = append(queue, step{chain: []string{dn}})
+	}
+	if primary != "" {
+		queue = append(queue, step{chain: []string{primary}, primary: true})
+	}
+
+	var out []GroupMembership
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		dn := cur.chain[len(cur.chain)-1]
+		if seen[strings.ToLower(dn)] {
+			continue
+		}
+		seen[strings.ToLower(dn)] = true
+
+		m := GroupMembership{DN: dn, Name: cnFromDN(dn), Primary: cur.primary, Chain: cur.chain}
+		if g := d.GroupByDN(dn); g != nil {
+			m.Name = firstNonEmptyString(g.SamAccountName, m.Name)
+			m.SID = g.ObjectSID
+		}
+		out = append(out, m)
+
+		for _, parent := range parents[strings.ToLower(dn)] {
+			if seen[strings.ToLower(parent)] {
+				continue
+			}
+			chain := append(append([]string(nil), cur.chain...), parent)
+			queue = append(queue, step{chain: chain, primary: cur.primary})
+		}
+	}
+	return out
+}
+
+// primaryGroupDN maps a primaryGroupID to a group DN: by SID when the domain
+// SID is known, then by well-known name, and finally as a synthesized
+// CN=...,CN=Users DN so the membership is still visible.
+func (d *Directory) primaryGroupDN(principalDN, principalSID string, rid int) string {
+	if rid == 0 {
+		return ""
+	}
+	// The principal's own SID names its domain, which matters once several
+	// domains are merged into one Directory.
+	domainSID := d.DomainSID
+	if i := strings.LastIndex(principalSID, "-"); i > 0 {
+		domainSID = principalSID[:i]
+	}
+	if domainSID != "" {
+		if ref, ok := d.LookupSID(domainSID + "-" + strconv.Itoa(rid)); ok && ref.Kind == KindGroup {
+			return ref.DN
+		}
+	}
+	name, ok := wellKnownPrimaryGroups[rid]
+	if !ok {
+		return ""
+	}
+	if ref, ok := d.LookupSAM(name); ok && ref.Kind == KindGroup && DomainFromDN(ref.DN) == DomainFromDN(principalDN) {
+		return ref.DN
+	}
+	dn := "CN=" + name + ",CN=Users"
+	if base := domainDNFromDN(principalDN); base != "" {
+		dn += "," + base
+	}
+	return dn
+}
+
+// resolveForeignPrincipal maps a CN=<SID>,CN=ForeignSecurityPrincipals
+// member back to the collected object with that SID, if any.
+func (d *Directory) resolveForeignPrincipal(dn string) string {
+	if !strings.Contains(strings.ToLower(dn), ",cn=foreignsecurityprincipals,") {
+		return dn
+	}
+	if ref, ok := d.LookupSID(cnFromDN(dn)); ok {
+		return ref.DN
+	}
+	return dn
+}
+
+// MergeChainedGroups adds groups reported by a server-side transitive query
+// (LDAP_MATCHING_RULE_IN_CHAIN) that the local closure did not reach.
+func MergeChainedGroups(known []GroupMembership, chained []string) []GroupMembership {
+	for _, dn := range chained {
+		found := false
+		for _, m := range known {
+			if strings.EqualFold(m.DN, dn) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			known = append(known, GroupMembership{DN: dn, Name: cnFromDN(dn)})
+		}
+	}
+	return known
+}
+
+// RIDFromSID returns the final sub-authority of a SID string, or 0.
+func RIDFromSID(sid string) int {
+	i := strings.LastIndex(sid, "-")
+	if i < 0 {
+		return 0
+	}
+	rid, _ := strconv.Atoi(sid[i+1:])
+	return rid
+}
+
+// domainDNFromDN keeps the DC= components of a DN (CN=x,DC=corp,DC=local →
+// DC=corp,DC=local).
+func domainDNFromDN(dn string) string {
+	var parts []string
+	for _, part := range strings.Split(dn, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) > 3 && strings.EqualFold(part[:3], "DC=") {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import "testing"
+
+func TestResolveMembershipsFollowsNestingAndPrimaryGroup(t *testing.T) {
+	const (
+		helpdesk = "CN=Helpdesk,OU=Groups,DC=corp,DC=local"
+		accOps   = "CN=Account Operators,CN=Builtin,DC=corp,DC=local"
+		das      = "CN=Domain Admins,CN=Users,DC=corp,DC=local"
+		loopA    = "CN=LoopA,OU=Groups,DC=corp,DC=local"
+		loopB    = "CN=LoopB,OU=Groups,DC=corp,DC=local"
+	)
+	dir := &Directory{
+		DomainSID: "S-1-5-21-1-2-3",
+		Groups: []Group{
+			// Nesting recorded only on the parent's member list.
+			{SamAccountName: "Account Operators", DistinguishedName: accOps, Members: []string{helpdesk}},
+			{SamAccountName: "Helpdesk", DistinguishedName: helpdesk, MemberOf: []string{loopA}},
+			{SamAccountName: "Domain Admins", DistinguishedName: das, ObjectSID: "S-1-5-21-1-2-3-512"},
+			{SamAccountName: "LoopA", DistinguishedName: loopA, MemberOf: []string{loopB}},
+			{SamAccountName: "LoopB", DistinguishedName: loopB, MemberOf: []string{loopA}},
+		},
+		Users: []User{
+			{SamAccountName: "alice", DistinguishedName: "CN=alice,OU=Staff,DC=corp,DC=local", MemberOf: []string{helpdesk}, PrimaryGroupID: 513},
+			{SamAccountName: "bob", DistinguishedName: "CN=bob,OU=Staff,DC=corp,DC=local", PrimaryGroupID: 512},
+		},
+	}
+	dir.ResolveMemberships()
+
+	alice := dir.Users[0]
+	byName := make(map[string]GroupMembership)
+	for _, m := range alice.EffectiveGroups {
+		byName[m.Name] = m
+	}
+	if len(byName) != 5 {
+		t.Fatalf("expected Helpdesk, Account Operators, LoopA, LoopB and Domain Users, got %+v", alice.EffectiveGroups)
+	}
+	if m := byName["Account Operators"]; !m.Nested() || m.Path() != "Helpdesk → Account Operators" {
+		t.Fatalf("unexpected nested membership %+v (%s)", m, m.Path())
+	}
+	if m := byName["LoopB"]; len(m.Chain) != 3 {
+		t.Fatalf("expected shortest chain through the cycle, got %+v", m.Chain)
+	}
+	if m := byName["Domain Users"]; !m.Primary || m.DN != "CN=Domain Users,CN=Users,DC=corp,DC=local" {
+		t.Fatalf("unexpected synthesized primary group %+v", m)
+	}
+
+	bob := dir.Users[1]
+	if len(bob.EffectiveGroups) != 1 || bob.EffectiveGroups[0].DN != das || !bob.EffectiveGroups[0].Primary {
+		t.Fatalf("primary group not resolved by SID: %+v", bob.EffectiveGroups)
+	}
+	if dns := bob.EffectiveGroupDNs(); len(dns) != 1 || dns[0] != das {
+		t.Fatalf("EffectiveGroupDNs = %v", dns)
+	}
+}
+
+func TestMergeChainedGroups(t *testing.T) {
+	known := []GroupMembership{{DN: "CN=Helpdesk,DC=corp,DC=local", Chain: []string{"CN=Helpdesk,DC=corp,DC=local"}}}
+	merged := MergeChainedGroups(known, []string{"cn=helpdesk,dc=corp,dc=local", "CN=Tier0,DC=corp,DC=local"})
+	if len(merged) != 2 || merged[1].Name != "Tier0" || len(merged[1].Chain) != 0 {
+		t.Fatalf("unexpected merge %+v", merged)
+	}
+	if RIDFromSID("S-1-5-21-1-2-3-513") != 513 {
+		t.Fatal("RIDFromSID failed")
+	}
+}
+
+func TestMergeResolvesCrossDomainMembership(t *testing.T) {
+	root := &Directory{
+		Domain:    "CORP.LOCAL",
+		DomainSID: "S-1-5-21-1-1-1",
+		Groups: []Group{{
+			SamAccountName:    "Enterprise Admins",
+			DistinguishedName: "CN=Enterprise Admins,CN=Users,DC=corp,DC=local",
+			ObjectSID:         "S-1-5-21-1-1-1-519",
+			Members:           []string{"CN=S-1-5-21-2-2-2-1105,CN=ForeignSecurityPrincipals,DC=corp,DC=local"},
+		}},
+		Users: []User{{SamAccountName: "admin", DistinguishedName: "CN=admin,CN=Users,DC=corp,DC=local"}},
+	}
+	child := &Directory{
+		Domain: "EU.CORP.LOCAL",
+		Users: []User{{
+			SamAccountName:    "admin",
+			DistinguishedName: "CN=admin,CN=Users,DC=eu,DC=corp,DC=local",
+			ObjectSID:         "S-1-5-21-2-2-2-1105",
+		}},
+	}
+	root.Merge(child)
+	root.ResolveMemberships()
+
+	if len(root.Domains) != 2 || len(root.Users) != 2 {
+		t.Fatalf("unexpected merge: domains=%v users=%d", root.Domains, len(root.Users))
+	}
+	if len(root.Users[0].EffectiveGroups) != 0 {
+		t.Fatalf("root admin should not inherit the child account's groups: %+v", root.Users[0].EffectiveGroups)
+	}
+	groups := root.Users[1].EffectiveGroups
+	if len(groups) != 1 || groups[0].Name != "Enterprise Admins" {
+		t.Fatalf("expected child admin in Enterprise Admins via FSP, got %+v", groups)
+	}
+}
@@ -25,10 +25,16 @@ type User struct {
 	UserAccountControl         int
 	ServicePrincipalNames      []string
 	PwdLastSet                 time.Time
+	SupportedEncryptionTypes   int // msDS-SupportedEncryptionTypes; 0 when unset
 	LastLogon                  time.Time
 	LastLogonTimestamp         time.Time
 	MemberOf                   []string
+	PrimaryGroupID             int
+	EffectiveGroups            []GroupMembership // filled by Directory.ResolveMemberships
+	ObjectSID                  string
+	NTSecurityDescriptor       []byte
 	RawFields                  map[string]string
+	DecodedFields              map[string]interface{} // typed values decoded by attribute syntax (see Schema)
 }
 
 func ParseAD(path string) ([]User, error) {
@@ -39,7 +45,7 @@ func ParseAD(path string) ([]User, error) {
 		return parseCSV(path)
 	case ".json":
 		return parseJSON(path)
-	case ".ldif":
+	case ".ldif", ".ldf":
 		return parseLDIF(path)
 	default:
 		// Try to detect format by content
@@ -187,70 +193,11 @@ func parseJSON(path string) ([]User, error) {
 }
 
 func parseLDIF(path string) ([]User, error) {
-	// Simplified LDIF parser
-	data, err := os.ReadFile(path)
+	entries, err := ParseLDIFFile(path)
 	if err != nil {
 		return nil, err
 	}
-
-	var users []User
-	lines := strings.Split(string(data), "\n")
-
-	var currentUser *User
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			if currentUser != nil && currentUser.SamAccountName != "" {
-				users = append(users, *currentUser)
-			}
-			currentUser = nil
-			continue
-		}
-
-		if strings.HasPrefix(line, "dn:") {
-			currentUser = &User{
-				RawFields:         make(map[string]string),
-				DistinguishedName: strings.TrimSpace(strings.TrimPrefix(line, "dn:")),
-			}
-			continue
-		}
-
-		if currentUser == nil {
-			continue
-		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		currentUser.RawFields[key] = value
-
-		switch normalizeFieldName(key) {
-		case "samaccountname":
-			currentUser.SamAccountName = value
-		case "useraccountcontrol":
-			currentUser.UserAccountControl, _ = strconv.Atoi(value)
-		case "serviceprincipalname":
-			currentUser.ServicePrincipalNames = append(currentUser.ServicePrincipalNames, value)
-		case "pwdlastset":
-			currentUser.PwdLastSet = parseTime(value)
-		case "lastlogon":
-			currentUser.LastLogon = parseTime(value)
-		case "memberof":
-			currentUser.MemberOf = append(currentUser.MemberOf, value)
-		}
-	}
-
-	if currentUser != nil && currentUser.SamAccountName != "" {
-		users = append(users, *currentUser)
-	}
-
-	return users, nil
+	return usersFromLDIF(entries), nil
 }
 
 func detectAndParse(path string) ([]User, error) {
@@ -263,7 +210,7 @@ func detectAndParse(path string) ([]User, error) {
 
 	if strings.Contains(content, "\"samAccountName\"") || strings.HasPrefix(strings.TrimSpace(content), "[") {
 		return parseJSON(path)
-	} else if strings.Contains(content, "dn:") {
+	} else if strings.Contains(content, "dn:") || strings.HasPrefix(strings.TrimSpace(content), "version:") {
 		return parseLDIF(path)
 	} else {
 		return parseCSV(path)
@@ -317,8 +264,12 @@ func parseGroups(s string) []string {
 	}
 
 	var groups []string
-	// Try multiple delimiters
+	// Try multiple delimiters. A comma only separates plain group names; in a
+	// DN it separates RDNs, so a single DN must not be split on it.
 	for _, delim := range []string{";", ",", "|"} {
+		if delim == "," && strings.Contains(s, "=") {
+			continue
+		}
 		if strings.Contains(s, delim) {
 			parts := strings.Split(s, delim)
 			for _, part := range parts {
@@ -359,6 +310,8 @@ func parseTime(s string) time.Time {
 	// Try ISO formats
 	formats := []string{
 		time.RFC3339,
+		"20060102150405.0Z", // GeneralizedTime (whenCreated, whenChanged)
+		"20060102150405Z",
 		"2006-01-02T15:04:05",
 		"2006-01-02 15:04:05",
 		"2006-01-02",
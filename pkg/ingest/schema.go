@@ -0,0 +1,433 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AttributeSyntax is how an attribute's values are encoded, derived from
+// attributeSchema (attributeSyntax, oMSyntax, oMObjectClass).
+type AttributeSyntax string
+
+const (
+	SyntaxString             AttributeSyntax = "string"
+	SyntaxDN                 AttributeSyntax = "dn"
+	SyntaxInteger            AttributeSyntax = "integer"
+	SyntaxBoolean            AttributeSyntax = "boolean"
+	SyntaxGeneralizedTime    AttributeSyntax = "generalized_time"
+	SyntaxOctet              AttributeSyntax = "octet"
+	SyntaxSID                AttributeSyntax = "sid"
+	SyntaxGUID               AttributeSyntax = "guid"
+	SyntaxFileTime           AttributeSyntax = "filetime"
+	SyntaxInterval           AttributeSyntax = "interval"
+	SyntaxDNBinary           AttributeSyntax = "dn_binary"
+	SyntaxDNString           AttributeSyntax = "dn_string"
+	SyntaxSecurityDescriptor AttributeSyntax = "security_descriptor"
+)
+
+// oMObjectClass values (BER-encoded OIDs) that split syntax 2.5.5.7 and 2.5.5.14.
+var (
+	omObjectClassDNBinary = []byte{0x2a, 0x86, 0x48, 0x86, 0xf7, 0x14, 0x01, 0x01, 0x01, 0x0b} // 1.2.840.113556.1.1.1.11
+	omObjectClassDNString = []byte{0x2a, 0x86, 0x48, 0x86, 0xf7, 0x14, 0x01, 0x01, 0x01, 0x0c} // 1.2.840.113556.1.1.1.12
+)
+
+// largeIntegerSyntax gives the meaning of a 2.5.5.16 attribute, which the
+// schema only describes as a 64-bit integer.
+func largeIntegerSyntax(name string) AttributeSyntax {
+	switch lower := strings.ToLower(name); {
+	case filetimeAttributes[lower]:
+		return SyntaxFileTime
+	case intervalAttributes[lower]:
+		return SyntaxInterval
+	}
+	return SyntaxInteger
+}
+
+// builtinSyntaxes covers the attributes Cold Relay reads when no schema was
+// read from the DC (offline exports).
+var builtinSyntaxes = map[string]AttributeSyntax{
+	"objectsid":              SyntaxSID,
+	"sidhistory":             SyntaxSID,
+	"tokengroups":            SyntaxSID,
+	"securityidentifier":     SyntaxSID,
+	"ms-ds-creatorsid":       SyntaxSID,
+	"objectguid":             SyntaxGUID,
+	"schemaidguid":           SyntaxGUID,
+	"attributesecurityguid":  SyntaxGUID,
+	"msds-managedpasswordid": SyntaxOctet,
+	"ntsecuritydescriptor":   SyntaxSecurityDescriptor,
+	"msds-allowedtoactonbehalfofotheridentity": SyntaxSecurityDescriptor,
+	"msds-groupmsamembership":                  SyntaxSecurityDescriptor,
+	"msds-keycredentiallink":                   SyntaxDNBinary,
+	"wellknownobjects":                         SyntaxDNBinary,
+	"otherwellknownobjects":                    SyntaxDNBinary,
+	"msds-revealedusers":                       SyntaxDNBinary,
+	"whenchanged":                              SyntaxGeneralizedTime,
+	"whencreated":                              SyntaxGeneralizedTime,
+	"member":                                   SyntaxDN,
+	"memberof":                                 SyntaxDN,
+	"distinguishedname":                        SyntaxDN,
+	"useraccountcontrol":                       SyntaxInteger,
+	"admincount":                               SyntaxInteger,
+	"primarygroupid":                           SyntaxInteger,
+	"iscriticalsystemobject":                   SyntaxBoolean,
+}
+
+func init() {
+	for name := range filetimeAttributes {
+		builtinSyntaxes[name] = SyntaxFileTime
+	}
+	for name := range intervalAttributes {
+		builtinSyntaxes[name] = SyntaxInterval
+	}
+	for name := range binaryAttributes {
+		if _, ok := builtinSyntaxes[name]; !ok {
+			builtinSyntaxes[name] = SyntaxOctet
+		}
+	}
+}
+
+// Schema maps attribute names to syntaxes. A nil or empty Schema falls back
+// to the built-in table.
+type Schema struct {
+	syntaxes map[string]AttributeSyntax
+}
+
+// NewSchema returns an empty schema to be filled from attributeSchema objects.
+func NewSchema() *Schema {
+	return &Schema{syntaxes: make(map[string]AttributeSyntax)}
+}
+
+// Add records the syntax of one attribute.
+func (s *Schema) Add(name string, syntax AttributeSyntax) {
+	s.syntaxes[strings.ToLower(name)] = syntax
+}
+
+// Len returns how many attributes the schema describes.
+func (s *Schema) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.syntaxes)
+}
+
+// Syntax returns the syntax of an attribute, preferring the DC's schema.
+func (s *Schema) Syntax(name string) AttributeSyntax {
+	key := strings.ToLower(name)
+	if s != nil {
+		if syntax, ok := s.syntaxes[key]; ok {
+			return syntax
+		}
+	}
+	if syntax, ok := builtinSyntaxes[key]; ok {
+		return syntax
+	}
+	return SyntaxString
+}
+
+// SyntaxFromSchema maps an attributeSchema definition onto an AttributeSyntax.
+// Large integers and octet strings carry no further type information, so the
+// attribute name decides between FILETIME/interval/integer and GUID/octet.
+func SyntaxFromSchema(name, attributeSyntax string, omObjectClass []byte) AttributeSyntax {
+	switch attributeSyntax {
+	case "2.5.5.17":
+		return SyntaxSID
+	case "2.5.5.15":
+		return SyntaxSecurityDescriptor
+	case "2.5.5.1":
+		return SyntaxDN
+	case "2.5.5.7":
+		if bytes.Equal(omObjectClass, omObjectClassDNString) {
+			return SyntaxDNString
+		}
+		return SyntaxDNBinary
+	case "2.5.5.14":
+		if bytes.Equal(omObjectClass, omObjectClassDNBinary) {
+			return SyntaxDNBinary
+		}
+		return SyntaxDNString
+	case "2.5.5.8":
+		return SyntaxBoolean
+	case "2.5.5.9":
+		return SyntaxInteger
+	case "2.5.5.11":
+		return SyntaxGeneralizedTime
+	case "2.5.5.16":
+		return largeIntegerSyntax(name)
+	case "2.5.5.10":
+		if strings.HasSuffix(strings.ToLower(name), "guid") {
+			return SyntaxGUID
+		}
+		return SyntaxOctet
+	default:
+		return SyntaxString
+	}
+}
+
+// DecodeFields renders attributes as RawFields text and, for syntaxes that
+// are not plain text, as typed values for JSON. Multi-valued text is joined
+// with ";" as before; binary values never appear as raw bytes.
+func (s *Schema) DecodeFields(attrs []LDIFAttribute) (map[string]string, map[string]interface{}) {
+	raw := make(map[string]string, len(attrs))
+	var decoded map[string]interface{}
+	for _, attr := range attrs {
+		syntax := s.Syntax(attr.Name)
+		for _, opt := range attr.Options {
+			if strings.EqualFold(opt, "binary") && syntax == SyntaxString {
+				syntax = SyntaxOctet
+			}
+		}
+		texts := make([]string, 0, len(attr.Values))
+		typed := make([]interface{}, 0, len(attr.Values))
+		for _, v := range attr.Values {
+			value, text := decodeValue(syntax, v)
+			texts = append(texts, text)
+			typed = append(typed, value)
+		}
+		raw[attr.Name] = strings.Join(texts, ";")
+		if !typedSyntax(syntax) {
+			continue
+		}
+		if decoded == nil {
+			decoded = make(map[string]interface{})
+		}
+		if len(typed) == 1 {
+			decoded[attr.Name] = typed[0]
+		} else {
+			decoded[attr.Name] = typed
+		}
+	}
+	return raw, decoded
+}
+
+// typedSyntax reports whether values gain anything from a typed rendering.
+func typedSyntax(syntax AttributeSyntax) bool {
+	switch syntax {
+	case SyntaxSID, SyntaxGUID, SyntaxFileTime, SyntaxInterval, SyntaxDNBinary, SyntaxDNString, SyntaxSecurityDescriptor, SyntaxOctet:
+		return true
+	}
+	return false
+}
+
+// Interval is a negative 100ns duration such as maxPwdAge.
+type Interval struct {
+	Seconds int64  `json:"seconds"`
+	Text    string `json:"text"`
+}
+
+// DNWithData is a DN-Binary (B:len:hex:dn) or DN-String (S:len:str:dn) value.
+type DNWithData struct {
+	DN     string `json:"dn"`
+	Binary string `json:"binary,omitempty"` // hex
+	String string `json:"string,omitempty"`
+}
+
+// SecurityDescriptor is a decoded self-relative SECURITY_DESCRIPTOR.
+type SecurityDescriptor struct {
+	Owner string    `json:"owner,omitempty"`
+	Group string    `json:"group,omitempty"`
+	DACL  []ACEInfo `json:"dacl,omitempty"`
+}
+
+// ACEInfo is one DACL entry of a decoded security descriptor.
+type ACEInfo struct {
+	Type       string `json:"type"`
+	SID        string `json:"sid"`
+	Mask       string `json:"mask"`
+	ObjectType string `json:"object_type,omitempty"`
+	Inherited  bool   `json:"inherited,omitempty"`
+}
+
+// decodeValue returns the typed value and its RawFields text. Binary syntaxes
+// get a readable text form; integers and DN-Binary keep their wire text, which
+// is already printable. Values that do not parse as their syntax fall back to
+// the raw text, or base64 when it is not printable.
+func decodeValue(syntax AttributeSyntax, v []byte) (interface{}, string) {
+	fallback := func() (interface{}, string) {
+		if isPrintable(v) {
+			return string(v), string(v)
+		}
+		b64 := base64.StdEncoding.EncodeToString(v)
+		return b64, b64
+	}
+	switch syntax {
+	case SyntaxSID:
+		if s := string(v); strings.HasPrefix(s, "S-1-") {
+			return s, s
+		}
+		if sid, err := FormatSID(v); err == nil {
+			return sid, sid
+		}
+	case SyntaxGUID:
+		if len(v) == 16 {
+			guid := FormatGUID(v)
+			return guid, guid
+		}
+	case SyntaxFileTime:
+		n, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+		if err != nil {
+			break
+		}
+		if n <= 0 || n == 0x7FFFFFFFFFFFFFFF {
+			return nil, string(v)
+		}
+		return filetimeToTime(n).Format(time.RFC3339), string(v)
+	case SyntaxInterval:
+		n, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+		if err != nil {
+			break
+		}
+		if n == -0x8000000000000000 {
+			return nil, string(v)
+		}
+		if n < 0 {
+			n = -n
+		}
+		return Interval{Seconds: n / 1e7, Text: formatInterval(time.Duration(n) * 100)}, string(v)
+	case SyntaxDNBinary, SyntaxDNString:
+		if d, ok := parseDNWithData(string(v)); ok {
+			return d, string(v)
+		}
+	case SyntaxSecurityDescriptor:
+		b64 := base64.StdEncoding.EncodeToString(v)
+		if sd, err := ParseSecurityDescriptor(v); err == nil {
+			return sd, b64
+		}
+		return b64, b64
+	case SyntaxOctet:
+		b64 := base64.StdEncoding.EncodeToString(v)
+		return b64, b64
+	}
+	return fallback()
+}
+
+// FormatGUID renders a 16-byte GUID in its little-endian AD layout as the
+// canonical 8-4-4-4-12 string.
+func FormatGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%s-%s",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		hex.EncodeToString(b[8:10]),
+		hex.EncodeToString(b[10:16]))
+}
+
+func filetimeToTime(ft int64) time.Time {
+	const epochDelta = 116444736000000000 // 1601-01-01 to 1970-01-01 in 100ns
+	return time.Unix(0, (ft-epochDelta)*100).UTC()
+}
+
+func formatInterval(d time.Duration) string {
+	days := int64(d / (24 * time.Hour))
+	rest := d % (24 * time.Hour)
+	switch {
+	case days > 0 && rest == 0:
+		return fmt.Sprintf("%dd", days)
+	case days > 0:
+		return fmt.Sprintf("%dd%s", days, rest)
+	default:
+		return d.String()
+	}
+}
+
+// parseDNWithData splits B:<len>:<hex>:<dn> and S:<len>:<string>:<dn>.
+func parseDNWithData(s string) (DNWithData, bool) {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 {
+		return DNWithData{}, false
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n != len(parts[2]) {
+		return DNWithData{}, false
+	}
+	switch parts[0] {
+	case "B":
+		return DNWithData{DN: parts[3], Binary: strings.ToLower(parts[2])}, true
+	case "S":
+		return DNWithData{DN: parts[3], String: parts[2]}, true
+	}
+	return DNWithData{}, false
+}
+
+var aceTypeNames = map[byte]string{
+	0x00: "ACCESS_ALLOWED",
+	0x01: "ACCESS_DENIED",
+	0x02: "SYSTEM_AUDIT",
+	0x05: "ACCESS_ALLOWED_OBJECT",
+	0x06: "ACCESS_DENIED_OBJECT",
+	0x07: "SYSTEM_AUDIT_OBJECT",
+}
+
+// ParseSecurityDescriptor decodes the owner, group and DACL of a
+// self-relative SECURITY_DESCRIPTOR (MS-DTYP 2.4.6).
+func ParseSecurityDescriptor(b []byte) (*SecurityDescriptor, error) {
+	if len(b) < 20 || b[0] != 1 {
+		return nil, fmt.Errorf("not a self-relative security descriptor")
+	}
+	sd := &SecurityDescriptor{}
+	sidAt := func(off uint32) string {
+		if off == 0 || int(off) >= len(b) {
+			return ""
+		}
+		sid, _ := FormatSID(b[off:])
+		return sid
+	}
+	sd.Owner = sidAt(binary.LittleEndian.Uint32(b[4:8]))
+	sd.Group = sidAt(binary.LittleEndian.Uint32(b[8:12]))
+
+	daclOff := int(binary.LittleEndian.Uint32(b[16:20]))
+	if daclOff == 0 {
+		return sd, nil
+	}
+	if daclOff+8 > len(b) {
+		return nil, fmt.Errorf("DACL offset out of range")
+	}
+	count := int(binary.LittleEndian.Uint16(b[daclOff+4 : daclOff+6]))
+	off := daclOff + 8
+	for i := 0; i < count; i++ {
+		if off+8 > len(b) {
+			return nil, fmt.Errorf("ACE %d truncated", i)
+		}
+		aceType, flags := b[off], b[off+1]
+		size := int(binary.LittleEndian.Uint16(b[off+2 : off+4]))
+		if size < 8 || off+size > len(b) {
+			return nil, fmt.Errorf("ACE %d has invalid size", i)
+		}
+		body := b[off+4 : off+size]
+		ace := ACEInfo{
+			Type:      aceTypeNames[aceType],
+			Mask:      fmt.Sprintf("0x%08x", binary.LittleEndian.Uint32(body[0:4])),
+			Inherited: flags&0x10 != 0,
+		}
+		if ace.Type == "" {
+			ace.Type = fmt.Sprintf("0x%02x", aceType)
+		}
+		sidStart := 4
+		if aceType >= 0x05 && aceType <= 0x08 && len(body) >= 8 {
+			objFlags := binary.LittleEndian.Uint32(body[4:8])
+			sidStart = 8
+			if objFlags&0x1 != 0 && len(body) >= sidStart+16 {
+				ace.ObjectType = FormatGUID(body[sidStart : sidStart+16])
+				sidStart += 16
+			}
+			if objFlags&0x2 != 0 {
+				sidStart += 16
+			}
+		}
+		if sidStart < len(body) {
+			ace.SID, _ = FormatSID(body[sidStart:])
+		}
+		sd.DACL = append(sd.DACL, ace)
+		off += size
+	}
+	return sd, nil
+}
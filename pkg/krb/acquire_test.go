@@ -0,0 +1,113 @@
+package krb
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRoaster answers roasting requests without a KDC and counts them.
+type fakeRoaster struct {
+	mu       sync.Mutex
+	requests map[string]int
+	active   int
+	peak     int
+	refuse   map[string]bool
+}
+
+func (f *fakeRoaster) enter(key string) {
+	f.mu.Lock()
+	f.requests[key]++
+	f.active++
+	if f.active > f.peak {
+		f.peak = f.active
+	}
+	f.mu.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	f.mu.Lock()
+	f.active--
+	f.mu.Unlock()
+}
+
+func (f *fakeRoaster) ExtractASREPHash(username string) (HashRecord, error) {
+	f.enter(username)
+	return HashRecord{Etype: 23, HashcatMode: 18200, Hash: "$krb5asrep$23$" + username + "@CORP.LOCAL:00$11"}, nil
+}
+
+func (f *fakeRoaster) ExtractKerberoastHash(username, spn string) (HashRecord, error) {
+	f.enter(spn)
+	if f.refuse[spn] {
+		return HashRecord{}, fmt.Errorf("KRB Error: (7) KDC_ERR_S_PRINCIPAL_UNKNOWN Server not found in Kerberos database")
+	}
+	return HashRecord{SPN: spn, Etype: 23, HashcatMode: 13100, Hash: "$krb5tgs$23$*" + username + "$CORP.LOCAL$" + spn + "*$00$11"}, nil
+}
+
+func TestAcquireHashesRequestsEachSPNOnce(t *testing.T) {
+	var spns []string
+	for i := 0; i < 12; i++ {
+		spns = append(spns, fmt.Sprintf("HTTP/web%02d", i))
+	}
+	candidates := []Candidate{
+		{SamAccountName: "svc", Type: "KERBEROAST", SPNs: spns},
+		{SamAccountName: "bob", Type: "ASREP"},
+		{SamAccountName: "done", Type: "KERBEROAST", SPNs: []string{"HTTP/done"},
+			Hashes: []HashRecord{{SPN: "HTTP/done", Hash: "stored"}}},
+		{SamAccountName: "stale", Type: "KERBEROAST", SPNs: []string{"HTTP/gone"}},
+		{SamAccountName: "alice", Type: "HVT"},
+	}
+	roaster := &fakeRoaster{requests: map[string]int{}, refuse: map[string]bool{"HTTP/gone": true}}
+
+	stats := AcquireHashes(roaster, candidates, AcquireOptions{Workers: 3})
+	if stats.Requests != 14 || stats.Hashes != 13 || stats.Failures != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+	for key, n := range roaster.requests {
+		if n != 1 {
+			t.Errorf("%s requested %d times", key, n)
+		}
+	}
+	if roaster.requests["HTTP/done"] != 0 {
+		t.Error("candidate with stored hashes was requested again")
+	}
+	if roaster.peak > 3 {
+		t.Errorf("%d concurrent requests, want at most 3", roaster.peak)
+	}
+
+	svc := candidates[0]
+	if len(svc.Hashes) != len(spns) {
+		t.Fatalf("svc has %d records", len(svc.Hashes))
+	}
+	for i, r := range svc.Hashes {
+		if r.SPN != spns[i] {
+			t.Errorf("record %d is %s, want %s", i, r.SPN, spns[i])
+		}
+	}
+	if svc.Hash != svc.Hashes[0].Hash {
+		t.Errorf("svc hash %q", svc.Hash)
+	}
+	if candidates[1].Hash == "" || len(candidates[1].Hashes) != 1 {
+		t.Errorf("bob %+v", candidates[1])
+	}
+	if candidates[2].Hashes[0].Hash != "stored" {
+		t.Errorf("stored records replaced: %+v", candidates[2].Hashes)
+	}
+	stale := candidates[3]
+	if stale.Hash != "" || len(stale.Hashes) != 1 || stale.Hashes[0].OK() || stale.Hashes[0].ErrorCode != 7 || stale.Hashes[0].SPN != "HTTP/gone" {
+		t.Errorf("stale %+v", stale.Hashes)
+	}
+	if candidates[4].Hashes != nil {
+		t.Errorf("HVT candidate got records")
+	}
+}
+
+func TestAcquireHashesPacesRequests(t *testing.T) {
+	candidates := []Candidate{{SamAccountName: "svc", Type: "KERBEROAST", SPNs: []string{"a/1", "a/2", "a/3", "a/4", "a/5"}}}
+	roaster := &fakeRoaster{requests: map[string]int{}}
+
+	start := time.Now()
+	AcquireHashes(roaster, candidates, AcquireOptions{Workers: 5, Interval: 20 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("5 requests 20ms apart finished in %s", elapsed)
+	}
+}
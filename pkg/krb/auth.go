@@ -0,0 +1,301 @@
+package krb
+
+import (
+	"crypto"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldap/v3/gssapi"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// AuthMethod selects how Connect authenticates the LDAP session.
+type AuthMethod string
+
+const (
+	AuthAnonymous   AuthMethod = "anonymous"
+	AuthSimple      AuthMethod = "simple"
+	AuthNTLM        AuthMethod = "ntlm"
+	AuthKerberos    AuthMethod = "kerberos"
+	AuthCertificate AuthMethod = "cert"
+)
+
+// ParseAuthMethod validates a --auth value. An empty string means "infer
+// from the supplied credentials".
+func ParseAuthMethod(s string) (AuthMethod, error) {
+	switch m := AuthMethod(strings.ToLower(strings.TrimSpace(s))); m {
+	case "", AuthAnonymous, AuthSimple, AuthNTLM, AuthKerberos, AuthCertificate:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown auth method %q (use simple, ntlm, kerberos or cert)", s)
+	}
+}
+
+// authMethod returns the explicit method, or infers one from which
+// credential material is present.
+func (o ConnectOptions) authMethod() AuthMethod {
+	switch {
+	case o.Auth != "":
+		return o.Auth
+	case o.CertFile != "":
+		return AuthCertificate
+	case o.CCache != "" || o.Keytab != "":
+		return AuthKerberos
+	case o.NTHash != "":
+		return AuthNTLM
+	case o.BindUser != "" && o.BindPass != "":
+		return AuthSimple
+	default:
+		return AuthAnonymous
+	}
+}
+
+// bindLDAP authenticates conn using the method selected by opts. host is the
+// DC hostname used to build the ldap/<host> service principal.
+func bindLDAP(conn *ldap.Conn, opts ConnectOptions, host string) error {
+	switch method := opts.authMethod(); method {
+	case AuthSimple:
+		log.Printf("[*] Binding as %s...", opts.BindUser)
+		if err := conn.Bind(opts.BindUser, opts.BindPass); err != nil {
+			return fmt.Errorf("LDAP bind as '%s' failed: %w\n"+
+				"    Hint: Try DOMAIN\\user, user@domain.com, or full DN format", opts.BindUser, err)
+		}
+		log.Printf("[+] Authenticated bind successful")
+
+	case AuthNTLM:
+		domain, user := splitBindUser(opts.BindUser)
+		if domain == "" {
+			domain = opts.Realm
+		}
+		if user == "" {
+			return fmt.Errorf("NTLM bind requires a username (-u)")
+		}
+		if opts.NTHash != "" {
+			hash, err := normalizeNTHash(opts.NTHash)
+			if err != nil {
+				return err
+			}
+			log.Printf("[*] NTLM bind as %s\\%s (NT hash)...", domain, user)
+			if err := conn.NTLMBindWithHash(domain, user, hash); err != nil {
+				return fmt.Errorf("NTLM bind with hash as '%s\\%s' failed: %w", domain, user, err)
+			}
+		} else {
+			log.Printf("[*] NTLM bind as %s\\%s...", domain, user)
+			if err := conn.NTLMBind(domain, user, opts.BindPass); err != nil {
+				return fmt.Errorf("NTLM bind as '%s\\%s' failed: %w", domain, user, err)
+			}
+		}
+		log.Printf("[+] NTLM bind successful")
+
+	case AuthKerberos:
+		gc, err := newGSSAPIClient(opts, host)
+		if err != nil {
+			return err
+		}
+		defer gc.Close()
+		spn := "ldap/" + host
+		log.Printf("[*] SASL GSSAPI bind to %s...", spn)
+		if err := conn.GSSAPIBind(gc, spn, ""); err != nil {
+			return fmt.Errorf("GSSAPI bind to %s failed: %v\n"+
+				"    Hint: target the DC by FQDN so the ldap/<host> SPN resolves", spn, err)
+		}
+		log.Printf("[+] Kerberos bind successful")
+
+	case AuthCertificate:
+		log.Printf("[*] SASL EXTERNAL bind with client certificate...")
+		if err := conn.ExternalBind(); err != nil {
+			return fmt.Errorf("certificate (SASL EXTERNAL) bind failed: %v", err)
+		}
+		log.Printf("[+] Certificate bind successful")
+
+	case AuthAnonymous:
+		log.Printf("[*] Attempting anonymous bind...")
+		if err := conn.UnauthenticatedBind(""); err != nil {
+			return fmt.Errorf("anonymous bind failed: %v\n"+
+				"    Most DCs block anonymous binds. Use --user and --pass.", err)
+		}
+		log.Printf("[!] Anonymous bind succeeded (results may be limited)")
+
+	default:
+		return fmt.Errorf("unsupported auth method %q", method)
+	}
+	return nil
+}
+
+// IsInvalidCredentials reports whether err is a bind the DC refused for bad
+// credentials (LDAP result 49). Retrying such a bind only adds failed
+// logons towards the account's lockout threshold.
+func IsInvalidCredentials(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials)
+}
+
+// newGSSAPIClient builds a gokrb5 client from a keytab for opts.BindUser,
+// from a ccache (opts.CCache, falling back to KRB5CCNAME), or, with neither,
+// from opts.BindPass.
+func newGSSAPIClient(opts ConnectOptions, host string) (*gssapi.Client, error) {
+	kdc := hostWithoutPort(firstNonEmptyString(opts.KDC, host))
+
+	if opts.Keytab != "" {
+		user, realm, cfg, err := principalConfig(opts, kdc)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := LoadKeytab(opts.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("load keytab %s: %w", opts.Keytab, err)
+		}
+		if !keytabHasPrincipal(entries, user, realm) {
+			log.Printf("[!] Keytab %s has no key for %s@%s", opts.Keytab, user, realm)
+		}
+		kt, err := gokrb5Keytab(entries)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[*] Using keytab %s for %s@%s", opts.Keytab, user, realm)
+		return &gssapi.Client{Client: client.NewWithKeytab(user, realm, kt, cfg, client.DisablePAFXFAST(true))}, nil
+	}
+
+	path := opts.CCache
+	if path == "" {
+		path = strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+	}
+	if path == "" && opts.BindPass != "" {
+		user, realm, cfg, err := principalConfig(opts, kdc)
+		if err != nil {
+			return nil, err
+		}
+		// gokrb5 takes the AES salt from the KDC's ETYPE-INFO2, which
+		// differs from one built from -u when the case does not match.
+		log.Printf("[*] Using the password for %s@%s", user, realm)
+		return &gssapi.Client{Client: client.NewWithPassword(user, realm, opts.BindPass, cfg, client.DisablePAFXFAST(true))}, nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("kerberos authentication needs --ccache, --keytab, a password or KRB5CCNAME")
+	}
+	cc, err := credentials.LoadCCache(path)
+	if err != nil {
+		return nil, fmt.Errorf("load ccache %s: %w", path, err)
+	}
+	cfg, err := newKrb5Config(firstNonEmptyString(opts.Realm, cc.DefaultPrincipal.Realm), kdc)
+	if err != nil {
+		return nil, fmt.Errorf("kerberos config: %v", err)
+	}
+	cl, err := client.NewFromCCache(cc, cfg, client.DisablePAFXFAST(true))
+	if err != nil {
+		return nil, fmt.Errorf("use ccache %s: %w", path, err)
+	}
+	log.Printf("[*] Using ccache %s for %s@%s", path, cc.DefaultPrincipal.PrincipalName.PrincipalNameString(), cc.DefaultPrincipal.Realm)
+	return &gssapi.Client{Client: cl}, nil
+}
+
+// principalConfig splits opts.BindUser into user and realm for keytab and
+// password logins and builds the krb5 config for the realm.
+func principalConfig(opts ConnectOptions, kdc string) (string, string, *config.Config, error) {
+	domain, user := splitBindUser(opts.BindUser)
+	realm := strings.ToUpper(firstNonEmptyString(opts.Realm, domain))
+	if user == "" || realm == "" {
+		return "", "", nil, fmt.Errorf("kerberos authentication with a keytab or password needs -u user@REALM (or -u user with -d)")
+	}
+	cfg, err := newKrb5Config(realm, kdc)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("kerberos config: %v", err)
+	}
+	return user, realm, cfg, nil
+}
+
+// keytabHasPrincipal reports whether entries hold a key for user@realm.
+func keytabHasPrincipal(entries []KeytabEntry, user, realm string) bool {
+	for _, e := range entries {
+		if strings.EqualFold(e.Principal, user) && strings.EqualFold(e.Realm, realm) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadClientCertificate reads a client certificate for Schannel
+// authentication. PFX/P12 files carry the key; PEM files may carry it inline
+// or in a separate keyFile.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("read certificate %s: %w", certFile, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(certFile)) {
+	case ".pfx", ".p12":
+		// go-pkcs12 reads the PBES2/AES-256 PFX files OpenSSL 3 and certipy
+		// write as well as the legacy RC2/3DES ones.
+		key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("decode PFX %s: %w", certFile, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return tls.Certificate{}, fmt.Errorf("decode PFX %s: unsupported private key %T", certFile, key)
+		}
+		if pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(signer.Public()) {
+			return tls.Certificate{}, fmt.Errorf("decode PFX %s: private key does not match the certificate", certFile)
+		}
+		cert := tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+		for _, ca := range chain {
+			cert.Certificate = append(cert.Certificate, ca.Raw)
+		}
+		return cert, nil
+	}
+
+	keyPEM := data
+	if keyFile != "" {
+		if keyPEM, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("read key %s: %w", keyFile, err)
+		}
+	}
+	cert, err := tls.X509KeyPair(data, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("load certificate %s: %w", certFile, err)
+	}
+	return cert, nil
+}
+
+// splitBindUser separates DOMAIN\user and user@domain into their parts.
+func splitBindUser(bindUser string) (domain, user string) {
+	bindUser = strings.TrimSpace(bindUser)
+	if i := strings.LastIndex(bindUser, "\\"); i >= 0 {
+		return bindUser[:i], bindUser[i+1:]
+	}
+	if i := strings.LastIndex(bindUser, "@"); i > 0 {
+		return bindUser[i+1:], bindUser[:i]
+	}
+	return "", bindUser
+}
+
+// normalizeNTHash accepts NT or LM:NT (secretsdump style) and returns the
+// 32-hex-digit NT hash.
+func normalizeNTHash(hash string) (string, error) {
+	hash = strings.TrimSpace(hash)
+	if i := strings.LastIndex(hash, ":"); i >= 0 {
+		hash = hash[i+1:]
+	}
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 16 {
+		return "", fmt.Errorf("invalid NT hash: expected 32 hex characters")
+	}
+	return strings.ToLower(hash), nil
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package krb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestAuthMethodInference(t *testing.T) {
+	cases := []struct {
+		opts ConnectOptions
+		want AuthMethod
+	}{
+		{ConnectOptions{}, AuthAnonymous},
+		{ConnectOptions{BindUser: "CORP\\alice", BindPass: "pw"}, AuthSimple},
+		{ConnectOptions{BindUser: "CORP\\alice", NTHash: "aad3b435b51404eeaad3b435b51404ee:31d6cfe0d16ae931b73c59d7e0c089c0"}, AuthNTLM},
+		{ConnectOptions{CCache: "/tmp/krb5cc_0"}, AuthKerberos},
+		{ConnectOptions{BindUser: "svc@CORP.LOCAL", Keytab: "svc.keytab"}, AuthKerberos},
+		{ConnectOptions{CertFile: "alice.pfx", BindUser: "alice", BindPass: "pw"}, AuthCertificate},
+		{ConnectOptions{Auth: AuthNTLM, BindUser: "alice", BindPass: "pw"}, AuthNTLM},
+	}
+	for _, tc := range cases {
+		if got := tc.opts.authMethod(); got != tc.want {
+			t.Errorf("authMethod(%+v) = %s, want %s", tc.opts, got, tc.want)
+		}
+	}
+	if _, err := ParseAuthMethod("digest"); err == nil {
+		t.Error("expected unknown auth method to be rejected")
+	}
+}
+
+func TestSplitBindUserAndNTHash(t *testing.T) {
+	if d, u := splitBindUser("CORP\\alice"); d != "CORP" || u != "alice" {
+		t.Errorf("splitBindUser(CORP\\alice) = %q, %q", d, u)
+	}
+	if d, u := splitBindUser("alice@corp.local"); d != "corp.local" || u != "alice" {
+		t.Errorf("splitBindUser(alice@corp.local) = %q, %q", d, u)
+	}
+	hash, err := normalizeNTHash("aad3b435b51404eeaad3b435b51404ee:31D6CFE0D16AE931B73C59D7E0C089C0")
+	if err != nil || hash != "31d6cfe0d16ae931b73c59d7e0c089c0" {
+		t.Errorf("normalizeNTHash = %q, %v", hash, err)
+	}
+	if _, err := normalizeNTHash("not-a-hash"); err == nil {
+		t.Error("expected invalid NT hash to be rejected")
+	}
+}
+
+func TestLoadClientCertificatePEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "alice"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "alice.crt")
+	keyPath := filepath.Join(dir, "alice.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := LoadClientCertificate(certPath, keyPath, "")
+	if err != nil {
+		t.Fatalf("LoadClientCertificate failed: %v", err)
+	}
+	if len(cert.Certificate) != 1 {
+		t.Fatalf("expected one certificate in chain, got %d", len(cert.Certificate))
+	}
+	if _, err := LoadClientCertificate(certPath, "", ""); err == nil {
+		t.Error("expected missing private key to fail")
+	}
+	if _, err := Connect(ConnectOptions{Target: "127.0.0.1", CertFile: certPath, KeyFile: keyPath}); err == nil {
+		t.Error("expected certificate auth over plain LDAP to be rejected")
+	}
+}
+
+// testdata/alice_aes256.pfx was exported by OpenSSL 3 with its defaults:
+// PBES2, PBKDF2-HMAC-SHA256 and AES-256-CBC, password Certipy1.
+func TestLoadClientCertificateAESPFX(t *testing.T) {
+	cert, err := LoadClientCertificate(filepath.Join("testdata", "alice_aes256.pfx"), "", "Certipy1")
+	if err != nil {
+		t.Fatalf("LoadClientCertificate failed: %v", err)
+	}
+	if len(cert.Certificate) != 1 || cert.Leaf == nil || cert.Leaf.Subject.CommonName != "alice" {
+		t.Fatalf("unexpected certificate %+v", cert.Leaf)
+	}
+	if _, ok := cert.PrivateKey.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("unexpected private key %T", cert.PrivateKey)
+	}
+	if _, err := LoadClientCertificate(filepath.Join("testdata", "alice_aes256.pfx"), "", "wrong"); err == nil {
+		t.Error("expected a wrong PFX password to fail")
+	}
+}
+
+func TestIsInvalidCredentials(t *testing.T) {
+	rejected := fmt.Errorf("LDAP bind as 'CORP\\alice' failed: %w", ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("80090308: LdapErr: DSID-0C09044E")))
+	if !IsInvalidCredentials(rejected) {
+		t.Error("wrapped result 49 not recognised")
+	}
+	if IsInvalidCredentials(ldap.NewError(ldap.LDAPResultStrongAuthRequired, errors.New("signing required"))) || IsInvalidCredentials(nil) {
+		t.Error("other bind results reported as bad credentials")
+	}
+}
+
+func TestNewGSSAPIClientSources(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	opts := ConnectOptions{BindUser: "Alice@corp.local", BindPass: "Summer2026!", KDC: "127.0.0.1"}
+
+	// A ccache from KRB5CCNAME still wins over a password.
+	t.Setenv("KRB5CCNAME", "FILE:"+missing)
+	if _, err := newGSSAPIClient(opts, "dc01.corp.local"); err == nil || !strings.Contains(err.Error(), "load ccache") {
+		t.Errorf("with KRB5CCNAME: %v, want the ccache to be used", err)
+	}
+
+	// Without one, the password goes to gokrb5, which salts it from the KDC.
+	t.Setenv("KRB5CCNAME", "")
+	gc, err := newGSSAPIClient(opts, "dc01.corp.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds := gc.Client.Credentials; !creds.HasPassword() || creds.HasKeytab() || creds.UserName() != "Alice" || creds.Realm() != "CORP.LOCAL" {
+		t.Errorf("password client: %+v", creds)
+	}
+
+	// --keytab is read only when given.
+	opts.Keytab = missing
+	if _, err := newGSSAPIClient(opts, "dc01.corp.local"); err == nil || !strings.Contains(err.Error(), "load keytab") {
+		t.Errorf("with --keytab: %v, want the keytab to be used", err)
+	}
+}
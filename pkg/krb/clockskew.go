@@ -0,0 +1,205 @@
+package krb
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// kdcTime returns the local time shifted by offset, split the way Kerberos
+// timestamps carry it (whole seconds plus microseconds).
+func kdcTime(offset time.Duration) (time.Time, int) {
+	t := time.Now().UTC().Add(offset)
+	return t.Truncate(time.Second), t.Nanosecond() / int(time.Microsecond)
+}
+
+// shiftReqTimes moves the till and renew-till times of a request body built
+// by gokrb5 from the local clock to the KDC clock.
+func shiftReqTimes(body *messages.KDCReqBody, offset time.Duration) {
+	if offset == 0 {
+		return
+	}
+	body.Till = body.Till.Add(offset)
+	if !body.RTime.IsZero() {
+		body.RTime = body.RTime.Add(offset)
+	}
+}
+
+// skewHint points at the clock when the KDC rejected a request for skew.
+func skewHint(err error) error {
+	if err != nil && strings.Contains(err.Error(), "KRB_AP_ERR_SKEW") {
+		return fmt.Errorf("%w (local clock differs from the KDC by more than %s)", err, ClockSkewTolerance)
+	}
+	return err
+}
+
+// kdcExchange sends req and unmarshals the reply into rep, returning the
+// KRB-ERROR when the KDC sent one instead.
+func kdcExchange(kdc string, req []byte, rep interface{ Unmarshal([]byte) error }) error {
+	b, err := sendToKDCTCP(kdc, req)
+	if err != nil {
+		return err
+	}
+	if err := rep.Unmarshal(b); err != nil {
+		var krbErr messages.KRBError
+		if krbErr.Unmarshal(b) == nil {
+			return krbErr
+		}
+		return err
+	}
+	return nil
+}
+
+// skewedTGT runs the AS exchange for the client principal with a
+// PA-ENC-TIMESTAMP in KDC time.
+func (k *RealKerberosClient) skewedTGT() (messages.Ticket, types.EncryptionKey, error) {
+	cname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, k.clientSAM)
+	asReq, err := messages.NewASReqForTGT(k.domain, k.config, cname)
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	shiftReqTimes(&asReq.ReqBody, k.clockOffset)
+
+	// The first request has no pre-authentication; the KDC answers with
+	// the etype and salt to use for the timestamp.
+	b, err := asReq.Marshal()
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	var asRep messages.ASRep
+	err = kdcExchange(k.kdcAddress, b, &asRep)
+	krbErr, isKRBErr := err.(messages.KRBError)
+	switch {
+	case err == nil:
+		return k.decryptASRep(asRep)
+	case !isKRBErr || krbErr.ErrorCode != errorcode.KDC_ERR_PREAUTH_REQUIRED:
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+
+	var pas types.PADataSequence
+	if err := pas.Unmarshal(krbErr.EData); err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, fmt.Errorf("PREAUTH_REQUIRED e-data: %v", err)
+	}
+	etypeID := k.config.LibDefaults.DefaultTktEnctypeIDs[0]
+	for _, pa := range pas {
+		if pa.PADataType == patype.PA_ETYPE_INFO2 {
+			if info, err := pa.GetETypeInfo2(); err == nil && len(info) > 0 {
+				etypeID = info[0].EType
+			}
+		}
+	}
+	key, _, err := crypto.GetKeyFromPassword(k.clientPassword, cname, k.domain, etypeID, pas)
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	ts, usec := kdcTime(k.clockOffset)
+	tsb, err := asn1.Marshal(types.PAEncTSEnc{PATimestamp: ts, PAUSec: usec})
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	encTS, err := crypto.GetEncryptedData(tsb, key, keyusage.AS_REQ_PA_ENC_TIMESTAMP, 0)
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	pb, err := encTS.Marshal()
+	if err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	asReq.PAData = append(asReq.PAData, types.PAData{PADataType: patype.PA_ENC_TIMESTAMP, PADataValue: pb})
+
+	if b, err = asReq.Marshal(); err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	if err := kdcExchange(k.kdcAddress, b, &asRep); err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	return k.decryptASRep(asRep)
+}
+
+func (k *RealKerberosClient) decryptASRep(asRep messages.ASRep) (messages.Ticket, types.EncryptionKey, error) {
+	creds := credentials.New(k.clientSAM, k.domain).WithPassword(k.clientPassword)
+	if _, err := asRep.DecryptEncPart(creds); err != nil {
+		return messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	return asRep.Ticket, asRep.DecryptedEncPart.Key, nil
+}
+
+// cachedSkewedTGT runs skewedTGT once per client.
+func (k *RealKerberosClient) cachedSkewedTGT() (messages.Ticket, types.EncryptionKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.skewTGT == nil {
+		tgt, key, err := k.skewedTGT()
+		if err != nil {
+			return messages.Ticket{}, types.EncryptionKey{}, err
+		}
+		k.skewTGT, k.skewKey = &tgt, key
+	}
+	return *k.skewTGT, k.skewKey, nil
+}
+
+// skewedServiceTicket gets a service ticket for spn on the shared TGT, with
+// every timestamp in KDC time.
+func (k *RealKerberosClient) skewedServiceTicket(spn string) (messages.Ticket, error) {
+	tgt, sessionKey, err := k.cachedSkewedTGT()
+	if err != nil {
+		return messages.Ticket{}, fmt.Errorf("TGT: %w", err)
+	}
+	cname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, k.clientSAM)
+	sname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, spn)
+	tgsReq, err := messages.NewTGSReq(cname, k.domain, k.config, tgt, sessionKey, sname, false)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	shiftReqTimes(&tgsReq.ReqBody, k.clockOffset)
+
+	// NewTGSReq stamped the authenticator with the local clock; rebuild
+	// PA-TGS-REQ over the shifted body with a KDC-time authenticator.
+	body, err := tgsReq.ReqBody.Marshal()
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	et, err := crypto.GetEtype(sessionKey.KeyType)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	cksum, err := et.GetChecksumHash(sessionKey.KeyValue, body, keyusage.TGS_REQ_PA_TGS_REQ_AP_REQ_AUTHENTICATOR_CHKSUM)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	auth, err := types.NewAuthenticator(tgt.Realm, cname)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	auth.CTime, auth.Cusec = kdcTime(k.clockOffset)
+	auth.Cksum = types.Checksum{CksumType: et.GetHashID(), Checksum: cksum}
+	apReq, err := messages.NewAPReq(tgt, sessionKey, auth)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	apb, err := apReq.Marshal()
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	tgsReq.PAData = types.PADataSequence{{PADataType: patype.PA_TGS_REQ, PADataValue: apb}}
+
+	b, err := tgsReq.Marshal()
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+	var tgsRep messages.TGSRep
+	if err := kdcExchange(k.kdcAddress, b, &tgsRep); err != nil {
+		return messages.Ticket{}, err
+	}
+	return tgsRep.Ticket, nil
+}
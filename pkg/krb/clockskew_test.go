@@ -0,0 +1,124 @@
+package krb
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// fakeKDC answers each TCP Kerberos request with reply(request).
+func fakeKDC(t *testing.T, reply func(req []byte) []byte) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var size uint32
+			if binary.Read(conn, binary.BigEndian, &size) == nil {
+				req := make([]byte, size)
+				if _, err := io.ReadFull(conn, req); err == nil {
+					resp := reply(req)
+					binary.Write(conn, binary.BigEndian, uint32(len(resp)))
+					conn.Write(resp)
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func krbErrorBytes(t *testing.T, code int32, edata []byte) []byte {
+	e := messages.NewKRBError(types.NewPrincipalName(nametype.KRB_NT_SRV_INST, "krbtgt/CORP.LOCAL"), "CORP.LOCAL", code, "")
+	e.EData = edata
+	b, err := e.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestSkewedPreauthTimestamp checks that with a clock offset the AS-REQ
+// pre-authentication timestamp and till time are in KDC time.
+func TestSkewedPreauthTimestamp(t *testing.T) {
+	offset := 3 * time.Hour
+	edata, err := asn1.Marshal(types.PADataSequence{{PADataType: patype.PA_ENC_TIMESTAMP}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stamped time.Time
+	var till time.Time
+	addr := fakeKDC(t, func(b []byte) []byte {
+		var req messages.ASReq
+		if err := req.Unmarshal(b); err != nil {
+			t.Errorf("AS-REQ: %v", err)
+			return nil
+		}
+		till = req.ReqBody.Till
+		for _, pa := range req.PAData {
+			if pa.PADataType != patype.PA_ENC_TIMESTAMP {
+				continue
+			}
+			var ed types.EncryptedData
+			if err := ed.Unmarshal(pa.PADataValue); err != nil {
+				t.Errorf("PA-ENC-TIMESTAMP: %v", err)
+				break
+			}
+			cname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "alice")
+			key, _, _ := crypto.GetKeyFromPassword("secret", cname, "CORP.LOCAL", etypeID.RC4_HMAC, nil)
+			plain, err := crypto.DecryptEncPart(ed, key, keyusage.AS_REQ_PA_ENC_TIMESTAMP)
+			if err != nil {
+				t.Errorf("decrypt PA-ENC-TIMESTAMP: %v", err)
+				break
+			}
+			var ts types.PAEncTSEnc
+			if _, err := asn1.Unmarshal(plain, &ts); err != nil {
+				t.Errorf("PA-ENC-TS-ENC: %v", err)
+			}
+			stamped = ts.PATimestamp
+			return krbErrorBytes(t, errorcode.KDC_ERR_PREAUTH_FAILED, nil)
+		}
+		return krbErrorBytes(t, errorcode.KDC_ERR_PREAUTH_REQUIRED, edata)
+	})
+
+	k, err := NewRealKerberosClient("CORP.LOCAL", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetClientCredentials("alice", "secret")
+	k.SetClockOffset(offset)
+	if _, _, err := k.skewedTGT(); err == nil {
+		t.Fatal("expected the fake KDC to reject the AS-REQ")
+	}
+
+	want := time.Now().UTC().Add(offset)
+	if d := stamped.Sub(want); d > 5*time.Second || d < -5*time.Second {
+		t.Errorf("PA-ENC-TIMESTAMP %s, want about %s", stamped, want)
+	}
+	if d := till.Sub(want.Add(24 * time.Hour)); d > 5*time.Second || d < -5*time.Second {
+		t.Errorf("till %s not shifted by the offset", till)
+	}
+	if k.config.LibDefaults.Clockskew != ClockSkewTolerance+offset {
+		t.Errorf("client clockskew = %s", k.config.LibDefaults.Clockskew)
+	}
+}
@@ -0,0 +1,115 @@
+package krb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DomainController is one DC registered in DNS.
+type DomainController struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+}
+
+// Host returns the address when it is known, else the DNS name.
+func (dc DomainController) Host() string {
+	if dc.Address != "" {
+		return dc.Address
+	}
+	return dc.Name
+}
+
+// LocateDomainControllers returns every DC registered under
+// _ldap._tcp.dc._msdcs.<domain>. The system resolver is asked first; when it
+// does not know the domain (common when the assessment host does not use AD
+// DNS), nameserver, usually the target DC, is queried directly.
+func LocateDomainControllers(domain, nameserver string) ([]DomainController, error) {
+	domain = strings.Trim(strings.TrimSpace(strings.ToLower(domain)), ".")
+	if domain == "" {
+		return nil, fmt.Errorf("cannot locate DCs without a domain name")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var dcs []DomainController
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "ldap", "tcp", "dc._msdcs."+domain)
+	if err == nil && len(addrs) > 0 {
+		for _, srv := range addrs {
+			name := strings.TrimSuffix(srv.Target, ".")
+			dcs = append(dcs, DomainController{Name: name, Address: resolveIPv4(ctx, name)})
+		}
+	} else if nameserver != "" {
+		if dcs, err = querySRV("_ldap._tcp.dc._msdcs."+domain, hostWithoutPort(nameserver)); err != nil {
+			return nil, err
+		}
+	}
+	if len(dcs) == 0 {
+		return nil, fmt.Errorf("no _ldap._tcp.dc._msdcs.%s records found", domain)
+	}
+
+	sort.Slice(dcs, func(i, j int) bool { return dcs[i].Name < dcs[j].Name })
+	unique := dcs[:0]
+	for i, dc := range dcs {
+		if i == 0 || !strings.EqualFold(dc.Name, dcs[i-1].Name) {
+			unique = append(unique, dc)
+		}
+	}
+	return unique, nil
+}
+
+// querySRV asks one DNS server for SRV records, taking addresses from the
+// additional section or a follow-up A query to the same server.
+func querySRV(name, nameserver string) ([]DomainController, error) {
+	client := &dns.Client{Timeout: 5 * time.Second}
+	server := net.JoinHostPort(nameserver, "53")
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeSRV)
+	resp, _, err := client.Exchange(m, server)
+	if err != nil {
+		return nil, fmt.Errorf("SRV query to %s failed: %v", nameserver, err)
+	}
+
+	glue := make(map[string]string)
+	for _, rr := range resp.Extra {
+		if a, ok := rr.(*dns.A); ok {
+			glue[strings.ToLower(a.Hdr.Name)] = a.A.String()
+		}
+	}
+	var dcs []DomainController
+	for _, rr := range resp.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		dc := DomainController{Name: strings.TrimSuffix(srv.Target, "."), Address: glue[strings.ToLower(srv.Target)]}
+		if dc.Address == "" {
+			q := new(dns.Msg)
+			q.SetQuestion(dns.Fqdn(dc.Name), dns.TypeA)
+			if ar, _, err := client.Exchange(q, server); err == nil {
+				for _, rr := range ar.Answer {
+					if a, ok := rr.(*dns.A); ok {
+						dc.Address = a.A.String()
+						break
+					}
+				}
+			}
+		}
+		dcs = append(dcs, dc)
+	}
+	return dcs, nil
+}
+
+func resolveIPv4(ctx context.Context, host string) string {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil || len(ips) == 0 {
+		return ""
+	}
+	return ips[0].String()
+}
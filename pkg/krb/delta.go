@@ -0,0 +1,96 @@
+package krb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// presenceFilter matches every object class a collection keeps, so one cheap
+// DN-only search reveals which snapshot objects have since been deleted.
+const presenceFilter = "(|(&(objectCategory=person)(objectClass=user))(objectClass=computer)(objectClass=group)(objectClass=organizationalUnit)(objectClass=groupPolicyContainer)(objectClass=domainDNS))"
+
+// SyncPoint is a DC's replication position. USNs are local to one DC
+// database, so a point is only reusable against the same DSA with the same
+// invocationId (a restore from backup assigns a new one).
+type SyncPoint struct {
+	DSA          string `json:"dsa"`
+	InvocationID string `json:"invocation_id,omitempty"`
+	USN          int64  `json:"highest_committed_usn"`
+	Host         string `json:"host,omitempty"`
+}
+
+// Matches reports whether a delta from p can be requested from a DC at cur.
+func (p SyncPoint) Matches(cur SyncPoint) bool {
+	return p.DSA != "" && p.DSA == cur.DSA && p.InvocationID == cur.InvocationID && p.USN > 0 && p.USN <= cur.USN
+}
+
+// SyncPoint reads highestCommittedUSN and the DSA identity from the bound DC.
+// Take it before collecting so changes made during the run are fetched again
+// next time rather than lost.
+func (c *LDAPClient) SyncPoint() (SyncPoint, error) {
+	dse, err := c.RootDSE("highestCommittedUSN", "dsServiceName")
+	if err != nil {
+		return SyncPoint{}, err
+	}
+	usn, err := strconv.ParseInt(dse.GetAttributeValue("highestCommittedUSN"), 10, 64)
+	if err != nil {
+		return SyncPoint{}, fmt.Errorf("RootDSE returned no usable highestCommittedUSN")
+	}
+	point := SyncPoint{DSA: dse.GetAttributeValue("dsServiceName"), USN: usn, Host: c.ldapHost}
+
+	if point.DSA != "" {
+		sr, err := c.conn.Search(ldap.NewSearchRequest(
+			point.DSA, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+			"(objectClass=*)", []string{"invocationId"}, nil,
+		))
+		if err == nil && len(sr.Entries) > 0 {
+			point.InvocationID = hex.EncodeToString(sr.Entries[0].GetRawAttributeValue("invocationId"))
+		}
+	}
+	return point, nil
+}
+
+// ChangedSince returns a client sharing this connection whose collection
+// searches only return objects with uSNChanged above usn.
+func (c *LDAPClient) ChangedSince(usn int64) *LDAPClient {
+	scoped := *c
+	scoped.sinceUSN = usn
+	return &scoped
+}
+
+func (c *LDAPClient) scopeFilter(filter string) string {
+	if c.sinceUSN <= 0 {
+		return filter
+	}
+	return fmt.Sprintf("(&%s(uSNChanged>=%d))", filter, c.sinceUSN+1)
+}
+
+// CollectDelta fetches objects changed since the USN of since and applies
+// them to snapshot, removing objects that no longer exist. Membership
+// backlinks are reconciled by ApplyDelta; effective groups still need to be
+// resolved by the caller.
+func (c *LDAPClient) CollectDelta(snapshot *ingest.Directory, since int64) (ingest.DeltaStats, error) {
+	log.Printf("[*] Collecting objects changed since USN %d...", since)
+	changed, err := c.ChangedSince(since).CollectDomain()
+	if err != nil {
+		return ingest.DeltaStats{}, err
+	}
+
+	var present []string
+	err = c.Stream(StreamRequest{Filter: presenceFilter, Attributes: []string{"1.1"}, PageSize: 1000}, func(e *ldap.Entry) error {
+		present = append(present, e.DN)
+		return nil
+	})
+	if err != nil {
+		return ingest.DeltaStats{}, fmt.Errorf("presence search failed: %v", err)
+	}
+
+	stats := snapshot.ApplyDelta(changed, present)
+	log.Printf("[+] Delta: %d added, %d updated, %d removed", stats.Added, stats.Updated, stats.Removed)
+	return stats, nil
+}
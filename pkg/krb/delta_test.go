@@ -0,0 +1,28 @@
+package krb
+
+import "testing"
+
+func TestChangedSinceScopesFilters(t *testing.T) {
+	c := &LDAPClient{baseDN: "DC=corp,DC=local"}
+	if got := c.scopeFilter("(objectClass=group)"); got != "(objectClass=group)" {
+		t.Fatalf("unscoped client rewrote filter: %s", got)
+	}
+	scoped := c.ChangedSince(41200)
+	if got := scoped.scopeFilter("(objectClass=group)"); got != "(&(objectClass=group)(uSNChanged>=41201))" {
+		t.Fatalf("unexpected delta filter %s", got)
+	}
+	if c.sinceUSN != 0 {
+		t.Fatal("ChangedSince must not modify the original client")
+	}
+
+	prev := SyncPoint{DSA: "CN=NTDS Settings,CN=DC01", InvocationID: "01", USN: 100}
+	if !prev.Matches(SyncPoint{DSA: prev.DSA, InvocationID: "01", USN: 150}) {
+		t.Fatal("same DC with a higher USN should match")
+	}
+	if prev.Matches(SyncPoint{DSA: "CN=NTDS Settings,CN=DC02", InvocationID: "01", USN: 150}) {
+		t.Fatal("USNs from another DC must not match")
+	}
+	if prev.Matches(SyncPoint{DSA: prev.DSA, InvocationID: "01", USN: 90}) {
+		t.Fatal("a USN that went backwards must not match")
+	}
+}
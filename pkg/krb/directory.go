@@ -0,0 +1,317 @@
+package krb
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// directoryFilter selects the non-user objects collected alongside users.
+const directoryFilter = "(|(objectClass=group)(objectClass=organizationalUnit)(objectClass=groupPolicyContainer)(objectClass=domainDNS))"
+
+var directoryAttributes = []string{
+	"objectClass",
+	"distinguishedName",
+	"sAMAccountName",
+	"objectSid",
+	"description",
+	"adminCount",
+	"member",
+	"memberOf",
+	"ou",
+	"cn",
+	"displayName",
+	"gPLink",
+	"gPOptions",
+	"gPCFileSysPath",
+	"versionNumber",
+	"flags",
+	"minPwdLength",
+	"pwdHistoryLength",
+	"pwdProperties",
+	"lockoutThreshold",
+	"lockoutDuration",
+	"maxPwdAge",
+	"minPwdAge",
+	"ms-DS-MachineAccountQuota",
+	"whenCreated",
+}
+
+// computerAttributes covers host identity, delegation configuration, the
+// MachineAccountQuota creator, both LAPS generations' expiry attributes and
+// the password age and encryption types behind the etype posture.
+var computerAttributes = []string{
+	"objectClass",
+	"distinguishedName",
+	"sAMAccountName",
+	"objectSid",
+	"dNSHostName",
+	"operatingSystem",
+	"operatingSystemVersion",
+	"userAccountControl",
+	"servicePrincipalName",
+	"msDS-AllowedToDelegateTo",
+	"ms-DS-CreatorSID",
+	"ms-Mcs-AdmPwdExpirationTime",
+	"msLAPS-PasswordExpirationTime",
+	"lastLogonTimestamp",
+	"pwdLastSet",
+	"msDS-SupportedEncryptionTypes",
+	"memberOf",
+	"primaryGroupID",
+}
+
+// EachComputer streams every computer object to fn as its page arrives. An
+// error from fn stops the search and is returned.
+func (c *LDAPClient) EachComputer(fn func(ingest.Computer) error) error {
+	log.Println("[*] Enumerating computers (with paging)...")
+
+	schema := c.Schema()
+	err := c.Stream(StreamRequest{
+		Filter:     "(objectClass=computer)",
+		Attributes: computerAttributes,
+		Progress:   logPages("computers"),
+	}, func(e *ldap.Entry) error {
+		record := recordFromLDAPEntry(e, schema)
+		return fn(ingest.ComputerFromEntry(&record))
+	})
+	if err != nil {
+		return fmt.Errorf("computer search failed: %v", err)
+	}
+	return nil
+}
+
+// EnumerateComputers returns all computer objects; see EachComputer.
+func (c *LDAPClient) EnumerateComputers() ([]ingest.Computer, error) {
+	var computers []ingest.Computer
+	err := c.EachComputer(func(comp ingest.Computer) error {
+		computers = append(computers, comp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[+] Found %d computer objects", len(computers))
+	return computers, nil
+}
+
+// CollectDomain streams the users and computers under this client's base DN
+// straight into a Directory and adds the groups, OUs, GPOs and domain head.
+// A failed computer search is logged and leaves the computers out. When the
+// group, OU and GPO search fails, the users and computers are returned with
+// the error.
+func (c *LDAPClient) CollectDomain() (*ingest.Directory, error) {
+	var users []ingest.User
+	err := c.EachUser(func(u ingest.User) error {
+		users = append(users, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[+] Found %d user objects", len(users))
+
+	var computers []ingest.Computer
+	err = c.EachComputer(func(comp ingest.Computer) error {
+		computers = append(computers, comp)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[!] Computer enumeration failed: %v", err)
+		computers = nil
+	} else {
+		log.Printf("[+] Found %d computer objects", len(computers))
+	}
+
+	dir, err := c.CollectDirectory(users, computers)
+	if err != nil {
+		return &ingest.Directory{Users: users, Computers: computers}, err
+	}
+	return dir, nil
+}
+
+// CollectDirectory gathers groups, OUs, GPOs and the domain head and returns
+// them together with already-enumerated users and computers as an
+// ingest.Directory.
+func (c *LDAPClient) CollectDirectory(users []ingest.User, computers []ingest.Computer) (*ingest.Directory, error) {
+	log.Println("[*] Collecting groups, OUs and GPOs...")
+
+	schema := c.Schema()
+	builder := ingest.NewDirectoryBuilder()
+	err := c.Stream(StreamRequest{
+		Filter:     directoryFilter,
+		Attributes: directoryAttributes,
+		Progress:   logPages("directory objects"),
+	}, func(e *ldap.Entry) error {
+		record := recordFromLDAPEntry(e, schema)
+		builder.Add(&record)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("directory search failed: %v", err)
+	}
+	dir := builder.Directory()
+	dir.Users = users
+	dir.Computers = computers
+	dir.Reindex()
+
+	log.Printf("[+] Collected %d groups, %d OUs, %d GPOs", len(dir.Groups), len(dir.OUs), len(dir.GPOs))
+	return dir, nil
+}
+
+// recordFromLDAPEntry converts a live search result into the attribute-bag
+// record shared with the offline readers, keeping raw bytes for binary values
+// and the schema that decodes them.
+func recordFromLDAPEntry(e *ldap.Entry, schema *ingest.Schema) ingest.LDIFEntry {
+	record := ingest.LDIFEntry{DN: e.DN, Schema: schema}
+	for _, attr := range e.Attributes {
+		if len(attr.ByteValues) == 0 {
+			continue
+		}
+		record.Attributes = append(record.Attributes, ingest.LDIFAttribute{
+			Name:   attr.Name,
+			Values: attr.ByteValues,
+		})
+	}
+	return record
+}
+
+// matchingRuleInChain is LDAP_MATCHING_RULE_IN_CHAIN, which makes the DC walk
+// group nesting server-side.
+const matchingRuleInChain = "1.2.840.113556.1.4.1941"
+
+// ResolveMemberships computes effective groups for every user and computer.
+// The local closure over collected groups supplies the nesting chain; one
+// LDAP_MATCHING_RULE_IN_CHAIN query per privileged or out-of-scope group adds
+// members it missed (e.g. nesting through groups that were not collected).
+func (c *LDAPClient) ResolveMemberships(dir *ingest.Directory) {
+	if dir == nil {
+		return
+	}
+	log.Println("[*] Resolving nested group memberships...")
+	dir.ResolveMemberships()
+	c.mergeChainedGroups(dir)
+
+	nested := 0
+	for _, u := range dir.Users {
+		if len(u.EffectiveGroups) > len(u.MemberOf) {
+			nested++
+		}
+	}
+	log.Printf("[+] %d users hold memberships beyond their direct memberOf", nested)
+}
+
+// mergeChainedGroups asks the DC for the transitive members of this
+// client's privileged groups (adminCount=1) and of the groups in its domain
+// that principals reference but the collection did not return, and merges
+// each group into the effective groups of the users and computers it
+// reaches. That is one query per such group rather than one per principal.
+func (c *LDAPClient) mergeChainedGroups(dir *ingest.Directory) {
+	domain := ingest.DomainFromDN(c.baseDN)
+	users := make(map[string]*ingest.User)
+	computers := make(map[string]*ingest.Computer)
+	for i := range dir.Users {
+		users[strings.ToLower(dir.Users[i].DistinguishedName)] = &dir.Users[i]
+	}
+	for i := range dir.Computers {
+		computers[strings.ToLower(dir.Computers[i].DistinguishedName)] = &dir.Computers[i]
+	}
+
+	for _, group := range chainedQueryGroups(dir, domain) {
+		members, err := c.ChainedMembers(group)
+		if err != nil {
+			log.Printf("[!] In-chain membership query for %s failed: %v", group, err)
+			continue
+		}
+		for _, dn := range members {
+			key := strings.ToLower(dn)
+			if u, ok := users[key]; ok {
+				u.EffectiveGroups = ingest.MergeChainedGroups(u.EffectiveGroups, []string{group})
+			} else if comp, ok := computers[key]; ok {
+				comp.EffectiveGroups = ingest.MergeChainedGroups(comp.EffectiveGroups, []string{group})
+			}
+		}
+	}
+}
+
+// chainedQueryGroups lists the groups of domain worth an in-chain query:
+// collected groups with adminCount=1, and group DNs referenced through
+// memberOf that are not among the collected groups.
+func chainedQueryGroups(dir *ingest.Directory, domain string) []string {
+	collected := make(map[string]bool, len(dir.Groups))
+	var groups []string
+	seen := make(map[string]bool)
+	add := func(dn string) {
+		key := strings.ToLower(dn)
+		if dn == "" || seen[key] || ingest.DomainFromDN(dn) != domain {
+			return
+		}
+		seen[key] = true
+		groups = append(groups, dn)
+	}
+	for _, g := range dir.Groups {
+		collected[strings.ToLower(g.DistinguishedName)] = true
+	}
+	for _, g := range dir.Groups {
+		if g.AdminCount {
+			add(g.DistinguishedName)
+		}
+	}
+	outOfScope := func(dns []string) {
+		for _, dn := range dns {
+			if !collected[strings.ToLower(dn)] {
+				add(dn)
+			}
+		}
+	}
+	for _, g := range dir.Groups {
+		outOfScope(g.MemberOf)
+	}
+	for _, u := range dir.Users {
+		outOfScope(u.MemberOf)
+	}
+	for _, comp := range dir.Computers {
+		outOfScope(comp.MemberOf)
+	}
+	return groups
+}
+
+// ChainedMembers returns the DN of every object that transitively belongs
+// to group. Primary-group members are not included; AD does not store the
+// primary group as a member link.
+func (c *LDAPClient) ChainedMembers(group string) ([]string, error) {
+	if group == "" {
+		return nil, nil
+	}
+	filter := fmt.Sprintf("(memberOf:%s:=%s)", matchingRuleInChain, ldap.EscapeFilter(group))
+	entries, err := c.SearchSubtreePaged(filter, []string{"distinguishedName"}, 500)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, e.DN)
+	}
+	return members, nil
+}
+
+// SPNOwner returns the sAMAccountName of the account that holds spn, whose
+// key encrypts the service tickets issued for it. It returns "" when no
+// account holds the SPN.
+func (c *LDAPClient) SPNOwner(spn string) (string, error) {
+	filter := fmt.Sprintf("(servicePrincipalName=%s)", ldap.EscapeFilter(spn))
+	entries, err := c.SearchSubtreePaged(filter, []string{"sAMAccountName"}, 10)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if name := e.GetAttributeValue("sAMAccountName"); name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
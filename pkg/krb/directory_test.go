@@ -0,0 +1,38 @@
+package krb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+func TestChainedQueryGroups(t *testing.T) {
+	dir := &ingest.Directory{
+		Groups: []ingest.Group{
+			{DistinguishedName: "CN=Domain Admins,CN=Users,DC=corp,DC=local", AdminCount: true},
+			{DistinguishedName: "CN=Helpdesk,OU=Groups,DC=corp,DC=local", MemberOf: []string{"CN=Tier1,OU=Hidden,DC=corp,DC=local"}},
+			{DistinguishedName: "CN=Enterprise Admins,CN=Users,DC=root,DC=local", AdminCount: true},
+		},
+		Users: []ingest.User{
+			{DistinguishedName: "CN=alice,DC=corp,DC=local", MemberOf: []string{
+				"CN=Helpdesk,OU=Groups,DC=corp,DC=local",
+				"cn=tier1,ou=hidden,dc=corp,dc=local",
+				"CN=Partners,DC=other,DC=local",
+			}},
+		},
+		Computers: []ingest.Computer{
+			{DistinguishedName: "CN=WS01,DC=corp,DC=local", MemberOf: []string{"CN=Workstations,OU=Hidden,DC=corp,DC=local"}},
+		},
+	}
+	// One query per privileged or uncollected group of this domain, never
+	// one per principal; groups of other domains are left to their DCs.
+	want := []string{
+		"CN=Domain Admins,CN=Users,DC=corp,DC=local",
+		"CN=Tier1,OU=Hidden,DC=corp,DC=local",
+		"CN=Workstations,OU=Hidden,DC=corp,DC=local",
+	}
+	if got := chainedQueryGroups(dir, "CORP.LOCAL"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package krb
+
+// msDS-SupportedEncryptionTypes bits (MS-KILE 2.2.7).
+const (
+	EtypeDESCBCCRC      = 0x01
+	EtypeDESCBCMD5      = 0x02
+	EtypeRC4HMAC        = 0x04
+	EtypeAES128         = 0x08
+	EtypeAES256         = 0x10
+	EtypeAESSessionKeys = 0x20
+
+	etypeAES = EtypeAES128 | EtypeAES256
+)
+
+// DefaultSupportedEtypes is what a KDC assumes for an account whose
+// msDS-SupportedEncryptionTypes is unset or zero: the
+// DefaultDomainSupportedEncTypes default since the November 2022 updates,
+// which still issues RC4 service tickets.
+const DefaultSupportedEtypes = EtypeDESCBCCRC | EtypeDESCBCMD5 | EtypeRC4HMAC | EtypeAESSessionKeys
+
+// Etype postures of an account, from the ticket encryption a KDC would use
+// for it.
+const (
+	EtypeRC4Only     = "rc4_only"     // no usable AES key; tickets are RC4
+	EtypeAESCapable  = "aes_capable"  // AES preferred but RC4 still allowed
+	EtypeAESEnforced = "aes_enforced" // RC4 refused
+)
+
+// EtypeNames lists the encryption types set in an
+// msDS-SupportedEncryptionTypes value.
+func EtypeNames(v int) []string {
+	bits := []struct {
+		bit  int
+		name string
+	}{
+		{EtypeDESCBCCRC, "DES-CBC-CRC"},
+		{EtypeDESCBCMD5, "DES-CBC-MD5"},
+		{EtypeRC4HMAC, "RC4-HMAC"},
+		{EtypeAES128, "AES128-CTS-HMAC-SHA1-96"},
+		{EtypeAES256, "AES256-CTS-HMAC-SHA1-96"},
+		{EtypeAESSessionKeys, "AES-SK"},
+	}
+	var names []string
+	for _, b := range bits {
+		if v&b.bit != 0 {
+			names = append(names, b.name)
+		}
+	}
+	return names
+}
+
+// ClassifyEtypes maps the ticket etypes a KDC would use for an account onto
+// a posture. Without AES keys (a password older than the domain's first
+// 2008 DC) the AES bits count for nothing.
+func ClassifyEtypes(effective int, aesKeys bool) string {
+	if !aesKeys {
+		effective &^= etypeAES
+	}
+	switch {
+	case effective&etypeAES == 0:
+		return EtypeRC4Only
+	case effective&EtypeRC4HMAC != 0:
+		return EtypeAESCapable
+	default:
+		return EtypeAESEnforced
+	}
+}
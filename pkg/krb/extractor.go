@@ -20,8 +20,12 @@ type Candidate struct {
 	PwdLastSet     time.Time
 	MemberOf       []string
 	ExportHashPath string
-	Hash           string // Actual extracted hash
-	Domain         string // Domain name
+	Hash           string       // Actual extracted hash (the first in Hashes that succeeded)
+	Hashes         []HashRecord `json:"hashes,omitempty"`   // one per roasting attempt: per SPN for Kerberoast
+	Password       string       `json:"password,omitempty"` // set once verified offline against Hash or Hashes
+	Domain         string       // Domain name
+	Delta          string       `json:"delta,omitempty"`         // "new" | "changed" against the previous incremental run
+	EtypePosture   string       `json:"etype_posture,omitempty"` // rc4_only | aes_capable | aes_enforced (Kerberoast candidates)
 }
 
 func FindASREPCandidates(users []ingest.User) []Candidate {
@@ -43,8 +47,9 @@ func FindASREPCandidates(users []ingest.User) []Candidate {
 			candidate := Candidate{
 				SamAccountName: user.SamAccountName,
 				Type:           "ASREP",
+				Domain:         ingest.DomainFromDN(user.DistinguishedName),
 				PwdLastSet:     user.PwdLastSet,
-				MemberOf:       user.MemberOf,
+				MemberOf:       user.EffectiveGroupDNs(),
 				Reasons:        []string{"DoesNotRequirePreAuth flag set"},
 			}
 
@@ -57,8 +62,9 @@ func FindASREPCandidates(users []ingest.User) []Candidate {
 				candidate.Reasons = append(candidate.Reasons, "Password older than 90 days")
 			}
 
-			if hasAdminGroup(user.MemberOf) {
+			if hasAdminGroup(user.EffectiveGroupDNs()) {
 				candidate.Reasons = append(candidate.Reasons, "Member of privileged group")
+				candidate.Reasons = append(candidate.Reasons, IndirectAdminEvidence(user)...)
 			}
 
 			candidates = append(candidates, candidate)
@@ -87,8 +93,9 @@ func FindKerberoastCandidates(users []ingest.User) []Candidate {
 			candidate := Candidate{
 				SamAccountName: user.SamAccountName,
 				Type:           "KERBEROAST",
+				Domain:         ingest.DomainFromDN(user.DistinguishedName),
 				PwdLastSet:     user.PwdLastSet,
-				MemberOf:       user.MemberOf,
+				MemberOf:       user.EffectiveGroupDNs(),
 				SPNs:           user.ServicePrincipalNames,
 				Reasons:        []string{"Has Service Principal Names"},
 			}
@@ -102,8 +109,9 @@ func FindKerberoastCandidates(users []ingest.User) []Candidate {
 				candidate.Reasons = append(candidate.Reasons, "Password older than 90 days")
 			}
 
-			if hasAdminGroup(user.MemberOf) {
+			if hasAdminGroup(user.EffectiveGroupDNs()) {
 				candidate.Reasons = append(candidate.Reasons, "Member of privileged group")
+				candidate.Reasons = append(candidate.Reasons, IndirectAdminEvidence(user)...)
 			}
 
 			candidates = append(candidates, candidate)
@@ -135,3 +143,23 @@ func hasAdminGroup(groups []string) bool {
 
 	return false
 }
+
+// IndirectAdminEvidence explains privileged memberships that are not visible
+// in memberOf: those held through nesting or through primaryGroupID.
+func IndirectAdminEvidence(user ingest.User) []string {
+	var evidence []string
+	for _, m := range user.EffectiveGroups {
+		if !hasAdminGroup([]string{m.DN}) {
+			continue
+		}
+		switch {
+		case m.Primary && !m.Nested():
+			evidence = append(evidence, "Primary group: "+m.Path())
+		case m.Primary:
+			evidence = append(evidence, "Nested via primary group: "+m.Path())
+		case m.Nested():
+			evidence = append(evidence, "Nested membership: "+m.Path())
+		}
+	}
+	return evidence
+}
@@ -109,3 +109,30 @@ func TestHasAdminGroup(t *testing.T) {
 		}
 	}
 }
+
+func TestCandidatesUseEffectiveGroups(t *testing.T) {
+	users := []ingest.User{{
+		SamAccountName:        "svc_helpdesk",
+		UserAccountControl:    512,
+		ServicePrincipalNames: []string{"HTTP/web.corp.local"},
+		MemberOf:              []string{"CN=Helpdesk,OU=Groups,DC=corp,DC=local"},
+		EffectiveGroups: []ingest.GroupMembership{
+			{DN: "CN=Helpdesk,OU=Groups,DC=corp,DC=local", Chain: []string{"CN=Helpdesk,OU=Groups,DC=corp,DC=local"}},
+			{DN: "CN=Account Operators,CN=Builtin,DC=corp,DC=local", Chain: []string{"CN=Helpdesk,OU=Groups,DC=corp,DC=local", "CN=Account Operators,CN=Builtin,DC=corp,DC=local"}},
+		},
+	}}
+
+	candidates := FindKerberoastCandidates(users)
+	if len(candidates) != 1 || len(candidates[0].MemberOf) != 2 {
+		t.Fatalf("expected effective groups on candidate, got %+v", candidates)
+	}
+	found := false
+	for _, r := range candidates[0].Reasons {
+		if r == "Nested membership: Helpdesk → Account Operators" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("missing nesting evidence in %v", candidates[0].Reasons)
+	}
+}
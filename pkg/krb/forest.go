@@ -0,0 +1,215 @@
+package krb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// crossRefDomainFilter selects crossRef objects for domain naming contexts
+// (systemFlags FLAG_CR_NTDS_DOMAIN), skipping configuration, schema and
+// application partitions.
+const crossRefDomainFilter = "(&(objectClass=crossRef)(systemFlags:1.2.840.113556.1.4.803:=2))"
+
+// ForestDomain is one domain partition listed in the forest's Partitions
+// container, with where it was collected from.
+type ForestDomain struct {
+	DNSName     string `json:"dns_name"`
+	NetBIOSName string `json:"netbios_name,omitempty"`
+	DN          string `json:"dn"`
+	Host        string `json:"host,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// EnumerateForestDomains lists every domain of the forest from the crossRef
+// objects under CN=Partitions of the configuration naming context.
+func (c *LDAPClient) EnumerateForestDomains() ([]ForestDomain, error) {
+	dse, err := c.RootDSE("configurationNamingContext")
+	if err != nil {
+		return nil, err
+	}
+	configNC := dse.GetAttributeValue("configurationNamingContext")
+	if configNC == "" {
+		return nil, fmt.Errorf("RootDSE returned no configurationNamingContext")
+	}
+
+	sr, err := c.conn.Search(ldap.NewSearchRequest(
+		"CN=Partitions,"+configNC,
+		ldap.ScopeSingleLevel,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		crossRefDomainFilter,
+		[]string{"nCName", "dnsRoot", "nETBIOSName"},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("partitions search failed: %v", err)
+	}
+
+	domains := make([]ForestDomain, 0, len(sr.Entries))
+	for _, e := range sr.Entries {
+		d := ForestDomain{
+			DNSName:     strings.ToUpper(e.GetAttributeValue("dnsRoot")),
+			NetBIOSName: e.GetAttributeValue("nETBIOSName"),
+			DN:          e.GetAttributeValue("nCName"),
+		}
+		if d.DNSName == "" {
+			d.DNSName = ingest.DomainFromDN(d.DN)
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+// CollectForest collects every other domain of the forest and merges it into
+// local, the bound domain's directory. With viaGC one Global Catalog
+// connection serves every domain (partial attribute set only); otherwise a
+// DC of each domain is located through DNS SRV and queried directly with the
+// same credentials. Domains that cannot be reached are returned with Error
+// set rather than failing the whole collection.
+func (c *LDAPClient) CollectForest(opts ConnectOptions, local *ingest.Directory, viaGC bool) ([]ForestDomain, error) {
+	log.Println("[*] Enumerating forest domains from CN=Partitions...")
+	domains, err := c.EnumerateForestDomains()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[+] Forest contains %d domains", len(domains))
+
+	var gc *LDAPClient
+	if viaGC {
+		if gc, err = c.connectGC(opts); err != nil {
+			return nil, err
+		}
+		defer gc.Close()
+	}
+
+	clients := []*LDAPClient{c}
+	for i := range domains {
+		d := &domains[i]
+		if strings.EqualFold(d.DN, c.baseDN) {
+			d.Host = c.ldapHost
+			continue
+		}
+
+		var dc *LDAPClient
+		if gc != nil {
+			dc = gc.withBaseDN(d.DN)
+			d.Host = gc.ldapHost
+		} else {
+			host, err := LocateDomainController(d.DNSName)
+			if err != nil {
+				d.Error = err.Error()
+				log.Printf("[!] %s: %v", d.DNSName, err)
+				continue
+			}
+			o := opts
+			o.Target = host
+			o.BaseDN = d.DN
+			if dc, err = Connect(o); err != nil {
+				d.Error = err.Error()
+				log.Printf("[!] %s: %v", d.DNSName, err)
+				continue
+			}
+			defer dc.Close()
+			dc.schema = c.schema // one schema per forest
+			d.Host = host
+		}
+
+		log.Printf("[*] Collecting domain %s via %s...", d.DNSName, d.Host)
+		sub, err := dc.CollectDomain()
+		if err != nil {
+			d.Error = err.Error()
+			log.Printf("[!] %s: %v", d.DNSName, err)
+			continue
+		}
+		if sub.Domain == "" {
+			sub.Domain = d.DNSName
+		}
+		local.Merge(sub)
+		clients = append(clients, dc)
+	}
+
+	log.Println("[*] Resolving nested group memberships across the forest...")
+	local.ResolveMemberships()
+	for _, dc := range clients {
+		dc.mergeChainedGroups(local)
+	}
+	return domains, nil
+}
+
+// withBaseDN returns a client sharing this connection but searching under dn,
+// used to walk every domain partition through one Global Catalog session.
+func (c *LDAPClient) withBaseDN(dn string) *LDAPClient {
+	scoped := *c
+	scoped.baseDN = dn
+	return &scoped
+}
+
+// connectGC opens a Global Catalog session on 3268 (3269 with LDAPS). The
+// host is opts.GC, then a _gc._tcp SRV record for the forest root, then the
+// DC already bound.
+func (c *LDAPClient) connectGC(opts ConnectOptions) (*LDAPClient, error) {
+	host := hostWithoutPort(strings.TrimSpace(opts.GC))
+	if host == "" {
+		if dse, err := c.RootDSE("rootDomainNamingContext"); err == nil {
+			host, _ = lookupSRVHost("gc", strings.ToLower(ingest.DomainFromDN(dse.GetAttributeValue("rootDomainNamingContext"))))
+		}
+	}
+	if host == "" {
+		host = c.ldapHost
+	}
+	port := "3268"
+	if opts.UseSSL {
+		port = "3269"
+	}
+	o := opts
+	o.Target = net.JoinHostPort(host, port)
+	o.BaseDN = c.baseDN
+	log.Printf("[*] Connecting to Global Catalog %s...", o.Target)
+	gc, err := Connect(o)
+	if err != nil {
+		return nil, fmt.Errorf("global catalog connection failed: %v", err)
+	}
+	gc.schema = c.schema // one schema per forest
+	return gc, nil
+}
+
+// LocateDomainController finds a DC for a domain via DNS SRV
+// (_ldap._tcp.dc._msdcs.<domain>, then _ldap._tcp.<domain>), falling back to
+// the domain name itself, which AD registers with the address of every DC.
+func LocateDomainController(domain string) (string, error) {
+	domain = strings.Trim(strings.TrimSpace(strings.ToLower(domain)), ".")
+	if domain == "" {
+		return "", fmt.Errorf("cannot locate a DC without a domain name")
+	}
+	for _, name := range []string{"dc._msdcs." + domain, domain} {
+		if host, err := lookupSRVHost("ldap", name); err == nil {
+			return host, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if addrs, err := net.DefaultResolver.LookupHost(ctx, domain); err == nil && len(addrs) > 0 {
+		return domain, nil
+	}
+	return "", fmt.Errorf("no DC found for %s via DNS SRV or A records", domain)
+}
+
+func lookupSRVHost(service, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", name)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no _%s._tcp.%s records", service, name)
+	}
+	return strings.TrimSuffix(addrs[0].Target, "."), nil
+}
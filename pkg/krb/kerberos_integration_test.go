@@ -111,7 +111,7 @@ func TestKerberosClientWrapper(t *testing.T) {
 
 func TestCreateKerberosClient(t *testing.T) {
 	// Test creating a Kerberos client through the wrapper
-	client, err := createKerberosClient("CORP.LOCAL", "10.0.0.1", "alice", "secret")
+	client, err := createKerberosClient("CORP.LOCAL", "10.0.0.1", "alice", "secret", 0)
 	if err != nil {
 		t.Fatalf("Failed to create Kerberos client: %v", err)
 	}
@@ -0,0 +1,182 @@
+package krb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// KeytabEtypes are the etypes DeriveKeys and KeytabForPassword produce,
+// strongest first.
+var KeytabEtypes = []int32{
+	etypeID.AES256_CTS_HMAC_SHA1_96,
+	etypeID.AES128_CTS_HMAC_SHA1_96,
+	etypeID.RC4_HMAC,
+}
+
+// ADSalt is the salt AD uses for an account's AES keys. Users are salted
+// with the upper-case realm and the account name as stored; computers
+// ("NAME$") with the realm, "host", the lower-case name and the lower-case
+// DNS domain, the way the KDC salts the host/ principal.
+func ADSalt(realm, account string) string {
+	realm = strings.ToUpper(realm)
+	if name, ok := strings.CutSuffix(account, "$"); ok {
+		return realm + "host" + strings.ToLower(name) + "." + strings.ToLower(realm)
+	}
+	return realm + account
+}
+
+// StringToKey derives the long-term key of etype from a password and salt
+// (RFC 3961 for RC4, where the key is the NT hash and the salt is ignored;
+// RFC 3962 PBKDF2 with the default 4096 iterations for AES).
+func StringToKey(etype int32, password, salt string) (types.EncryptionKey, error) {
+	et, err := crypto.GetEtype(etype)
+	if err != nil {
+		return types.EncryptionKey{}, err
+	}
+	key, err := et.StringToKey(password, salt, et.GetDefaultStringToKeyParams())
+	if err != nil {
+		return types.EncryptionKey{}, err
+	}
+	return types.EncryptionKey{KeyType: etype, KeyValue: key}, nil
+}
+
+// DeriveKeys derives the account's key for each of etypes (KeytabEtypes
+// when none are given) with its AD salt.
+func DeriveKeys(realm, account, password string, etypes ...int32) ([]types.EncryptionKey, error) {
+	if len(etypes) == 0 {
+		etypes = KeytabEtypes
+	}
+	salt := ADSalt(realm, account)
+	keys := make([]types.EncryptionKey, 0, len(etypes))
+	for _, e := range etypes {
+		k, err := StringToKey(e, password, salt)
+		if err != nil {
+			return nil, fmt.Errorf("etype %d: %w", e, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// KeytabEntry is one key in a keytab.
+type KeytabEntry struct {
+	Principal string // name components joined with "/", e.g. alice or HTTP/web.corp.local
+	Realm     string
+	NameType  int32
+	Timestamp time.Time
+	KVNO      uint32
+	Key       types.EncryptionKey
+}
+
+// String is the principal as principal@REALM.
+func (e KeytabEntry) String() string {
+	return e.Principal + "@" + e.Realm
+}
+
+// KeytabForPassword returns one entry per etype in KeytabEtypes for the
+// account, keyed with its AD salt.
+func KeytabForPassword(realm, account, password string, kvno uint32) ([]KeytabEntry, error) {
+	keys, err := DeriveKeys(realm, account, password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Second)
+	entries := make([]KeytabEntry, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, KeytabEntry{
+			Principal: account,
+			Realm:     strings.ToUpper(realm),
+			NameType:  nametype.KRB_NT_PRINCIPAL,
+			Timestamp: now,
+			KVNO:      kvno,
+			Key:       k,
+		})
+	}
+	return entries, nil
+}
+
+// ReadKeytab decodes a keytab (format 0x502 or 0x501).
+func ReadKeytab(data []byte) ([]KeytabEntry, error) {
+	kt := keytab.New()
+	if err := kt.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	entries := make([]KeytabEntry, 0, len(kt.Entries))
+	for _, e := range kt.Entries {
+		kvno := e.KVNO
+		if kvno == 0 {
+			kvno = uint32(e.KVNO8)
+		}
+		entries = append(entries, KeytabEntry{
+			Principal: strings.Join(e.Principal.Components, "/"),
+			Realm:     e.Principal.Realm,
+			NameType:  e.Principal.NameType,
+			Timestamp: e.Timestamp,
+			KVNO:      kvno,
+			Key:       e.Key,
+		})
+	}
+	return entries, nil
+}
+
+// LoadKeytab reads a keytab file.
+func LoadKeytab(path string) ([]KeytabEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadKeytab(data)
+}
+
+// MarshalKeytab encodes entries as an MIT keytab, format 0x502.
+func MarshalKeytab(entries []KeytabEntry) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{5, 2})
+	for _, e := range entries {
+		var rec bytes.Buffer
+		u16 := func(v uint16) { binary.Write(&rec, binary.BigEndian, v) }
+		u32 := func(v uint32) { binary.Write(&rec, binary.BigEndian, v) }
+		data := func(d []byte) { u16(uint16(len(d))); rec.Write(d) }
+
+		components := strings.Split(e.Principal, "/")
+		u16(uint16(len(components)))
+		data([]byte(e.Realm))
+		for _, c := range components {
+			data([]byte(c))
+		}
+		u32(uint32(e.NameType))
+		u32(uint32(e.Timestamp.Unix()))
+		rec.WriteByte(uint8(e.KVNO))
+		u16(uint16(e.Key.KeyType))
+		data(e.Key.KeyValue)
+		u32(e.KVNO)
+
+		binary.Write(&b, binary.BigEndian, int32(rec.Len()))
+		b.Write(rec.Bytes())
+	}
+	return b.Bytes()
+}
+
+// WriteKeytab writes entries to path, readable only by the owner.
+func WriteKeytab(path string, entries []KeytabEntry) error {
+	return os.WriteFile(path, MarshalKeytab(entries), 0600)
+}
+
+// gokrb5Keytab converts entries for gokrb5's client.
+func gokrb5Keytab(entries []KeytabEntry) (*keytab.Keytab, error) {
+	kt := keytab.New()
+	if err := kt.Unmarshal(MarshalKeytab(entries)); err != nil {
+		return nil, err
+	}
+	return kt, nil
+}
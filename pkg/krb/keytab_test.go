@@ -0,0 +1,76 @@
+package krb
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+func TestADSalt(t *testing.T) {
+	for account, want := range map[string]string{
+		"svc_sql": "CORP.LOCALsvc_sql",
+		"WS01$":   "CORP.LOCALhostws01.corp.local",
+	} {
+		if got := ADSalt("corp.local", account); got != want {
+			t.Errorf("ADSalt(%s) = %s, want %s", account, got, want)
+		}
+	}
+}
+
+func TestStringToKey(t *testing.T) {
+	rc4, err := StringToKey(etypeID.RC4_HMAC, "Password", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(rc4.KeyValue); got != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("RC4 key %s is not the NT hash", got)
+	}
+	// AES keys depend on the salt, so two users sharing a password differ.
+	a, _ := StringToKey(etypeID.AES256_CTS_HMAC_SHA1_96, "Password", ADSalt("corp.local", "alice"))
+	b, _ := StringToKey(etypeID.AES256_CTS_HMAC_SHA1_96, "Password", ADSalt("corp.local", "bob"))
+	if len(a.KeyValue) != 32 || hex.EncodeToString(a.KeyValue) == hex.EncodeToString(b.KeyValue) {
+		t.Errorf("AES256 keys %x %x", a.KeyValue, b.KeyValue)
+	}
+}
+
+func TestKeytabRoundTrip(t *testing.T) {
+	entries, err := KeytabForPassword("corp.local", "WS01$", "ws01", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(KeytabEtypes) {
+		t.Fatalf("%d entries, want %d", len(entries), len(KeytabEtypes))
+	}
+	path := filepath.Join(t.TempDir(), "ws01.keytab")
+	if err := WriteKeytab(path, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadKeytab(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("read %d entries, want %d", len(got), len(entries))
+	}
+	for i, e := range got {
+		want := entries[i]
+		if e.String() != "WS01$@CORP.LOCAL" || e.KVNO != 3 || !e.Timestamp.Equal(want.Timestamp) ||
+			e.Key.KeyType != want.Key.KeyType || hex.EncodeToString(e.Key.KeyValue) != hex.EncodeToString(want.Key.KeyValue) {
+			t.Errorf("entry %d: %+v, want %+v", i, e, want)
+		}
+	}
+
+	// gokrb5's client looks keys up by principal, etype and kvno.
+	kt, err := gokrb5Keytab(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, kvno, err := kt.GetEncryptionKey(types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "WS01$"), "CORP.LOCAL", 3, etypeID.AES128_CTS_HMAC_SHA1_96)
+	if err != nil || kvno != 3 || hex.EncodeToString(key.KeyValue) != hex.EncodeToString(entries[1].Key.KeyValue) {
+		t.Errorf("gokrb5 lookup: kvno %d, %v", kvno, err)
+	}
+}
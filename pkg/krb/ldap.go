@@ -26,16 +26,27 @@ type ConnectOptions struct {
 	CAFile   string // PEM CA bundle for TLS (ignored if Insecure)
 	Timeout  time.Duration
 	KDC      string // Optional explicit Kerberos host
-	GC       string // Optional Global Catalog host (reserved)
+	GC       string // Optional Global Catalog host for forest collection
+	BaseDN   string // Search base; defaults to RootDSE defaultNamingContext
+
+	// Auth selects the bind mechanism; empty infers it from the credentials
+	// below (certificate, then ccache/keytab, then NT hash, then password).
+	Auth     AuthMethod
+	Realm    string // Kerberos realm / NTLM domain when BindUser has none
+	NTHash   string // NTLM pass-the-hash (NT or LM:NT)
+	CCache   string // Kerberos credential cache (defaults to KRB5CCNAME)
+	Keytab   string // Kerberos keytab for BindUser
+	CertFile string // Client certificate (PFX/P12 or PEM) for Schannel auth
+	KeyFile  string // PEM private key when not bundled in CertFile
+	CertPass string // PFX password
 }
 
 // HashResult contains extracted Kerberos hashes
 type HashResult struct {
 	Username string
 	Domain   string
-	Hash     string
 	HashType string // "asrep" or "kerberoast"
-	SPN      string // for kerberoast
+	HashRecord
 }
 
 // LDAPClient wraps LDAP connection for AD enumeration
@@ -46,6 +57,19 @@ type LDAPClient struct {
 	bindSAM     string
 	bindPass    string
 	kdcOverride string
+	// sinceUSN, when set, limits collection searches to objects whose
+	// uSNChanged is above it (see ChangedSince).
+	sinceUSN int64
+	schema   *schemaCache
+	// opts is kept for probes that open their own connections.
+	opts ConnectOptions
+	// searcher, when set, runs Stream's searches instead of conn.
+	searcher searcher
+	// rootDSE is the last RootDSE read; clockOffset is added to the local
+	// clock for Kerberos requests once clockSynced (see SyncClock).
+	rootDSE     *RootDSE
+	clockOffset time.Duration
+	clockSynced bool
 }
 
 // Connect establishes an LDAP connection to a domain controller.
@@ -54,6 +78,9 @@ type LDAPClient struct {
 //   - --ssl          → ldaps:// on port 636 (implicit TLS)
 //   - --starttls     → ldap:// on port 389, then STARTTLS upgrade
 //   - (default)      → ldap:// on port 389 (plaintext)
+//
+// The session is then authenticated with simple, NTLM, Kerberos (GSSAPI)
+// or client-certificate (SASL EXTERNAL) bind; see ConnectOptions.Auth.
 func Connect(opts ConnectOptions) (*LDAPClient, error) {
 	target := opts.Target
 	timeout := opts.Timeout
@@ -85,6 +112,17 @@ func Connect(opts ConnectOptions) (*LDAPClient, error) {
 		tlsConfig.RootCAs = pool
 	}
 
+	if opts.authMethod() == AuthCertificate {
+		if !opts.UseSSL && !opts.StartTLS {
+			return nil, fmt.Errorf("certificate authentication requires --ldaps or --starttls")
+		}
+		cert, err := LoadClientCertificate(opts.CertFile, opts.KeyFile, opts.CertPass)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
 	var conn *ldap.Conn
 	var err error
 
@@ -136,27 +174,16 @@ func Connect(opts ConnectOptions) (*LDAPClient, error) {
 		}
 	}
 
-	// Bind — authenticated or anonymous
-	if opts.BindUser != "" && opts.BindPass != "" {
-		log.Printf("[*] Binding as %s...", opts.BindUser)
-		if err := conn.Bind(opts.BindUser, opts.BindPass); err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("LDAP bind as '%s' failed: %v\n"+
-				"    Hint: Try DOMAIN\\user, user@domain.com, or full DN format", opts.BindUser, err)
-		}
-		log.Printf("[+] Authenticated bind successful")
-	} else {
-		log.Printf("[*] Attempting anonymous bind...")
-		if err := conn.UnauthenticatedBind(""); err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("anonymous bind failed: %v\n"+
-				"    Most DCs block anonymous binds. Use --user and --pass.", err)
-		}
-		log.Printf("[!] Anonymous bind succeeded (results may be limited)")
+	if err := bindLDAP(conn, opts, host); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
 	// Discover Base DN from RootDSE
-	baseDN, err := getBaseDN(conn)
+	baseDN, err := opts.BaseDN, nil
+	if baseDN == "" {
+		baseDN, err = getBaseDN(conn)
+	}
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to determine base DN: %v\n"+
@@ -178,6 +205,8 @@ func Connect(opts ConnectOptions) (*LDAPClient, error) {
 		bindSAM:     SAMAccountNameFromBind(opts.BindUser),
 		bindPass:    opts.BindPass,
 		kdcOverride: strings.TrimSpace(opts.KDC),
+		schema:      &schemaCache{},
+		opts:        opts,
 	}, nil
 }
 
@@ -194,146 +223,120 @@ func ConnectLDAP(target, bindUser, bindPass string, useSSL bool) (*LDAPClient, e
 	})
 }
 
-// EnumerateUsers performs paged LDAP search for all user objects.
-// Uses LDAP Simple Paged Results control to handle directories with >1000 users.
-func (c *LDAPClient) EnumerateUsers() ([]ingest.User, error) {
+// EachUser streams every user object to fn as its page arrives, with Simple
+// Paged Results, so a caller that keeps only what it needs never holds the
+// whole domain. An error from fn stops the search and is returned.
+func (c *LDAPClient) EachUser(fn func(ingest.User) error) error {
 	log.Println("[*] Enumerating users (with paging)...")
 
-	searchFilter := "(&(objectCategory=person)(objectClass=user))"
-	attributes := []string{
-		"sAMAccountName",
-		"distinguishedName",
-		"userAccountControl",
-		"servicePrincipalName",
-		"pwdLastSet",
-		"lastLogon",
-		"lastLogonTimestamp",
-		"memberOf",
-		"description",
-		"mail",
-		"info",
-		"comment",
-		"physicalDeliveryOfficeName",
-		"postOfficeBox",
-	}
-
-	searchRequest := ldap.NewSearchRequest(
-		c.baseDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0, // no size limit (paging handles it)
-		0, // no time limit
-		false,
-		searchFilter,
-		attributes,
-		nil,
-	)
-
-	// Use paged search — most DCs enforce a 1000-entry page limit
-	const pageSize uint32 = 500
-	sr, err := c.conn.SearchWithPaging(searchRequest, pageSize)
+	schema := c.Schema()
+	err := c.Stream(StreamRequest{
+		Filter:     "(&(objectCategory=person)(objectClass=user))",
+		Attributes: userAttributes,
+		Progress:   logPages("users"),
+	}, func(entry *ldap.Entry) error {
+		return fn(userFromLDAPEntry(entry, schema))
+	})
 	if err != nil {
-		// Specific check for hardened anonymous access
-		if strings.Contains(err.Error(), "000004DC") {
-			return nil, fmt.Errorf("enumeration failed: your connection is anonymous and the DC requires authentication (Bind).\n    [!] HINT: Use -u and -p to provide credentials.")
-		}
-
-		// Fallback: if paging is unsupported, try a simple search
-		log.Printf("[!] Paged search failed (%v), falling back to simple search...", err)
-		sr, err = c.conn.Search(searchRequest)
-		if err != nil {
-			if strings.Contains(err.Error(), "000004DC") {
-				return nil, fmt.Errorf("enumeration failed: your connection is anonymous and the DC requires authentication (Bind).\n    [!] HINT: Use -u and -p to provide credentials.")
-			}
-			return nil, fmt.Errorf("LDAP search failed: %v", err)
+		if needsBind(err) {
+			return fmt.Errorf("enumeration failed: your connection is anonymous and the DC requires authentication (Bind).\n    [!] HINT: Use -u and -p to provide credentials.")
 		}
+		return fmt.Errorf("LDAP search failed: %v", err)
 	}
+	return nil
+}
 
-	log.Printf("[+] Found %d user objects", len(sr.Entries))
-
+// EnumerateUsers returns all user objects; see EachUser.
+func (c *LDAPClient) EnumerateUsers() ([]ingest.User, error) {
 	var users []ingest.User
-	for _, entry := range sr.Entries {
-		user := ingest.User{
-			SamAccountName:             entry.GetAttributeValue("sAMAccountName"),
-			DistinguishedName:          entry.GetAttributeValue("distinguishedName"),
-			Description:                entry.GetAttributeValue("description"),
-			Info:                       entry.GetAttributeValue("info"),
-			Comment:                    entry.GetAttributeValue("comment"),
-			PhysicalDeliveryOfficeName: entry.GetAttributeValue("physicalDeliveryOfficeName"),
-			PostOfficeBox:              entry.GetAttributeValue("postOfficeBox"),
-			Email:                      entry.GetAttributeValue("mail"),
-			ServicePrincipalNames:      entry.GetAttributeValues("servicePrincipalName"),
-			MemberOf:                   entry.GetAttributeValues("memberOf"),
-			RawFields:                  make(map[string]string),
-		}
+	err := c.EachUser(func(u ingest.User) error {
+		users = append(users, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Parse userAccountControl flags
-		uacStr := entry.GetAttributeValue("userAccountControl")
-		if uacStr != "" {
-			if uac, err := strconv.Atoi(uacStr); err == nil {
-				user.UserAccountControl = uac
-				user.DoesNotRequirePreAuth = (uac & 0x400000) != 0 // DONT_REQ_PREAUTH
-			}
-		}
+	log.Printf("[+] Found %d user objects", len(users))
+	return users, nil
+}
 
-		// Parse Windows FILETIME timestamps
-		user.PwdLastSet = parseWindowsTimestamp(entry.GetAttributeValue("pwdLastSet"))
-		user.LastLogon = parseWindowsTimestamp(entry.GetAttributeValue("lastLogon"))
-		user.LastLogonTimestamp = parseWindowsTimestamp(entry.GetAttributeValue("lastLogonTimestamp"))
+var userAttributes = []string{
+	"sAMAccountName",
+	"distinguishedName",
+	"userAccountControl",
+	"servicePrincipalName",
+	"pwdLastSet",
+	"lastLogon",
+	"lastLogonTimestamp",
+	"memberOf",
+	"primaryGroupID",
+	"objectSid",
+	"description",
+	"mail",
+	"info",
+	"comment",
+	"physicalDeliveryOfficeName",
+	"postOfficeBox",
+	"msDS-SupportedEncryptionTypes",
+}
 
-		// Store raw fields for debugging
-		for _, attr := range entry.Attributes {
-			if len(attr.Values) > 0 {
-				user.RawFields[attr.Name] = strings.Join(attr.Values, ";")
-			}
+func userFromLDAPEntry(entry *ldap.Entry, schema *ingest.Schema) ingest.User {
+	user := ingest.User{
+		SamAccountName:             entry.GetAttributeValue("sAMAccountName"),
+		DistinguishedName:          entry.GetAttributeValue("distinguishedName"),
+		Description:                entry.GetAttributeValue("description"),
+		Info:                       entry.GetAttributeValue("info"),
+		Comment:                    entry.GetAttributeValue("comment"),
+		PhysicalDeliveryOfficeName: entry.GetAttributeValue("physicalDeliveryOfficeName"),
+		PostOfficeBox:              entry.GetAttributeValue("postOfficeBox"),
+		Email:                      entry.GetAttributeValue("mail"),
+		ServicePrincipalNames:      entry.GetAttributeValues("servicePrincipalName"),
+		MemberOf:                   entry.GetAttributeValues("memberOf"),
+	}
+
+	// Parse userAccountControl flags
+	uacStr := entry.GetAttributeValue("userAccountControl")
+	if uacStr != "" {
+		if uac, err := strconv.Atoi(uacStr); err == nil {
+			user.UserAccountControl = uac
+			user.DoesNotRequirePreAuth = (uac & 0x400000) != 0 // DONT_REQ_PREAUTH
 		}
-
-		users = append(users, user)
 	}
 
-	return users, nil
+	user.ObjectSID, _ = ingest.FormatSID(entry.GetRawAttributeValue("objectSid"))
+	user.PrimaryGroupID, _ = strconv.Atoi(entry.GetAttributeValue("primaryGroupID"))
+	user.SupportedEncryptionTypes, _ = strconv.Atoi(entry.GetAttributeValue("msDS-SupportedEncryptionTypes"))
+
+	// Parse Windows FILETIME timestamps
+	user.PwdLastSet = parseWindowsTimestamp(entry.GetAttributeValue("pwdLastSet"))
+	user.LastLogon = parseWindowsTimestamp(entry.GetAttributeValue("lastLogon"))
+	user.LastLogonTimestamp = parseWindowsTimestamp(entry.GetAttributeValue("lastLogonTimestamp"))
+
+	// Keep every attribute, decoded by its schema syntax
+	user.RawFields, user.DecodedFields = schema.DecodeFields(recordFromLDAPEntry(entry, schema).Attributes)
+	return user
 }
 
-// GetDomainInfo retrieves basic domain information from the DC.
+// GetDomainInfo retrieves basic domain information from the DC. The RootDSE
+// is read once and reused.
 func (c *LDAPClient) GetDomainInfo() (*DomainInfo, error) {
 	log.Println("[*] Gathering domain information...")
 
-	searchRequest := ldap.NewSearchRequest(
-		c.baseDN,
-		ldap.ScopeBaseObject,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		"(objectClass=*)",
-		[]string{"distinguishedName", "dnsHostName", "ldapServiceName", "domainFunctionality"},
-		nil,
-	)
-
-	sr, err := c.conn.Search(searchRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get domain info: %v", err)
-	}
-
-	if len(sr.Entries) == 0 {
-		return nil, fmt.Errorf("no domain information found")
-	}
-
-	entry := sr.Entries[0]
-
-	// Map functional levels
-	flMap := map[string]string{
-		"0": "2000", "1": "2003 Mixed", "2": "2003", "3": "2008",
-		"4": "2008 R2", "5": "2012", "6": "2012 R2", "7": "2016",
-	}
-	fl := entry.GetAttributeValue("domainFunctionality")
-	if val, ok := flMap[fl]; ok {
-		fl = val
+	rootDSE := c.rootDSE
+	if rootDSE == nil {
+		var err error
+		if rootDSE, err = c.ReadRootDSE(); err != nil {
+			return nil, fmt.Errorf("failed to get domain info: %v", err)
+		}
 	}
 
 	info := &DomainInfo{
 		BaseDN:          c.baseDN,
-		DNSHostName:     entry.GetAttributeValue("dnsHostName"),
-		LDAPService:     entry.GetAttributeValue("ldapServiceName"),
-		FunctionalLevel: fl,
+		DNSHostName:     rootDSE.DNSHostName,
+		LDAPService:     rootDSE.LDAPServiceName,
+		FunctionalLevel: rootDSE.DomainFunctionality,
+		RootDSE:         rootDSE,
 	}
 
 	// Try to get OS version from the DC computer object
@@ -376,28 +379,52 @@ func (c *LDAPClient) LDAPHost() string {
 	return c.ldapHost
 }
 
-// SearchSubtreePaged runs a whole-subtree search with Simple Paged Results.
-func (c *LDAPClient) SearchSubtreePaged(filter string, attributes []string, pageSize uint32) ([]*ldap.Entry, error) {
+// ConnectOptions returns the options the client was connected with.
+func (c *LDAPClient) ConnectOptions() ConnectOptions {
+	return c.opts
+}
+
+// RootDSE reads the requested attributes of the server's RootDSE.
+func (c *LDAPClient) RootDSE(attributes ...string) (*ldap.Entry, error) {
 	if c.conn == nil {
 		return nil, fmt.Errorf("ldap: no connection")
 	}
-	if pageSize == 0 {
-		pageSize = 500
+	sr, err := c.conn.Search(ldap.NewSearchRequest(
+		"", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("RootDSE query failed: %v", err)
 	}
-	req := ldap.NewSearchRequest(
-		c.baseDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		filter,
-		attributes,
-		nil,
-	)
-	sr, err := c.conn.SearchWithPaging(req, pageSize)
+	if len(sr.Entries) == 0 {
+		return nil, fmt.Errorf("RootDSE returned no entries")
+	}
+	return sr.Entries[0], nil
+}
+
+// SearchSubtreePaged runs a whole-subtree search with Simple Paged Results
+// and range retrieval, returning every entry. Prefer Stream for searches
+// that can return the whole directory.
+func (c *LDAPClient) SearchSubtreePaged(filter string, attributes []string, pageSize uint32) ([]*ldap.Entry, error) {
+	var entries []*ldap.Entry
+	err := c.Stream(StreamRequest{Filter: filter, Attributes: attributes, PageSize: pageSize}, func(e *ldap.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return sr.Entries, nil
+	return entries, nil
+}
+
+// logPages returns a Progress callback that logs every 20 pages, enough to
+// show a large collection is moving without flooding the console.
+func logPages(label string) func(SearchProgress) {
+	return func(p SearchProgress) {
+		if p.Pages%20 == 0 {
+			log.Printf("[*] %s: %d entries after %d pages (%d ranged attributes)", label, p.Entries, p.Pages, p.Ranged)
+		}
+	}
 }
 
 // DomainInfo holds basic domain information
@@ -408,6 +435,7 @@ type DomainInfo struct {
 	LDAPService     string
 	FunctionalLevel string
 	OS              string
+	RootDSE         *RootDSE
 }
 
 // --- Hash Extraction ---
@@ -422,16 +450,16 @@ func (c *LDAPClient) ExtractASREPHash(username, domain string) (*HashResult, err
 		domainInfo = &DomainInfo{DomainName: domain}
 	}
 
-	hash, err := c.extractRealASREPHash(username, domain, domainInfo)
+	record, err := c.extractRealASREPHash(username, domain, domainInfo)
 	if err != nil {
-		return nil, fmt.Errorf("AS-REP extraction failed for %s: %v", username, err)
+		return nil, fmt.Errorf("AS-REP extraction failed for %s: %w", username, err)
 	}
 
 	return &HashResult{
-		Username: username,
-		Domain:   domain,
-		Hash:     hash,
-		HashType: "asrep",
+		Username:   username,
+		Domain:     domain,
+		HashType:   HashTypeASREP,
+		HashRecord: record,
 	}, nil
 }
 
@@ -445,17 +473,16 @@ func (c *LDAPClient) ExtractKerberoastHash(username, domain, spn string) (*HashR
 		domainInfo = &DomainInfo{DomainName: domain}
 	}
 
-	hash, err := c.extractRealKerberoastHash(username, domain, spn, domainInfo)
+	record, err := c.extractRealKerberoastHash(username, domain, spn, domainInfo)
 	if err != nil {
-		return nil, fmt.Errorf("Kerberoast extraction failed for %s (SPN: %s): %v", username, spn, err)
+		return nil, fmt.Errorf("Kerberoast extraction failed for %s (SPN: %s): %w", username, spn, err)
 	}
 
 	return &HashResult{
-		Username: username,
-		Domain:   domain,
-		Hash:     hash,
-		HashType: "kerberoast",
-		SPN:      spn,
+		Username:   username,
+		Domain:     domain,
+		HashType:   HashTypeKerberoast,
+		HashRecord: record,
 	}, nil
 }
 
@@ -512,25 +539,25 @@ func ensurePort(target, defaultPort string) string {
 
 // --- Kerberos Protocol Helpers (used by hash extraction) ---
 
-func (c *LDAPClient) extractRealASREPHash(username, domain string, domainInfo *DomainInfo) (string, error) {
+func (c *LDAPClient) extractRealASREPHash(username, domain string, domainInfo *DomainInfo) (HashRecord, error) {
 	realm := domainInfo.DomainName
 	if realm == "" {
 		realm = domain
 	}
 	kdcHost, err := ResolveKDCHost(c.ldapHost, c.kdcOverride, domainInfo.DNSHostName, strings.ToLower(realm))
 	if err != nil {
-		return "", err
+		return HashRecord{}, err
 	}
-	kerbClient, err := createKerberosClient(domain, kdcHost, c.bindSAM, c.bindPass)
+	kerbClient, err := createKerberosClient(domain, kdcHost, c.bindSAM, c.bindPass, c.ClockOffset())
 	if err != nil {
-		return "", fmt.Errorf("failed to create Kerberos client: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to create Kerberos client: %v", err)
 	}
 	return kerbClient.ExtractASREPHash(username)
 }
 
-func (c *LDAPClient) extractRealKerberoastHash(serviceSAM, domain, spn string, domainInfo *DomainInfo) (string, error) {
+func (c *LDAPClient) extractRealKerberoastHash(serviceSAM, domain, spn string, domainInfo *DomainInfo) (HashRecord, error) {
 	if c.bindSAM == "" || c.bindPass == "" {
-		return "", fmt.Errorf("Kerberoasting requires authenticated LDAP bind credentials (-u/-p)")
+		return HashRecord{}, fmt.Errorf("Kerberoasting requires authenticated LDAP bind credentials (-u/-p)")
 	}
 	realm := domainInfo.DomainName
 	if realm == "" {
@@ -538,28 +565,29 @@ func (c *LDAPClient) extractRealKerberoastHash(serviceSAM, domain, spn string, d
 	}
 	kdcHost, err := ResolveKDCHost(c.ldapHost, c.kdcOverride, domainInfo.DNSHostName, strings.ToLower(realm))
 	if err != nil {
-		return "", err
+		return HashRecord{}, err
 	}
-	kerbClient, err := createKerberosClient(domain, kdcHost, c.bindSAM, c.bindPass)
+	kerbClient, err := createKerberosClient(domain, kdcHost, c.bindSAM, c.bindPass, c.ClockOffset())
 	if err != nil {
-		return "", fmt.Errorf("failed to create Kerberos client: %v", err)
+		return HashRecord{}, fmt.Errorf("failed to create Kerberos client: %v", err)
 	}
 	return kerbClient.ExtractKerberoastHash(serviceSAM, spn)
 }
 
-func createKerberosClient(domain, kdcAddress, clientSAM, clientPass string) (KerberosProtocolClient, error) {
+func createKerberosClient(domain, kdcAddress, clientSAM, clientPass string, clockOffset time.Duration) (KerberosProtocolClient, error) {
 	return &kerberosClientWrapper{
-		domain:     domain,
-		kdcAddress: kdcAddress,
-		clientSAM:  clientSAM,
-		clientPass: clientPass,
+		domain:      domain,
+		kdcAddress:  kdcAddress,
+		clientSAM:   clientSAM,
+		clientPass:  clientPass,
+		clockOffset: clockOffset,
 	}, nil
 }
 
 // KerberosProtocolClient interface for Kerberos operations
 type KerberosProtocolClient interface {
-	ExtractASREPHash(username string) (string, error)
-	ExtractKerberoastHash(username, spn string) (string, error)
+	ExtractASREPHash(username string) (HashRecord, error)
+	ExtractKerberoastHash(username, spn string) (HashRecord, error)
 }
 
 type kerberosClientWrapper struct {
@@ -567,28 +595,33 @@ type kerberosClientWrapper struct {
 	kdcAddress string
 	clientSAM  string
 	clientPass string
-	realClient *RealKerberosClient
+	// clockOffset is the KDC clock minus the local clock when it exceeds
+	// tolerance, else zero.
+	clockOffset time.Duration
+	realClient  *RealKerberosClient
 }
 
-func (k *kerberosClientWrapper) ExtractASREPHash(username string) (string, error) {
+func (k *kerberosClientWrapper) ExtractASREPHash(username string) (HashRecord, error) {
 	if k.realClient == nil {
 		client, err := NewRealKerberosClient(k.domain, k.kdcAddress)
 		if err != nil {
-			return "", err
+			return HashRecord{}, err
 		}
 		client.SetClientCredentials(k.clientSAM, k.clientPass)
+		client.SetClockOffset(k.clockOffset)
 		k.realClient = client
 	}
 	return k.realClient.ExtractASREPHash(username)
 }
 
-func (k *kerberosClientWrapper) ExtractKerberoastHash(username, spn string) (string, error) {
+func (k *kerberosClientWrapper) ExtractKerberoastHash(username, spn string) (HashRecord, error) {
 	if k.realClient == nil {
 		client, err := NewRealKerberosClient(k.domain, k.kdcAddress)
 		if err != nil {
-			return "", err
+			return HashRecord{}, err
 		}
 		client.SetClientCredentials(k.clientSAM, k.clientPass)
+		client.SetClockOffset(k.clockOffset)
 		k.realClient = client
 	}
 	return k.realClient.ExtractKerberoastHash(username, spn)
@@ -0,0 +1,232 @@
+package krb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM negotiate flags (MS-NLMP 2.2.2.5). The probe negotiator never asks
+// for signing or sealing, so a DC that requires either rejects the bind.
+const (
+	ntlmNegotiateUnicode          = 0x00000001
+	ntlmRequestTarget             = 0x00000004
+	ntlmNegotiateNTLM             = 0x00000200
+	ntlmNegotiateExtendedSecurity = 0x00080000
+	ntlmNegotiateTargetInfo       = 0x00800000
+	ntlmNegotiate128              = 0x20000000
+	ntlmNegotiate56               = 0x80000000
+
+	ntlmProbeFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateExtendedSecurity | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+// AV_PAIR ids used when rewriting the server's target info.
+const (
+	avEOL             = 0x0000
+	avTimestamp       = 0x0007
+	avChannelBindings = 0x000a
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiator is an ldap.NTLMNegotiator that builds the NTLMv2
+// AUTHENTICATE message itself so the probes control exactly what is sent:
+// no signing flags, and an MsvAvChannelBindings value of their choosing.
+type ntlmNegotiator struct {
+	domain string
+	// bindings is the 16-byte channel binding hash; nil leaves the AV pair
+	// out entirely, as a client without channel binding support would.
+	bindings []byte
+
+	// clientChallenge and timestamp are fixed by tests; nil means random
+	// and the current time.
+	clientChallenge []byte
+	timestamp       []byte
+}
+
+func (n *ntlmNegotiator) Negotiate(domain, workstation string) ([]byte, error) {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmProbeFlags)
+	return msg, nil
+}
+
+// ChallengeResponse answers a CHALLENGE message. hash is the hex NT hash;
+// go-ldap derives it from the password when none was given.
+func (n *ntlmNegotiator) ChallengeResponse(challenge []byte, username, hash string) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) ||
+		binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, fmt.Errorf("not an NTLM CHALLENGE message")
+	}
+	ntHash, err := hex.DecodeString(hash)
+	if err != nil || len(ntHash) != 16 {
+		return nil, fmt.Errorf("invalid NT hash")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:]) & ntlmProbeFlags
+	serverChallenge := challenge[24:32]
+	targetInfo, err := ntlmField(challenge, 40)
+	if err != nil {
+		return nil, fmt.Errorf("target info: %v", err)
+	}
+
+	clientChallenge := n.clientChallenge
+	if clientChallenge == nil {
+		clientChallenge = make([]byte, 8)
+		if _, err := rand.Read(clientChallenge); err != nil {
+			return nil, err
+		}
+	}
+	info, timestamp := n.rewriteTargetInfo(targetInfo)
+	serverTimestamp := timestamp != nil
+	if !serverTimestamp {
+		timestamp = n.timestamp
+	}
+	if timestamp == nil {
+		timestamp = binary.LittleEndian.AppendUint64(nil, timeToFiletime(time.Now()))
+	}
+
+	key := ntowfv2(ntHash, username, n.domain)
+	temp := make([]byte, 0, 28+len(info)+4)
+	temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, info...)
+	temp = append(temp, 0, 0, 0, 0)
+	ntResponse := append(hmacMD5(key, serverChallenge, temp), temp...)
+
+	// With a server timestamp the LMv2 response must be zeros (MS-NLMP
+	// 3.1.5.1.2).
+	lmResponse := make([]byte, 24)
+	if !serverTimestamp {
+		lmResponse = append(hmacMD5(key, serverChallenge, clientChallenge), clientChallenge...)
+	}
+
+	fields := [][]byte{lmResponse, ntResponse, utf16le(n.domain), utf16le(username), nil, nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	for i, f := range fields {
+		putNTLMField(msg[12+8*i:], len(f), len(msg))
+		msg = append(msg, f...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	return msg, nil
+}
+
+// rewriteTargetInfo copies the server's AV pairs, inserting
+// MsvAvChannelBindings before MsvAvEOL, and returns the server timestamp
+// when one was sent.
+func (n *ntlmNegotiator) rewriteTargetInfo(info []byte) (out, timestamp []byte) {
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		size := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+size > len(info) {
+			break
+		}
+		if id == avTimestamp && size == 8 {
+			timestamp = info[4:12]
+		}
+		if id != avChannelBindings {
+			out = append(out, info[:4+size]...)
+		}
+		info = info[4+size:]
+	}
+	if n.bindings != nil {
+		out = appendAVPair(out, avChannelBindings, n.bindings)
+	}
+	return appendAVPair(out, avEOL, nil), timestamp
+}
+
+func appendAVPair(b []byte, id uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, id)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+func ntlmField(msg []byte, at int) ([]byte, error) {
+	size := int(binary.LittleEndian.Uint16(msg[at:]))
+	offset := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	if offset+size > len(msg) {
+		return nil, fmt.Errorf("field runs past the message")
+	}
+	return msg[offset : offset+size], nil
+}
+
+func putNTLMField(b []byte, size, offset int) {
+	binary.LittleEndian.PutUint16(b, uint16(size))
+	binary.LittleEndian.PutUint16(b[2:], uint16(size))
+	binary.LittleEndian.PutUint32(b[4:], uint32(offset))
+}
+
+// ntowfv2 is HMAC-MD5(NT hash, UNICODE(Uppercase(user) + domain)).
+func ntowfv2(ntHash []byte, user, domain string) []byte {
+	return hmacMD5(ntHash, utf16le(strings.ToUpper(user)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+// ntHashFromPassword returns MD4(UTF-16LE(password)) as hex.
+func ntHashFromPassword(password string) string {
+	h := md4.New()
+	h.Write(utf16le(password))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func timeToFiletime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+// ChannelBindingHash returns the MsvAvChannelBindings value for a TLS
+// server certificate: MD5 of a gss_channel_bindings_struct whose
+// application data is "tls-server-end-point:" and the certificate hash
+// (RFC 5929). The certificate is hashed with its signature hash, or SHA-256
+// when that is MD5 or SHA-1.
+func ChannelBindingHash(cert *x509.Certificate) []byte {
+	var digest []byte
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		sum := sha512.Sum384(cert.Raw)
+		digest = sum[:]
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		sum := sha512.Sum512(cert.Raw)
+		digest = sum[:]
+	default:
+		sum := sha256.Sum256(cert.Raw)
+		digest = sum[:]
+	}
+
+	appData := append([]byte("tls-server-end-point:"), digest...)
+	bindings := make([]byte, 20, 20+len(appData))
+	binary.LittleEndian.PutUint32(bindings[16:], uint32(len(appData)))
+	sum := md5.Sum(append(bindings, appData...))
+	return sum[:]
+}
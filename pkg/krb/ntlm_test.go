@@ -0,0 +1,129 @@
+package krb
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// ntlmChallenge builds a CHALLENGE message with the MS-NLMP 4.2.4 server
+// challenge and target info.
+func ntlmChallenge(targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmProbeFlags)
+	copy(msg[24:], []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+	putNTLMField(msg[40:], len(targetInfo), len(msg))
+	return append(msg, targetInfo...)
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestNTLMv2Response checks the AUTHENTICATE message against the NTLMv2
+// example in MS-NLMP 4.2.4.
+func TestNTLMv2Response(t *testing.T) {
+	if got := ntHashFromPassword("Password"); got != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Fatalf("NT hash = %s", got)
+	}
+	targetInfo := appendAVPair(nil, 2, utf16le("Domain"))
+	targetInfo = appendAVPair(targetInfo, 1, utf16le("Server"))
+	targetInfo = appendAVPair(targetInfo, avEOL, nil)
+
+	n := &ntlmNegotiator{
+		domain:          "Domain",
+		clientChallenge: bytes.Repeat([]byte{0xaa}, 8),
+		timestamp:       make([]byte, 8),
+	}
+	msg, err := n.ChallengeResponse(ntlmChallenge(targetInfo), "User", "a4f49c406510bdcab6824ee7c30fd852")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, err := ntlmField(msg, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nt, err := ntlmField(msg, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := mustHex(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"); !bytes.Equal(lm, want) {
+		t.Errorf("LMv2 = %x, want %x", lm, want)
+	}
+	if want := mustHex(t, "68cd0ab851e51c96aabc927bebef6a1c"); !bytes.Equal(nt[:16], want) {
+		t.Errorf("NTProofStr = %x, want %x", nt[:16], want)
+	}
+	if !bytes.HasSuffix(nt, append(targetInfo, 0, 0, 0, 0)) {
+		t.Errorf("NT response does not carry the server target info unchanged")
+	}
+	if user, _ := ntlmField(msg, 36); !bytes.Equal(user, utf16le("User")) {
+		t.Errorf("user = %x", user)
+	}
+	if flags := binary.LittleEndian.Uint32(msg[60:]); flags&0x30 != 0 {
+		t.Errorf("AUTHENTICATE asks for signing or sealing: %#x", flags)
+	}
+}
+
+func TestNTLMChannelBindingsAVPair(t *testing.T) {
+	timestamp := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	targetInfo := appendAVPair(nil, 2, utf16le("CORP"))
+	targetInfo = appendAVPair(targetInfo, avTimestamp, timestamp)
+	targetInfo = appendAVPair(targetInfo, avEOL, nil)
+
+	bindings := bytes.Repeat([]byte{0x42}, 16)
+	n := &ntlmNegotiator{domain: "CORP", bindings: bindings}
+	msg, err := n.ChallengeResponse(ntlmChallenge(targetInfo), "alice", "31d6cfe0d16ae931b73c59d7e0c089c0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nt, _ := ntlmField(msg, 20)
+	want := appendAVPair(nil, 2, utf16le("CORP"))
+	want = appendAVPair(want, avTimestamp, timestamp)
+	want = appendAVPair(want, avChannelBindings, bindings)
+	want = appendAVPair(want, avEOL, nil)
+	if !bytes.HasSuffix(nt, append(want, 0, 0, 0, 0)) {
+		t.Errorf("MsvAvChannelBindings not inserted before MsvAvEOL: %x", nt[44:])
+	}
+	if !bytes.Equal(nt[24:32], timestamp) {
+		t.Errorf("server timestamp not used: %x", nt[24:32])
+	}
+	if lm, _ := ntlmField(msg, 12); !bytes.Equal(lm, make([]byte, 24)) {
+		t.Errorf("LMv2 must be zeros with a server timestamp, got %x", lm)
+	}
+}
+
+func TestChannelBindingHash(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("certificate"), SignatureAlgorithm: x509.SHA256WithRSA}
+	certHash := sha256.Sum256(cert.Raw)
+
+	var gss bytes.Buffer
+	gss.Write(make([]byte, 16)) // initiator and acceptor address type and length
+	binary.Write(&gss, binary.LittleEndian, uint32(21+len(certHash)))
+	gss.WriteString("tls-server-end-point:")
+	gss.Write(certHash[:])
+	want := md5.Sum(gss.Bytes())
+
+	if got := ChannelBindingHash(cert); !bytes.Equal(got, want[:]) {
+		t.Errorf("ChannelBindingHash = %x, want %x", got, want)
+	}
+	cert.SignatureAlgorithm = x509.SHA1WithRSA
+	if got := ChannelBindingHash(cert); !bytes.Equal(got, want[:]) {
+		t.Errorf("SHA-1 signed certificates must be hashed with SHA-256")
+	}
+	cert.SignatureAlgorithm = x509.SHA384WithRSA
+	if got := ChannelBindingHash(cert); bytes.Equal(got, want[:]) {
+		t.Errorf("SHA-384 signed certificates must be hashed with SHA-384")
+	}
+}
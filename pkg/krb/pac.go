@@ -0,0 +1,327 @@
+package krb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/adtype"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/pac"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// PAC buffer types (MS-PAC 2.4).
+const (
+	PACLogonInfo       = 1
+	PACServerChecksum  = 6
+	PACKDCChecksum     = 7
+	PACClientInfo      = 10
+	PACUPNDNSInfo      = 12
+	PACTicketChecksum  = 16
+	PACAttributesInfo  = 17
+	PACRequestor       = 18
+	PACFullPACChecksum = 19
+)
+
+// PAC signature states.
+const (
+	SignatureValid      = "valid"
+	SignatureInvalid    = "invalid"
+	SignatureUnverified = "unverified" // no key that could check it was supplied
+	SignatureMissing    = "missing"
+)
+
+// ErrNoTicketKey means none of the supplied keys decrypts the ticket.
+var ErrNoTicketKey = errors.New("no supplied key decrypts the ticket")
+
+// TicketPAC is what a decrypted ticket says about its client: the
+// EncTicketPart, which only the holder of the server key can write, and
+// the PAC inside it.
+type TicketPAC struct {
+	ClientName  string
+	ClientRealm string
+	Flags       []string
+	AuthTime    time.Time
+	StartTime   time.Time
+	EndTime     time.Time
+	RenewTill   time.Time
+	// DecryptedWith is the principal of the key that opened the ticket.
+	DecryptedWith string
+
+	HasPAC  bool
+	Buffers []uint32 // buffer types in PAC order
+
+	// KERB_VALIDATION_INFO
+	EffectiveName      string
+	LogonDomain        string
+	DomainSID          string
+	UserRID            uint32
+	PrimaryGroupRID    uint32
+	GroupRIDs          []uint32
+	ExtraSIDs          []string
+	ResourceGroupSIDs  []string
+	UserAccountControl uint32
+	LogonTime          time.Time
+
+	// PAC_CLIENT_INFO
+	ClientInfoName string
+	ClientInfoTime time.Time
+
+	// UPN_DNS_INFO
+	UPN       string
+	DNSDomain string
+
+	// RequestorSID is PAC_REQUESTOR, the SID of the account the TGT was
+	// issued to; empty when the buffer is absent.
+	RequestorSID string
+	// Attributes is PAC_ATTRIBUTES_INFO (1 = PAC requested, 2 = given
+	// implicitly); HasAttributes is false when the buffer is absent.
+	Attributes    uint32
+	HasAttributes bool
+
+	ServerSignature string
+	KDCSignature    string
+}
+
+// UserSID is the client's SID from the logon info.
+func (p *TicketPAC) UserSID() string {
+	if p.DomainSID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", p.DomainSID, p.UserRID)
+}
+
+// HasBuffer reports whether the PAC holds a buffer of type t.
+func (p *TicketPAC) HasBuffer(t uint32) bool {
+	for _, b := range p.Buffers {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeTicketPAC decrypts t with whichever key in keys opens it and
+// decodes its PAC. The server signature is checked with that key; the KDC
+// signature with a krbtgt key, which for a TGT is the same key.
+func DecodeTicketPAC(t CachedTicket, keys []TicketKey) (*TicketPAC, error) {
+	tkt := t.Ticket
+	var serviceKey *TicketKey
+	for i := range keys {
+		if keys[i].Key.KeyType != tkt.EncPart.EType {
+			continue
+		}
+		if err := tkt.Decrypt(keys[i].Key); err == nil {
+			serviceKey = &keys[i]
+			break
+		}
+	}
+	if serviceKey == nil {
+		return nil, ErrNoTicketKey
+	}
+
+	enc := tkt.DecryptedEncPart
+	p := &TicketPAC{
+		ClientName:    enc.CName.PrincipalNameString(),
+		ClientRealm:   enc.CRealm,
+		Flags:         TicketFlagNames(enc.Flags),
+		AuthTime:      enc.AuthTime,
+		StartTime:     enc.StartTime,
+		EndTime:       enc.EndTime,
+		RenewTill:     enc.RenewTill,
+		DecryptedWith: serviceKey.Principal,
+	}
+	raw, ok := findPAC(enc.AuthorizationData)
+	if !ok {
+		return p, nil
+	}
+	p.HasPAC = true
+
+	krbtgtKeys := make([]TicketKey, 0, len(keys))
+	for _, k := range keys {
+		if k.IsKrbtgt() {
+			krbtgtKeys = append(krbtgtKeys, k)
+		}
+	}
+	if t.IsTGT() {
+		krbtgtKeys = append(krbtgtKeys, *serviceKey)
+	}
+	if err := p.decode(raw, serviceKey.Key, krbtgtKeys); err != nil {
+		return p, fmt.Errorf("PAC: %w", err)
+	}
+	return p, nil
+}
+
+// findPAC returns the AD-WIN2K-PAC inside the ticket's AD-IF-RELEVANT.
+func findPAC(ad types.AuthorizationData) ([]byte, bool) {
+	for _, entry := range ad {
+		if entry.ADType != adtype.ADIfRelevant {
+			continue
+		}
+		var inner types.AuthorizationData
+		if err := inner.Unmarshal(entry.ADData); err != nil {
+			continue
+		}
+		for _, e := range inner {
+			if e.ADType == adtype.ADWin2KPAC {
+				return e.ADData, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// decode reads the PAC buffers and checks both signatures.
+func (p *TicketPAC) decode(raw []byte, serviceKey types.EncryptionKey, krbtgtKeys []TicketKey) error {
+	var pt pac.PACType
+	if err := pt.Unmarshal(raw); err != nil {
+		return err
+	}
+	// The server signature covers the PAC with both signatures zeroed.
+	zeroed := append([]byte(nil), raw...)
+	var server, kdc *pac.SignatureData
+
+	for _, buf := range pt.Buffers {
+		p.Buffers = append(p.Buffers, buf.ULType)
+		end := buf.Offset + uint64(buf.CBBufferSize)
+		if end > uint64(len(raw)) {
+			return fmt.Errorf("buffer type %d runs past the PAC", buf.ULType)
+		}
+		b := raw[buf.Offset:end]
+
+		switch buf.ULType {
+		case PACLogonInfo:
+			var k pac.KerbValidationInfo
+			if err := k.Unmarshal(b); err != nil {
+				return err
+			}
+			p.logonInfo(&k)
+		case PACClientInfo:
+			var k pac.ClientInfo
+			if err := k.Unmarshal(b); err != nil {
+				return err
+			}
+			p.ClientInfoName = k.Name
+			p.ClientInfoTime = k.ClientID.Time().UTC()
+		case PACUPNDNSInfo:
+			var k pac.UPNDNSInfo
+			if err := k.Unmarshal(b); err != nil {
+				return err
+			}
+			p.UPN, p.DNSDomain = k.UPN, k.DNSDomain
+		case PACAttributesInfo:
+			// FlagsLength (bits) then the flags.
+			if len(b) >= 8 {
+				p.Attributes = binary.LittleEndian.Uint32(b[4:8])
+				p.HasAttributes = true
+			}
+		case PACRequestor:
+			sid, err := ingest.FormatSID(b)
+			if err != nil {
+				return fmt.Errorf("PAC_REQUESTOR: %w", err)
+			}
+			p.RequestorSID = sid
+		case PACServerChecksum, PACKDCChecksum:
+			var sig pac.SignatureData
+			if _, err := sig.Unmarshal(b); err != nil {
+				return err
+			}
+			for i := range sig.Signature {
+				zeroed[int(buf.Offset)+4+i] = 0
+			}
+			if buf.ULType == PACServerChecksum {
+				server = &sig
+			} else {
+				kdc = &sig
+			}
+		}
+	}
+
+	p.ServerSignature, p.KDCSignature = SignatureMissing, SignatureMissing
+	if server != nil {
+		p.ServerSignature = verifySignature(server, zeroed, []TicketKey{{Key: serviceKey}})
+	}
+	if kdc != nil && server != nil {
+		p.KDCSignature = verifySignature(kdc, server.Signature, krbtgtKeys)
+	}
+	return nil
+}
+
+func (p *TicketPAC) logonInfo(k *pac.KerbValidationInfo) {
+	p.EffectiveName = k.EffectiveName.Value
+	p.LogonDomain = k.LogonDomainName.Value
+	p.DomainSID = k.LogonDomainID.String()
+	p.UserRID = k.UserID
+	p.PrimaryGroupRID = k.PrimaryGroupID
+	p.UserAccountControl = k.UserAccountControl
+	p.LogonTime = k.LogOnTime.Time().UTC()
+	for _, g := range k.GroupIDs {
+		p.GroupRIDs = append(p.GroupRIDs, g.RelativeID)
+	}
+	for i := range k.ExtraSIDs {
+		p.ExtraSIDs = append(p.ExtraSIDs, k.ExtraSIDs[i].SID.String())
+	}
+	if len(k.ResourceGroupIDs) > 0 {
+		domain := k.ResourceGroupDomainSID.String()
+		for _, g := range k.ResourceGroupIDs {
+			p.ResourceGroupSIDs = append(p.ResourceGroupSIDs, fmt.Sprintf("%s-%d", domain, g.RelativeID))
+		}
+	}
+}
+
+// verifySignature checks sig over data with every key of the checksum's
+// etype: valid if one matches, unverified if none could be tried.
+func verifySignature(sig *pac.SignatureData, data []byte, keys []TicketKey) string {
+	et, err := crypto.GetChksumEtype(int32(sig.SignatureType))
+	if err != nil {
+		return SignatureUnverified
+	}
+	tried := false
+	for _, k := range keys {
+		if k.Key.KeyType != et.GetETypeID() {
+			continue
+		}
+		tried = true
+		if et.VerifyChecksum(k.Key.KeyValue, data, sig.Signature, keyusage.KERB_NON_KERB_CKSUM_SALT) {
+			return SignatureValid
+		}
+	}
+	if !tried {
+		return SignatureUnverified
+	}
+	return SignatureInvalid
+}
+
+// sidRID splits the RID off a domain SID.
+func sidRID(sid string) (string, uint32, bool) {
+	i := strings.LastIndexByte(sid, '-')
+	if i < 0 {
+		return "", 0, false
+	}
+	var rid uint32
+	if _, err := fmt.Sscanf(sid[i+1:], "%d", &rid); err != nil {
+		return "", 0, false
+	}
+	return sid[:i], rid, true
+}
+
+// ForeignExtraSIDs returns the extra SIDs from a domain other than the
+// logon domain, with their RIDs: the shape of SID-history injection.
+func (p *TicketPAC) ForeignExtraSIDs() map[string]uint32 {
+	out := make(map[string]uint32)
+	for _, sid := range p.ExtraSIDs {
+		if !strings.HasPrefix(sid, "S-1-5-21-") {
+			continue
+		}
+		domain, rid, ok := sidRID(sid)
+		if ok && !strings.EqualFold(domain, p.DomainSID) {
+			out[sid] = rid
+		}
+	}
+	return out
+}
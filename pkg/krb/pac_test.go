@@ -0,0 +1,257 @@
+package krb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/adtype"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/test/testdata"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// The logon info in gokrb5's test vectors: testuser1 (RID 1105) of TEST,
+// S-1-5-21-3167651404-3865080224-2280184895.
+const testUserSID = "S-1-5-21-3167651404-3865080224-2280184895-1105"
+
+func testKey(etype int32, fill byte) types.EncryptionKey {
+	return types.EncryptionKey{KeyType: etype, KeyValue: bytes.Repeat([]byte{fill}, keyLengths[etype])}
+}
+
+type pacSpec struct {
+	requestor  string // SID; empty leaves PAC_REQUESTOR out
+	attributes bool
+	serviceKey types.EncryptionKey
+	kdcKey     types.EncryptionKey
+	tamper     bool // change the logon info after signing
+}
+
+func sidBytes(t *testing.T, sid string) []byte {
+	t.Helper()
+	var parts []uint32
+	for _, p := range strings.Split(strings.TrimPrefix(sid, "S-1-5-"), "-") {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, uint32(v))
+	}
+	b := []byte{1, byte(len(parts)), 0, 0, 0, 0, 0, 5}
+	for _, v := range parts {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func checksumType(etype int32) uint32 {
+	if etype == etypeID.RC4_HMAC {
+		return chksumtype.KERB_CHECKSUM_HMAC_MD5_UNSIGNED
+	}
+	return uint32(chksumtype.HMAC_SHA1_96_AES256)
+}
+
+func signature(t *testing.T, key types.EncryptionKey, data []byte) []byte {
+	t.Helper()
+	et, err := crypto.GetEtype(key.KeyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := et.GetChecksumHash(key.KeyValue, data, keyusage.KERB_NON_KERB_CKSUM_SALT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// buildPAC lays out a PAC the way a KDC does and signs it.
+func buildPAC(t *testing.T, spec pacSpec) []byte {
+	t.Helper()
+	hexBuffer := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	sigLen := func(k types.EncryptionKey) int {
+		if k.KeyType == etypeID.RC4_HMAC {
+			return 16
+		}
+		return 12
+	}
+	sigBuffer := func(k types.EncryptionKey) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, checksumType(k.KeyType))
+		return append(b, make([]byte, sigLen(k))...)
+	}
+	type buffer struct {
+		typ  uint32
+		data []byte
+	}
+	buffers := []buffer{
+		{PACLogonInfo, hexBuffer(testdata.MarshaledPAC_Kerb_Validation_Info)},
+		{PACClientInfo, hexBuffer(testdata.MarshaledPAC_Client_Info)},
+		{PACUPNDNSInfo, hexBuffer(testdata.MarshaledPAC_UPN_DNS_Info)},
+	}
+	if spec.attributes {
+		buffers = append(buffers, buffer{PACAttributesInfo, []byte{2, 0, 0, 0, 1, 0, 0, 0}})
+	}
+	if spec.requestor != "" {
+		buffers = append(buffers, buffer{PACRequestor, sidBytes(t, spec.requestor)})
+	}
+	buffers = append(buffers,
+		buffer{PACServerChecksum, sigBuffer(spec.serviceKey)},
+		buffer{PACKDCChecksum, sigBuffer(spec.kdcKey)})
+
+	offset := 8 + 16*len(buffers)
+	var header, body bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, uint32(len(buffers)))
+	binary.Write(&header, binary.LittleEndian, uint32(0))
+	offsets := make([]int, len(buffers))
+	for i, b := range buffers {
+		offsets[i] = offset + body.Len()
+		binary.Write(&header, binary.LittleEndian, b.typ)
+		binary.Write(&header, binary.LittleEndian, uint32(len(b.data)))
+		binary.Write(&header, binary.LittleEndian, uint64(offsets[i]))
+		body.Write(b.data)
+		for body.Len()%8 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	raw := append(header.Bytes(), body.Bytes()...)
+
+	n := len(buffers)
+	serverAt, kdcAt := offsets[n-2]+4, offsets[n-1]+4
+	server := signature(t, spec.serviceKey, raw)
+	copy(raw[serverAt:], server)
+	copy(raw[kdcAt:], signature(t, spec.kdcKey, server))
+	if spec.tamper {
+		raw[offsets[0]+100] ^= 0xff
+	}
+	return raw
+}
+
+// pacTicket seals a PAC into a ticket for spn encrypted with key.
+func pacTicket(t *testing.T, spn string, pac []byte, key types.EncryptionKey, start, end time.Time) CachedTicket {
+	t.Helper()
+	ifRelevant, err := asn1.Marshal(types.AuthorizationData{{ADType: adtype.ADWin2KPAC, ADData: pac}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := messages.EncTicketPart{
+		Flags:             testFlags(),
+		Key:               testKey(etypeID.AES256_CTS_HMAC_SHA1_96, 9),
+		CRealm:            "TEST.GOKRB5",
+		CName:             types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "testuser1"),
+		AuthTime:          start,
+		StartTime:         start,
+		EndTime:           end,
+		RenewTill:         end,
+		AuthorizationData: types.AuthorizationData{{ADType: adtype.ADIfRelevant, ADData: ifRelevant}},
+	}
+	b, err := asn1.Marshal(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = asn1tools.AddASNAppTag(b, asnAppTag.EncTicketPart)
+	ed, err := crypto.GetEncryptedData(b, key, keyusage.KDC_REP_TICKET, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sname := types.NewPrincipalName(nametype.KRB_NT_SRV_INST, spn)
+	return CachedTicket{
+		ServerName:  spn,
+		ServerRealm: "TEST.GOKRB5",
+		Ticket:      messages.Ticket{TktVNO: 5, Realm: "TEST.GOKRB5", SName: sname, EncPart: ed},
+	}
+}
+
+func TestDecodeTicketPAC(t *testing.T) {
+	service := testKey(etypeID.AES256_CTS_HMAC_SHA1_96, 1)
+	krbtgt := testKey(etypeID.AES256_CTS_HMAC_SHA1_96, 2)
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	tkt := pacTicket(t, "HTTP/web.test.gokrb5",
+		buildPAC(t, pacSpec{requestor: testUserSID, attributes: true, serviceKey: service, kdcKey: krbtgt}),
+		service, start, start.Add(10*time.Hour))
+	keys := []TicketKey{{Principal: "HTTP/web.test.gokrb5@TEST.GOKRB5", Key: service}, {Principal: "krbtgt/TEST.GOKRB5", Key: krbtgt}}
+
+	p, err := DecodeTicketPAC(tkt, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.HasPAC || p.ClientName != "testuser1" || !p.EndTime.Equal(start.Add(10*time.Hour)) {
+		t.Fatalf("enc-part %+v", p)
+	}
+	if p.EffectiveName != "testuser1" || p.UserSID() != testUserSID || p.PrimaryGroupRID != 513 {
+		t.Errorf("logon info: %s %s %d", p.EffectiveName, p.UserSID(), p.PrimaryGroupRID)
+	}
+	if len(p.GroupRIDs) == 0 || p.ClientInfoName != "testuser1" || p.UPN != "testuser1@test.gokrb5" {
+		t.Errorf("groups %v client info %q upn %q", p.GroupRIDs, p.ClientInfoName, p.UPN)
+	}
+	if p.RequestorSID != testUserSID || !p.HasAttributes || p.Attributes != 1 {
+		t.Errorf("requestor %q attributes %v %d", p.RequestorSID, p.HasAttributes, p.Attributes)
+	}
+	if p.ServerSignature != SignatureValid || p.KDCSignature != SignatureValid {
+		t.Errorf("signatures server %s kdc %s", p.ServerSignature, p.KDCSignature)
+	}
+
+	// Without the krbtgt key the KDC signature cannot be judged.
+	p, err = DecodeTicketPAC(tkt, keys[:1])
+	if err != nil || p.KDCSignature != SignatureUnverified {
+		t.Errorf("KDC signature without krbtgt key: %v %v", p.KDCSignature, err)
+	}
+
+	// A silver ticket: signed by the service key, KDC signature faked.
+	silver := pacTicket(t, "HTTP/web.test.gokrb5",
+		buildPAC(t, pacSpec{serviceKey: service, kdcKey: testKey(etypeID.AES256_CTS_HMAC_SHA1_96, 3)}),
+		service, start, start.Add(10*time.Hour))
+	p, err = DecodeTicketPAC(silver, keys)
+	if err != nil || p.ServerSignature != SignatureValid || p.KDCSignature != SignatureInvalid {
+		t.Errorf("silver: server %s kdc %s (%v)", p.ServerSignature, p.KDCSignature, err)
+	}
+	if p.RequestorSID != "" || p.HasAttributes {
+		t.Errorf("silver: requestor %q attributes %v", p.RequestorSID, p.HasAttributes)
+	}
+
+	// An RC4 TGT is checked with its own key for both signatures.
+	rc4 := testKey(etypeID.RC4_HMAC, 4)
+	tgt := pacTicket(t, "krbtgt/TEST.GOKRB5", buildPAC(t, pacSpec{serviceKey: rc4, kdcKey: rc4, tamper: true}),
+		rc4, start, start.AddDate(10, 0, 0))
+	p, err = DecodeTicketPAC(tgt, []TicketKey{{Key: rc4}})
+	if err != nil || p.ServerSignature != SignatureInvalid || p.KDCSignature != SignatureValid {
+		t.Errorf("tampered TGT: server %s kdc %s (%v)", p.ServerSignature, p.KDCSignature, err)
+	}
+
+	if _, err := DecodeTicketPAC(tkt, []TicketKey{{Key: krbtgt}}); err != ErrNoTicketKey {
+		t.Errorf("wrong key: %v", err)
+	}
+}
+
+func TestParseTicketKey(t *testing.T) {
+	k, err := ParseTicketKey("krbtgt:aes256:" + strings.Repeat("ab", 32))
+	if err != nil || !k.IsKrbtgt() || k.Key.KeyType != etypeID.AES256_CTS_HMAC_SHA1_96 || len(k.Key.KeyValue) != 32 {
+		t.Fatalf("%+v %v", k, err)
+	}
+	k, err = ParseTicketKey("rc4:31d6cfe0d16ae931b73c59d7e0c089c0")
+	if err != nil || k.IsKrbtgt() || k.Key.KeyType != etypeID.RC4_HMAC {
+		t.Fatalf("%+v %v", k, err)
+	}
+	for _, bad := range []string{"aes256:abcd", "des:0011223344556677", "rc4:zz", strings.Repeat("ab", 16)} {
+		if _, err := ParseTicketKey(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
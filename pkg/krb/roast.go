@@ -0,0 +1,129 @@
+package krb
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/messages"
+)
+
+// Hash types, as in HashResult.HashType.
+const (
+	HashTypeASREP      = "asrep"
+	HashTypeKerberoast = "kerberoast"
+)
+
+// KerberoastRequestEtypes is the etype list a Kerberoast TGS-REQ offers. RC4
+// comes first so an account that still allows it returns the hash that
+// cracks at NT-hash speed.
+var KerberoastRequestEtypes = []int32{
+	etypeID.RC4_HMAC,
+	etypeID.AES256_CTS_HMAC_SHA1_96,
+	etypeID.AES128_CTS_HMAC_SHA1_96,
+}
+
+// ASREPRequestEtypes is the etype list an AS-REP roasting AS-REQ offers.
+var ASREPRequestEtypes = []int32{
+	etypeID.AES256_CTS_HMAC_SHA1_96,
+	etypeID.AES128_CTS_HMAC_SHA1_96,
+	etypeID.RC4_HMAC,
+}
+
+// HashRecord is one roasting attempt: the SPN asked for (empty for AS-REP),
+// what was requested and returned, and either the hash or the KDC's error.
+type HashRecord struct {
+	SPN             string  `json:"spn,omitempty"`
+	RequestedEtypes []int32 `json:"requested_etypes,omitempty"`
+	Etype           int32   `json:"etype,omitempty"`
+	KVNO            int     `json:"kvno,omitempty"`
+	Realm           string  `json:"realm,omitempty"` // server realm of the returned ticket
+	HashcatMode     int     `json:"hashcat_mode,omitempty"`
+	Hash            string  `json:"hash,omitempty"`
+	ErrorCode       int32   `json:"error_code,omitempty"` // KRB-ERROR code, 0 for non-Kerberos failures
+	ErrorName       string  `json:"error_name,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// OK reports whether the attempt produced a hash.
+func (r HashRecord) OK() bool {
+	return r.Hash != ""
+}
+
+// FailedHashRecord records a roasting attempt that returned no hash.
+func FailedHashRecord(spn string, requested []int32, err error) HashRecord {
+	r := HashRecord{SPN: spn, RequestedEtypes: requested, Error: err.Error()}
+	if code, ok := krbErrorCode(err); ok {
+		r.ErrorCode = code
+		r.ErrorName = krbErrorName(code)
+	}
+	return r
+}
+
+// krbErrorPattern matches the code in messages.KRBError.Error(), which is
+// all that survives gokrb5 wrapping the KRB-ERROR in a krberror.Krberror.
+var krbErrorPattern = regexp.MustCompile(`KRB Error: \((\d+)\)`)
+
+func krbErrorCode(err error) (int32, bool) {
+	var krbErr messages.KRBError
+	if errors.As(err, &krbErr) {
+		return krbErr.ErrorCode, true
+	}
+	if m := krbErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return int32(code), true
+	}
+	return 0, false
+}
+
+// krbErrorName is the RFC 4120 name of a KRB-ERROR code, e.g.
+// KDC_ERR_S_PRINCIPAL_UNKNOWN.
+func krbErrorName(code int32) string {
+	// Lookup returns "(7) KDC_ERR_S_PRINCIPAL_UNKNOWN Server not found ..."
+	fields := strings.Fields(errorcode.Lookup(code))
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// HashcatMode is the hashcat mode that cracks a hash of hashType
+// ("asrep" or "kerberoast") encrypted with etype; 0 when hashcat has none.
+func HashcatMode(hashType string, etype int32) int {
+	modes := map[string]map[int32]int{
+		HashTypeKerberoast: {
+			etypeID.RC4_HMAC:                13100,
+			etypeID.AES128_CTS_HMAC_SHA1_96: 19600,
+			etypeID.AES256_CTS_HMAC_SHA1_96: 19700,
+		},
+		HashTypeASREP: {
+			etypeID.RC4_HMAC:                18200,
+			etypeID.AES128_CTS_HMAC_SHA1_96: 32100,
+			etypeID.AES256_CTS_HMAC_SHA1_96: 32200,
+		},
+	}
+	return modes[hashType][etype]
+}
+
+// HashcatModeOf reads the hash type and etype from a $krb5tgs$ or
+// $krb5asrep$ line and returns its hashcat mode; $sntp-ms$ is 31300.
+func HashcatModeOf(hash string) int {
+	if strings.HasPrefix(hash, "$sntp-ms$") {
+		return 31300
+	}
+	for prefix, hashType := range map[string]string{"$krb5tgs$": HashTypeKerberoast, "$krb5asrep$": HashTypeASREP} {
+		if !strings.HasPrefix(hash, prefix) {
+			continue
+		}
+		rest := hash[len(prefix):]
+		if i := strings.IndexByte(rest, '$'); i > 0 {
+			if etype, err := strconv.Atoi(rest[:i]); err == nil {
+				return HashcatMode(hashType, int32(etype))
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package krb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+func TestHashcatModes(t *testing.T) {
+	cases := []struct {
+		hash string
+		mode int
+	}{
+		{"$krb5tgs$23$*svc$CORP.LOCAL$HTTP/web*$00$11", 13100},
+		{"$krb5tgs$17$svc$CORP.LOCAL$*HTTP/web*$00$11", 19600},
+		{"$krb5tgs$18$svc$CORP.LOCAL$*HTTP/web*$00$11", 19700},
+		{"$krb5asrep$23$bob@CORP.LOCAL:00$11", 18200},
+		{"$krb5asrep$18$bob$CORP.LOCAL$00$11", 32200},
+		{"$krb5tgs$3$*svc$CORP.LOCAL$HTTP/web*$00$11", 0},
+		{"not a hash", 0},
+	}
+	for _, c := range cases {
+		if got := HashcatModeOf(c.hash); got != c.mode {
+			t.Errorf("HashcatModeOf(%q) = %d, want %d", c.hash, got, c.mode)
+		}
+	}
+}
+
+func TestFormatKerberoastHashSplitsChecksum(t *testing.T) {
+	cipher := make([]byte, 40)
+	for i := range cipher {
+		cipher[i] = byte(i)
+	}
+	tkt := messages.Ticket{EncPart: types.EncryptedData{EType: 23, Cipher: cipher}}
+	rc4 := formatKerberoastHashForHashcat("svc", "CORP.LOCAL", "HTTP/web", tkt)
+	if want := fmt.Sprintf("$krb5tgs$23$*svc$CORP.LOCAL$HTTP/web*$%x$%x", cipher[:16], cipher[16:]); rc4 != want {
+		t.Fatalf("RC4 hash\n got %s\nwant %s", rc4, want)
+	}
+
+	tkt.EncPart.EType = 18
+	aes := formatKerberoastHashForHashcat("svc", "CORP.LOCAL", "HTTP/web", tkt)
+	if want := fmt.Sprintf("$krb5tgs$18$svc$CORP.LOCAL$*HTTP/web*$%x$%x", cipher[28:], cipher[:28]); aes != want {
+		t.Fatalf("AES hash\n got %s\nwant %s", aes, want)
+	}
+}
+
+func TestFailedHashRecordKeepsKDCError(t *testing.T) {
+	krbErr := messages.KRBError{ErrorCode: errorcode.KDC_ERR_S_PRINCIPAL_UNKNOWN}
+
+	// gokrb5 flattens the KRB-ERROR to text; the direct exchange keeps it.
+	for _, err := range []error{
+		errors.New("GetServiceTicket failed: [Root cause: KDC_Error] KDC_Error: TGS Exchange Error: kerberos error response from KDC: " + krbErr.Error()),
+		fmt.Errorf("service ticket failed: %w", krbErr),
+	} {
+		r := FailedHashRecord("HTTP/gone", KerberoastRequestEtypes, err)
+		if r.OK() || r.ErrorCode != 7 || r.ErrorName != "KDC_ERR_S_PRINCIPAL_UNKNOWN" {
+			t.Errorf("record %+v from %v", r, err)
+		}
+	}
+	if r := FailedHashRecord("HTTP/web", KerberoastRequestEtypes, errors.New("failed to connect to KDC")); r.ErrorCode != 0 || !strings.Contains(r.Error, "connect") {
+		t.Errorf("network failure recorded as %+v", r)
+	}
+}
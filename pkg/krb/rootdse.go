@@ -0,0 +1,166 @@
+package krb
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ClockSkewTolerance is the default Kerberos policy "Maximum tolerance for
+// computer clock synchronization". A KDC rejects pre-authentication and
+// authenticators further off than this with KRB_AP_ERR_SKEW.
+const ClockSkewTolerance = 5 * time.Minute
+
+// RootDSE is the server's RootDSE as read at connection time.
+type RootDSE struct {
+	DNSHostName                   string    `json:"dns_host_name,omitempty"`
+	ServerName                    string    `json:"server_name,omitempty"`
+	DSServiceName                 string    `json:"ds_service_name,omitempty"`
+	LDAPServiceName               string    `json:"ldap_service_name,omitempty"`
+	DefaultNamingContext          string    `json:"default_naming_context,omitempty"`
+	ConfigurationNamingContext    string    `json:"configuration_naming_context,omitempty"`
+	SchemaNamingContext           string    `json:"schema_naming_context,omitempty"`
+	RootDomainNamingContext       string    `json:"root_domain_naming_context,omitempty"`
+	NamingContexts                []string  `json:"naming_contexts,omitempty"`
+	SupportedLDAPVersions         []string  `json:"supported_ldap_versions,omitempty"`
+	SupportedControls             []string  `json:"supported_controls,omitempty"`
+	SupportedCapabilities         []string  `json:"supported_capabilities,omitempty"`
+	SupportedExtensions           []string  `json:"supported_extensions,omitempty"`
+	SupportedSASLMechanisms       []string  `json:"supported_sasl_mechanisms,omitempty"`
+	SupportedLDAPPolicies         []string  `json:"supported_ldap_policies,omitempty"`
+	DomainFunctionality           string    `json:"domain_functionality,omitempty"`
+	ForestFunctionality           string    `json:"forest_functionality,omitempty"`
+	DomainControllerFunctionality string    `json:"dc_functionality,omitempty"`
+	IsGlobalCatalogReady          bool      `json:"is_global_catalog_ready"`
+	IsSynchronized                bool      `json:"is_synchronized"`
+	HighestCommittedUSN           int64     `json:"highest_committed_usn,omitempty"`
+	CurrentTime                   time.Time `json:"current_time,omitempty"`
+	// ClockSkew is the DC's currentTime minus the local time at the read;
+	// positive when the DC is ahead.
+	ClockSkew        time.Duration `json:"-"`
+	ClockSkewSeconds float64       `json:"clock_skew_seconds"`
+	// Attributes holds every attribute the server returned.
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// FunctionalLevelName maps an msDS-Behavior-Version value to the Windows
+// Server release that introduced it.
+func FunctionalLevelName(level string) string {
+	names := map[string]string{
+		"0": "2000", "1": "2003 Mixed", "2": "2003", "3": "2008",
+		"4": "2008 R2", "5": "2012", "6": "2012 R2", "7": "2016", "10": "2025",
+	}
+	if name, ok := names[level]; ok {
+		return name
+	}
+	return level
+}
+
+// SkewExceeded reports whether the DC clock is further off than
+// ClockSkewTolerance.
+func (r *RootDSE) SkewExceeded() bool {
+	return r != nil && !r.CurrentTime.IsZero() &&
+		(r.ClockSkew > ClockSkewTolerance || r.ClockSkew < -ClockSkewTolerance)
+}
+
+// ReadRootDSE reads every RootDSE attribute and measures the clock skew
+// against the local time.
+func (c *LDAPClient) ReadRootDSE() (*RootDSE, error) {
+	sent := time.Now()
+	entry, err := c.RootDSE("*", "currentTime", "supportedControl", "supportedSASLMechanisms",
+		"supportedLDAPPolicies", "isGlobalCatalogReady", "domainControllerFunctionality")
+	if err != nil {
+		return nil, err
+	}
+	// currentTime was taken somewhere between send and receive.
+	local := sent.Add(time.Since(sent) / 2)
+	r := parseRootDSE(entry, local)
+	c.rootDSE = r
+	return r, nil
+}
+
+func parseRootDSE(entry *ldap.Entry, local time.Time) *RootDSE {
+	r := &RootDSE{Attributes: make(map[string][]string, len(entry.Attributes))}
+	for _, attr := range entry.Attributes {
+		r.Attributes[attr.Name] = attr.Values
+	}
+	get := entry.GetAttributeValue
+	r.DNSHostName = get("dnsHostName")
+	r.ServerName = get("serverName")
+	r.DSServiceName = get("dsServiceName")
+	r.LDAPServiceName = get("ldapServiceName")
+	r.DefaultNamingContext = get("defaultNamingContext")
+	r.ConfigurationNamingContext = get("configurationNamingContext")
+	r.SchemaNamingContext = get("schemaNamingContext")
+	r.RootDomainNamingContext = get("rootDomainNamingContext")
+	r.NamingContexts = entry.GetAttributeValues("namingContexts")
+	r.SupportedLDAPVersions = entry.GetAttributeValues("supportedLDAPVersion")
+	r.SupportedControls = entry.GetAttributeValues("supportedControl")
+	r.SupportedCapabilities = entry.GetAttributeValues("supportedCapabilities")
+	r.SupportedExtensions = entry.GetAttributeValues("supportedExtension")
+	r.SupportedSASLMechanisms = entry.GetAttributeValues("supportedSASLMechanisms")
+	r.SupportedLDAPPolicies = entry.GetAttributeValues("supportedLDAPPolicies")
+	r.DomainFunctionality = FunctionalLevelName(get("domainFunctionality"))
+	r.ForestFunctionality = FunctionalLevelName(get("forestFunctionality"))
+	r.DomainControllerFunctionality = FunctionalLevelName(get("domainControllerFunctionality"))
+	r.IsGlobalCatalogReady = strings.EqualFold(get("isGlobalCatalogReady"), "TRUE")
+	r.IsSynchronized = strings.EqualFold(get("isSynchronized"), "TRUE")
+	r.HighestCommittedUSN, _ = strconv.ParseInt(get("highestCommittedUSN"), 10, 64)
+	if t, err := parseGeneralizedTime(get("currentTime")); err == nil {
+		r.CurrentTime = t
+		r.ClockSkew = t.Sub(local).Round(time.Second)
+		r.ClockSkewSeconds = r.ClockSkew.Seconds()
+	}
+	return r
+}
+
+// parseGeneralizedTime parses the AD GeneralizedTime form, e.g.
+// 20240117212000.0Z.
+func parseGeneralizedTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+	for _, layout := range []string{"20060102150405.0Z", "20060102150405Z", "20060102150405.0Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised GeneralizedTime %q", s)
+}
+
+// SyncClock re-reads the RootDSE currentTime and, when the DC is further off
+// than ClockSkewTolerance, makes later Kerberos requests from this client use
+// the DC's time instead of the local clock. It returns the fresh RootDSE,
+// whose ClockSkew is the measured skew.
+func (c *LDAPClient) SyncClock() (*RootDSE, error) {
+	c.clockSynced = true
+	r, err := c.ReadRootDSE()
+	if err != nil {
+		return nil, fmt.Errorf("read RootDSE currentTime: %v", err)
+	}
+	if r.CurrentTime.IsZero() {
+		return r, fmt.Errorf("RootDSE returned no currentTime")
+	}
+	c.clockOffset = 0
+	if r.SkewExceeded() {
+		c.clockOffset = r.ClockSkew
+		log.Printf("[!] DC clock is %s off the local clock (tolerance %s); Kerberos requests will use the DC time",
+			r.ClockSkew, ClockSkewTolerance)
+	}
+	return r, nil
+}
+
+// ClockOffset is what Kerberos requests add to the local clock; zero unless
+// SyncClock found the skew above tolerance. The clock is synced on first use.
+func (c *LDAPClient) ClockOffset() time.Duration {
+	if !c.clockSynced {
+		if _, err := c.SyncClock(); err != nil {
+			log.Printf("[!] Clock skew check skipped: %v", err)
+		}
+	}
+	return c.clockOffset
+}
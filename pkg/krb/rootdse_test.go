@@ -0,0 +1,46 @@
+package krb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestParseRootDSE(t *testing.T) {
+	entry := ldap.NewEntry("", map[string][]string{
+		"currentTime":                   {"20240117212000.0Z"},
+		"dnsHostName":                   {"dc01.corp.local"},
+		"supportedControl":              {"1.2.840.113556.1.4.319", "1.2.840.113556.1.4.417"},
+		"supportedSASLMechanisms":       {"GSSAPI", "GSS-SPNEGO", "EXTERNAL", "DIGEST-MD5"},
+		"supportedLDAPPolicies":         {"MaxPageSize", "MaxValRange"},
+		"domainFunctionality":           {"7"},
+		"forestFunctionality":           {"6"},
+		"domainControllerFunctionality": {"10"},
+		"isGlobalCatalogReady":          {"TRUE"},
+		"highestCommittedUSN":           {"123456"},
+	})
+	local := time.Date(2024, 1, 17, 21, 10, 0, 0, time.UTC)
+	r := parseRootDSE(entry, local)
+
+	if r.DNSHostName != "dc01.corp.local" || !r.IsGlobalCatalogReady || r.HighestCommittedUSN != 123456 {
+		t.Errorf("unexpected RootDSE: %+v", r)
+	}
+	if len(r.SupportedControls) != 2 || len(r.SupportedSASLMechanisms) != 4 || len(r.SupportedLDAPPolicies) != 2 {
+		t.Errorf("multi-valued attributes not kept: %+v", r)
+	}
+	if r.DomainFunctionality != "2016" || r.ForestFunctionality != "2012 R2" || r.DomainControllerFunctionality != "2025" {
+		t.Errorf("functional levels = %s/%s/%s", r.DomainFunctionality, r.ForestFunctionality, r.DomainControllerFunctionality)
+	}
+	if r.ClockSkew != 10*time.Minute || r.ClockSkewSeconds != 600 || !r.SkewExceeded() {
+		t.Errorf("clock skew = %s (exceeded %v)", r.ClockSkew, r.SkewExceeded())
+	}
+	if len(r.Attributes["supportedControl"]) != 2 {
+		t.Errorf("raw attributes not captured")
+	}
+
+	r = parseRootDSE(entry, local.Add(8*time.Minute))
+	if r.ClockSkew != 2*time.Minute || r.SkewExceeded() {
+		t.Errorf("2m skew should be within tolerance, got %s", r.ClockSkew)
+	}
+}
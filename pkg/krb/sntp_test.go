@@ -0,0 +1,75 @@
+package krb
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+)
+
+func TestTimeroastComputers(t *testing.T) {
+	responder, err := NewSNTPResponder("127.0.0.1:0", map[uint32]string{
+		1103: "ws01",                     // pre-created
+		1104: "q7#Lr9!vX2mZ0$kP4@tW8&nB", // machine-generated
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+
+	computers := []ingest.Computer{
+		{SamAccountName: "WS01$", ObjectSID: "S-1-5-21-1-2-3-1103"},
+		{SamAccountName: "SRV01$", ObjectSID: "S-1-5-21-1-2-3-1104"},
+		{SamAccountName: "GONE$", ObjectSID: "S-1-5-21-1-2-3-1999"}, // no answer
+		{SamAccountName: "NOSID$"},
+	}
+	hashes, err := TimeroastComputers(responder.Addr(), computers, TimeroastOptions{Interval: time.Millisecond, Timeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("got %d hashes, want 2: %+v", len(hashes), hashes)
+	}
+
+	ws, srv := hashes[0], hashes[1]
+	if ws.RID != 1103 || ws.Account != "WS01$" || !ws.PreCreated || ws.Password != "ws01" {
+		t.Errorf("pre-created account: %+v", ws)
+	}
+	if srv.RID != 1104 || srv.Account != "SRV01$" || srv.PreCreated || srv.Password != "" {
+		t.Errorf("machine password account: %+v", srv)
+	}
+	parts := strings.Split(srv.Hash, "$")
+	if HashcatModeOf(srv.Hash) != 31300 || len(parts) != 4 || len(parts[2]) != 32 || len(parts[3]) != 96 {
+		t.Errorf("hash %s", srv.Hash)
+	}
+	if !CheckTimeroastPassword(srv.Hash, "q7#Lr9!vX2mZ0$kP4@tW8&nB") || CheckTimeroastPassword(srv.Hash, "srv01") {
+		t.Errorf("password check on %s", srv.Hash)
+	}
+}
+
+func TestParseSNTPResponse(t *testing.T) {
+	req := sntpRequest(1105, true, time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	if len(req) != 68 || req[0] != 0xdb || hex.EncodeToString(req[48:52]) != "51040080" {
+		t.Fatalf("request %x", req)
+	}
+	// A request, or a response without a MAC, is not a hash.
+	if _, err := ParseSNTPResponse(req); err == nil {
+		t.Error("client request parsed as a response")
+	}
+	unsigned := append([]byte(nil), req...)
+	unsigned[0] = 0x1c
+	if _, err := ParseSNTPResponse(unsigned); err == nil {
+		t.Error("unsigned response parsed")
+	}
+	signed := append([]byte(nil), unsigned...)
+	signed[60] = 1
+	h, err := ParseSNTPResponse(signed)
+	if err != nil || h.RID != 1105 || !h.OldPassword {
+		t.Errorf("%+v %v", h, err)
+	}
+	if PreCreatedComputerPassword("VERYLONGCOMPUTERNAME$") != "verylongcomput" {
+		t.Error("pre-created password not cut to 14 characters")
+	}
+}
@@ -0,0 +1,209 @@
+package krb
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// SearchProgress reports how far a streamed search has got.
+type SearchProgress struct {
+	Pages   int
+	Entries int
+	// Ranged counts attributes that needed extra range-retrieval queries.
+	Ranged int
+}
+
+// StreamRequest describes a paged whole-subtree search for Stream.
+type StreamRequest struct {
+	BaseDN     string // defaults to the client's base DN
+	Filter     string
+	Attributes []string
+	PageSize   uint32 // defaults to 500
+	Controls   []ldap.Control
+	// Progress, when set, is called after each page has been handed out.
+	Progress func(SearchProgress)
+}
+
+// searcher is the part of *ldap.Conn that Stream needs.
+type searcher interface {
+	Search(*ldap.SearchRequest) (*ldap.SearchResult, error)
+}
+
+// search runs req on c.searcher, or on the connection when none is set.
+func (c *LDAPClient) search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.searcher != nil {
+		return c.searcher.Search(req)
+	}
+	return c.conn.Search(req)
+}
+
+// Stream runs a paged search and hands each entry to fn as its page arrives,
+// so only one page is held in memory. Multi-valued attributes the DC returns
+// in ranges (member;range=0-1499) are completed before fn sees the entry and
+// appear under their plain name. An error from fn abandons the search and is
+// returned as is. When the server refuses the first paged request, the
+// search is retried once without paging.
+func (c *LDAPClient) Stream(req StreamRequest, fn func(*ldap.Entry) error) error {
+	if c.conn == nil && c.searcher == nil {
+		return fmt.Errorf("ldap: no connection")
+	}
+	baseDN := req.BaseDN
+	if baseDN == "" {
+		baseDN = c.baseDN
+	}
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = 500
+	}
+
+	paging := ldap.NewControlPaging(pageSize)
+	search := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		c.scopeFilter(req.Filter),
+		req.Attributes,
+		append(append([]ldap.Control(nil), req.Controls...), paging),
+	)
+
+	var progress SearchProgress
+	for {
+		sr, err := c.search(search)
+		if err != nil && progress.Pages == 0 && !needsBind(err) {
+			// Some DCs and LDAP proxies reject the paging control outright.
+			log.Printf("[!] Paged search failed (%v), falling back to simple search...", err)
+			search.Controls = req.Controls
+			paging = nil
+			sr, err = c.search(search)
+		}
+		if err != nil {
+			return err
+		}
+		progress.Pages++
+		for _, entry := range sr.Entries {
+			ranged, err := c.completeRanges(entry)
+			if err != nil {
+				return fmt.Errorf("range retrieval for %s: %v", entry.DN, err)
+			}
+			progress.Ranged += ranged
+			if err := fn(entry); err != nil {
+				if paging != nil {
+					c.abandonPaging(search, paging)
+				}
+				return err
+			}
+			progress.Entries++
+		}
+		if req.Progress != nil {
+			req.Progress(progress)
+		}
+
+		// A DC that ignores the (non-critical) paging control returns
+		// everything in one response without a cookie.
+		resp, ok := ldap.FindControl(sr.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if paging == nil || !ok || len(resp.Cookie) == 0 {
+			return nil
+		}
+		paging.SetCookie(resp.Cookie)
+	}
+}
+
+// abandonPaging tells the DC to release the paged result set when the caller
+// stops early.
+func (c *LDAPClient) abandonPaging(search *ldap.SearchRequest, paging *ldap.ControlPaging) {
+	if len(paging.Cookie) == 0 {
+		return
+	}
+	paging.PagingSize = 0
+	_, _ = c.search(search)
+}
+
+// completeRanges replaces each attr;range=lo-hi attribute on entry with the
+// full value set under attr, issuing base searches for the remaining ranges.
+// It returns how many attributes were ranged.
+func (c *LDAPClient) completeRanges(entry *ldap.Entry) (int, error) {
+	ranged := 0
+	for _, attr := range entry.Attributes {
+		name, _, hi, ok := parseRange(attr.Name)
+		if !ok {
+			continue
+		}
+		ranged++
+		attr.Name = name
+		for hi >= 0 {
+			next, err := c.fetchRange(entry.DN, name, hi+1)
+			if err != nil {
+				return ranged, err
+			}
+			if next == nil {
+				break
+			}
+			attr.Values = append(attr.Values, next.Values...)
+			attr.ByteValues = append(attr.ByteValues, next.ByteValues...)
+			prev := hi
+			if _, _, hi, _ = parseRange(next.Name); hi >= 0 && hi <= prev {
+				return ranged, fmt.Errorf("%s range did not advance past %d", name, prev)
+			}
+		}
+	}
+	return ranged, nil
+}
+
+// fetchRange reads name;range=from-* from one object. The DC caps the
+// answer at MaxValRange and says where it stopped in the returned name.
+func (c *LDAPClient) fetchRange(dn, name string, from int) (*ldap.EntryAttribute, error) {
+	sr, err := c.search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{fmt.Sprintf("%s;range=%d-*", name, from)},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Entries) == 0 {
+		return nil, nil
+	}
+	for _, attr := range sr.Entries[0].Attributes {
+		if n, _, _, ok := parseRange(attr.Name); ok && strings.EqualFold(n, name) {
+			return attr, nil
+		}
+	}
+	return nil, nil
+}
+
+// needsBind reports whether err is the DC refusing an anonymous search
+// (000004DC), which a search without paging would hit just the same.
+func needsBind(err error) bool {
+	return strings.Contains(err.Error(), "000004DC")
+}
+
+// parseRange splits "member;range=0-1499" into its attribute name and
+// bounds. hi is -1 for the final range ("1500-*").
+func parseRange(attrName string) (name string, lo, hi int, ok bool) {
+	i := strings.Index(strings.ToLower(attrName), ";range=")
+	if i < 0 {
+		return "", 0, 0, false
+	}
+	bounds := strings.SplitN(attrName[i+len(";range="):], "-", 2)
+	if len(bounds) != 2 {
+		return "", 0, 0, false
+	}
+	lo, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	if bounds[1] == "*" {
+		return attrName[:i], lo, -1, true
+	}
+	hi, err = strconv.Atoi(bounds[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return attrName[:i], lo, hi, true
+}
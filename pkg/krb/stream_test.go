@@ -0,0 +1,146 @@
+package krb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		in     string
+		name   string
+		lo, hi int
+		ok     bool
+	}{
+		{"member;range=0-1499", "member", 0, 1499, true},
+		{"member;Range=1500-*", "member", 1500, -1, true},
+		{"msDS-RevealedUsers;range=3000-4499", "msDS-RevealedUsers", 3000, 4499, true},
+		{"member", "", 0, 0, false},
+		{"member;range=x-1", "", 0, 0, false},
+	}
+	for _, tc := range cases {
+		name, lo, hi, ok := parseRange(tc.in)
+		if ok != tc.ok || name != tc.name || (ok && (lo != tc.lo || hi != tc.hi)) {
+			t.Errorf("parseRange(%q) = %q, %d, %d, %v", tc.in, name, lo, hi, ok)
+		}
+	}
+}
+
+// fakeDirectory answers Stream's searches: pages of entries behind the
+// paging cookie, and base searches for member;range=N-* capped at maxRange
+// values the way a DC applies MaxValRange.
+type fakeDirectory struct {
+	pages        [][]*ldap.Entry
+	members      []string // every value of CN=big's member attribute
+	maxRange     int
+	rejectPaging bool
+	rangeQueries []string
+}
+
+func (f *fakeDirectory) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if req.Scope == ldap.ScopeBaseObject {
+		f.rangeQueries = append(f.rangeQueries, req.Attributes[0])
+		_, from, _, _ := parseRange(req.Attributes[0])
+		return &ldap.SearchResult{Entries: []*ldap.Entry{f.memberRange(from)}}, nil
+	}
+	paging, _ := ldap.FindControl(req.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	if paging == nil {
+		var all []*ldap.Entry
+		for _, p := range f.pages {
+			all = append(all, p...)
+		}
+		return &ldap.SearchResult{Entries: all}, nil
+	}
+	if f.rejectPaging {
+		return nil, ldap.NewError(ldap.LDAPResultUnavailableCriticalExtension, errors.New("paged results not supported"))
+	}
+	page := 0
+	if len(paging.Cookie) > 0 {
+		page = int(paging.Cookie[0])
+	}
+	resp := ldap.NewControlPaging(paging.PagingSize)
+	if page+1 < len(f.pages) {
+		resp.SetCookie([]byte{byte(page + 1)})
+	}
+	return &ldap.SearchResult{Entries: f.pages[page], Controls: []ldap.Control{resp}}, nil
+}
+
+// memberRange is CN=big with the member values from index from on.
+func (f *fakeDirectory) memberRange(from int) *ldap.Entry {
+	to := min(from+f.maxRange, len(f.members))
+	name := fmt.Sprintf("member;range=%d-%d", from, to-1)
+	if to == len(f.members) {
+		name = fmt.Sprintf("member;range=%d-*", from)
+	}
+	return ldap.NewEntry("CN=big,DC=corp,DC=local", map[string][]string{name: f.members[from:to]})
+}
+
+func newFakeDirectory() *fakeDirectory {
+	f := &fakeDirectory{maxRange: 2}
+	for i := 0; i < 5; i++ {
+		f.members = append(f.members, fmt.Sprintf("CN=user%d,DC=corp,DC=local", i))
+	}
+	entry := func(cn string) *ldap.Entry {
+		return ldap.NewEntry("CN="+cn+",DC=corp,DC=local", map[string][]string{"cn": {cn}})
+	}
+	f.pages = [][]*ldap.Entry{
+		{entry("a"), f.memberRange(0)},
+		{entry("b")},
+		{entry("c")},
+	}
+	return f
+}
+
+func TestStreamFollowsPagesAndRanges(t *testing.T) {
+	f := newFakeDirectory()
+	c := &LDAPClient{baseDN: "DC=corp,DC=local", searcher: f}
+
+	var seen []string
+	var last SearchProgress
+	err := c.Stream(StreamRequest{Filter: "(objectClass=*)", Progress: func(p SearchProgress) { last = p }}, func(e *ldap.Entry) error {
+		seen = append(seen, e.DN)
+		if e.DN == "CN=big,DC=corp,DC=local" {
+			if got := e.GetAttributeValues("member"); !slices.Equal(got, f.members) {
+				t.Errorf("member = %v, want all %d values", got, len(f.members))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 4 || len(slices.Compact(slices.Sorted(slices.Values(seen)))) != 4 {
+		t.Errorf("entries %v, want each of the 4 once", seen)
+	}
+	if want := []string{"member;range=2-*", "member;range=4-*"}; !slices.Equal(f.rangeQueries, want) {
+		t.Errorf("range queries %v, want %v", f.rangeQueries, want)
+	}
+	if last.Pages != 3 || last.Entries != 4 || last.Ranged != 1 {
+		t.Errorf("progress %+v", last)
+	}
+
+	// A callback error stops the search.
+	stop := errors.New("stop")
+	calls := 0
+	if err := c.Stream(StreamRequest{Filter: "(objectClass=*)"}, func(*ldap.Entry) error { calls++; return stop }); err != stop || calls != 1 {
+		t.Errorf("callback error: %v after %d calls", err, calls)
+	}
+}
+
+func TestStreamFallsBackWithoutPaging(t *testing.T) {
+	f := newFakeDirectory()
+	f.rejectPaging = true
+	c := &LDAPClient{baseDN: "DC=corp,DC=local", searcher: f}
+
+	n := 0
+	if err := c.Stream(StreamRequest{Filter: "(objectClass=*)"}, func(*ldap.Entry) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("%d entries without paging, want 4", n)
+	}
+}
@@ -0,0 +1,203 @@
+package krb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Ticket file formats.
+const (
+	TicketFormatKirbi  = "kirbi"  // KRB-CRED, as written by mimikatz and Rubeus
+	TicketFormatCCache = "ccache" // MIT credential cache, v3 or v4
+)
+
+// CachedTicket is one ticket read from a .kirbi or ccache file, with the
+// metadata the file stores next to it in the clear.
+type CachedTicket struct {
+	ClientName  string
+	ClientRealm string
+	ServerName  string // e.g. krbtgt/CORP.LOCAL or MSSQLSvc/sql01.corp.local:1433
+	ServerRealm string
+	Flags       []string
+	AuthTime    time.Time
+	StartTime   time.Time
+	EndTime     time.Time
+	RenewTill   time.Time
+	// SessionKey is the ticket's session key, which both formats carry.
+	SessionKey types.EncryptionKey
+	// Ticket is the ticket itself; Ticket.EncPart is encrypted with the
+	// server's long-term key.
+	Ticket messages.Ticket
+}
+
+// Etype is the encryption type of the ticket's enc-part.
+func (t CachedTicket) Etype() int32 {
+	return t.Ticket.EncPart.EType
+}
+
+// KVNO is the server key version the ticket was encrypted with.
+func (t CachedTicket) KVNO() int {
+	return t.Ticket.EncPart.KVNO
+}
+
+// IsTGT reports whether the ticket is a ticket-granting ticket.
+func (t CachedTicket) IsTGT() bool {
+	return strings.HasPrefix(strings.ToLower(t.ServerName), "krbtgt/")
+}
+
+// KerberoastHash formats the ticket's enc-part for hashcat (13100, 19600 or
+// 19700). account labels the hash and, for AES, is part of the salt, so it
+// should be the service account's sAMAccountName when known.
+func (t CachedTicket) KerberoastHash(account string) string {
+	return formatKerberoastHashForHashcat(account, t.ServerRealm, t.ServerName, t.Ticket)
+}
+
+// ParseTicketFile reads a .kirbi or ccache file, telling them apart by their
+// first byte.
+func ParseTicketFile(data []byte) ([]CachedTicket, string, error) {
+	if len(data) < 2 {
+		return nil, "", fmt.Errorf("ticket data too short (%d bytes)", len(data))
+	}
+	switch {
+	case data[0] == 0x05:
+		tickets, err := ParseCCache(data)
+		return tickets, TicketFormatCCache, err
+	case data[0] == 0x76: // [APPLICATION 22] KRB-CRED
+		tickets, err := ParseKirbi(data)
+		return tickets, TicketFormatKirbi, err
+	default:
+		return nil, "", fmt.Errorf("unrecognised ticket data (first byte 0x%02x)", data[0])
+	}
+}
+
+// ParseKirbi decodes a KRB-CRED. Exported tickets carry their
+// EncKrbCredPart unencrypted (etype 0); anything else needs the key the
+// KRB-CRED was sealed with and is rejected.
+func ParseKirbi(data []byte) ([]CachedTicket, error) {
+	var cred messages.KRBCred
+	if err := cred.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("KRB-CRED: %w", err)
+	}
+	if cred.EncPart.EType != 0 {
+		return nil, fmt.Errorf("KRB-CRED enc-part is encrypted (etype %d)", cred.EncPart.EType)
+	}
+	var part messages.EncKrbCredPart
+	if err := part.Unmarshal(cred.EncPart.Cipher); err != nil {
+		return nil, fmt.Errorf("KRB-CRED enc-part: %w", err)
+	}
+	if len(part.TicketInfo) != len(cred.Tickets) {
+		return nil, fmt.Errorf("KRB-CRED has %d tickets but %d ticket infos", len(cred.Tickets), len(part.TicketInfo))
+	}
+
+	tickets := make([]CachedTicket, 0, len(cred.Tickets))
+	for i, info := range part.TicketInfo {
+		tkt := cred.Tickets[i]
+		t := CachedTicket{
+			ClientName:  info.PName.PrincipalNameString(),
+			ClientRealm: info.PRealm,
+			ServerName:  info.SName.PrincipalNameString(),
+			ServerRealm: info.SRealm,
+			Flags:       TicketFlagNames(info.Flags),
+			AuthTime:    info.AuthTime,
+			StartTime:   info.StartTime,
+			EndTime:     info.EndTime,
+			RenewTill:   info.RenewTill,
+			SessionKey:  info.Key,
+			Ticket:      tkt,
+		}
+		// The ticket itself always names the server; KrbCredInfo may not.
+		if t.ServerName == "" {
+			t.ServerName = tkt.SName.PrincipalNameString()
+		}
+		if t.ServerRealm == "" {
+			t.ServerRealm = tkt.Realm
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, nil
+}
+
+// ParseCCache decodes an MIT credential cache (v3 or v4), skipping
+// X-CACHECONF configuration entries.
+func ParseCCache(data []byte) (tickets []CachedTicket, err error) {
+	if len(data) < 2 || data[0] != 0x05 || (data[1] != 3 && data[1] != 4) {
+		return nil, fmt.Errorf("not a v3/v4 credential cache")
+	}
+	// gokrb5 slices the buffer without bounds checks: cap it so a truncated
+	// file cannot read past its end, and turn the panic into an error.
+	data = data[:len(data):len(data)]
+	defer func() {
+		if r := recover(); r != nil {
+			tickets, err = nil, fmt.Errorf("truncated credential cache: %v", r)
+		}
+	}()
+	var cc credentials.CCache
+	if err := cc.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	for _, cred := range cc.GetEntries() {
+		var tkt messages.Ticket
+		if err := tkt.Unmarshal(cred.Ticket); err != nil {
+			return nil, fmt.Errorf("ticket for %s: %w", cred.Server.PrincipalName.PrincipalNameString(), err)
+		}
+		tickets = append(tickets, CachedTicket{
+			ClientName:  cred.Client.PrincipalName.PrincipalNameString(),
+			ClientRealm: cred.Client.Realm,
+			ServerName:  cred.Server.PrincipalName.PrincipalNameString(),
+			ServerRealm: cred.Server.Realm,
+			Flags:       TicketFlagNames(cred.TicketFlags),
+			AuthTime:    ccacheTime(cred.AuthTime),
+			StartTime:   ccacheTime(cred.StartTime),
+			EndTime:     ccacheTime(cred.EndTime),
+			RenewTill:   ccacheTime(cred.RenewTill),
+			SessionKey:  cred.Key,
+			Ticket:      tkt,
+		})
+	}
+	return tickets, nil
+}
+
+// ccacheTime maps the ccache's "unset" (0) onto the zero time.
+func ccacheTime(t time.Time) time.Time {
+	if t.Unix() == 0 {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// TicketFlagNames lists the RFC 4120 ticket flags set in f.
+func TicketFlagNames(f asn1.BitString) []string {
+	names := []struct {
+		bit  int
+		name string
+	}{
+		{flags.Forwardable, "FORWARDABLE"},
+		{flags.Forwarded, "FORWARDED"},
+		{flags.Proxiable, "PROXIABLE"},
+		{flags.Proxy, "PROXY"},
+		{flags.MayPostDate, "MAY_POSTDATE"},
+		{flags.PostDated, "POSTDATED"},
+		{flags.Invalid, "INVALID"},
+		{flags.Renewable, "RENEWABLE"},
+		{flags.Initial, "INITIAL"},
+		{flags.PreAuthent, "PRE_AUTHENT"},
+		{flags.HWAuthent, "HW_AUTHENT"},
+		{flags.TransitedPolicyChecked, "TRANSITED_POLICY_CHECKED"},
+		{flags.OKAsDelegate, "OK_AS_DELEGATE"},
+		{flags.Canonicalize, "NAME_CANONICALIZE"}, // as klist names bit 15
+	}
+	var set []string
+	for _, n := range names {
+		if n.bit/8 < len(f.Bytes) && types.IsFlagSet(&f, n.bit) {
+			set = append(set, n.name)
+		}
+	}
+	return set
+}
@@ -0,0 +1,199 @@
+package krb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+var (
+	testStart = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	testEnd   = testStart.Add(10 * time.Hour)
+	testRenew = testStart.Add(7 * 24 * time.Hour)
+)
+
+func testTicket(t *testing.T, spn string) messages.Ticket {
+	t.Helper()
+	cipher := make([]byte, 64)
+	for i := range cipher {
+		cipher[i] = byte(i)
+	}
+	return messages.Ticket{
+		TktVNO:  5,
+		Realm:   "CORP.LOCAL",
+		SName:   types.NewPrincipalName(nametype.KRB_NT_SRV_INST, spn),
+		EncPart: types.EncryptedData{EType: 23, KVNO: 4, Cipher: cipher},
+	}
+}
+
+func testFlags() asn1.BitString {
+	f := types.NewKrbFlags()
+	types.SetFlags(&f, []int{flags.Forwardable, flags.Renewable, flags.PreAuthent})
+	return f
+}
+
+// buildKirbi encodes tkt as a KRB-CRED with an unencrypted enc-part, as
+// Rubeus and mimikatz export it.
+func buildKirbi(t *testing.T, tkt messages.Ticket) []byte {
+	t.Helper()
+	part := messages.EncKrbCredPart{TicketInfo: []messages.KrbCredInfo{{
+		Key:       types.EncryptionKey{KeyType: 18, KeyValue: make([]byte, 32)},
+		PRealm:    "CORP.LOCAL",
+		PName:     types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "alice"),
+		Flags:     testFlags(),
+		AuthTime:  testStart,
+		StartTime: testStart,
+		EndTime:   testEnd,
+		RenewTill: testRenew,
+		SRealm:    "CORP.LOCAL",
+		SName:     tkt.SName,
+	}}}
+	partBytes, err := asn1.Marshal(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partBytes = asn1tools.AddASNAppTag(partBytes, asnAppTag.EncKrbCredPart)
+
+	tktBytes, err := tkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := struct {
+		PVNO    int `asn1:"explicit,tag:0"`
+		MsgType int `asn1:"explicit,tag:1"`
+		Tickets asn1.RawValue
+		EncPart types.EncryptedData `asn1:"explicit,tag:3"`
+	}{
+		PVNO:    5,
+		MsgType: 22,
+		Tickets: explicitTag(t, 2, asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: tktBytes}),
+		EncPart: types.EncryptedData{EType: 0, Cipher: partBytes},
+	}
+	b, err := asn1.Marshal(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1tools.AddASNAppTag(b, asnAppTag.KRBCred)
+}
+
+// explicitTag wraps v in a [tag] EXPLICIT, which asn1.Marshal does not do
+// for a RawValue field.
+func explicitTag(t *testing.T, tag int, v asn1.RawValue) asn1.RawValue {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: b}
+}
+
+// buildCCache writes a v4 credential cache holding a configuration entry
+// followed by tkt.
+func buildCCache(t *testing.T, tkt messages.Ticket) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	u16 := func(v uint16) { binary.Write(&b, binary.BigEndian, v) }
+	u32 := func(v uint32) { binary.Write(&b, binary.BigEndian, v) }
+	data := func(d []byte) { u32(uint32(len(d))); b.Write(d) }
+	principal := func(nameType int32, realm string, components ...string) {
+		u32(uint32(nameType))
+		u32(uint32(len(components)))
+		data([]byte(realm))
+		for _, c := range components {
+			data([]byte(c))
+		}
+	}
+	credential := func(server []string, serverRealm string, ticket []byte, start, end, renew time.Time, fl asn1.BitString) {
+		principal(nametype.KRB_NT_PRINCIPAL, "CORP.LOCAL", "alice")
+		principal(nametype.KRB_NT_SRV_INST, serverRealm, server...)
+		u16(18)
+		data(make([]byte, 32))
+		for _, ts := range []time.Time{start, start, end, renew} {
+			if ts.IsZero() {
+				u32(0)
+			} else {
+				u32(uint32(ts.Unix()))
+			}
+		}
+		b.WriteByte(0)
+		b.Write(fl.Bytes)
+		u32(0) // addresses
+		u32(0) // authdata
+		data(ticket)
+		data(nil)
+	}
+
+	b.Write([]byte{5, 4})
+	u16(12)
+	u16(1) // KDC offset tag
+	u16(8)
+	u32(0)
+	u32(0)
+	principal(nametype.KRB_NT_PRINCIPAL, "CORP.LOCAL", "alice")
+	credential([]string{"krb5_ccache_conf_data", "fast_avail"}, "X-CACHECONF:", []byte("yes"),
+		time.Time{}, time.Time{}, time.Time{}, types.NewKrbFlags())
+	tktBytes, err := tkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential(tkt.SName.NameString, "CORP.LOCAL", tktBytes, testStart, testEnd, testRenew, testFlags())
+	return b.Bytes()
+}
+
+func TestParseTicketFile(t *testing.T) {
+	tkt := testTicket(t, "MSSQLSvc/sql01.corp.local:1433")
+	for name, data := range map[string][]byte{
+		TicketFormatKirbi:  buildKirbi(t, tkt),
+		TicketFormatCCache: buildCCache(t, tkt),
+	} {
+		tickets, format, err := ParseTicketFile(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if format != name || len(tickets) != 1 {
+			t.Fatalf("%s: format %s, %d tickets", name, format, len(tickets))
+		}
+		got := tickets[0]
+		if got.ClientName != "alice" || got.ClientRealm != "CORP.LOCAL" ||
+			got.ServerName != "MSSQLSvc/sql01.corp.local:1433" || got.ServerRealm != "CORP.LOCAL" {
+			t.Errorf("%s: principals %s@%s -> %s@%s", name, got.ClientName, got.ClientRealm, got.ServerName, got.ServerRealm)
+		}
+		if !got.StartTime.Equal(testStart) || !got.EndTime.Equal(testEnd) || !got.RenewTill.Equal(testRenew) {
+			t.Errorf("%s: times %v %v %v", name, got.StartTime, got.EndTime, got.RenewTill)
+		}
+		if strings.Join(got.Flags, ",") != "FORWARDABLE,RENEWABLE,PRE_AUTHENT" {
+			t.Errorf("%s: flags %v", name, got.Flags)
+		}
+		if got.Etype() != 23 || got.KVNO() != 4 || got.SessionKey.KeyType != 18 || got.IsTGT() {
+			t.Errorf("%s: etype %d kvno %d session key %d", name, got.Etype(), got.KVNO(), got.SessionKey.KeyType)
+		}
+		hash := got.KerberoastHash("sqlsvc")
+		if HashcatModeOf(hash) != 13100 || !strings.HasPrefix(hash, "$krb5tgs$23$*sqlsvc$CORP.LOCAL$MSSQLSvc/sql01.corp.local:1433*$000102") {
+			t.Errorf("%s: hash %s", name, hash)
+		}
+	}
+}
+
+func TestParseTicketFileRejectsGarbage(t *testing.T) {
+	ccache := buildCCache(t, testTicket(t, "HTTP/web"))
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("not a ticket"),
+		"truncated": ccache[:len(ccache)-40],
+		"v2 ccache": {5, 2, 0, 0},
+	} {
+		if _, _, err := ParseTicketFile(data); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
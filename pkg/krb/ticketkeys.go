@@ -0,0 +1,87 @@
+package krb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// TicketKey is a long-term key supplied to decrypt captured tickets and
+// check their PAC signatures. Principal is optional except for krbtgt keys,
+// which must say so: only they can check a KDC signature.
+type TicketKey struct {
+	Principal string
+	Key       types.EncryptionKey
+}
+
+// IsKrbtgt reports whether the key belongs to a krbtgt account.
+func (k TicketKey) IsKrbtgt() bool {
+	return strings.HasPrefix(strings.ToLower(k.Principal), "krbtgt")
+}
+
+var ticketKeyEtypes = map[string]int32{
+	"rc4":    etypeID.RC4_HMAC,
+	"ntlm":   etypeID.RC4_HMAC,
+	"aes128": etypeID.AES128_CTS_HMAC_SHA1_96,
+	"aes256": etypeID.AES256_CTS_HMAC_SHA1_96,
+}
+
+// keyLengths is the key size in bytes of each supported etype.
+var keyLengths = map[int32]int{
+	etypeID.RC4_HMAC:                16,
+	etypeID.AES128_CTS_HMAC_SHA1_96: 16,
+	etypeID.AES256_CTS_HMAC_SHA1_96: 32,
+}
+
+// ParseTicketKey reads a key given as [principal:]etype:hex, where etype is
+// rc4 (or ntlm), aes128, aes256 or an etype number, e.g.
+// krbtgt:aes256:6a8e... or rc4:31d6cfe0d16ae931b73c59d7e0c089c0.
+func ParseTicketKey(spec string) (TicketKey, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return TicketKey{}, fmt.Errorf("key %q: want [principal:]etype:hex", spec)
+	}
+	var k TicketKey
+	if len(parts) == 3 {
+		k.Principal = parts[0]
+		parts = parts[1:]
+	}
+	etype, ok := ticketKeyEtypes[strings.ToLower(parts[0])]
+	if !ok {
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return TicketKey{}, fmt.Errorf("key %q: unknown etype %q", spec, parts[0])
+		}
+		etype = int32(n)
+	}
+	value, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return TicketKey{}, fmt.Errorf("key %q: %v", spec, err)
+	}
+	want, ok := keyLengths[etype]
+	if !ok {
+		return TicketKey{}, fmt.Errorf("key %q: unsupported etype %d", spec, etype)
+	}
+	if len(value) != want {
+		return TicketKey{}, fmt.Errorf("key %q: etype %d keys are %d bytes, got %d", spec, etype, want, len(value))
+	}
+	k.Key = types.EncryptionKey{KeyType: etype, KeyValue: value}
+	return k, nil
+}
+
+// LoadKeytabKeys returns every key in a keytab, labelled with its principal.
+func LoadKeytabKeys(path string) ([]TicketKey, error) {
+	entries, err := LoadKeytab(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]TicketKey, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, TicketKey{Principal: e.String(), Key: e.Key})
+	}
+	return keys, nil
+}
@@ -0,0 +1,197 @@
+package krb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// RoastHash is a $krb5tgs$ or $krb5asrep$ hash taken apart: whose key it is
+// under and the ciphertext in Kerberos order (RC4 checksum first, AES
+// checksum last).
+type RoastHash struct {
+	Type   string // HashTypeASREP or HashTypeKerberoast
+	Etype  int32
+	User   string
+	Realm  string
+	SPN    string // Kerberoast only
+	Cipher []byte
+}
+
+// Usage is the key usage the ciphertext was encrypted with.
+func (h RoastHash) Usage() uint32 {
+	if h.Type == HashTypeASREP {
+		return keyusage.AS_REP_ENCPART
+	}
+	return keyusage.KDC_REP_TICKET
+}
+
+// Salt is the account's AD salt, which the AES etypes need.
+func (h RoastHash) Salt() string {
+	return ADSalt(h.Realm, h.User)
+}
+
+// ParseRoastHash reads the hashcat formats this package writes: 13100,
+// 19600/19700 (Kerberoast) and 18200, 32100/32200 (AS-REP).
+func ParseRoastHash(hash string) (RoastHash, error) {
+	var h RoastHash
+	var rest string
+	switch {
+	case strings.HasPrefix(hash, "$krb5tgs$"):
+		h.Type, rest = HashTypeKerberoast, hash[len("$krb5tgs$"):]
+	case strings.HasPrefix(hash, "$krb5asrep$"):
+		h.Type, rest = HashTypeASREP, hash[len("$krb5asrep$"):]
+	default:
+		return h, fmt.Errorf("not a $krb5tgs$ or $krb5asrep$ hash")
+	}
+	i := strings.IndexByte(rest, '$')
+	if i < 0 {
+		return h, fmt.Errorf("hash has no etype")
+	}
+	etype, err := strconv.Atoi(rest[:i])
+	if err != nil {
+		return h, fmt.Errorf("etype %q: %v", rest[:i], err)
+	}
+	h.Etype = int32(etype)
+	rest = rest[i+1:]
+
+	// The principal part ends where the hex checksum$edata begins. Account
+	// names may end in $, so the realm is cut off at the last $.
+	var principal, checksum, edata string
+	switch {
+	case h.Type == HashTypeKerberoast && strings.HasPrefix(rest, "*"): // *user$realm$spn*
+		end := strings.Index(rest, "*$")
+		if end < 0 {
+			return h, fmt.Errorf("unterminated principal")
+		}
+		inner := rest[1:end]
+		j := strings.LastIndexByte(inner, '$')
+		if j < 0 {
+			return h, fmt.Errorf("principal %q has no SPN", inner)
+		}
+		principal, h.SPN = inner[:j], inner[j+1:]
+		checksum, edata, _ = strings.Cut(rest[end+2:], "$")
+	case h.Type == HashTypeKerberoast: // user$realm$*spn*
+		start := strings.Index(rest, "$*")
+		end := strings.LastIndex(rest, "*$")
+		if start < 0 || end <= start {
+			return h, fmt.Errorf("hash has no *spn*")
+		}
+		principal, h.SPN = rest[:start], rest[start+2:end]
+		checksum, edata, _ = strings.Cut(rest[end+2:], "$")
+	case h.Etype == etypeID.RC4_HMAC: // user@realm:checksum$edata
+		j := strings.LastIndexByte(rest, ':')
+		if j < 0 {
+			return h, fmt.Errorf("hash has no user@realm")
+		}
+		if at := strings.LastIndexByte(rest[:j], '@'); at >= 0 {
+			h.User, h.Realm = rest[:at], rest[at+1:j]
+		} else {
+			h.User = rest[:j]
+		}
+		checksum, edata, _ = strings.Cut(rest[j+1:], "$")
+	default: // user$realm$checksum$edata
+		parts := strings.Split(rest, "$")
+		if len(parts) < 4 {
+			return h, fmt.Errorf("hash has too few fields")
+		}
+		principal = strings.Join(parts[:len(parts)-2], "$")
+		checksum, edata = parts[len(parts)-2], parts[len(parts)-1]
+	}
+	if principal != "" {
+		j := strings.LastIndexByte(principal, '$')
+		if j < 0 {
+			return h, fmt.Errorf("principal %q has no realm", principal)
+		}
+		h.User, h.Realm = principal[:j], principal[j+1:]
+	}
+
+	sum, err := hex.DecodeString(checksum)
+	if err != nil || len(sum) == 0 {
+		return h, fmt.Errorf("checksum: not hex")
+	}
+	data, err := hex.DecodeString(edata)
+	if err != nil || len(data) == 0 {
+		return h, fmt.Errorf("edata: not hex")
+	}
+	if h.Etype == etypeID.RC4_HMAC {
+		h.Cipher = append(sum, data...)
+	} else {
+		h.Cipher = append(data, sum...)
+	}
+	return h, nil
+}
+
+// Key derives the key password gives this hash's account.
+func (h RoastHash) Key(password string) (types.EncryptionKey, error) {
+	if h.Etype != etypeID.RC4_HMAC && (h.User == "" || strings.EqualFold(h.User, "unknown") || h.Realm == "") {
+		return types.EncryptionKey{}, fmt.Errorf("etype %d needs the account name and realm for the salt", h.Etype)
+	}
+	return StringToKey(h.Etype, password, h.Salt())
+}
+
+// Verify reports whether key decrypts the hash's ciphertext; the
+// ciphertext's integrity checksum makes a wrong key fail.
+func (h RoastHash) Verify(key types.EncryptionKey) bool {
+	_, err := crypto.DecryptMessage(h.Cipher, key, h.Usage())
+	return err == nil
+}
+
+// VerifyPassword checks password against a captured AS-REP or TGS hash
+// offline, by deriving the account's key and decrypting the ciphertext.
+func VerifyPassword(hash, password string) (bool, error) {
+	h, err := ParseRoastHash(hash)
+	if err != nil {
+		return false, err
+	}
+	key, err := h.Key(password)
+	if err != nil {
+		return false, err
+	}
+	return h.Verify(key), nil
+}
+
+// VerifyCandidatePassword checks a cracked or known password against the
+// candidate's captured hashes. When one decrypts, the password is stored
+// and the candidate marked validated; a cracker's word alone is not enough.
+func VerifyCandidatePassword(c *Candidate, password string) (bool, error) {
+	hashes := make([]string, 0, len(c.Hashes)+1)
+	for _, r := range c.Hashes {
+		if r.OK() {
+			hashes = append(hashes, r.Hash)
+		}
+	}
+	if c.Hash != "" && !slices.Contains(hashes, c.Hash) {
+		hashes = append(hashes, c.Hash)
+	}
+	if len(hashes) == 0 {
+		return false, fmt.Errorf("%s has no captured hash to verify against", c.SamAccountName)
+	}
+
+	var lastErr error
+	for _, hash := range hashes {
+		ok, err := VerifyPassword(hash, password)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+		h, _ := ParseRoastHash(hash)
+		c.Password = password
+		SetCandidateValidation(c, StatusValidated,
+			[]string{fmt.Sprintf("Password verified offline: it derives the etype %d key that decrypts the captured %s hash.", h.Etype, h.Type)},
+			nil,
+			[]string{"Rotate the password and review where the account is used."})
+		return true, nil
+	}
+	return false, lastErr
+}
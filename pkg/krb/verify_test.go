@@ -0,0 +1,99 @@
+package krb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+)
+
+// roastedHash encrypts a dummy enc-part under the account's key, the way
+// the KDC would, and formats it as this package does.
+func roastedHash(t *testing.T, asrep bool, etype int32, account, password string) string {
+	t.Helper()
+	keys, err := DeriveKeys("corp.local", account, password, etype)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := uint32(keyusage.KDC_REP_TICKET)
+	if asrep {
+		usage = keyusage.AS_REP_ENCPART
+	}
+	ed, err := crypto.GetEncryptedData([]byte(strings.Repeat("enc-part ", 8)), keys[0], usage, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asrep {
+		return ASREPHash(account, "corp.local", ed)
+	}
+	return KerberoastHash(account, "CORP.LOCAL", "MSSQLSvc/sql01.corp.local:1433", ed)
+}
+
+func TestVerifyPassword(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		asrep   bool
+		etype   int32
+		account string
+	}{
+		{"asrep rc4", true, etypeID.RC4_HMAC, "alice"},
+		{"asrep aes256", true, etypeID.AES256_CTS_HMAC_SHA1_96, "alice"},
+		{"tgs rc4", false, etypeID.RC4_HMAC, "svc_sql"},
+		{"tgs aes128", false, etypeID.AES128_CTS_HMAC_SHA1_96, "svc_sql"},
+		{"tgs aes256 computer", false, etypeID.AES256_CTS_HMAC_SHA1_96, "SQL01$"},
+		{"tgs rc4 computer", false, etypeID.RC4_HMAC, "SQL01$"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hash := roastedHash(t, tc.asrep, tc.etype, tc.account, "Summer2026!")
+			h, err := ParseRoastHash(hash)
+			if err != nil {
+				t.Fatalf("%s: %v", hash, err)
+			}
+			if h.User != tc.account || !strings.EqualFold(h.Realm, "corp.local") || h.Etype != tc.etype {
+				t.Errorf("parsed %+v", h)
+			}
+			if !tc.asrep && h.SPN != "MSSQLSvc/sql01.corp.local:1433" {
+				t.Errorf("SPN %q", h.SPN)
+			}
+			if ok, err := VerifyPassword(hash, "Summer2026!"); !ok || err != nil {
+				t.Errorf("right password: %v %v", ok, err)
+			}
+			if ok, err := VerifyPassword(hash, "Winter2026!"); ok || err != nil {
+				t.Errorf("wrong password: %v %v", ok, err)
+			}
+		})
+	}
+
+	// Ticket files carry no account name, so AES hashes from them have no salt.
+	hash := roastedHash(t, false, etypeID.AES256_CTS_HMAC_SHA1_96, "unknown", "x")
+	if _, err := VerifyPassword(hash, "x"); err == nil {
+		t.Error("AES hash without an account verified")
+	}
+}
+
+func TestVerifyCandidatePassword(t *testing.T) {
+	hash := roastedHash(t, false, etypeID.AES256_CTS_HMAC_SHA1_96, "svc_sql", "Summer2026!")
+	c := Candidate{
+		SamAccountName: "svc_sql",
+		Type:           "KERBEROAST",
+		Hash:           hash,
+		Hashes: []HashRecord{
+			{SPN: "HTTP/gone.corp.local", Error: "KDC_ERR_S_PRINCIPAL_UNKNOWN"},
+			{SPN: "MSSQLSvc/sql01.corp.local:1433", Hash: hash},
+		},
+	}
+	if ok, _ := VerifyCandidatePassword(&c, "Winter2026!"); ok || c.Password != "" || c.Validation != "" {
+		t.Fatalf("wrong password accepted: %+v", c)
+	}
+	if ok, err := VerifyCandidatePassword(&c, "Summer2026!"); !ok || err != nil {
+		t.Fatalf("right password rejected: %v", err)
+	}
+	if c.Password != "Summer2026!" || c.Validation != StatusValidated || len(c.Evidence) == 0 {
+		t.Errorf("candidate not validated: %+v", c)
+	}
+	if _, err := VerifyCandidatePassword(&Candidate{SamAccountName: "bob"}, "x"); err == nil {
+		t.Error("candidate without hashes verified")
+	}
+}
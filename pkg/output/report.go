@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/fleet"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/reasoning"
 )
@@ -31,6 +32,7 @@ type ReportData struct {
 	SeverityDistribution map[string]int
 	TypeDistribution     map[string]int
 	ExecutiveSummary     ExecutiveSummary
+	DCFleet              *fleet.Report
 	FullJSONOutput       string
 }
 
@@ -80,6 +82,7 @@ func WriteHTMLReport(path string, results Results) error {
 	// Create template with custom functions
 	funcMap := template.FuncMap{
 		"toLower": strings.ToLower,
+		"join":    strings.Join,
 	}
 	tmpl, err := template.New("report").Funcs(funcMap).Parse(htmlTemplate)
 	if err != nil {
@@ -107,6 +110,7 @@ func prepareReportData(results Results) ReportData {
 		Domain:               results.Domain,
 		Summary:              results.Summary,
 		RiskInsights:         results.RiskInsights,
+		DCFleet:              results.DCFleet,
 		ValidationCounts:     make(map[string]int),
 		MermaidDiagrams:      make([]string, 0),
 		SeverityDistribution: make(map[string]int),
@@ -282,6 +286,10 @@ func generateExecutiveSummary(results Results, data ReportData) ExecutiveSummary
 	if len(results.Advanced.SensitiveFiles) > 0 {
 		summary.TopRisks = append(summary.TopRisks, fmt.Sprintf("%d sensitive files accessible on network shares", len(results.Advanced.SensitiveFiles)))
 	}
+	if etypes := results.Advanced.EtypePosture; etypes != nil && len(etypes.RC4OnlyServiceAccounts) > 0 {
+		summary.TopRisks = append(summary.TopRisks, fmt.Sprintf("%d Kerberoastable service accounts are RC4-only (%s)",
+			len(etypes.RC4OnlyServiceAccounts), strings.Join(etypes.RC4OnlyServiceAccounts, ", ")))
+	}
 
 	// Identify quick wins
 	if results.Summary.ASREPCandidates > 0 {
@@ -293,6 +301,9 @@ func generateExecutiveSummary(results Results, data ReportData) ExecutiveSummary
 	if results.Summary.KerberoastCandidates > 0 {
 		summary.QuickWins = append(summary.QuickWins, "Rotate service account passwords with 25+ character complexity")
 	}
+	if etypes := results.Advanced.EtypePosture; etypes != nil && len(etypes.RC4OnlyServiceAccounts) > 0 {
+		summary.QuickWins = append(summary.QuickWins, "Set msDS-SupportedEncryptionTypes to 0x18 (AES only) on service accounts, then reset their passwords")
+	}
 
 	return summary
 }
@@ -311,7 +322,11 @@ func generateRemediationSteps(c krb.Candidate) []string {
 
 	case "KERBEROAST":
 		steps = append(steps, "Use a strong, randomly-generated password (25+ characters)")
-		steps = append(steps, "Enable AES256 encryption for Kerberos")
+		if c.EtypePosture == krb.EtypeRC4Only {
+			steps = append(steps, "Account is RC4-only: set msDS-SupportedEncryptionTypes to 0x18 and reset the password so AES keys exist")
+		} else {
+			steps = append(steps, "Enable AES256 encryption for Kerberos")
+		}
 		steps = append(steps, "Review if all SPNs are necessary; remove unused ones")
 		steps = append(steps, "Consider using Group Managed Service Accounts (gMSA)")
 		steps = append(steps, "Implement service account monitoring")
@@ -605,6 +620,39 @@ const htmlTemplate = `<!DOCTYPE html>
       </div>
     </div>
 
+    {{if .DCFleet}}
+    <div class="card">
+      <h2>Domain Controller Comparison</h2>
+      <table class="table">
+        <thead><tr><th>Host</th><th>Source</th><th>SMB Signing</th><th>SMBv1</th><th>LDAP Signing</th><th>Channel Binding</th><th>RDP NLA</th><th>LDAPS Certificate</th></tr></thead>
+        <tbody>
+        {{range .DCFleet.Hosts}}
+          <tr>
+            <td><strong>{{.Host}}</strong>{{if .Name}}<br>{{.Name}}{{end}}{{if not .DC}}<br><span class="empty">not a DC</span>{{end}}</td>
+            <td>{{.Source}}</td>
+            <td>{{.SMBSigning}}{{if .SMBDialects}}<br><span class="empty">{{join .SMBDialects ", "}}</span>{{end}}</td>
+            <td>{{.SMB1}}</td>
+            <td>{{.LDAPSigning}}</td>
+            <td>{{.ChannelBinding}}</td>
+            <td>{{.RDPNLA}}</td>
+            <td>{{with .Certificate}}{{.Subject}}<br><span class="empty">{{.Issuer}}</span><br>until {{.NotAfter.Format "2006-01-02"}}{{if .Expired}} <span class="pill blocked">expired</span>{{end}}{{else}}<span class="empty">none</span>{{end}}</td>
+          </tr>
+        {{end}}
+        </tbody>
+      </table>
+      {{if .DCFleet.Differences}}
+      <p><strong>Inconsistent hardening</strong></p>
+      <ul>
+        {{range .DCFleet.Differences}}
+        <li><strong>{{.Check}}</strong>: {{range $value, $hosts := .Values}}{{$value}} on {{join $hosts ", "}}; {{end}}</li>
+        {{end}}
+      </ul>
+      {{else}}
+      <p class="empty">All compared hosts report the same hardening.</p>
+      {{end}}
+    </div>
+    {{end}}
+
     <div class="card">
       <h2>Confirmed Findings (Observed Evidence)</h2>
       {{if .ConfirmedCandidates}}
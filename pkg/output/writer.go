@@ -7,10 +7,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/advanced"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/controlplane"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/fleet"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/reasoning"
@@ -19,15 +21,31 @@ import (
 
 // Results is the top-level output structure
 type Results struct {
-	SchemaVersion string           `json:"schema_version,omitempty"`
-	Domain        DomainInfo       `json:"domain"`
-	Summary       Summary          `json:"summary"`
-	Candidates    []krb.Candidate  `json:"candidates"`
-	RiskInsights  []string         `json:"risk_insights,omitempty"`
-	AttackGraph   *reasoning.Graph `json:"attack_graph,omitempty"`
+	SchemaVersion string              `json:"schema_version,omitempty"`
+	Domain        DomainInfo          `json:"domain"`
+	Forest        []krb.ForestDomain  `json:"forest,omitempty"`
+	DCFleet       *fleet.Report       `json:"dc_fleet,omitempty"`
+	Summary       Summary             `json:"summary"`
+	Candidates    []krb.Candidate     `json:"candidates"`
+	RiskInsights  []string            `json:"risk_insights,omitempty"`
+	AttackGraph   *reasoning.Graph    `json:"attack_graph,omitempty"`
 	ControlPlane  *controlplane.Graph `json:"control_plane,omitempty"`
-	Users         []ingest.User    `json:"users"`
-	Advanced      AdvancedResults  `json:"advanced,omitempty"`
+	Users         []ingest.User       `json:"users"`
+	Computers     []ingest.Computer   `json:"computers,omitempty"`
+	Advanced      AdvancedResults     `json:"advanced,omitempty"`
+	Delta         *DeltaReport        `json:"delta,omitempty"`
+}
+
+// DeltaReport describes an incremental run against the previous snapshot.
+type DeltaReport struct {
+	PreviousRun     string            `json:"previous_run"`
+	DC              string            `json:"dc"`
+	SinceUSN        int64             `json:"since_usn"`
+	HighestUSN      int64             `json:"highest_usn"`
+	Objects         ingest.DeltaStats `json:"objects"`
+	NewFindings     int               `json:"new_findings"`
+	ChangedFindings int               `json:"changed_findings"`
+	GoneFindings    []krb.Candidate   `json:"gone_findings,omitempty"`
 }
 
 // DomainInfo holds global domain data
@@ -36,6 +54,9 @@ type DomainInfo struct {
 	DN              string `json:"dn"`
 	FunctionalLevel string `json:"functional_level"`
 	OS              string `json:"os_version"`
+	// RootDSE is the full RootDSE of the bound DC, including its
+	// currentTime and the measured clock skew.
+	RootDSE *krb.RootDSE `json:"rootdse,omitempty"`
 }
 
 // Summary holds high-level counts
@@ -53,20 +74,26 @@ type Summary struct {
 
 // AdvancedResults holds detailed findings from advanced modules
 type AdvancedResults struct {
-	Shares         []string               `json:"shares,omitempty"`
-	Pwned          bool                   `json:"pwned,omitempty"`
-	SensitiveFiles []advanced.FileFinding `json:"sensitive_files,omitempty"`
-	GPPHashes      interface{}            `json:"gpp_hashes,omitempty"`
-	DCSync         interface{}            `json:"dcsync,omitempty"`
-	Delegation     interface{}            `json:"delegation,omitempty"`
-	RBCD           interface{}            `json:"rbcd,omitempty"`
-	PKINIT         interface{}            `json:"pkinit,omitempty"`
-	Trusts         interface{}            `json:"trusts,omitempty"`
-	DNSTransfers   interface{}            `json:"dns_transfers,omitempty"`
-	LAPS           interface{}            `json:"laps,omitempty"`
-	GPOs           interface{}            `json:"gpos,omitempty"`
-	Sessions       interface{}            `json:"sessions,omitempty"`
-	ACLAnalysis    interface{}            `json:"acl_analysis,omitempty"`
+	Shares           []string                 `json:"shares,omitempty"`
+	Pwned            bool                     `json:"pwned,omitempty"`
+	SensitiveFiles   []advanced.FileFinding   `json:"sensitive_files,omitempty"`
+	GPPHashes        interface{}              `json:"gpp_hashes,omitempty"`
+	DCSync           interface{}              `json:"dcsync,omitempty"`
+	Delegation       interface{}              `json:"delegation,omitempty"`
+	RBCD             interface{}              `json:"rbcd,omitempty"`
+	PKINIT           interface{}              `json:"pkinit,omitempty"`
+	Trusts           interface{}              `json:"trusts,omitempty"`
+	DNSTransfers     interface{}              `json:"dns_transfers,omitempty"`
+	LAPS             interface{}              `json:"laps,omitempty"`
+	GPOs             interface{}              `json:"gpos,omitempty"`
+	Sessions         interface{}              `json:"sessions,omitempty"`
+	ACLAnalysis      interface{}              `json:"acl_analysis,omitempty"`
+	PasswordPolicies interface{}              `json:"password_policies,omitempty"`
+	DeletedObjects   interface{}              `json:"deleted_objects,omitempty"`
+	LDAPConfig       interface{}              `json:"ldap_config,omitempty"`
+	EtypePosture     *advanced.EtypeReport    `json:"etype_posture,omitempty"`
+	Timeroast        []krb.TimeroastHash      `json:"timeroast,omitempty"`
+	Tickets          []*advanced.TicketResult `json:"tickets,omitempty"`
 }
 
 func WriteJSON(path string, results Results) error {
@@ -130,67 +157,96 @@ func WriteCSV(path string, results Results) error {
 	return nil
 }
 
-func WriteHashExport(basePath string, results Results) error {
-	// Create hashes directory
-	hashDir := "hashes"
-	if err := os.MkdirAll(hashDir, 0755); err != nil {
-		return err
-	}
+// hashModeNames labels the hashcat modes in export headers and the guide.
+var hashModeNames = map[int]string{
+	13100: "Kerberoast RC4-HMAC (etype 23)",
+	19600: "Kerberoast AES128 (etype 17)",
+	19700: "Kerberoast AES256 (etype 18)",
+	18200: "AS-REP RC4-HMAC (etype 23)",
+	32100: "AS-REP AES128 (etype 17)",
+	32200: "AS-REP AES256 (etype 18)",
+	31300: "Timeroast MS-SNTP",
+}
 
-	// Separate AS-REP and Kerberoast hashes
-	var asrepHashes []string
-	var kerberoastHashes []string
+// hashExportFile is where WriteHashExport puts hashes of one hashcat mode.
+func hashExportFile(mode int) string {
+	switch {
+	case mode == 0:
+		return "unknown_mode_hashes.txt"
+	case strings.HasPrefix(hashModeNames[mode], "AS-REP"):
+		return fmt.Sprintf("asrep_%d.txt", mode)
+	case strings.HasPrefix(hashModeNames[mode], "Timeroast"):
+		return fmt.Sprintf("timeroast_%d.txt", mode)
+	default:
+		return fmt.Sprintf("kerberoast_%d.txt", mode)
+	}
+}
 
+// WriteHashExport writes every captured roasting hash under dir, one file
+// per hashcat mode (kerberoast_13100.txt, asrep_18200.txt, ...), so each
+// file can be fed to hashcat as is, plus a cracking guide.
+func WriteHashExport(dir string, results Results) error {
+	byMode := make(map[int][]string)
+	seen := make(map[string]bool)
+	add := func(mode int, hash string) {
+		if hash == "" || seen[hash] {
+			return
+		}
+		seen[hash] = true
+		byMode[mode] = append(byMode[mode], hash)
+	}
 	for _, candidate := range results.Candidates {
-		if candidate.Hash != "" {
-			switch candidate.Type {
-			case "ASREP":
-				asrepHashes = append(asrepHashes, candidate.Hash)
-			case "KERBEROAST":
-				kerberoastHashes = append(kerberoastHashes, candidate.Hash)
+		for _, record := range candidate.Hashes {
+			if record.OK() {
+				add(record.HashcatMode, record.Hash)
 			}
 		}
+		add(krb.HashcatModeOf(candidate.Hash), candidate.Hash)
 	}
-
-	// Write AS-REP hashes
-	if len(asrepHashes) > 0 {
-		asrepFile := filepath.Join(hashDir, "asrep_hashes.txt")
-		if err := writeHashFile(asrepFile, asrepHashes, "AS-REP Roasting"); err != nil {
-			return fmt.Errorf("failed to write AS-REP hashes: %v", err)
-		}
-		log.Printf("[+] Exported %d AS-REP hashes to %s", len(asrepHashes), asrepFile)
+	for _, h := range results.Advanced.Timeroast {
+		add(krb.HashcatModeOf(h.Hash), h.Hash)
 	}
-
-	// Write Kerberoast hashes
-	if len(kerberoastHashes) > 0 {
-		kerbFile := filepath.Join(hashDir, "kerberoast_hashes.txt")
-		if err := writeHashFile(kerbFile, kerberoastHashes, "Kerberoasting"); err != nil {
-			return fmt.Errorf("failed to write Kerberoast hashes: %v", err)
-		}
-		log.Printf("[+] Exported %d Kerberoast hashes to %s", len(kerberoastHashes), kerbFile)
+	if len(byMode) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
 	}
 
-	// Write cracking instructions
-	if len(asrepHashes) > 0 || len(kerberoastHashes) > 0 {
-		readmePath := filepath.Join(hashDir, "CRACKING_GUIDE.txt")
-		if err := writeCrackingGuide(readmePath, len(asrepHashes), len(kerberoastHashes)); err != nil {
-			return fmt.Errorf("failed to write cracking guide: %v", err)
+	modes := make([]int, 0, len(byMode))
+	for mode := range byMode {
+		modes = append(modes, mode)
+	}
+	sort.Ints(modes)
+	counts := make(map[int]int, len(modes))
+	for _, mode := range modes {
+		path := filepath.Join(dir, hashExportFile(mode))
+		title := hashModeNames[mode]
+		if title == "" {
+			title = "Unrecognised etype"
 		}
+		if err := writeHashFile(path, byMode[mode], title, mode); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		counts[mode] = len(byMode[mode])
+		log.Printf("[+] Exported %d %s hashes to %s", counts[mode], title, path)
 	}
-
-	return nil
+	return writeCrackingGuide(filepath.Join(dir, "CRACKING_GUIDE.txt"), modes, counts)
 }
 
 // writeHashFile writes hashes to a file with proper formatting
-func writeHashFile(filePath string, hashes []string, attackType string) error {
-	file, err := os.Create(filePath)
+func writeHashFile(filePath string, hashes []string, title string, mode int) error {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	// Write header
-	fmt.Fprintf(file, "# %s Hash Export\n", attackType)
+	fmt.Fprintf(file, "# %s Hash Export\n", title)
+	if mode != 0 {
+		fmt.Fprintf(file, "# hashcat -m %d\n", mode)
+	}
 	fmt.Fprintf(file, "# Generated by COLD-RELAY v1.0.0\n")
 	fmt.Fprintf(file, "# WARNING: For authorized security testing only!\n")
 	fmt.Fprintf(file, "# Total hashes: %d\n", len(hashes))
@@ -205,58 +261,50 @@ func writeHashFile(filePath string, hashes []string, attackType string) error {
 }
 
 // writeCrackingGuide creates a guide for hash cracking
-func writeCrackingGuide(filePath string, asrepCount, kerbCount int) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+func writeCrackingGuide(filePath string, modes []int, counts map[int]int) error {
+	var summary, commands strings.Builder
+	for _, mode := range modes {
+		name := hashExportFile(mode)
+		fmt.Fprintf(&summary, "- %s: %d (in %s)\n", hashModeNames[mode], counts[mode], name)
+		if mode == 0 {
+			continue
+		}
+		fmt.Fprintf(&commands, "%s (mode %d):\nhashcat -m %d %s /usr/share/wordlists/rockyou.txt -o cracked_%d.pot\n\n",
+			hashModeNames[mode], mode, mode, name, mode)
 	}
-	defer file.Close()
 
 	guide := fmt.Sprintf(`KERBEROS HASH CRACKING GUIDE
 ============================
 Generated by COLD-RELAY v1.0.0
 
 HASH SUMMARY:
-- AS-REP hashes: %d (in asrep_hashes.txt)
-- Kerberoast hashes: %d (in kerberoast_hashes.txt)
-
+%s
 HASHCAT COMMANDS:
 ================
 
-AS-REP Roasting (mode 18200):
-hashcat -m 18200 asrep_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_asrep.pot
-
-Kerberoasting (mode 13100):
-hashcat -m 13100 kerberoast_hashes.txt /usr/share/wordlists/rockyou.txt -o cracked_kerberoast.pot
+%sRC4-HMAC hashes (13100, 18200) are keyed by the NT hash and crack orders of
+magnitude faster than the AES ones (19600/19700, 32100/32200).
 
 JOHN THE RIPPER COMMANDS:
 ========================
 
-AS-REP Roasting:
-john --format=krb5asrep asrep_hashes.txt --wordlist=/usr/share/wordlists/rockyou.txt
-
-Kerberoasting:
-john --format=krb5tgs kerberoast_hashes.txt --wordlist=/usr/share/wordlists/rockyou.txt
+john --format=krb5tgs kerberoast_13100.txt --wordlist=/usr/share/wordlists/rockyou.txt
+john --format=krb5asrep asrep_18200.txt --wordlist=/usr/share/wordlists/rockyou.txt
 
 ADDITIONAL OPTIONS:
 ==================
 
 # Use GPU acceleration (if available)
-hashcat -m 18200 asrep_hashes.txt rockyou.txt -O -w 3
-
-# Use custom wordlist
-hashcat -m 13100 kerberoast_hashes.txt /path/to/custom/wordlist.txt
+hashcat -m 13100 kerberoast_13100.txt rockyou.txt -O -w 3
 
 # Show cracked passwords
-hashcat -m 18200 asrep_hashes.txt --show
-john --format=krb5asrep asrep_hashes.txt --show
+hashcat -m 13100 kerberoast_13100.txt --show
 
 WARNING: Only use these commands on systems you own or have explicit 
 written permission to test. Unauthorized access is illegal!
-`, asrepCount, kerbCount)
+`, summary.String(), commands.String())
 
-	_, err = file.WriteString(guide)
-	return err
+	return os.WriteFile(filePath, []byte(guide), 0600)
 }
 
 func WriteSigmaRules(path string, results Results) error {
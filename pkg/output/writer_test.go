@@ -162,3 +162,43 @@ func TestExtractSeverity(t *testing.T) {
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) && strings.Contains(s, substr)
 }
+
+func TestWriteHashExportSplitsByMode(t *testing.T) {
+	results := Results{Candidates: []krb.Candidate{
+		{SamAccountName: "svc", Type: "KERBEROAST", Hash: "$krb5tgs$23$*svc$CORP$HTTP/a*$00$11", Hashes: []krb.HashRecord{
+			{SPN: "HTTP/a", Etype: 23, HashcatMode: 13100, Hash: "$krb5tgs$23$*svc$CORP$HTTP/a*$00$11"},
+			{SPN: "HTTP/b", Etype: 18, HashcatMode: 19700, Hash: "$krb5tgs$18$svc$CORP$*HTTP/b*$00$11"},
+			{SPN: "HTTP/c", ErrorCode: 7, Error: "KDC_ERR_S_PRINCIPAL_UNKNOWN"},
+		}},
+		{SamAccountName: "bob", Type: "ASREP", Hash: "$krb5asrep$23$bob@CORP:00$11"},
+	}}
+	results.Advanced.Timeroast = []krb.TimeroastHash{{RID: 1103, Account: "WS01$", Hash: "$sntp-ms$00$11"}}
+	dir := filepath.Join(t.TempDir(), "hashes")
+	if err := WriteHashExport(dir, results); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"kerberoast_13100.txt": "$krb5tgs$23$*svc$CORP$HTTP/a*$00$11",
+		"kerberoast_19700.txt": "$krb5tgs$18$svc$CORP$*HTTP/b*$00$11",
+		"asrep_18200.txt":      "$krb5asrep$23$bob@CORP:00$11",
+		"timeroast_31300.txt":  "$sntp-ms$00$11",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		var hashes []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" && !strings.HasPrefix(line, "#") {
+				hashes = append(hashes, line)
+			}
+		}
+		if len(hashes) != 1 || hashes[0] != want {
+			t.Errorf("%s holds %v, want only %s", file, hashes, want)
+		}
+	}
+	guide, err := os.ReadFile(filepath.Join(dir, "CRACKING_GUIDE.txt"))
+	if err != nil || !strings.Contains(string(guide), "hashcat -m 19700 kerberoast_19700.txt") {
+		t.Fatalf("cracking guide missing the AES256 command: %v", err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
 
 func TestFileRunStoreSave(t *testing.T) {
@@ -33,3 +36,51 @@ func TestFileRunStoreSaveMissingID(t *testing.T) {
 		t.Fatal("expected error for missing ID")
 	}
 }
+
+func TestSnapshotRoundTripAndFindingDiff(t *testing.T) {
+	store := NewFileRunStore(t.TempDir())
+	if snap, err := store.LoadSnapshot("CORP.LOCAL"); err != nil || snap != nil {
+		t.Fatalf("expected no snapshot yet, got %v, %v", snap, err)
+	}
+
+	point := krb.SyncPoint{DSA: "CN=NTDS Settings,CN=DC01,CN=Servers,CN=Default-First-Site-Name,CN=Sites,CN=Configuration,DC=corp,DC=local", InvocationID: "ab", USN: 1200}
+	dir := &ingest.Directory{Users: []ingest.User{{SamAccountName: "svc_sql", DistinguishedName: "CN=svc_sql,CN=Users,DC=corp,DC=local"}}}
+	err := store.SaveSnapshot(Snapshot{
+		Domain:     "CORP.LOCAL",
+		SyncPoints: map[string]krb.SyncPoint{point.DSA: point},
+		Directory:  dir,
+		Candidates: []krb.Candidate{
+			{SamAccountName: "svc_sql", Type: "KERBEROAST", Score: 60},
+			{SamAccountName: "old_svc", Type: "KERBEROAST", Score: 60},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	snap, err := store.LoadSnapshot("corp.local")
+	if err != nil || snap == nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if _, ok := snap.Directory.LookupSAM("svc_sql"); !ok {
+		t.Fatal("snapshot directory was not reindexed")
+	}
+	if _, ok := snap.SyncPointFor(krb.SyncPoint{DSA: point.DSA, InvocationID: "ab", USN: 1500}); !ok {
+		t.Fatal("expected stored sync point to match the same DC")
+	}
+	if _, ok := snap.SyncPointFor(krb.SyncPoint{DSA: point.DSA, InvocationID: "cd", USN: 1500}); ok {
+		t.Fatal("a restored DC (new invocationId) must not resume from an old USN")
+	}
+
+	current := []krb.Candidate{
+		{SamAccountName: "svc_sql", Type: "KERBEROAST", Score: 80},
+		{SamAccountName: "jdoe", Type: "ASREP", Score: 70},
+	}
+	newCount, changedCount, gone := DiffFindings(snap.Candidates, current)
+	if newCount != 1 || changedCount != 1 || len(gone) != 1 || gone[0].SamAccountName != "old_svc" {
+		t.Fatalf("unexpected diff: new=%d changed=%d gone=%+v", newCount, changedCount, gone)
+	}
+	if current[0].Delta != "changed" || current[1].Delta != "new" {
+		t.Fatalf("candidates not marked: %+v", current)
+	}
+}
@@ -0,0 +1,149 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
+)
+
+// Snapshot is the collected state of a domain kept between incremental runs:
+// the directory, the findings derived from it and the replication position of
+// every DC it has been synchronised from.
+type Snapshot struct {
+	Domain     string                   `json:"domain"`
+	RunID      string                   `json:"run_id"`
+	SyncPoints map[string]krb.SyncPoint `json:"sync_points"` // keyed by DSA DN
+	Directory  *ingest.Directory        `json:"directory"`
+	Candidates []krb.Candidate          `json:"candidates"`
+}
+
+// SyncPointFor returns the stored position for the DC described by cur, if
+// a delta can be requested from it.
+func (s *Snapshot) SyncPointFor(cur krb.SyncPoint) (krb.SyncPoint, bool) {
+	if s == nil || s.Directory == nil {
+		return krb.SyncPoint{}, false
+	}
+	prev, ok := s.SyncPoints[cur.DSA]
+	return prev, ok && prev.Matches(cur)
+}
+
+func (s *FileRunStore) snapshotPath(domain string) string {
+	return filepath.Join(s.baseDir, "snapshots", strings.ToLower(domain)+".json")
+}
+
+// LoadSnapshot returns the stored snapshot for domain, or nil when there is
+// none yet.
+func (s *FileRunStore) LoadSnapshot(domain string) (*Snapshot, error) {
+	if s == nil || s.baseDir == "" {
+		return nil, fmt.Errorf("run store directory is empty")
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("snapshot domain is required")
+	}
+	data, err := os.ReadFile(s.snapshotPath(domain))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Directory != nil {
+		snap.Directory.Reindex()
+	}
+	return &snap, nil
+}
+
+// SaveSnapshot writes the snapshot for snap.Domain, replacing the previous one.
+func (s *FileRunStore) SaveSnapshot(snap Snapshot) error {
+	if s == nil || s.baseDir == "" {
+		return fmt.Errorf("run store directory is empty")
+	}
+	if snap.Domain == "" {
+		return fmt.Errorf("snapshot domain is required")
+	}
+	if snap.RunID == "" {
+		snap.RunID = time.Now().UTC().Format("20060102T150405Z")
+	}
+	path := s.snapshotPath(snap.Domain)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create snapshot dir: %w", err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+	// Write then rename so an interrupted run never leaves a torn snapshot.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+// DiffFindings marks each current candidate as new or changed relative to
+// previous and returns the previous candidates that no longer appear.
+func DiffFindings(previous, current []krb.Candidate) (newCount, changedCount int, gone []krb.Candidate) {
+	prev := make(map[string]krb.Candidate, len(previous))
+	for _, c := range previous {
+		prev[findingKey(c)] = c
+	}
+	seen := make(map[string]bool, len(current))
+	for i := range current {
+		key := findingKey(current[i])
+		seen[key] = true
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			current[i].Delta = "new"
+			newCount++
+		case findingChanged(old, current[i]):
+			current[i].Delta = "changed"
+			changedCount++
+		default:
+			current[i].Delta = ""
+		}
+	}
+	for _, c := range previous {
+		if key := findingKey(c); !seen[key] {
+			seen[key] = true
+			c.Delta = "gone"
+			gone = append(gone, c)
+		}
+	}
+	return newCount, changedCount, gone
+}
+
+func findingKey(c krb.Candidate) string {
+	return strings.ToLower(c.Type + "|" + c.Domain + "|" + c.SamAccountName)
+}
+
+// findingChanged compares what an analyst acts on; hashes and timestamps
+// differ between runs without the exposure changing.
+func findingChanged(a, b krb.Candidate) bool {
+	return a.Score != b.Score ||
+		a.Validation != b.Validation ||
+		sortedJoin(a.Reasons) != sortedJoin(b.Reasons) ||
+		sortedJoin(a.SPNs) != sortedJoin(b.SPNs) ||
+		sortedJoin(a.MemberOf) != sortedJoin(b.MemberOf)
+}
+
+func sortedJoin(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\n")
+}
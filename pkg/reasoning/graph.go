@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/advanced"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/discovery"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
@@ -16,6 +17,9 @@ type BuildContext struct {
 	CurrentUser string   `json:"current_user,omitempty"`
 	Mode        string   `json:"mode,omitempty"`
 	Services    []string `json:"services,omitempty"`
+	// Discovered carries the fingerprints behind Services; each becomes the
+	// properties of its service node.
+	Discovered []discovery.Service `json:"-"`
 }
 
 type Graph struct {
@@ -70,6 +74,22 @@ type builder struct {
 	nodes map[string]Node
 	edges map[string]Edge
 	paths []AttackPath
+	// domain is the primary domain; accounts from other forest domains get
+	// domain-qualified node IDs.
+	domain string
+}
+
+// kdcRefusals lists the KDC errors of roasting attempts, empty unless the
+// KDC answered every attempt with a KRB-ERROR.
+func kdcRefusals(records []krb.HashRecord) []string {
+	var refusals []string
+	for _, r := range records {
+		if r.OK() || r.ErrorCode == 0 {
+			return nil
+		}
+		refusals = append(refusals, fmt.Sprintf("%s: KDC refused with %s (%d)", r.SPN, r.ErrorName, r.ErrorCode))
+	}
+	return refusals
 }
 
 func AnnotateCandidates(candidates []krb.Candidate) []krb.Candidate {
@@ -96,6 +116,10 @@ func AnnotateCandidates(candidates []krb.Candidate) []krb.Candidate {
 				krb.SetCandidateValidation(&out[i], krb.StatusValidated,
 					[]string{"KDC returned a service ticket and a crackable TGS hash was captured."}, nil,
 					[]string{"Export hash material and attempt offline cracking."})
+			} else if refusals := kdcRefusals(out[i].Hashes); len(refusals) > 0 {
+				krb.SetCandidateValidation(&out[i], krb.StatusBlocked,
+					[]string{"Service tickets were requested for every SPN."}, refusals,
+					[]string{"Check the SPN registrations and the account's encryption types against the KDC errors."})
 			} else {
 				krb.SetCandidateValidation(&out[i], krb.StatusTheoretical,
 					[]string{"LDAP shows one or more SPNs on an enabled user account."},
@@ -150,8 +174,9 @@ func addHeuristicGuardrail(candidate *krb.Candidate) {
 
 func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidate, advResults map[string]interface{}) Graph {
 	b := &builder{
-		nodes: make(map[string]Node),
-		edges: make(map[string]Edge),
+		nodes:  make(map[string]Node),
+		edges:  make(map[string]Edge),
+		domain: ctx.Domain,
 	}
 	b.addNode("session:active", "session_state", "likely active session", nil)
 	b.addNode("privilege:protected", "privilege", "protected privileged object", nil)
@@ -188,12 +213,20 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 		}
 	}
 
+	fingerprints := make(map[string]discovery.Service, len(ctx.Discovered))
+	for _, svc := range ctx.Discovered {
+		fingerprints[svc.String()] = svc
+	}
 	for _, service := range ctx.Services {
 		serviceID, serviceName := serviceNode(ctx.Target, service)
 		if serviceID == "" {
 			continue
 		}
-		b.addNode(serviceID, "service", serviceName, map[string]interface{}{"raw": service})
+		props := map[string]interface{}{"raw": service}
+		if svc, ok := fingerprints[service]; ok {
+			props = svc.Properties()
+		}
+		b.addNode(serviceID, "service", serviceName, props)
 		if ctx.Target != "" {
 			b.addEdge(targetID, serviceID, "exposes_service", krb.StatusValidated,
 				[]string{"TCP connect succeeded during protocol discovery."}, nil)
@@ -205,9 +238,13 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 		if user.SamAccountName == "" {
 			continue
 		}
-		uid := principalID(user.SamAccountName)
-		userByName[strings.ToLower(user.SamAccountName)] = user
+		userDomain := ingest.DomainFromDN(user.DistinguishedName)
+		uid := b.principalFor(user.SamAccountName, userDomain)
+		if !b.foreign(userDomain) {
+			userByName[strings.ToLower(user.SamAccountName)] = user
+		}
 		b.addNode(uid, "principal", user.SamAccountName, map[string]interface{}{
+			"domain":             userDomain,
 			"distinguished_name": user.DistinguishedName,
 			"disabled":           user.UserAccountControl&0x2 != 0,
 			"spn_count":          len(user.ServicePrincipalNames),
@@ -219,6 +256,7 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 			b.addEdge(uid, gid, "member_of", krb.StatusValidated,
 				[]string{"LDAP memberOf attribute returned this group."}, nil)
 		}
+		b.addEffectiveGroups(uid, user.EffectiveGroups)
 		for _, spn := range user.ServicePrincipalNames {
 			sid := spnID(spn)
 			b.addNode(sid, "spn", spn, nil)
@@ -228,13 +266,14 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 	}
 
 	for _, candidate := range candidates {
-		findingID := findingID(candidate)
+		findingID := b.findingFor(candidate)
 		b.addNode(findingID, "finding", candidate.Type+" "+candidate.SamAccountName, map[string]interface{}{
+			"domain":     candidate.Domain,
 			"type":       candidate.Type,
 			"validation": candidate.Validation,
 			"reasons":    candidate.Reasons,
 		})
-		fromID := principalID(candidate.SamAccountName)
+		fromID := b.principalFor(candidate.SamAccountName, candidate.Domain)
 		if candidate.Type == "RECON" {
 			fromID = shareID(candidate.SamAccountName)
 			b.addNode(fromID, "share", candidate.SamAccountName, map[string]interface{}{"source": "recon_candidate"})
@@ -246,6 +285,7 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 		b.addCandidatePath(candidate)
 	}
 
+	b.addDirectory(users, advResults)
 	b.addShares(ctx, advResults)
 	b.addSessions(userByName, advResults)
 	b.addACLObjects(advResults)
@@ -258,6 +298,228 @@ func BuildGraph(ctx BuildContext, users []ingest.User, candidates []krb.Candidat
 	return b.graph()
 }
 
+// addDirectory adds groups, computers and ACE-bearing objects from a full
+// directory collection (e.g. an imported SharpHound archive). Users are
+// already handled by BuildGraph.
+func (b *builder) addDirectory(users []ingest.User, advResults map[string]interface{}) {
+	dir := asDirectory(advResults["directory"])
+	if dir == nil {
+		return
+	}
+	for _, group := range dir.Groups {
+		if group.DistinguishedName == "" {
+			continue
+		}
+		gid := groupID(group.DistinguishedName)
+		b.addNode(gid, "group", firstNonEmpty(group.SamAccountName, displayName(group.DistinguishedName)), map[string]interface{}{
+			"domain":      ingest.DomainFromDN(group.DistinguishedName),
+			"dn":          group.DistinguishedName,
+			"sid":         group.ObjectSID,
+			"admin_count": group.AdminCount,
+		})
+		for _, parent := range group.MemberOf {
+			pid := groupID(parent)
+			b.addNode(pid, "group", displayName(parent), map[string]interface{}{"dn": parent})
+			b.addEdge(gid, pid, "member_of", krb.StatusValidated,
+				[]string{"Collected group membership lists this group as a nested member."}, nil)
+		}
+		if group.AdminCount {
+			b.addEdge(gid, "privilege:protected", "marked_privileged", krb.StatusValidated,
+				[]string{"Collected adminCount marks this group as protected."}, nil)
+		}
+	}
+	for _, computer := range dir.Computers {
+		if computer.SamAccountName == "" {
+			continue
+		}
+		computerDomain := ingest.DomainFromDN(computer.DistinguishedName)
+		cid := b.computerFor(computer.SamAccountName, computerDomain)
+		props := map[string]interface{}{
+			"domain":                   computerDomain,
+			"distinguished_name":       computer.DistinguishedName,
+			"sid":                      computer.ObjectSID,
+			"dns_host_name":            computer.DNSHostName,
+			"operating_system":         computer.OperatingSystem,
+			"disabled":                 computer.UserAccountControl&0x2 != 0,
+			"unconstrained_delegation": computer.UnconstrainedDelegation(),
+			"protocol_transition":      computer.ProtocolTransition(),
+			"laps":                     computer.HasLAPS(),
+		}
+		if computer.CreatorSID != "" {
+			props["creator_sid"] = computer.CreatorSID
+		}
+		if !computer.LastLogonTimestamp.IsZero() {
+			props["last_logon_timestamp"] = computer.LastLogonTimestamp
+		}
+		b.addNode(cid, "computer", firstNonEmpty(computer.DNSHostName, computer.SamAccountName), props)
+		for _, group := range computer.MemberOf {
+			gid := groupID(group)
+			b.addNode(gid, "group", displayName(group), map[string]interface{}{"dn": group})
+			b.addEdge(cid, gid, "member_of", krb.StatusValidated,
+				[]string{"Collected group membership lists this computer."}, nil)
+		}
+		b.addEffectiveGroups(cid, computer.EffectiveGroups)
+		for _, spn := range computer.ServicePrincipalNames {
+			sid := spnID(spn)
+			b.addNode(sid, "spn", spn, nil)
+			b.addEdge(cid, sid, "owns_spn", krb.StatusValidated,
+				[]string{"Collected servicePrincipalName attribute lists this SPN."}, nil)
+		}
+		for _, target := range computer.AllowedToDelegateTo {
+			sid := spnID(target)
+			b.addNode(sid, "spn", target, nil)
+			b.addEdge(cid, sid, "allowed_to_delegate", krb.StatusLikely,
+				[]string{"Collected msDS-AllowedToDelegateTo lists this service."}, nil)
+		}
+	}
+	b.addSPNHosts(dir, users)
+	for _, gpo := range dir.GPOs {
+		b.addNode(gpoID(gpo.Name, gpo.DisplayName), "gpo", firstNonEmpty(gpo.DisplayName, gpo.Name), map[string]interface{}{
+			"cn":              gpo.Name,
+			"dn":              gpo.DistinguishedName,
+			"filesystem_path": gpo.FileSysPath,
+		})
+	}
+	linkGPOs := func(from string, links []ingest.GPLink) {
+		for _, link := range links {
+			gpo := dir.GPOByDN(link.GPODN)
+			if gpo == nil || link.Disabled {
+				continue
+			}
+			b.addEdge(from, gpoID(gpo.Name, gpo.DisplayName), "gp_link", krb.StatusValidated,
+				[]string{"Collected gPLink attribute links this GPO."}, map[string]interface{}{"enforced": link.Enforced})
+		}
+	}
+	if dir.Domain != "" {
+		linkGPOs(domainID(dir.Domain), dir.DomainGPLinks)
+	}
+	for _, name := range dir.Domains {
+		if !b.foreign(name) {
+			continue
+		}
+		b.addNode(domainID(name), "domain", name, nil)
+		b.addEdge(domainID(b.domain), domainID(name), "same_forest", krb.StatusValidated,
+			[]string{"Forest Partitions container lists this domain."}, nil)
+	}
+	for _, ou := range dir.OUs {
+		if ou.DistinguishedName == "" {
+			continue
+		}
+		oid := ouID(ou.DistinguishedName)
+		b.addNode(oid, "ou", firstNonEmpty(ou.Name, displayName(ou.DistinguishedName)), map[string]interface{}{
+			"dn":                 ou.DistinguishedName,
+			"blocks_inheritance": ou.BlocksInheritance,
+		})
+		linkGPOs(oid, ou.GPLinks)
+		if parent := dir.OUByDN(ingest.ParentDN(ou.DistinguishedName)); parent != nil {
+			b.addNode(ouID(parent.DistinguishedName), "ou", firstNonEmpty(parent.Name, displayName(parent.DistinguishedName)), nil)
+			b.addEdge(ouID(parent.DistinguishedName), oid, "contains", krb.StatusValidated,
+				[]string{"OU is nested in its parent container."}, nil)
+		}
+	}
+	if len(dir.OUs) > 0 {
+		contains := func(dn, child string) {
+			if ou := dir.OUByDN(ingest.ParentDN(dn)); ou != nil {
+				b.addEdge(ouID(ou.DistinguishedName), child, "contains", krb.StatusValidated,
+					[]string{"Object DN places it in this OU."}, nil)
+			}
+		}
+		for _, user := range dir.Users {
+			if user.SamAccountName != "" {
+				contains(user.DistinguishedName, b.principalFor(user.SamAccountName, ingest.DomainFromDN(user.DistinguishedName)))
+			}
+		}
+		for _, computer := range dir.Computers {
+			if computer.SamAccountName != "" {
+				contains(computer.DistinguishedName, b.computerFor(computer.SamAccountName, ingest.DomainFromDN(computer.DistinguishedName)))
+			}
+		}
+	}
+
+	// ACL control edges reference objects by DN; make sure both ends exist.
+	for _, ace := range dir.ACEs {
+		if ace.TargetDN == "" {
+			continue
+		}
+		b.addNode(objectID(ace.TargetDN), "directory_object", displayName(ace.TargetDN), map[string]interface{}{
+			"dn":          ace.TargetDN,
+			"object_type": ace.TargetType,
+		})
+		if ace.PrincipalDN != "" {
+			b.addNode(objectID(ace.PrincipalDN), "directory_object", displayName(ace.PrincipalDN), map[string]interface{}{
+				"dn":          ace.PrincipalDN,
+				"object_type": ace.PrincipalType,
+			})
+		}
+	}
+}
+
+// addEffectiveGroups records resolved memberships on a principal node and
+// adds the primary-group link, which memberOf never lists. Nested memberships
+// are already reachable through the group-to-group member_of edges.
+func (b *builder) addEffectiveGroups(id string, memberships []ingest.GroupMembership) {
+	if len(memberships) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(memberships))
+	for _, m := range memberships {
+		paths = append(paths, m.Path())
+		if m.Primary && !m.Nested() {
+			gid := groupID(m.DN)
+			b.addNode(gid, "group", firstNonEmpty(m.Name, displayName(m.DN)), map[string]interface{}{"dn": m.DN})
+			b.addEdge(id, gid, "member_of", krb.StatusValidated,
+				[]string{"primaryGroupID designates this group."}, map[string]interface{}{"primary": true})
+		}
+	}
+	b.addNode(id, "", "", map[string]interface{}{"effective_groups": paths})
+}
+
+// addSPNHosts links each SPN node to the computer named by its host part, so
+// service accounts attach to the machines their services run on.
+func (b *builder) addSPNHosts(dir *ingest.Directory, users []ingest.User) {
+	hosts := make(map[string]string)
+	for _, computer := range dir.Computers {
+		if computer.SamAccountName == "" {
+			continue
+		}
+		cid := b.computerFor(computer.SamAccountName, ingest.DomainFromDN(computer.DistinguishedName))
+		hosts[strings.ToLower(strings.TrimSuffix(computer.SamAccountName, "$"))] = cid
+		if computer.DNSHostName != "" {
+			hosts[strings.ToLower(computer.DNSHostName)] = cid
+		}
+	}
+	if len(hosts) == 0 {
+		return
+	}
+	link := func(spns []string) {
+		for _, spn := range spns {
+			if cid, ok := hosts[spnHost(spn)]; ok {
+				b.addEdge(spnID(spn), cid, "hosted_on", krb.StatusLikely,
+					[]string{"SPN host component matches a collected computer name."}, nil)
+			}
+		}
+	}
+	for _, user := range users {
+		link(user.ServicePrincipalNames)
+	}
+	for _, computer := range dir.Computers {
+		link(computer.ServicePrincipalNames)
+	}
+}
+
+// spnHost returns the lower-cased host of service/host[:port][/name].
+func spnHost(spn string) string {
+	i := strings.Index(spn, "/")
+	if i < 0 {
+		return ""
+	}
+	host := spn[i+1:]
+	if j := strings.IndexAny(host, ":/"); j >= 0 {
+		host = host[:j]
+	}
+	return strings.ToLower(host)
+}
+
 func (b *builder) addShares(ctx BuildContext, advResults map[string]interface{}) {
 	shares := asStringSlice(advResults["shares"])
 	for _, share := range shares {
@@ -330,7 +592,7 @@ func (b *builder) addSessions(userByName map[string]ingest.User, advResults map[
 				"last_logon_timestamp": session.LastLogonTimestamp,
 				"logon_count":          session.LogonCount,
 			})
-		if user, ok := userByName[strings.ToLower(session.SamAccountName)]; ok && hasPrivilegedGroup(user.MemberOf) {
+		if user, ok := userByName[strings.ToLower(session.SamAccountName)]; ok && hasPrivilegedGroup(user.EffectiveGroupDNs()) {
 			b.paths = append(b.paths, AttackPath{
 				Title:      fmt.Sprintf("Privileged active-session lead: %s", session.SamAccountName),
 				Severity:   "high",
@@ -599,7 +861,7 @@ func (b *builder) addCandidatePath(candidate krb.Candidate) {
 			Evidence:   candidate.Evidence,
 			Blockers:   candidate.Blockers,
 			Steps: []PathStep{
-				{From: principalID(candidate.SamAccountName), To: findingID(candidate), Action: "Validate no-preauth KDC response", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
+				{From: b.principalFor(candidate.SamAccountName, candidate.Domain), To: b.findingFor(candidate), Action: "Validate no-preauth KDC response", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
 			},
 		})
 	case "KERBEROAST":
@@ -610,7 +872,7 @@ func (b *builder) addCandidatePath(candidate krb.Candidate) {
 			Evidence:   candidate.Evidence,
 			Blockers:   candidate.Blockers,
 			Steps: []PathStep{
-				{From: principalID(candidate.SamAccountName), To: findingID(candidate), Action: "Validate TGS request and hash capture", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
+				{From: b.principalFor(candidate.SamAccountName, candidate.Domain), To: b.findingFor(candidate), Action: "Validate TGS request and hash capture", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
 			},
 		})
 	case "HVT":
@@ -621,7 +883,7 @@ func (b *builder) addCandidatePath(candidate krb.Candidate) {
 			Evidence:   candidate.Evidence,
 			Blockers:   candidate.Blockers,
 			Steps: []PathStep{
-				{From: principalID(candidate.SamAccountName), To: "privilege:domain", Action: "Treat as high-value objective, not an access path", Validation: krb.StatusTheoretical, Evidence: candidate.Evidence},
+				{From: b.principalFor(candidate.SamAccountName, candidate.Domain), To: "privilege:domain", Action: "Treat as high-value objective, not an access path", Validation: krb.StatusTheoretical, Evidence: candidate.Evidence},
 			},
 		})
 	case "LOOT":
@@ -631,12 +893,40 @@ func (b *builder) addCandidatePath(candidate krb.Candidate) {
 			Validation: safeStatus(candidate.Validation),
 			Evidence:   candidate.Evidence,
 			Steps: []PathStep{
-				{From: findingID(candidate), To: principalID(candidate.SamAccountName), Action: "Manually verify secret and map reuse scope", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
+				{From: b.findingFor(candidate), To: b.principalFor(candidate.SamAccountName, candidate.Domain), Action: "Manually verify secret and map reuse scope", Validation: safeStatus(candidate.Validation), Evidence: candidate.Evidence},
 			},
 		})
 	}
 }
 
+// foreign reports whether domain is set and differs from the primary domain.
+func (b *builder) foreign(domain string) bool {
+	return domain != "" && b.domain != "" && !strings.EqualFold(domain, b.domain)
+}
+
+// principalFor returns an account's node ID, qualified with its domain when
+// it belongs to another forest domain so same-named accounts stay distinct.
+func (b *builder) principalFor(name, domain string) string {
+	if b.foreign(domain) {
+		return principalID(name + "@" + domain)
+	}
+	return principalID(name)
+}
+
+func (b *builder) computerFor(name, domain string) string {
+	if b.foreign(domain) {
+		return computerID(name + "@" + domain)
+	}
+	return computerID(name)
+}
+
+func (b *builder) findingFor(candidate krb.Candidate) string {
+	if b.foreign(candidate.Domain) {
+		candidate.SamAccountName += "@" + candidate.Domain
+	}
+	return findingID(candidate)
+}
+
 func (b *builder) addNode(id, typ, name string, props map[string]interface{}) {
 	if id == "" {
 		return
@@ -737,6 +1027,14 @@ func asStringSlice(value interface{}) []string {
 	return items
 }
 
+func asDirectory(value interface{}) *ingest.Directory {
+	dir, ok := value.(*ingest.Directory)
+	if !ok {
+		return nil
+	}
+	return dir
+}
+
 func asFileFindings(value interface{}) []advanced.FileFinding {
 	items, ok := value.([]advanced.FileFinding)
 	if !ok {
@@ -1027,6 +1325,10 @@ func groupID(name string) string {
 	return "group:" + key(name)
 }
 
+func computerID(name string) string {
+	return "computer:" + key(name)
+}
+
 func spnID(spn string) string {
 	return "spn:" + key(spn)
 }
@@ -1062,6 +1364,10 @@ func lapsID(name string) string {
 	return "managed_credential:" + key(name)
 }
 
+func ouID(dn string) string {
+	return "ou:" + key(dn)
+}
+
 func gpoID(cn, displayName string) string {
 	return "gpo:" + key(cn+"|"+displayName)
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/advanced"
+	"github.com/thechosenone-shall-prevail/cold-relay/pkg/discovery"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/ingest"
 	"github.com/thechosenone-shall-prevail/cold-relay/pkg/krb"
 )
@@ -14,6 +15,9 @@ func TestAnnotateCandidates(t *testing.T) {
 		{SamAccountName: "svc_sql", Type: "KERBEROAST", SPNs: []string{"MSSQLSvc/sql.local"}},
 		{SamAccountName: "asrep_user", Type: "ASREP", Hash: "$krb5asrep$23$user@REALM:abcd"},
 		{SamAccountName: "Administrator", Type: "HVT"},
+		{SamAccountName: "svc_old", Type: "KERBEROAST", SPNs: []string{"HTTP/gone.local"}, Hashes: []krb.HashRecord{
+			{SPN: "HTTP/gone.local", ErrorCode: 7, ErrorName: "KDC_ERR_S_PRINCIPAL_UNKNOWN"},
+		}},
 	}
 
 	annotated := AnnotateCandidates(candidates)
@@ -30,6 +34,9 @@ func TestAnnotateCandidates(t *testing.T) {
 	if annotated[2].Validation != krb.StatusLikely {
 		t.Fatalf("expected HVT membership finding to be likely, got %q", annotated[2].Validation)
 	}
+	if annotated[3].Validation != krb.StatusBlocked || len(annotated[3].Blockers) != 1 {
+		t.Fatalf("expected kerberoast refused by the KDC to be blocked, got %q %v", annotated[3].Validation, annotated[3].Blockers)
+	}
 }
 
 func TestBuildGraphConnectsCoreObjects(t *testing.T) {
@@ -166,6 +173,87 @@ func TestBuildGraphConnectsAdvancedModuleOutputs(t *testing.T) {
 	}
 }
 
+func TestBuildGraphAddsCollectedDirectory(t *testing.T) {
+	dir := &ingest.Directory{
+		Groups: []ingest.Group{
+			{
+				SamAccountName:    "Helpdesk",
+				DistinguishedName: "CN=Helpdesk,OU=Groups,DC=corp,DC=local",
+				MemberOf:          []string{"CN=Account Operators,CN=Builtin,DC=corp,DC=local"},
+			},
+		},
+		Computers: []ingest.Computer{
+			{
+				SamAccountName:        "WS01$",
+				DistinguishedName:     "CN=WS01,OU=Workstations,DC=corp,DC=local",
+				DNSHostName:           "ws01.corp.local",
+				UserAccountControl:    0x1000,
+				ServicePrincipalNames: []string{"HOST/ws01.corp.local"},
+				MemberOf:              []string{"CN=Helpdesk,OU=Groups,DC=corp,DC=local"},
+			},
+		},
+		OUs: []ingest.OU{
+			{
+				Name:              "Workstations",
+				DistinguishedName: "OU=Workstations,DC=corp,DC=local",
+				GPLinks:           []ingest.GPLink{{GPODN: "CN={6AC1786C-016F-11D2-945F-00C04FB984F9},CN=Policies,CN=System,DC=corp,DC=local"}},
+			},
+		},
+		GPOs: []ingest.GPO{
+			{
+				Name:              "{6AC1786C-016F-11D2-945F-00C04FB984F9}",
+				DisplayName:       "Workstation Baseline",
+				DistinguishedName: "CN={6AC1786C-016F-11D2-945F-00C04FB984F9},CN=Policies,CN=System,DC=corp,DC=local",
+			},
+		},
+		ACEs: []ingest.ACE{
+			{
+				TargetDN:     "CN=Domain Admins,CN=Users,DC=corp,DC=local",
+				PrincipalSID: "S-1-5-21-1-2-3-1105",
+				PrincipalDN:  "CN=Helpdesk,OU=Groups,DC=corp,DC=local",
+				Right:        "GenericAll",
+			},
+		},
+	}
+
+	users := []ingest.User{{SamAccountName: "svc_sql", ServicePrincipalNames: []string{"MSSQLSvc/WS01.corp.local:1433"}}}
+	graph := BuildGraph(BuildContext{Domain: "CORP.LOCAL", Mode: "offline"}, users, nil, map[string]interface{}{"directory": dir})
+
+	if graph.Summary.NodeCounts["computer"] != 1 {
+		t.Fatalf("expected one computer node, got %#v", graph.Summary.NodeCounts)
+	}
+	var nested, computerMember bool
+	for _, edge := range graph.Edges {
+		if edge.Type != "member_of" {
+			continue
+		}
+		if edge.From == groupID("CN=Helpdesk,OU=Groups,DC=corp,DC=local") {
+			nested = true
+		}
+		if edge.From == computerID("WS01$") {
+			computerMember = true
+		}
+	}
+	if !nested || !computerMember {
+		t.Fatalf("expected nested group and computer membership edges, got %+v", graph.Edges)
+	}
+	if graph.Summary.NodeCounts["directory_object"] == 0 {
+		t.Fatal("expected ACE target objects in graph")
+	}
+	if !hasEdgeType(graph, "gp_link") || !hasEdgeType(graph, "contains") {
+		t.Fatal("expected OU gp_link and contains edges")
+	}
+	var sqlHosted bool
+	for _, edge := range graph.Edges {
+		if edge.Type == "hosted_on" && edge.From == spnID("MSSQLSvc/WS01.corp.local:1433") && edge.To == computerID("WS01$") {
+			sqlHosted = true
+		}
+	}
+	if !sqlHosted {
+		t.Fatalf("expected user SPN to attach to its host computer, got %+v", graph.Edges)
+	}
+}
+
 func hasEdgeType(graph Graph, edgeType string) bool {
 	for _, edge := range graph.Edges {
 		if edge.Type == edgeType {
@@ -174,3 +262,70 @@ func hasEdgeType(graph Graph, edgeType string) bool {
 	}
 	return false
 }
+
+func TestBuildGraphKeepsForestAccountsDistinct(t *testing.T) {
+	dir := &ingest.Directory{Domain: "CORP.LOCAL", Domains: []string{"CORP.LOCAL", "EU.CORP.LOCAL"}}
+	users := []ingest.User{
+		{SamAccountName: "svc_sql", DistinguishedName: "CN=svc_sql,CN=Users,DC=corp,DC=local"},
+		{SamAccountName: "svc_sql", DistinguishedName: "CN=svc_sql,CN=Users,DC=eu,DC=corp,DC=local"},
+	}
+	candidates := AnnotateCandidates([]krb.Candidate{
+		{SamAccountName: "svc_sql", Domain: "EU.CORP.LOCAL", Type: "KERBEROAST", SPNs: []string{"MSSQLSvc/sql.eu.corp.local"}},
+	})
+	graph := BuildGraph(BuildContext{Domain: "CORP.LOCAL"}, users, candidates, map[string]interface{}{"directory": dir})
+
+	if graph.Summary.NodeCounts["principal"] != 2 {
+		t.Fatalf("expected two svc_sql principals, got %#v", graph.Summary.NodeCounts)
+	}
+	var childFinding, sameForest bool
+	for _, edge := range graph.Edges {
+		if edge.From == principalID("svc_sql@EU.CORP.LOCAL") && edge.Type == "has_finding" {
+			childFinding = true
+		}
+		if edge.Type == "same_forest" && edge.To == domainID("EU.CORP.LOCAL") {
+			sameForest = true
+		}
+	}
+	if !childFinding || !sameForest {
+		t.Fatalf("expected child finding and forest edges, got %+v", graph.Edges)
+	}
+}
+
+func TestBuildGraphAttachesServiceFingerprints(t *testing.T) {
+	discovered := []discovery.Service{
+		{Name: "SMB", Port: 445, SMB: &discovery.SMBInfo{Dialects: []string{"2.1", "3.1.1"}, SigningRequired: false}},
+		{Name: "RDP", Port: 3389, RDP: &discovery.RDPInfo{NLARequired: true}},
+	}
+	graph := BuildGraph(BuildContext{
+		Target:     "10.0.0.5",
+		Services:   append(discovery.Names(discovered), "LDAP:389"),
+		Discovered: discovered,
+	}, nil, nil, nil)
+
+	found := 0
+	for _, node := range graph.Nodes {
+		if node.Type != "service" {
+			continue
+		}
+		switch node.Name {
+		case "SMB:445":
+			found++
+			if node.Properties["smb_signing_required"] != false || node.Properties["port"] != 445 {
+				t.Fatalf("SMB fingerprint missing from node: %+v", node.Properties)
+			}
+		case "RDP:3389":
+			found++
+			if node.Properties["rdp_nla_required"] != true {
+				t.Fatalf("RDP fingerprint missing from node: %+v", node.Properties)
+			}
+		case "LDAP:389":
+			found++
+			if node.Properties["raw"] != "LDAP:389" {
+				t.Fatalf("unfingerprinted service should keep raw: %+v", node.Properties)
+			}
+		}
+	}
+	if found != 3 {
+		t.Fatalf("expected 3 service nodes, found %d", found)
+	}
+}
@@ -23,6 +23,8 @@ type Weights struct {
 	KerberoastBase       int `yaml:"kerberoast_base"`
 	KerberoastSPN        int `yaml:"kerberoast_spn"`
 	KerberoastPwdOld     int `yaml:"kerberoast_pwd_old"`
+	KerberoastRC4Only    int `yaml:"kerberoast_rc4_only"`
+	KerberoastAESOnly    int `yaml:"kerberoast_aes_only"`
 }
 
 type Thresholds struct {
@@ -45,6 +47,8 @@ func DefaultConfig() *Config {
 			KerberoastBase:       40,
 			KerberoastSPN:        20,
 			KerberoastPwdOld:     15,
+			KerberoastRC4Only:    15,
+			KerberoastAESOnly:    -15,
 		},
 		Thresholds: Thresholds{
 			High:   80,
@@ -106,6 +110,14 @@ func ScoreCandidates(asreps, kerb []krb.Candidate, cfg *Config) []krb.Candidate
 			candidate.Score += cfg.Weights.KerberoastPwdOld
 		}
 
+		// RC4 tickets crack at NT-hash speed; AES-only ones rarely crack
+		switch candidate.EtypePosture {
+		case krb.EtypeRC4Only:
+			candidate.Score += cfg.Weights.KerberoastRC4Only
+		case krb.EtypeAESEnforced:
+			candidate.Score += cfg.Weights.KerberoastAESOnly
+		}
+
 		// Check admin group membership
 		if isInAdminGroup(candidate.MemberOf, cfg.AdminGroups) {
 			candidate.Score += cfg.Weights.ASREPAdminGroup
@@ -108,3 +108,27 @@ func TestGetSeverity(t *testing.T) {
 		}
 	}
 }
+
+func TestScoreKerberoastEtypePosture(t *testing.T) {
+	cfg := DefaultConfig()
+	base := krb.Candidate{Type: "KERBEROAST", SPNs: []string{"HTTP/web.corp.local"}, PwdLastSet: time.Now()}
+	rc4, aes, unknown := base, base, base
+	rc4.SamAccountName, rc4.EtypePosture = "rc4svc", krb.EtypeRC4Only
+	aes.SamAccountName, aes.EtypePosture = "aessvc", krb.EtypeAESEnforced
+	unknown.SamAccountName = "svc"
+
+	scores := make(map[string]int)
+	for _, c := range ScoreCandidates(nil, []krb.Candidate{rc4, aes, unknown}, cfg) {
+		scores[c.SamAccountName] = c.Score
+	}
+	plain := cfg.Weights.KerberoastBase + cfg.Weights.KerberoastSPN
+	if scores["svc"] != plain {
+		t.Errorf("unclassified candidate scored %d, want %d", scores["svc"], plain)
+	}
+	if scores["rc4svc"] != plain+cfg.Weights.KerberoastRC4Only {
+		t.Errorf("RC4-only candidate scored %d", scores["rc4svc"])
+	}
+	if scores["aessvc"] != plain+cfg.Weights.KerberoastAESOnly {
+		t.Errorf("AES-enforced candidate scored %d", scores["aessvc"])
+	}
+}